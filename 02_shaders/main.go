@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"github.com/ginuerzh/anton-gocode/common"
+	"github.com/ginuerzh/anton-gocode/gfx"
 	"github.com/go-gl/gl/v3.3-core/gl"
 	"github.com/go-gl/glfw/v3.1/glfw"
 	"os"
@@ -13,27 +14,18 @@ func init() {
 	runtime.LockOSThread()
 }
 
-func createVbo() (buffer uint32) {
+var layout = gfx.Planar(
+	gfx.Attrib{Name: "vertex_position", Location: 0, Size: 3, Type: gl.FLOAT},
+)
+
+func createMesh() (*gfx.Mesh, error) {
 	points := []float32{
 		0.0, 0.5, 0.0,
 		0.5, -0.5, 0.0,
 		-0.5, -0.5, 0.0,
 	}
-	gl.GenBuffers(1, &buffer)
-	gl.BindBuffer(gl.ARRAY_BUFFER, buffer)
-	gl.BufferData(gl.ARRAY_BUFFER, len(points)*4, gl.Ptr(points), gl.STATIC_DRAW)
 
-	return
-}
-
-func createVao() (vao uint32) {
-	gl.GenVertexArrays(1, &vao)
-	gl.BindVertexArray(vao)
-	var index uint32 = 0
-	gl.EnableVertexAttribArray(index)
-	gl.VertexAttribPointer(index, 3, gl.FLOAT, false, 0, gl.PtrOffset(0))
-
-	return
+	return gfx.NewMesh(layout, nil, points)
 }
 
 func main() {
@@ -48,11 +40,12 @@ func main() {
 	gl.Enable(gl.DEPTH_TEST)
 	gl.DepthFunc(gl.LESS)
 
-	buffer := createVbo()
-	defer gl.DeleteBuffers(1, &buffer)
-
-	vao := createVao()
-	defer gl.DeleteVertexArrays(1, &vao)
+	triangle, err := createMesh()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer triangle.Delete()
 
 	vs, err := common.CreateShaderFile(gl.VERTEX_SHADER, "vs.glsl")
 	if err != nil {
@@ -75,6 +68,11 @@ func main() {
 	}
 	defer gl.DeleteProgram(program)
 
+	if err := layout.Validate(program); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+
 	common.PrintAll(program)
 
 	name := []byte("inputColour")
@@ -93,7 +91,7 @@ func main() {
 		gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
 		gl.ClearColor(0.6, 0.6, 0.8, 1.0)
 
-		gl.DrawArrays(gl.TRIANGLES, 0, 3)
+		triangle.Draw()
 
 		glfw.PollEvents()
 		window.SwapBuffers()
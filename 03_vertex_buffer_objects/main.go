@@ -3,12 +3,18 @@ package main
 import (
 	"fmt"
 	"github.com/ginuerzh/anton-gocode/common"
+	"github.com/ginuerzh/anton-gocode/gfx"
 	"github.com/go-gl/gl/v3.3-core/gl"
 	"github.com/go-gl/glfw/v3.1/glfw"
 	"os"
 )
 
-func createVbo() (buffers []uint32) {
+var layout = gfx.Planar(
+	gfx.Attrib{Name: "vertex_position", Location: 0, Size: 3, Type: gl.FLOAT},
+	gfx.Attrib{Name: "vertex_colour", Location: 1, Size: 3, Type: gl.FLOAT},
+)
+
+func createMesh() (*gfx.Mesh, error) {
 	points := []float32{
 		0.0, 0.5, 0.0,
 		0.5, -0.5, 0.0,
@@ -20,32 +26,7 @@ func createVbo() (buffers []uint32) {
 		0.0, 0.0, 1.0,
 	}
 
-	buffers = make([]uint32, 2)
-	gl.GenBuffers(2, &buffers[0])
-	gl.BindBuffer(gl.ARRAY_BUFFER, buffers[0])
-	gl.BufferData(gl.ARRAY_BUFFER, len(points)*4, gl.Ptr(points), gl.STATIC_DRAW)
-
-	gl.BindBuffer(gl.ARRAY_BUFFER, buffers[1])
-	gl.BufferData(gl.ARRAY_BUFFER, len(colours)*4, gl.Ptr(colours), gl.STATIC_DRAW)
-
-	return
-}
-
-func createVao(buffers []uint32) (vao uint32) {
-	gl.GenVertexArrays(1, &vao)
-	gl.BindVertexArray(vao)
-
-	gl.BindBuffer(gl.ARRAY_BUFFER, buffers[0])
-	var attrLoc1 uint32 = 0
-	gl.EnableVertexAttribArray(attrLoc1)
-	gl.VertexAttribPointer(attrLoc1, 3, gl.FLOAT, false, 0, gl.PtrOffset(0))
-
-	var attrLoc2 uint32 = 1
-	gl.BindBuffer(gl.ARRAY_BUFFER, buffers[1])
-	gl.EnableVertexAttribArray(attrLoc2)
-	gl.VertexAttribPointer(attrLoc2, 3, gl.FLOAT, false, 0, gl.PtrOffset(0))
-
-	return
+	return gfx.NewMesh(layout, nil, points, colours)
 }
 
 func main() {
@@ -60,11 +41,12 @@ func main() {
 	gl.Enable(gl.DEPTH_TEST)
 	gl.DepthFunc(gl.LESS)
 
-	buffers := createVbo()
-	defer gl.DeleteBuffers(2, &buffers[0])
-
-	vao := createVao(buffers)
-	defer gl.DeleteVertexArrays(1, &vao)
+	triangle, err := createMesh()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer triangle.Delete()
 
 	vs, err := common.CreateShaderFile(gl.VERTEX_SHADER, "vs.glsl")
 	if err != nil {
@@ -87,6 +69,11 @@ func main() {
 	}
 	defer gl.DeleteProgram(program)
 
+	if err := layout.Validate(program); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+
 	common.PrintAll(program)
 
 	gl.Enable(gl.CULL_FACE)
@@ -101,7 +88,7 @@ func main() {
 		gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
 		gl.ClearColor(0.6, 0.6, 0.8, 1.0)
 
-		gl.DrawArrays(gl.TRIANGLES, 0, 3)
+		triangle.Draw()
 
 		glfw.PollEvents()
 		window.SwapBuffers()
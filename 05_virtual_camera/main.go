@@ -1,8 +1,11 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"github.com/ginuerzh/anton-gocode/common"
+	"github.com/ginuerzh/anton-gocode/gfx"
+	"github.com/ginuerzh/anton-gocode/texture"
 	"github.com/ginuerzh/math3d/m32"
 	"github.com/go-gl/gl/v3.3-core/gl"
 	"github.com/go-gl/glfw/v3.1/glfw"
@@ -10,7 +13,14 @@ import (
 	"os"
 )
 
-func createVbo() (buffers []uint32) {
+var sky = flag.String("sky", "", "equirectangular panorama drawn as skybox")
+
+var layout = gfx.Planar(
+	gfx.Attrib{Name: "vertex_position", Location: 0, Size: 3, Type: gl.FLOAT},
+	gfx.Attrib{Name: "vertex_colour", Location: 1, Size: 3, Type: gl.FLOAT},
+)
+
+func createMesh() (*gfx.Mesh, error) {
 	points := []float32{
 		0.0, 0.5, 0.0,
 		0.5, -0.5, 0.0,
@@ -22,32 +32,7 @@ func createVbo() (buffers []uint32) {
 		0.0, 0.0, 1.0,
 	}
 
-	buffers = make([]uint32, 2)
-	gl.GenBuffers(2, &buffers[0])
-	gl.BindBuffer(gl.ARRAY_BUFFER, buffers[0])
-	gl.BufferData(gl.ARRAY_BUFFER, len(points)*4, gl.Ptr(points), gl.STATIC_DRAW)
-
-	gl.BindBuffer(gl.ARRAY_BUFFER, buffers[1])
-	gl.BufferData(gl.ARRAY_BUFFER, len(colours)*4, gl.Ptr(colours), gl.STATIC_DRAW)
-
-	return
-}
-
-func createVao(buffers []uint32) (vao uint32) {
-	gl.GenVertexArrays(1, &vao)
-	gl.BindVertexArray(vao)
-
-	gl.BindBuffer(gl.ARRAY_BUFFER, buffers[0])
-	var attrLoc1 uint32 = 0
-	gl.EnableVertexAttribArray(attrLoc1)
-	gl.VertexAttribPointer(attrLoc1, 3, gl.FLOAT, false, 0, gl.PtrOffset(0))
-
-	var attrLoc2 uint32 = 1
-	gl.BindBuffer(gl.ARRAY_BUFFER, buffers[1])
-	gl.EnableVertexAttribArray(attrLoc2)
-	gl.VertexAttribPointer(attrLoc2, 3, gl.FLOAT, false, 0, gl.PtrOffset(0))
-
-	return
+	return gfx.NewMesh(layout, nil, points, colours)
 }
 
 func main() {
@@ -62,11 +47,12 @@ func main() {
 	gl.Enable(gl.DEPTH_TEST)
 	gl.DepthFunc(gl.LESS)
 
-	buffers := createVbo()
-	defer gl.DeleteBuffers(2, &buffers[0])
-
-	vao := createVao(buffers)
-	defer gl.DeleteVertexArrays(1, &vao)
+	triangle, err := createMesh()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer triangle.Delete()
 
 	vs, err := common.CreateShaderFile(gl.VERTEX_SHADER, "vs.glsl")
 	if err != nil {
@@ -89,8 +75,37 @@ func main() {
 	}
 	defer gl.DeleteProgram(program)
 
+	if err := layout.Validate(program); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+
 	common.PrintAll(program)
 
+	var skybox *gfx.Skybox
+	if *sky != "" {
+		cube, err := texture.LoadEquirect(*sky, 512, texture.DefaultOptions())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+		defer cube.Delete()
+
+		if skybox, err = gfx.NewSkybox(cube); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+		defer skybox.Delete()
+	}
+
+	/* effects from the -post flag, e.g. -post bloom,aces,fxaa */
+	post, err := gfx.NewPostStackFromFlags(common.WindowSize())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer post.Delete()
+
 	/* create PROJECTION MATRIX */
 	w, h := common.WindowSize()
 	aspect := float32(w) / float32(h)
@@ -118,62 +133,82 @@ func main() {
 	gl.CullFace(gl.BACK)
 	gl.FrontFace(gl.CW)
 
-	prevSecs := glfw.GetTime()
+	input, err := common.NewInput(window)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer input.Close()
+
+	prevSecs := input.Time()
 
 	for !window.ShouldClose() {
-		curSecs := glfw.GetTime()
+		curSecs := input.Time()
 		elapsedSecs := curSecs - prevSecs
 		prevSecs = curSecs
 
-		common.ShowFPS(window)
+		common.ShowFPSAt(window, curSecs)
 
+		if err := post.Begin(common.WindowSize()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
 		gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
 		gl.ClearColor(0.6, 0.6, 0.8, 1.0)
 
-		gl.DrawArrays(gl.TRIANGLES, 0, 3)
-		glfw.PollEvents()
+		gl.UseProgram(program)
+		triangle.Draw()
+		if skybox != nil {
+			skybox.Draw(vm, pm)
+		}
+
+		if err := post.End(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+		input.Poll()
 
 		moved := false
 
-		if window.GetKey(glfw.KeyA) != glfw.Release {
+		if input.GetKey(glfw.KeyA) != glfw.Release {
 			pos[0] -= float32(speed * elapsedSecs)
 			moved = true
 		}
-		if window.GetKey(glfw.KeyD) != glfw.Release {
+		if input.GetKey(glfw.KeyD) != glfw.Release {
 			pos[0] += float32(speed * elapsedSecs)
 			moved = true
 		}
-		if window.GetKey(glfw.KeyLeftShift) == glfw.Release &&
-			window.GetKey(glfw.KeySpace) != glfw.Release {
+		if input.GetKey(glfw.KeyLeftShift) == glfw.Release &&
+			input.GetKey(glfw.KeySpace) != glfw.Release {
 			pos[1] += float32(speed * elapsedSecs)
 			moved = true
 		}
-		if window.GetKey(glfw.KeyLeftShift) != glfw.Release &&
-			window.GetKey(glfw.KeySpace) != glfw.Release {
+		if input.GetKey(glfw.KeyLeftShift) != glfw.Release &&
+			input.GetKey(glfw.KeySpace) != glfw.Release {
 			pos[1] -= float32(speed * elapsedSecs)
 			moved = true
 		}
-		if window.GetKey(glfw.KeyW) != glfw.Release {
+		if input.GetKey(glfw.KeyW) != glfw.Release {
 			pos[2] -= float32(speed * elapsedSecs)
 			moved = true
 		}
-		if window.GetKey(glfw.KeyS) != glfw.Release {
+		if input.GetKey(glfw.KeyS) != glfw.Release {
 			pos[2] += float32(speed * elapsedSecs)
 			moved = true
 		}
-		if window.GetKey(glfw.KeyLeft) != glfw.Release {
+		if input.GetKey(glfw.KeyLeft) != glfw.Release {
 			yawYDeg += float32(yawSpeed * elapsedSecs)
 			moved = true
 		}
-		if window.GetKey(glfw.KeyRight) != glfw.Release {
+		if input.GetKey(glfw.KeyRight) != glfw.Release {
 			yawYDeg -= float32(yawSpeed * elapsedSecs)
 			moved = true
 		}
-		if window.GetKey(glfw.KeyUp) != glfw.Release {
+		if input.GetKey(glfw.KeyUp) != glfw.Release {
 			yawXDeg += float32(yawSpeed * elapsedSecs)
 			moved = true
 		}
-		if window.GetKey(glfw.KeyDown) != glfw.Release {
+		if input.GetKey(glfw.KeyDown) != glfw.Release {
 			yawXDeg -= float32(yawSpeed * elapsedSecs)
 			moved = true
 		}
@@ -183,10 +218,11 @@ func main() {
 			r = m32.Ident4().RotateY(-yawYDeg)
 			r = r.RotateX(-yawXDeg)
 			vm = r.Mul4(t)
+			gl.UseProgram(program)
 			gl.UniformMatrix4fv(viewMatLoc, 1, false, &vm[0])
 		}
 
-		if window.GetKey(glfw.KeyEscape) == glfw.Press {
+		if input.GetKey(glfw.KeyEscape) == glfw.Press {
 			window.SetShouldClose(true)
 		}
 
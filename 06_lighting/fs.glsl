@@ -0,0 +1,13 @@
+#version 330
+
+#include "shadows.glsl"
+#include "lighting.glsl"
+
+in vec3 position_eye;
+in vec3 normal_eye;
+
+out vec4 frag_colour;
+
+void main() {
+	frag_colour = vec4(blinn_phong(position_eye, normal_eye, vec3(1.0)), 1.0);
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/ginuerzh/anton-gocode/common"
+	"github.com/ginuerzh/anton-gocode/gfx"
+	"github.com/ginuerzh/anton-gocode/mesh"
+	"github.com/ginuerzh/math3d/m32"
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"github.com/go-gl/glfw/v3.1/glfw"
+	"math"
+	"os"
+)
+
+var (
+	shadows     = flag.Bool("shadows", true, "shadows of the sun and the spot light")
+	shadowDebug = flag.Bool("shadowdebug", false, "show the depth of the shadow cascades")
+)
+
+type object struct {
+	mesh     *gfx.Mesh
+	model    [16]float32
+	material gfx.Material
+}
+
+func translate(x, y, z float32) [16]float32 {
+	return [16]float32{1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, x, y, z, 1}
+}
+
+func main() {
+	window, err := common.StartGL("06 - Lighting")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer glfw.Terminate()
+	defer window.Destroy()
+
+	gl.Enable(gl.DEPTH_TEST)
+	gl.DepthFunc(gl.LESS)
+	/* the mesh generators wind counter-clockwise */
+	gl.Enable(gl.CULL_FACE)
+	gl.CullFace(gl.BACK)
+	gl.FrontFace(gl.CCW)
+
+	shapes := []struct {
+		m        *mesh.Mesh
+		x, y, z  float32
+		material gfx.Material
+	}{
+		{mesh.Plane(10, 10, 1, 1), 0, -1, 0, gfx.DefaultMaterial()},
+		{mesh.Torus(0.6, 0.25, 48, 24), -1.5, 0, 0, gfx.Material{
+			Ambient: [3]float32{1, 0.3, 0.3}, Diffuse: [3]float32{0.8, 0.2, 0.2},
+			Specular: [3]float32{1, 1, 1}, Shininess: 64,
+		}},
+		{mesh.UVSphere(0.7, 48, 24), 0, 0, 0, gfx.Material{
+			Ambient: [3]float32{0.3, 1, 0.3}, Diffuse: [3]float32{0.2, 0.8, 0.2},
+			Specular: [3]float32{0.5, 0.5, 0.5}, Shininess: 32,
+		}},
+		{mesh.Cube(1, 1), 1.5, -0.5, 0, gfx.Material{
+			Ambient: [3]float32{0.3, 0.3, 1}, Diffuse: [3]float32{0.2, 0.2, 0.8},
+			Specular: [3]float32{0.2, 0.2, 0.2}, Shininess: 8,
+		}},
+	}
+	var objects []object
+	for _, s := range shapes {
+		g, err := gfx.UploadMesh(s.m)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+		defer g.Delete()
+		objects = append(objects, object{g, translate(s.x, s.y, s.z), s.material})
+	}
+
+	program, err := gfx.LoadProgram("vs.glsl", "fs.glsl")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer gl.DeleteProgram(program)
+	if err := gfx.BindLighting(program); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	modelViewLoc := gl.GetUniformLocation(program, gl.Str("model_view\x00"))
+	normalMatLoc := gl.GetUniformLocation(program, gl.Str("normal_matrix\x00"))
+	projMatLoc := gl.GetUniformLocation(program, gl.Str("proj\x00"))
+	material := gfx.NewMaterialUniforms(program)
+	shadowUniforms := gfx.NewShadowUniforms(program)
+
+	lighting := gfx.NewLighting()
+	defer lighting.Delete()
+	lighting.Lights = []gfx.Light{
+		gfx.NewDirectionalLight([3]float32{-0.3, -1, -0.5}, [3]float32{0.4, 0.4, 0.35}),
+		gfx.NewPointLight([3]float32{0, 1, 1}, [3]float32{1, 0.8, 0.5}, 6),
+		gfx.NewSpotLight([3]float32{0, 3, 0}, [3]float32{0, -1, 0}, [3]float32{0.6, 0.6, 1}, 10,
+			float32(25*math.Pi/180)),
+	}
+
+	/* the sun gets cascades over the view, the spot light one map */
+	var sun *gfx.CascadedShadowMap
+	var spot *gfx.SpotShadowMap
+	if *shadows {
+		if sun, err = gfx.NewCascadedShadowMap(0, 3, 1024); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+		defer sun.Delete()
+		sun.Distance = 20
+		if spot, err = gfx.NewSpotShadowMap(2, 512); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+		defer spot.Delete()
+	}
+	drawCasters := func(p *gfx.ShadowPass) {
+		for _, o := range objects {
+			p.Draw(o.mesh, o.model)
+		}
+	}
+
+	post, err := gfx.NewPostStackFromFlags(common.WindowSize())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer post.Delete()
+
+	input, err := common.NewInput(window)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer input.Close()
+
+	yawDeg := float32(0.0)
+	for !window.ShouldClose() {
+		now := input.Time()
+		common.ShowFPS(window)
+
+		/* the point light circles the shapes, the camera orbits with
+		the arrow keys */
+		lighting.Lights[1].Position = [3]float32{
+			float32(2 * math.Cos(now)), 0.5, float32(2 * math.Sin(now)),
+		}
+		w, h := common.WindowSize()
+		pm := m32.Perspective(67.0, float32(w)/float32(h), 0.1, 100.0)
+		vm := m32.Ident4().Translate(m32.Vec3{0, -0.5, -5}).Mul4(m32.Ident4().RotateY(yawDeg))
+		lighting.Upload(vm)
+
+		if sun != nil {
+			sun.Update(vm, 67.0, float32(w)/float32(h), 0.1, lighting.Lights[0].Direction)
+			sun.Render(drawCasters)
+			spot.Update(lighting.Lights[2], vm)
+			spot.Render(drawCasters)
+		}
+
+		if err := post.Begin(w, h); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+		gl.ClearColor(0.05, 0.05, 0.08, 1.0)
+		gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+
+		gl.UseProgram(program)
+		gl.UniformMatrix4fv(projMatLoc, 1, false, &pm[0])
+		shadowUniforms.Set(sun, spot, 0)
+		for _, o := range objects {
+			mv := gfx.MulMat4(vm, o.model)
+			nm := gfx.NormalMatrix(mv)
+			gl.UniformMatrix4fv(modelViewLoc, 1, false, &mv[0])
+			gl.UniformMatrix3fv(normalMatLoc, 1, false, &nm[0])
+			material.Set(o.material)
+			o.mesh.DrawAll()
+		}
+
+		if err := post.End(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+		if sun != nil && *shadowDebug {
+			if err := sun.DrawDebug(8, 8, int32(h/4)); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return
+			}
+		}
+
+		input.Poll()
+		if input.GetKey(glfw.KeyLeft) != glfw.Release {
+			yawDeg += 1
+		}
+		if input.GetKey(glfw.KeyRight) != glfw.Release {
+			yawDeg -= 1
+		}
+		if window.GetKey(glfw.KeyEscape) == glfw.Press {
+			window.SetShouldClose(true)
+		}
+		window.SwapBuffers()
+	}
+}
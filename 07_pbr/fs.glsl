@@ -0,0 +1,14 @@
+#version 330
+
+#include "pbr.glsl"
+
+in vec3 position_eye;
+in vec3 normal_eye;
+in vec4 tangent_eye;
+in vec2 texcoord;
+
+out vec4 frag_colour;
+
+void main() {
+	frag_colour = pbr_material_shade(position_eye, normal_eye, tangent_eye, texcoord);
+}
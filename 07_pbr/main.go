@@ -0,0 +1,232 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/ginuerzh/anton-gocode/common"
+	"github.com/ginuerzh/anton-gocode/gfx"
+	"github.com/ginuerzh/anton-gocode/gltf"
+	"github.com/ginuerzh/anton-gocode/mesh"
+	"github.com/ginuerzh/math3d/m32"
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"github.com/go-gl/glfw/v3.1/glfw"
+	"os"
+)
+
+var (
+	env   = flag.String("env", "", "equirectangular panorama, preferably .hdr, lighting the scene")
+	cache = flag.String("cache", "ibl_cache", "directory keeping the precomputed IBL maps, empty for none")
+	model = flag.String("model", "", "glTF model shown instead of the spheres")
+)
+
+type drawable struct {
+	mesh        *gfx.Mesh
+	model       [16]float32
+	material    gfx.PBRMaterial
+	doubleSided bool
+}
+
+func translate(x, y, z float32) [16]float32 {
+	return [16]float32{1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, x, y, z, 1}
+}
+
+/* spheres getting rougher to the right and more metallic to the top */
+func sphereGrid() ([]drawable, func(), error) {
+	sphere, err := gfx.UploadMesh(mesh.UVSphere(0.4, 48, 24))
+	if err != nil {
+		return nil, nil, err
+	}
+	var grid []drawable
+	for row := 0; row < 5; row++ {
+		for col := 0; col < 5; col++ {
+			m := gfx.DefaultPBRMaterial()
+			m.BaseColour = [4]float32{0.9, 0.2, 0.1, 1}
+			m.Metallic = float32(row) / 4
+			m.Roughness = float32(col) / 4
+			grid = append(grid, drawable{
+				mesh:     sphere,
+				model:    translate(float32(col-2), float32(row-2), 0),
+				material: m,
+			})
+		}
+	}
+	return grid, sphere.Delete, nil
+}
+
+func loadModel(filename string) ([]drawable, func(), error) {
+	m, err := gltf.Load(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := m.Upload(); err != nil {
+		return nil, nil, err
+	}
+	var list []drawable
+	m.Walk(func(n *gltf.Node, world [16]float32) {
+		if n.Mesh == nil {
+			return
+		}
+		for _, p := range n.Mesh.Primitives {
+			d := drawable{mesh: p.GL, model: world, material: m.PBRMaterial(p.Material)}
+			if p.Material >= 0 && p.Material < len(m.Materials) {
+				d.doubleSided = m.Materials[p.Material].DoubleSided
+			}
+			list = append(list, d)
+		}
+	})
+	return list, m.Delete, nil
+}
+
+func main() {
+	window, err := common.StartGL("07 - Physically Based Rendering")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer glfw.Terminate()
+	defer window.Destroy()
+
+	gl.Enable(gl.DEPTH_TEST)
+	gl.DepthFunc(gl.LESS)
+	gl.Enable(gl.CULL_FACE)
+	gl.CullFace(gl.BACK)
+	gl.FrontFace(gl.CCW)
+
+	var scene []drawable
+	var free func()
+	if *model != "" {
+		scene, free, err = loadModel(*model)
+	} else {
+		scene, free, err = sphereGrid()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer free()
+
+	/* the first run renders the maps and writes them to the cache, later
+	runs load them from there */
+	var ibl *gfx.IBL
+	var sky *gfx.Skybox
+	if *env != "" {
+		ibl, err = gfx.LoadIBL(*env, 512, *cache, gfx.DefaultIBLOptions())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+		defer ibl.Delete()
+		if sky, err = gfx.NewSkybox(ibl.Environment); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+		defer sky.Delete()
+	}
+
+	program, err := gfx.LoadProgram("vs.glsl", "fs.glsl")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer gl.DeleteProgram(program)
+	if err := gfx.BindLighting(program); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	modelViewLoc := gl.GetUniformLocation(program, gl.Str("model_view\x00"))
+	normalMatLoc := gl.GetUniformLocation(program, gl.Str("normal_matrix\x00"))
+	projMatLoc := gl.GetUniformLocation(program, gl.Str("proj\x00"))
+	material := gfx.NewPBRUniforms(program)
+	iblUniforms := gfx.NewIBLUniforms(program)
+
+	lighting := gfx.NewLighting()
+	defer lighting.Delete()
+	lighting.Ambient = [3]float32{0.03, 0.03, 0.03}
+	sun := gfx.NewDirectionalLight([3]float32{-0.5, -0.7, -1}, [3]float32{1, 0.95, 0.9})
+	sun.Intensity = 3
+	lighting.Lights = []gfx.Light{sun}
+
+	/* the shading is HDR, without -post it is tone mapped with ACES */
+	w, h := common.WindowSize()
+	post, err := gfx.NewPostStackFromFlags(w, h)
+	if err == nil && post == nil {
+		var tone *gfx.ToneMap
+		if tone, err = gfx.NewToneMap(); err == nil {
+			post, err = gfx.NewPostStack(w, h, 0, tone)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer post.Delete()
+
+	input, err := common.NewInput(window)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer input.Close()
+
+	yawDeg, pitchDeg := float32(0.0), float32(0.0)
+	for !window.ShouldClose() {
+		common.ShowFPS(window)
+
+		w, h = common.WindowSize()
+		pm := m32.Perspective(60.0, float32(w)/float32(h), 0.1, 100.0)
+		vm := m32.Ident4().Translate(m32.Vec3{0, 0, -7}).
+			Mul4(m32.Ident4().RotateX(pitchDeg)).Mul4(m32.Ident4().RotateY(yawDeg))
+		lighting.Upload(vm)
+
+		if err := post.Begin(w, h); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+		gl.ClearColor(0.02, 0.02, 0.02, 1.0)
+		gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+
+		gl.UseProgram(program)
+		gl.UniformMatrix4fv(projMatLoc, 1, false, &pm[0])
+		iblUniforms.Set(ibl, vm, gfx.PBRIBLUnit)
+		for _, d := range scene {
+			mv := gfx.MulMat4(vm, d.model)
+			nm := gfx.NormalMatrix(mv)
+			gl.UniformMatrix4fv(modelViewLoc, 1, false, &mv[0])
+			gl.UniformMatrix3fv(normalMatLoc, 1, false, &nm[0])
+			material.Set(d.material, gfx.PBRMaterialUnit)
+			if d.doubleSided {
+				gl.Disable(gl.CULL_FACE)
+			}
+			d.mesh.DrawAll()
+			if d.doubleSided {
+				gl.Enable(gl.CULL_FACE)
+			}
+		}
+		if sky != nil {
+			sky.Draw(vm, pm)
+		}
+
+		if err := post.End(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+
+		input.Poll()
+		if input.GetKey(glfw.KeyLeft) != glfw.Release {
+			yawDeg += 1
+		}
+		if input.GetKey(glfw.KeyRight) != glfw.Release {
+			yawDeg -= 1
+		}
+		if input.GetKey(glfw.KeyUp) != glfw.Release {
+			pitchDeg += 1
+		}
+		if input.GetKey(glfw.KeyDown) != glfw.Release {
+			pitchDeg -= 1
+		}
+		if window.GetKey(glfw.KeyEscape) == glfw.Press {
+			window.SetShouldClose(true)
+		}
+		window.SwapBuffers()
+	}
+}
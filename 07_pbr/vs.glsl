@@ -0,0 +1,22 @@
+#version 330
+
+layout(location = 0) in vec3 vertex_position;
+layout(location = 2) in vec3 vertex_normal;
+layout(location = 3) in vec2 vertex_texcoord;
+layout(location = 4) in vec4 vertex_tangent;
+
+uniform mat4 model_view, proj;
+uniform mat3 normal_matrix;
+
+out vec3 position_eye;
+out vec3 normal_eye;
+out vec4 tangent_eye;
+out vec2 texcoord;
+
+void main() {
+	position_eye = vec3(model_view * vec4(vertex_position, 1.0));
+	normal_eye = normal_matrix * vertex_normal;
+	tangent_eye = vec4(mat3(model_view) * vertex_tangent.xyz, vertex_tangent.w);
+	texcoord = vertex_texcoord;
+	gl_Position = proj * vec4(position_eye, 1.0);
+}
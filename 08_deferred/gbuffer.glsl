@@ -0,0 +1,12 @@
+#version 330
+
+#include "gbuffer.glsl"
+
+in vec3 position_eye;
+in vec3 normal_eye;
+in vec4 tangent_eye;
+in vec2 texcoord;
+
+void main() {
+	gbuffer_material_write(position_eye, normal_eye, tangent_eye, texcoord);
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/ginuerzh/anton-gocode/common"
+	"github.com/ginuerzh/anton-gocode/gfx"
+	"github.com/ginuerzh/anton-gocode/mesh"
+	"github.com/ginuerzh/math3d/m32"
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"github.com/go-gl/glfw/v3.1/glfw"
+	"math"
+	"os"
+)
+
+var (
+	deferred  = flag.Bool("deferred", true, "start with deferred shading, Tab switches")
+	numLights = flag.Int("lights", 48, "point lights circling the scene")
+)
+
+type object struct {
+	mesh     *gfx.Mesh
+	model    [16]float32
+	material gfx.PBRMaterial
+}
+
+func translate(x, y, z float32) [16]float32 {
+	return [16]float32{1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, x, y, z, 1}
+}
+
+/* uniform locations of the geometry and the forward program */
+type program struct {
+	id                            uint32
+	modelView, normalMatrix, proj int32
+	material                      gfx.PBRUniforms
+}
+
+func loadProgram(vs, fs string) (program, error) {
+	id, err := gfx.LoadProgram(vs, fs)
+	if err != nil {
+		return program{}, err
+	}
+	return program{
+		id:           id,
+		modelView:    gl.GetUniformLocation(id, gl.Str("model_view\x00")),
+		normalMatrix: gl.GetUniformLocation(id, gl.Str("normal_matrix\x00")),
+		proj:         gl.GetUniformLocation(id, gl.Str("proj\x00")),
+		material:     gfx.NewPBRUniforms(id),
+	}, nil
+}
+
+func (p program) draw(objects []object, view, proj [16]float32) {
+	gl.UseProgram(p.id)
+	gl.UniformMatrix4fv(p.proj, 1, false, &proj[0])
+	for _, o := range objects {
+		mv := gfx.MulMat4(view, o.model)
+		nm := gfx.NormalMatrix(mv)
+		gl.UniformMatrix4fv(p.modelView, 1, false, &mv[0])
+		gl.UniformMatrix3fv(p.normalMatrix, 1, false, &nm[0])
+		p.material.Set(o.material, gfx.PBRMaterialUnit)
+		o.mesh.DrawAll()
+	}
+}
+
+func main() {
+	window, err := common.StartGL("08 - Deferred Shading")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer glfw.Terminate()
+	defer window.Destroy()
+
+	gl.Enable(gl.DEPTH_TEST)
+	gl.DepthFunc(gl.LESS)
+	gl.Enable(gl.CULL_FACE)
+	gl.CullFace(gl.BACK)
+	gl.FrontFace(gl.CCW)
+
+	/* a floor with a grid of shapes, and glass spheres drawn forward */
+	floor, err := gfx.UploadMesh(mesh.Plane(20, 20, 1, 1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer floor.Delete()
+	cube, err := gfx.UploadMesh(mesh.Cube(0.8, 1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer cube.Delete()
+	sphere, err := gfx.UploadMesh(mesh.UVSphere(0.5, 32, 16))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer sphere.Delete()
+
+	opaque := []object{{floor, translate(0, -0.4, 0), gfx.DefaultPBRMaterial()}}
+	for x := -4; x <= 4; x += 2 {
+		for z := -4; z <= 4; z += 2 {
+			m := gfx.DefaultPBRMaterial()
+			m.Roughness = 0.3 + 0.1*float32((x+z+8)%5)
+			m.Metallic = float32((x + 4) / 2 % 2)
+			opaque = append(opaque, object{cube, translate(float32(x), 0, float32(z)), m})
+		}
+	}
+	var glass []object
+	for i := 0; i < 4; i++ {
+		m := gfx.DefaultPBRMaterial()
+		m.BaseColour = [4]float32{0.6, 0.8, 1, 0.35}
+		m.Roughness = 0.1
+		a := float64(i) * math.Pi / 2
+		glass = append(glass, object{sphere, translate(float32(3*math.Cos(a)), 1.2, float32(3*math.Sin(a))), m})
+	}
+
+	gbufferProgram, err := loadProgram("vs.glsl", "gbuffer.glsl")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer gl.DeleteProgram(gbufferProgram.id)
+	forwardProgram, err := loadProgram("vs.glsl", "forward.glsl")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer gl.DeleteProgram(forwardProgram.id)
+	if err := gfx.BindLighting(forwardProgram.id); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	/* the forward program has no IBL, it has to know */
+	gl.UseProgram(forwardProgram.id)
+	gfx.NewIBLUniforms(forwardProgram.id).Set(nil, gfx.Ident(), gfx.PBRIBLUnit)
+
+	lighting := gfx.NewLighting()
+	defer lighting.Delete()
+	lighting.Ambient = [3]float32{0.02, 0.02, 0.03}
+	n := *numLights
+	if n > gfx.MaxLights-1 {
+		n = gfx.MaxLights - 1
+	}
+	moon := gfx.NewDirectionalLight([3]float32{0.3, -1, 0.2}, [3]float32{0.2, 0.2, 0.3})
+	lighting.Lights = []gfx.Light{moon}
+	for i := 0; i < n; i++ {
+		h := float64(i) / float64(n)
+		colour := [3]float32{
+			float32(0.5 + 0.5*math.Cos(2*math.Pi*h)),
+			float32(0.5 + 0.5*math.Cos(2*math.Pi*(h-1.0/3))),
+			float32(0.5 + 0.5*math.Cos(2*math.Pi*(h-2.0/3))),
+		}
+		light := gfx.NewPointLight([3]float32{}, colour, 2.5)
+		light.Intensity = 2
+		lighting.Lights = append(lighting.Lights, light)
+	}
+
+	w, h := common.WindowSize()
+	renderer, err := gfx.NewDeferred(int32(w), int32(h))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer renderer.Delete()
+
+	/* both paths shade in HDR, the tone mapping comes last */
+	post, err := gfx.NewPostStackFromFlags(w, h)
+	if err == nil && post == nil {
+		var tone *gfx.ToneMap
+		if tone, err = gfx.NewToneMap(); err == nil {
+			post, err = gfx.NewPostStack(w, h, 0, tone)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer post.Delete()
+
+	input, err := common.NewInput(window)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer input.Close()
+
+	tabDown := false
+	yawDeg := float32(0.0)
+	for !window.ShouldClose() {
+		now := input.Time()
+		common.ShowFPS(window)
+		common.ShowStats(fmt.Sprintf("%d lights, deferred: %v", len(lighting.Lights), *deferred))
+
+		for i := range lighting.Lights[1:] {
+			a := now*0.3 + float64(i)*2*math.Pi/float64(n)
+			r := 2 + 3*math.Sin(float64(i)*1.7)*math.Sin(float64(i)*1.7)
+			lighting.Lights[i+1].Position = [3]float32{
+				float32(r * math.Cos(a)), 0.3 + 0.2*float32(math.Sin(now+float64(i))), float32(r * math.Sin(a)),
+			}
+		}
+
+		w, h = common.WindowSize()
+		pm := m32.Perspective(60.0, float32(w)/float32(h), 0.1, 100.0)
+		vm := m32.Ident4().Translate(m32.Vec3{0, -1, -10}).
+			Mul4(m32.Ident4().RotateX(30)).Mul4(m32.Ident4().RotateY(yawDeg))
+		lighting.Upload(vm)
+
+		if err := post.Begin(w, h); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+		gl.ClearColor(0, 0, 0, 1.0)
+		gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+
+		if *deferred {
+			if err := renderer.Resize(int32(w), int32(h)); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return
+			}
+			renderer.BeginGeometry()
+			gbufferProgram.draw(opaque, vm, pm)
+			renderer.Light(lighting, nil, vm, pm)
+			renderer.BeginForward()
+			forwardProgram.draw(glass, vm, pm)
+			renderer.End()
+		} else {
+			forwardProgram.draw(opaque, vm, pm)
+			gl.Enable(gl.BLEND)
+			gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+			gl.DepthMask(false)
+			forwardProgram.draw(glass, vm, pm)
+			gl.DepthMask(true)
+			gl.Disable(gl.BLEND)
+		}
+
+		if err := post.End(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+
+		input.Poll()
+		tab := input.GetKey(glfw.KeyTab) != glfw.Release
+		if tab && !tabDown {
+			*deferred = !*deferred
+		}
+		tabDown = tab
+		if input.GetKey(glfw.KeyLeft) != glfw.Release {
+			yawDeg += 1
+		}
+		if input.GetKey(glfw.KeyRight) != glfw.Release {
+			yawDeg -= 1
+		}
+		if window.GetKey(glfw.KeyEscape) == glfw.Press {
+			window.SetShouldClose(true)
+		}
+		window.SwapBuffers()
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/ginuerzh/anton-gocode/common"
+	"github.com/ginuerzh/anton-gocode/gfx"
+	"github.com/ginuerzh/anton-gocode/mesh"
+	"github.com/ginuerzh/math3d/m32"
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"github.com/go-gl/glfw/v3.1/glfw"
+	"math"
+	"os"
+)
+
+var (
+	count  = flag.Int("count", 10000, "cubes to start with, up and down change it")
+	update = flag.String("update", "orphan", "how the instances are streamed: orphan, map or ring")
+)
+
+var updates = map[string]gfx.InstanceUpdate{
+	"orphan": gfx.OrphanInstances,
+	"map":    gfx.MapInstances,
+	"ring":   gfx.RingInstances,
+}
+
+/* spinning cubes on a square grid, coloured around the hue circle */
+func animate(instances []gfx.Instance, now float64) {
+	side := int(math.Ceil(math.Sqrt(float64(len(instances)))))
+	for i := range instances {
+		x := 1.5 * float32(i%side-side/2)
+		z := 1.5 * float32(i/side-side/2)
+		a := now + float64(i)*0.1
+		s, c := float32(0.5*math.Sin(a)), float32(0.5*math.Cos(a))
+		y := 0.3 * float32(math.Sin(now*2+float64(x+z)*0.2))
+		instances[i].Model = [16]float32{c, 0, -s, 0, 0, 0.5, 0, 0, s, 0, c, 0, x, y, z, 1}
+
+		h := float64(i) / float64(len(instances))
+		instances[i].Colour = [4]float32{
+			float32(0.5 + 0.5*math.Cos(2*math.Pi*h)),
+			float32(0.5 + 0.5*math.Cos(2*math.Pi*(h-1.0/3))),
+			float32(0.5 + 0.5*math.Cos(2*math.Pi*(h-2.0/3))),
+			1,
+		}
+	}
+}
+
+func main() {
+	window, err := common.StartGL("09 - Instancing")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer glfw.Terminate()
+	defer window.Destroy()
+	mode, ok := updates[*update]
+	if !ok {
+		fmt.Fprintln(os.Stderr, "unknown -update", *update)
+		return
+	}
+
+	gl.Enable(gl.DEPTH_TEST)
+	gl.DepthFunc(gl.LESS)
+	gl.Enable(gl.CULL_FACE)
+	gl.CullFace(gl.BACK)
+	gl.FrontFace(gl.CCW)
+
+	cube, err := gfx.UploadMesh(mesh.Cube(1, 1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer cube.Delete()
+	/* starts small on purpose, the buffer grows as needed */
+	cubes := gfx.NewInstanced(cube, 1024, mode)
+	defer cubes.Delete()
+
+	program, err := gfx.LoadProgram("vs.glsl", "fs.glsl")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer gl.DeleteProgram(program)
+	if err := cubes.Layout.Validate(program); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	if err := gfx.BindLighting(program); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	viewLoc := gl.GetUniformLocation(program, gl.Str("view\x00"))
+	projLoc := gl.GetUniformLocation(program, gl.Str("proj\x00"))
+	gl.UseProgram(program)
+	gfx.NewMaterialUniforms(program).Set(gfx.DefaultMaterial())
+
+	lighting := gfx.NewLighting()
+	defer lighting.Delete()
+	lighting.Lights = []gfx.Light{
+		gfx.NewDirectionalLight([3]float32{-0.3, -1, -0.5}, [3]float32{0.9, 0.9, 0.8}),
+	}
+
+	post, err := gfx.NewPostStackFromFlags(common.WindowSize())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer post.Delete()
+
+	input, err := common.NewInput(window)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer input.Close()
+
+	n := *count
+	yawDeg := float32(0.0)
+	for !window.ShouldClose() {
+		now := input.Time()
+		common.ShowFPS(window)
+
+		if len(cubes.Instances) != n {
+			cubes.Instances = make([]gfx.Instance, n)
+			common.ShowStats(fmt.Sprintf("%d cubes", n))
+		}
+		animate(cubes.Instances, now)
+		cubes.Upload()
+
+		w, h := common.WindowSize()
+		side := float32(math.Sqrt(float64(n)))
+		pm := m32.Perspective(60.0, float32(w)/float32(h), 0.1, 10*side+100)
+		vm := m32.Ident4().Translate(m32.Vec3{0, 0, -1.2*side - 5}).
+			Mul4(m32.Ident4().RotateX(40)).Mul4(m32.Ident4().RotateY(yawDeg))
+		lighting.Upload(vm)
+
+		if err := post.Begin(w, h); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+		gl.ClearColor(0.1, 0.1, 0.12, 1.0)
+		gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+
+		gl.UseProgram(program)
+		gl.UniformMatrix4fv(viewLoc, 1, false, &vm[0])
+		gl.UniformMatrix4fv(projLoc, 1, false, &pm[0])
+		cubes.Draw()
+
+		if err := post.End(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+
+		input.Poll()
+		if input.GetKey(glfw.KeyUp) != glfw.Release {
+			n += n/50 + 1
+		}
+		if input.GetKey(glfw.KeyDown) != glfw.Release && n > 1 {
+			n -= n/50 + 1
+			if n < 1 {
+				n = 1
+			}
+		}
+		if input.GetKey(glfw.KeyLeft) != glfw.Release {
+			yawDeg += 1
+		}
+		if input.GetKey(glfw.KeyRight) != glfw.Release {
+			yawDeg -= 1
+		}
+		if window.GetKey(glfw.KeyEscape) == glfw.Press {
+			window.SetShouldClose(true)
+		}
+		window.SwapBuffers()
+	}
+}
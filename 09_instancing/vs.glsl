@@ -0,0 +1,22 @@
+#version 330
+
+layout(location = 0) in vec3 vertex_position;
+layout(location = 2) in vec3 vertex_normal;
+layout(location = 5) in mat4 instance_model; // locations 5 to 8
+layout(location = 9) in vec4 instance_colour;
+
+uniform mat4 view, proj;
+
+out vec3 position_eye;
+out vec3 normal_eye;
+out vec4 colour;
+
+void main() {
+	mat4 model_view = view * instance_model;
+	position_eye = vec3(model_view * vec4(vertex_position, 1.0));
+	/* the instances are only rotated and uniformly scaled, no need for the
+	inverse transpose */
+	normal_eye = mat3(model_view) * vertex_normal;
+	colour = instance_colour;
+	gl_Position = proj * vec4(position_eye, 1.0);
+}
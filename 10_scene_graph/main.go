@@ -0,0 +1,176 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/ginuerzh/anton-gocode/common"
+	"github.com/ginuerzh/anton-gocode/gfx"
+	"github.com/ginuerzh/anton-gocode/mesh"
+	"github.com/ginuerzh/anton-gocode/scene"
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"github.com/go-gl/glfw/v3.1/glfw"
+	"math"
+	"os"
+)
+
+var asteroids = flag.Int("asteroids", 2000, "rocks in the belt round the sun, most of them out of view")
+
+func material(name string, diffuse, emissive [3]float32) *scene.Material {
+	m := scene.NewMaterial(name)
+	m.Phong.Ambient = diffuse
+	m.Phong.Diffuse = diffuse
+	m.Phong.Emissive = emissive
+	return m
+}
+
+func main() {
+	window, err := common.StartGL("10 - Scene Graph")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer glfw.Terminate()
+	defer window.Destroy()
+
+	gl.Enable(gl.DEPTH_TEST)
+	gl.DepthFunc(gl.LESS)
+	gl.Enable(gl.CULL_FACE)
+	gl.CullFace(gl.BACK)
+	gl.FrontFace(gl.CCW)
+
+	sphere, err := gfx.UploadMesh(mesh.UVSphere(1, 48, 24))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer sphere.Delete()
+
+	program, err := scene.LoadProgram("phong", "vs.glsl", "fs.glsl")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer program.Delete()
+	renderer := scene.NewRenderer(program)
+	defer renderer.Delete()
+	renderer.Lighting.Ambient = [3]float32{0.05, 0.05, 0.05}
+
+	/* a sun with a planet going round it, and a moon going round the
+	planet: each orbit is a pivot node turning its children with it */
+	root := scene.NewNode("root")
+	sun := scene.NewNode("sun")
+	sun.Mesh = sphere
+	sun.Material = material("sun", [3]float32{0, 0, 0}, [3]float32{1, 0.8, 0.3})
+	light := gfx.NewPointLight([3]float32{}, [3]float32{1, 0.95, 0.8}, 40)
+	sun.Light = &light
+	root.Add(sun)
+
+	planetOrbit := scene.NewNode("planet orbit")
+	root.Add(planetOrbit)
+	planet := scene.NewNode("planet")
+	planet.Mesh = sphere
+	planet.Material = material("planet", [3]float32{0.2, 0.4, 0.9}, [3]float32{})
+	planet.SetTranslation([3]float32{6, 0, 0})
+	planet.SetScale([3]float32{0.5, 0.5, 0.5})
+	planetOrbit.Add(planet)
+
+	/* the moon hangs off the planet, and is scaled along with it */
+	moonOrbit := scene.NewNode("moon orbit")
+	planet.Add(moonOrbit)
+	moon := scene.NewNode("moon")
+	moon.Mesh = sphere
+	moon.Material = material("moon", [3]float32{0.6, 0.6, 0.6}, [3]float32{})
+	moon.SetTranslation([3]float32{2.5, 0, 0})
+	moon.SetScale([3]float32{0.3, 0.3, 0.3})
+	moonOrbit.Add(moon)
+
+	/* the belt turns as a whole, its rocks are culled one by one through
+	the world bounds the hierarchy gives them */
+	rock, err := gfx.UploadMesh(mesh.Icosphere(1, 1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer rock.Delete()
+	belt := scene.NewNode("belt")
+	root.Add(belt)
+	rockMaterial := material("rock", [3]float32{0.45, 0.4, 0.35}, [3]float32{})
+	for i := 0; i < *asteroids; i++ {
+		a := float64(i) * 2.39996 // the golden angle spreads them evenly
+		r := 10 + 3*math.Mod(float64(i)*0.618, 1)
+		n := scene.NewNode(fmt.Sprintf("rock %d", i))
+		n.Mesh = rock
+		n.Material = rockMaterial
+		n.SetTranslation([3]float32{float32(r * math.Cos(a)), float32(0.6 * math.Sin(a*7)), float32(r * math.Sin(a))})
+		n.SetEuler(float32(i*37%360), float32(i*53%360), 0)
+		size := 0.05 + 0.1*float32(math.Mod(float64(i)*0.377, 1))
+		n.SetScale([3]float32{size, size * 0.7, size})
+		belt.Add(n)
+	}
+
+	camera := scene.NewNode("camera")
+	camera.Camera = scene.NewCamera(60, 0.1, 100)
+	camera.SetTranslation([3]float32{0, 3, 16})
+	camera.LookAt([3]float32{}, [3]float32{0, 1, 0})
+	root.Add(camera)
+
+	post, err := gfx.NewPostStackFromFlags(common.WindowSize())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer post.Delete()
+
+	input, err := common.NewInput(window)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer input.Close()
+
+	prevSecs := input.Time()
+	cDown := false
+	for !window.ShouldClose() {
+		now := input.Time()
+		elapsed := float32(now - prevSecs)
+		prevSecs = now
+		common.ShowFPS(window)
+
+		planetOrbit.Rotate([3]float32{0, 1, 0}, 20*elapsed)
+		planet.Rotate([3]float32{0, 1, 0}, 90*elapsed)
+		moonOrbit.Rotate([3]float32{0, 1, 0}, 60*elapsed)
+		belt.Rotate([3]float32{0, 1, 0}, 5*elapsed)
+
+		w, h := common.WindowSize()
+		if err := post.Begin(w, h); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+		gl.ClearColor(0.01, 0.01, 0.02, 1.0)
+		gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+		renderer.Render(root, camera, float32(w)/float32(h))
+		common.ShowStats(renderer.Stats.String())
+		if err := post.End(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+
+		input.Poll()
+		c := input.GetKey(glfw.KeyC) != glfw.Release
+		if c && !cDown {
+			renderer.Cull = !renderer.Cull
+			fmt.Println("culling:", renderer.Cull)
+		}
+		cDown = c
+		if input.GetKey(glfw.KeyLeft) != glfw.Release {
+			camera.Rotate([3]float32{0, 1, 0}, 60*elapsed)
+		}
+		if input.GetKey(glfw.KeyRight) != glfw.Release {
+			camera.Rotate([3]float32{0, 1, 0}, -60*elapsed)
+		}
+		if window.GetKey(glfw.KeyEscape) == glfw.Press {
+			window.SetShouldClose(true)
+		}
+		window.SwapBuffers()
+	}
+}
@@ -0,0 +1,16 @@
+#version 330
+
+layout(location = 0) in vec3 vertex_position;
+layout(location = 2) in vec3 vertex_normal;
+
+uniform mat4 model_view, proj;
+uniform mat3 normal_matrix;
+
+out vec3 position_eye;
+out vec3 normal_eye;
+
+void main() {
+	position_eye = vec3(model_view * vec4(vertex_position, 1.0));
+	normal_eye = normal_matrix * vertex_normal;
+	gl_Position = proj * vec4(position_eye, 1.0);
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/ginuerzh/anton-gocode/common"
+	"github.com/ginuerzh/anton-gocode/gfx"
+	"github.com/ginuerzh/anton-gocode/scene"
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"github.com/go-gl/glfw/v3.1/glfw"
+	"os"
+)
+
+var (
+	sceneFile = flag.String("scene", "scene.json", "scene to show")
+	save      = flag.String("save", "", "write the scene back out to this file, S saves it again")
+)
+
+func main() {
+	window, err := common.StartGL("11 - Scene File")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer glfw.Terminate()
+	defer window.Destroy()
+
+	gl.Enable(gl.DEPTH_TEST)
+	gl.DepthFunc(gl.LESS)
+	gl.Enable(gl.CULL_FACE)
+	gl.CullFace(gl.BACK)
+	gl.FrontFace(gl.CCW)
+
+	/* everything, down to the shaders, comes from the file */
+	s, err := scene.Load(*sceneFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer s.Delete()
+	if s.Camera == nil || len(s.Programs) == 0 {
+		fmt.Fprintln(os.Stderr, *sceneFile, "needs a camera and a program")
+		return
+	}
+	if *save != "" {
+		if err := s.Save(*save); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+	}
+
+	/* materials without a program of their own use the first one */
+	renderer := scene.NewRenderer(s.Programs[0])
+	defer renderer.Delete()
+
+	post, err := gfx.NewPostStackFromFlags(common.WindowSize())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer post.Delete()
+
+	input, err := common.NewInput(window)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer input.Close()
+
+	prevSecs := input.Time()
+	sDown := false
+	for !window.ShouldClose() {
+		now := input.Time()
+		elapsed := float32(now - prevSecs)
+		prevSecs = now
+		common.ShowFPS(window)
+
+		if table := s.Root.Find("table"); table != nil {
+			table.Rotate([3]float32{0, 1, 0}, 30*elapsed)
+		}
+
+		w, h := common.WindowSize()
+		if err := post.Begin(w, h); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+		gl.ClearColor(0.02, 0.02, 0.03, 1.0)
+		gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+		s.Render(renderer, float32(w)/float32(h))
+		common.ShowStats(renderer.Stats.String())
+		if err := post.End(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+
+		input.Poll()
+		key := input.GetKey(glfw.KeyS) != glfw.Release
+		if key && !sDown && *save != "" {
+			if err := s.Save(*save); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			} else {
+				fmt.Println("saved", *save)
+			}
+		}
+		sDown = key
+		if window.GetKey(glfw.KeyEscape) == glfw.Press {
+			window.SetShouldClose(true)
+		}
+		window.SwapBuffers()
+	}
+}
@@ -0,0 +1,312 @@
+// Package atlas packs many small images into a few large textures and
+// keeps track of where each image ended up.
+package atlas
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ginuerzh/anton-gocode/texture"
+	"image"
+	"image/draw"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Options controls the packing.
+type Options struct {
+	MaxSize    int  // largest page width and height, 2048 if 0
+	Padding    int  // transparent pixels between images
+	Extrude    int  // pixels the image edges are repeated outwards
+	PowerOfTwo bool // round page sizes up to powers of two, at most MaxSize
+}
+
+// Region is where an image lives in the atlas. UV is u0, v0, u1, v1 for a
+// page uploaded with FlipY, so v0 is the bottom edge.
+type Region struct {
+	Name string
+	Page int
+	Rect image.Rectangle // in page pixels, without padding and extrusion
+	UV   [4]float32
+}
+
+// Atlas is a set of pages and the regions on them. Textures is filled by
+// Upload.
+type Atlas struct {
+	Pages    []*image.NRGBA
+	Regions  map[string]Region
+	Textures []*texture.Texture
+}
+
+// Pack packs images, keyed by name, into as few pages as it can. Larger
+// images are placed first.
+func Pack(images map[string]image.Image, opts Options) (*Atlas, error) {
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = 2048
+	}
+	// pack into the largest power of two that fits, so rounding the pages
+	// up never goes past MaxSize
+	if opts.PowerOfTwo {
+		opts.MaxSize = powerOfTwo(opts.MaxSize/2 + 1)
+	}
+	border := opts.Extrude*2 + opts.Padding
+
+	names := make([]string, 0, len(images))
+	for name := range images {
+		names = append(names, name)
+	}
+	size := func(name string) (int, int) {
+		b := images[name].Bounds()
+		return b.Dx(), b.Dy()
+	}
+	sort.Slice(names, func(i, j int) bool {
+		wi, hi := size(names[i])
+		wj, hj := size(names[j])
+		if mi, mj := max(wi, hi), max(wj, hj); mi != mj {
+			return mi > mj
+		}
+		if wi*hi != wj*hj {
+			return wi*hi > wj*hj
+		}
+		return names[i] < names[j]
+	})
+
+	// padding is left on the right and bottom of every slot, so the page
+	// gets an extra strip on the top and left to pad the first images too
+	var bins []*maxRects
+	slots := make(map[string]image.Rectangle, len(names))
+	pages := make(map[string]int, len(names))
+	for _, name := range names {
+		w, h := size(name)
+		w, h = w+border, h+border
+		if w > opts.MaxSize-opts.Padding || h > opts.MaxSize-opts.Padding {
+			return nil, fmt.Errorf("atlas: %s (%dx%d) does not fit a %d page",
+				name, w-border, h-border, opts.MaxSize)
+		}
+
+		placed := false
+		for i, b := range bins {
+			if r, ok := b.insert(w, h); ok {
+				slots[name], pages[name], placed = r, i, true
+				break
+			}
+		}
+		if !placed {
+			b := newMaxRects(opts.MaxSize-opts.Padding, opts.MaxSize-opts.Padding)
+			r, _ := b.insert(w, h)
+			bins = append(bins, b)
+			slots[name], pages[name] = r, len(bins)-1
+		}
+	}
+
+	a := &Atlas{Regions: make(map[string]Region, len(names))}
+	for _, b := range bins {
+		w, h := b.used.Max.X+opts.Padding, b.used.Max.Y+opts.Padding
+		if opts.PowerOfTwo {
+			w, h = powerOfTwo(w), powerOfTwo(h)
+		}
+		a.Pages = append(a.Pages, image.NewNRGBA(image.Rect(0, 0, w, h)))
+	}
+	for _, name := range names {
+		slot := slots[name].Add(image.Pt(opts.Padding, opts.Padding))
+		page := a.Pages[pages[name]]
+		img := images[name]
+		min := slot.Min.Add(image.Pt(opts.Extrude, opts.Extrude))
+		r := image.Rectangle{min, min.Add(img.Bounds().Size())}
+
+		draw.Draw(page, r, img, img.Bounds().Min, draw.Src)
+		extrude(page, r, opts.Extrude)
+		a.Regions[name] = a.region(name, pages[name], r)
+	}
+	return a, nil
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func powerOfTwo(n int) int {
+	p := 1
+	for p < n {
+		p *= 2
+	}
+	return p
+}
+
+// extrude repeats the outermost pixels of r n times outwards, so bilinear
+// filtering at the edges of a region never reads its neighbours.
+func extrude(page *image.NRGBA, r image.Rectangle, n int) {
+	for i := 1; i <= n; i++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			page.Set(x, r.Min.Y-i, page.At(x, r.Min.Y))
+			page.Set(x, r.Max.Y-1+i, page.At(x, r.Max.Y-1))
+		}
+	}
+	for i := 1; i <= n; i++ {
+		for y := r.Min.Y - n; y < r.Max.Y+n; y++ {
+			page.Set(r.Min.X-i, y, page.At(r.Min.X, y))
+			page.Set(r.Max.X-1+i, y, page.At(r.Max.X-1, y))
+		}
+	}
+}
+
+func (a *Atlas) region(name string, page int, r image.Rectangle) Region {
+	size := a.Pages[page].Bounds().Size()
+	w, h := float32(size.X), float32(size.Y)
+	return Region{
+		Name: name,
+		Page: page,
+		Rect: r,
+		UV: [4]float32{
+			float32(r.Min.X) / w, 1 - float32(r.Max.Y)/h,
+			float32(r.Max.X) / w, 1 - float32(r.Min.Y)/h,
+		},
+	}
+}
+
+// Region returns the region of the named image.
+func (a *Atlas) Region(name string) (Region, bool) {
+	r, ok := a.Regions[name]
+	return r, ok
+}
+
+// Upload creates one texture per page. Pages are always flipped, to match
+// the UVs of the regions.
+func (a *Atlas) Upload(opts texture.Options) {
+	opts.FlipY = true
+	for _, p := range a.Pages {
+		a.Textures = append(a.Textures, texture.FromImage(p, opts))
+	}
+}
+
+// Delete frees the page textures.
+func (a *Atlas) Delete() {
+	for _, t := range a.Textures {
+		t.Delete()
+	}
+	a.Textures = nil
+}
+
+// LoadImages decodes image files for Pack, naming each after its file name
+// without the extension.
+func LoadImages(files ...string) (map[string]image.Image, error) {
+	images := make(map[string]image.Image, len(files))
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		img, _, err := image.Decode(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+		name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		if _, ok := images[name]; ok {
+			return nil, fmt.Errorf("%s: name %q used twice", file, name)
+		}
+		images[name] = img
+	}
+	return images, nil
+}
+
+type manifest struct {
+	Pages   []string                  `json:"pages"`
+	Regions map[string]manifestRegion `json:"regions"`
+}
+
+type manifestRegion struct {
+	Page int `json:"page"`
+	X    int `json:"x"`
+	Y    int `json:"y"`
+	W    int `json:"w"`
+	H    int `json:"h"`
+}
+
+// Save writes the pages as PNG files next to the JSON manifest, named
+// after it: ui.json, ui_0.png, ui_1.png, ...
+func (a *Atlas) Save(filename string) error {
+	base := strings.TrimSuffix(filename, filepath.Ext(filename))
+	m := manifest{Regions: make(map[string]manifestRegion, len(a.Regions))}
+	for i, p := range a.Pages {
+		page := fmt.Sprintf("%s_%d.png", base, i)
+		if err := savePNG(page, p); err != nil {
+			return err
+		}
+		m.Pages = append(m.Pages, filepath.Base(page))
+	}
+	for name, r := range a.Regions {
+		m.Regions[name] = manifestRegion{r.Page, r.Rect.Min.X, r.Rect.Min.Y, r.Rect.Dx(), r.Rect.Dy()}
+	}
+
+	data, err := json.MarshalIndent(&m, "", "\t")
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func savePNG(filename string, img image.Image) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Load reads an atlas written by Save. Page paths are relative to the
+// manifest.
+func Load(filename string) (*Atlas, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	a := &Atlas{Regions: make(map[string]Region, len(m.Regions))}
+	dir := filepath.Dir(filename)
+	for _, page := range m.Pages {
+		f, err := os.Open(filepath.Join(dir, page))
+		if err != nil {
+			return nil, err
+		}
+		img, _, err := image.Decode(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", page, err)
+		}
+		a.Pages = append(a.Pages, texture.NRGBA(img, false))
+	}
+	for name, r := range m.Regions {
+		if r.Page < 0 || r.Page >= len(a.Pages) {
+			return nil, fmt.Errorf("%s: region %s on missing page %d", filename, name, r.Page)
+		}
+		rect := image.Rect(r.X, r.Y, r.X+r.W, r.Y+r.H)
+		if !rect.In(a.Pages[r.Page].Bounds()) {
+			return nil, fmt.Errorf("%s: region %s outside its page", filename, name)
+		}
+		a.Regions[name] = a.region(name, r.Page, rect)
+	}
+	return a, nil
+}
@@ -0,0 +1,141 @@
+package atlas
+
+import (
+	"image"
+	"image/color"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// images returns n solid images of random sizes between 4 and max pixels,
+// each filled with its own grey.
+func images(n, max int, seed int64) map[string]image.Image {
+	r := rand.New(rand.NewSource(seed))
+	imgs := make(map[string]image.Image, n)
+	for i := 0; i < n; i++ {
+		img := image.NewNRGBA(image.Rect(0, 0, 4+r.Intn(max-3), 4+r.Intn(max-3)))
+		for k := range img.Pix {
+			img.Pix[k] = byte(i + 1)
+		}
+		imgs[string(rune('a'+i%26))+string(rune('0'+i/26))] = img
+	}
+	return imgs
+}
+
+func isPowerOfTwo(n int) bool {
+	return n > 0 && n&(n-1) == 0
+}
+
+func TestPack(t *testing.T) {
+	tests := []struct {
+		name   string
+		images map[string]image.Image
+		opts   Options
+		pages  int // expected page count, 0 to skip the check
+		ok     bool
+	}{
+		{"one image", images(1, 20, 1), Options{}, 1, true},
+		{"default size", images(50, 60, 2), Options{}, 1, true},
+		{"padded and extruded", images(200, 60, 3), Options{MaxSize: 256, Padding: 2, Extrude: 1}, 0, true},
+		{"power of two", images(200, 60, 4), Options{MaxSize: 256, Padding: 2, Extrude: 1, PowerOfTwo: true}, 0, true},
+		{"power of two under an odd size", images(200, 60, 5), Options{MaxSize: 300, Padding: 1, PowerOfTwo: true}, 0, true},
+		{"exact fit", map[string]image.Image{"x": image.NewNRGBA(image.Rect(0, 0, 64, 64))}, Options{MaxSize: 64}, 1, true},
+		{"too wide", map[string]image.Image{"x": image.NewNRGBA(image.Rect(0, 0, 300, 10))}, Options{MaxSize: 256}, 0, false},
+		{"too wide with padding", map[string]image.Image{"x": image.NewNRGBA(image.Rect(0, 0, 64, 64))}, Options{MaxSize: 64, Padding: 1}, 0, false},
+		{"too wide for the power of two", map[string]image.Image{"x": image.NewNRGBA(image.Rect(0, 0, 280, 10))}, Options{MaxSize: 300, PowerOfTwo: true}, 0, false},
+	}
+	for _, tt := range tests {
+		a, err := Pack(tt.images, tt.opts)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: error %v", tt.name, err)
+			continue
+		}
+		if !tt.ok {
+			continue
+		}
+		if tt.pages > 0 && len(a.Pages) != tt.pages {
+			t.Errorf("%s: %d pages, want %d", tt.name, len(a.Pages), tt.pages)
+		}
+		maxSize := tt.opts.MaxSize
+		if maxSize == 0 {
+			maxSize = 2048
+		}
+		for i, p := range a.Pages {
+			size := p.Bounds().Size()
+			if size.X > maxSize || size.Y > maxSize {
+				t.Errorf("%s: page %d is %v, larger than %d", tt.name, i, size, maxSize)
+			}
+			if tt.opts.PowerOfTwo && (!isPowerOfTwo(size.X) || !isPowerOfTwo(size.Y)) {
+				t.Errorf("%s: page %d is %v", tt.name, i, size)
+			}
+		}
+
+		// regions keep their padding and extrusion clear of each other and
+		// of the page edges, and hold the image with its edges repeated
+		border := tt.opts.Padding + tt.opts.Extrude
+		for n1, r1 := range a.Regions {
+			if r1.Rect.Size() != tt.images[n1].Bounds().Size() {
+				t.Errorf("%s: %s is %v, the image %v", tt.name, n1, r1.Rect, tt.images[n1].Bounds())
+			}
+			if !r1.Rect.Inset(-border).In(a.Pages[r1.Page].Bounds()) {
+				t.Errorf("%s: %s at %v is outside its page %v", tt.name, n1, r1.Rect, a.Pages[r1.Page].Bounds())
+			}
+			for n2, r2 := range a.Regions {
+				if n1 != n2 && r1.Page == r2.Page && r1.Rect.Inset(-tt.opts.Extrude).Overlaps(r2.Rect.Inset(-tt.opts.Extrude)) {
+					t.Errorf("%s: %s and %s overlap", tt.name, n1, n2)
+				}
+			}
+			p, want := a.Pages[r1.Page], tt.images[n1].At(0, 0)
+			e := tt.opts.Extrude
+			if p.At(r1.Rect.Min.X, r1.Rect.Min.Y) != want || p.At(r1.Rect.Min.X-e, r1.Rect.Min.Y-e) != want ||
+				p.At(r1.Rect.Max.X-1+e, r1.Rect.Max.Y-1+e) != want {
+				t.Errorf("%s: %s is not drawn and extruded", tt.name, n1)
+			}
+			if tt.opts.Padding > 0 && p.At(r1.Rect.Min.X-e-1, r1.Rect.Min.Y) != (color.NRGBA{}) {
+				t.Errorf("%s: %s is not padded", tt.name, n1)
+			}
+			uv := r1.UV
+			if uv[0] >= uv[2] || uv[1] >= uv[3] || uv[0] < 0 || uv[1] < 0 || uv[2] > 1 || uv[3] > 1 {
+				t.Errorf("%s: %s has UVs %v", tt.name, n1, uv)
+			}
+		}
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "atlas")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a, err := Pack(images(100, 40, 6), Options{MaxSize: 128, Padding: 1, PowerOfTwo: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a.Pages) < 2 {
+		t.Fatalf("%d pages, want several", len(a.Pages))
+	}
+	file := filepath.Join(dir, "ui.json")
+	if err := a.Save(file); err != nil {
+		t.Fatal(err)
+	}
+	b, err := Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Pages) != len(a.Pages) || len(b.Regions) != len(a.Regions) {
+		t.Fatalf("loaded %d pages and %d regions, want %d and %d", len(b.Pages), len(b.Regions), len(a.Pages), len(a.Regions))
+	}
+	for name, r := range a.Regions {
+		if b.Regions[name] != r {
+			t.Errorf("%s: loaded %+v, want %+v", name, b.Regions[name], r)
+		}
+		if b.Pages[r.Page].At(r.Rect.Min.X, r.Rect.Min.Y) != a.Pages[r.Page].At(r.Rect.Min.X, r.Rect.Min.Y) {
+			t.Errorf("%s: pixels differ", name)
+		}
+	}
+}
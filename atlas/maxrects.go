@@ -0,0 +1,87 @@
+package atlas
+
+import (
+	"image"
+)
+
+// maxRects packs rectangles into a bin with the MaxRects algorithm (Jukka
+// Jylänki), placing each one by the best short side fit.
+type maxRects struct {
+	width, height int
+	free          []image.Rectangle
+	used          image.Rectangle // union of placed rectangles
+}
+
+func newMaxRects(width, height int) *maxRects {
+	return &maxRects{
+		width:  width,
+		height: height,
+		free:   []image.Rectangle{image.Rect(0, 0, width, height)},
+	}
+}
+
+// insert finds a place for a w x h rectangle.
+func (p *maxRects) insert(w, h int) (image.Rectangle, bool) {
+	best := -1
+	bestShort, bestLong := 0, 0
+	for i, f := range p.free {
+		if f.Dx() < w || f.Dy() < h {
+			continue
+		}
+		dx, dy := f.Dx()-w, f.Dy()-h
+		short, long := dx, dy
+		if short > long {
+			short, long = long, short
+		}
+		if best < 0 || short < bestShort || short == bestShort && long < bestLong {
+			best, bestShort, bestLong = i, short, long
+		}
+	}
+	if best < 0 {
+		return image.Rectangle{}, false
+	}
+
+	r := image.Rect(p.free[best].Min.X, p.free[best].Min.Y,
+		p.free[best].Min.X+w, p.free[best].Min.Y+h)
+	p.place(r)
+	return r, true
+}
+
+// place splits every free rectangle overlapping r into the up to four
+// maximal rectangles around it, then drops those contained in others.
+func (p *maxRects) place(r image.Rectangle) {
+	var next []image.Rectangle
+	for _, f := range p.free {
+		if !f.Overlaps(r) {
+			next = append(next, f)
+			continue
+		}
+		if r.Min.X > f.Min.X {
+			next = append(next, image.Rect(f.Min.X, f.Min.Y, r.Min.X, f.Max.Y))
+		}
+		if r.Max.X < f.Max.X {
+			next = append(next, image.Rect(r.Max.X, f.Min.Y, f.Max.X, f.Max.Y))
+		}
+		if r.Min.Y > f.Min.Y {
+			next = append(next, image.Rect(f.Min.X, f.Min.Y, f.Max.X, r.Min.Y))
+		}
+		if r.Max.Y < f.Max.Y {
+			next = append(next, image.Rect(f.Min.X, r.Max.Y, f.Max.X, f.Max.Y))
+		}
+	}
+
+	p.free = p.free[:0]
+	for i, a := range next {
+		contained := false
+		for j, b := range next {
+			if i != j && a.In(b) && (a != b || j < i) {
+				contained = true
+				break
+			}
+		}
+		if !contained {
+			p.free = append(p.free, a)
+		}
+	}
+	p.used = p.used.Union(r)
+}
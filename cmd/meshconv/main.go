@@ -0,0 +1,120 @@
+// Command meshconv converts OBJ, PLY, STL and glTF/GLB files into the
+// binary mesh cache format read by gfx.LoadMeshCache.
+//
+//	meshconv [-o out.mesh] [-z] [-optimize] [-normals deg] [-tangents] in.obj
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/ginuerzh/anton-gocode/gltf"
+	"github.com/ginuerzh/anton-gocode/mesh"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	output   = flag.String("o", "", "output file, input with a .mesh extension by default")
+	compress = flag.Bool("z", false, "zlib compress the payload")
+	optimize = flag.Bool("optimize", false, "weld and reorder for the vertex cache, printing ACMR")
+	normals  = flag.Float64("normals", -1, "generate smooth normals with this crease angle in degrees if missing")
+	tangents = flag.Bool("tangents", false, "generate tangents if missing")
+)
+
+// loadGLTF flattens the default scene of a glTF file into a single mesh.
+func loadGLTF(filename string) (*mesh.Mesh, error) {
+	model, err := gltf.Load(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var parts []*mesh.Mesh
+	model.Walk(func(n *gltf.Node, world [16]float32) {
+		if n.Mesh == nil {
+			return
+		}
+		for _, p := range n.Mesh.Primitives {
+			part := mesh.Merge(p.Mesh)
+			part.Transform(world)
+			parts = append(parts, part)
+		}
+	})
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("%s: no meshes in the scene", filename)
+	}
+
+	m := mesh.Merge(parts...)
+	m.Name = model.Name
+	for i, mat := range model.Materials {
+		name := mat.Name
+		if name == "" {
+			name = fmt.Sprintf("material%d", i)
+		}
+		m.Materials = append(m.Materials, mesh.Material{Name: name})
+	}
+	return m, nil
+}
+
+func convert(in, out string) error {
+	var m *mesh.Mesh
+	var err error
+	switch strings.ToLower(filepath.Ext(in)) {
+	case ".gltf", ".glb":
+		m, err = loadGLTF(in)
+	default:
+		m, err = mesh.Load(in)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *normals >= 0 && len(m.Normals) == 0 {
+		m.GenerateNormals(float32(*normals * math.Pi / 180))
+	}
+	if *tangents && len(m.Tangents) == 0 {
+		if err := m.GenerateTangents(); err != nil {
+			return fmt.Errorf("%s: %v", in, err)
+		}
+	}
+	if *optimize {
+		for _, s := range m.Optimize() {
+			fmt.Println(s)
+		}
+	}
+
+	if err := mesh.SaveCache(out, m, *compress); err != nil {
+		return err
+	}
+	fmt.Printf("%s: %d vertices, %d triangles, %d groups\n",
+		out, m.VertexCount(), m.TriangleCount(), len(m.Groups))
+	return nil
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] file...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 || *output != "" && flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	failed := false
+	for _, in := range flag.Args() {
+		out := *output
+		if out == "" {
+			out = strings.TrimSuffix(in, filepath.Ext(in)) + ".mesh"
+		}
+		if err := convert(in, out); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,84 @@
+package common
+
+import (
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"sort"
+)
+
+// compressedFormatNames names the block compressed formats the texture
+// loaders know about.
+var compressedFormatNames = map[uint32]string{
+	0x83F0: "BC1 RGB (DXT1)",
+	0x83F1: "BC1 RGBA (DXT1)",
+	0x83F2: "BC2 (DXT3)",
+	0x83F3: "BC3 (DXT5)",
+	0x8C4C: "BC1 sRGB",
+	0x8C4D: "BC1 sRGB alpha",
+	0x8C4E: "BC2 sRGB",
+	0x8C4F: "BC3 sRGB",
+	0x8DBB: "BC4 (RGTC1)",
+	0x8DBC: "BC4 signed",
+	0x8DBD: "BC5 (RGTC2)",
+	0x8DBE: "BC5 signed",
+	0x8E8C: "BC7",
+	0x8E8D: "BC7 sRGB",
+	0x8E8E: "BC6H signed",
+	0x8E8F: "BC6H unsigned",
+	0x9270: "EAC R11",
+	0x9271: "EAC R11 signed",
+	0x9272: "EAC RG11",
+	0x9273: "EAC RG11 signed",
+	0x9274: "ETC2 RGB",
+	0x9275: "ETC2 sRGB",
+	0x9276: "ETC2 RGB A1",
+	0x9277: "ETC2 sRGB A1",
+	0x9278: "ETC2 RGBA",
+	0x9279: "ETC2 sRGB alpha",
+}
+
+var compressedFormats map[uint32]bool
+
+// CompressedFormats returns the compressed texture formats the context
+// accepts in glCompressedTexImage2D.
+func CompressedFormats() map[uint32]bool {
+	if compressedFormats == nil {
+		compressedFormats = make(map[uint32]bool)
+		var n int32
+		gl.GetIntegerv(gl.NUM_COMPRESSED_TEXTURE_FORMATS, &n)
+		if n > 0 {
+			formats := make([]int32, n)
+			gl.GetIntegerv(gl.COMPRESSED_TEXTURE_FORMATS, &formats[0])
+			for _, f := range formats {
+				compressedFormats[uint32(f)] = true
+			}
+		}
+		// RGTC is core since 3.0 but not always listed
+		for _, f := range []uint32{0x8DBB, 0x8DBC, 0x8DBD, 0x8DBE} {
+			compressedFormats[f] = true
+		}
+	}
+	return compressedFormats
+}
+
+// CompressedFormatName returns a readable name for a compressed format.
+func CompressedFormatName(format uint32) string {
+	if name, ok := compressedFormatNames[format]; ok {
+		return name
+	}
+	return "other"
+}
+
+func logCompressedFormats() {
+	var names []string
+	for f := range CompressedFormats() {
+		if name, ok := compressedFormatNames[f]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	GLog("GL_COMPRESSED_TEXTURE_FORMATS %d\n", len(CompressedFormats()))
+	for _, name := range names {
+		GLog("  %s\n", name)
+	}
+}
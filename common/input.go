@@ -0,0 +1,250 @@
+package common
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"github.com/go-gl/glfw/v3.1/glfw"
+	"io"
+	"os"
+)
+
+const (
+	eventKey    = "key"
+	eventButton = "button"
+	eventCursor = "cursor"
+	eventScroll = "scroll"
+)
+
+// InputEvent is one window input event as delivered by GLFW.
+type InputEvent struct {
+	Type     string  `json:"type"`
+	Key      int     `json:"key,omitempty"`
+	Scancode int     `json:"scancode,omitempty"`
+	Button   int     `json:"button,omitempty"`
+	Action   int     `json:"action,omitempty"`
+	Mods     int     `json:"mods,omitempty"`
+	X        float64 `json:"x,omitempty"`
+	Y        float64 `json:"y,omitempty"`
+}
+
+// InputFrame holds everything the application observed during one frame:
+// the timestamps it read and the events delivered by the following poll.
+type InputFrame struct {
+	Times  []float64    `json:"times"`
+	Events []InputEvent `json:"events,omitempty"`
+}
+
+// Input feeds window input and time to the main loop. Key, button and cursor
+// state is always rebuilt from events, so a live, recorded and replayed
+// session runs through exactly the same code path.
+//
+// With -record file every frame is appended to file as one JSON line,
+// with -replay file the frames are read back instead of polling the window.
+type Input struct {
+	window *glfw.Window
+
+	keys    map[glfw.Key]glfw.Action
+	buttons map[glfw.MouseButton]glfw.Action
+	cursorX float64
+	cursorY float64
+	scrollX float64
+	scrollY float64
+
+	lastTime float64
+	frame    InputFrame // frame being recorded or replayed
+	pending  []InputEvent
+
+	file    *os.File
+	writer  *bufio.Writer
+	encoder *json.Encoder
+	reader  *bufio.Reader
+	replay  bool
+	done    bool
+}
+
+func NewInput(window *glfw.Window) (*Input, error) {
+	in := &Input{
+		window:  window,
+		keys:    make(map[glfw.Key]glfw.Action),
+		buttons: make(map[glfw.MouseButton]glfw.Action),
+	}
+
+	switch {
+	case config.Replay != "":
+		file, err := os.Open(config.Replay)
+		if err != nil {
+			GLogErr("ERROR: opening input replay file %s: %s\n", config.Replay, err)
+			return nil, err
+		}
+		in.file = file
+		in.reader = bufio.NewReader(file)
+		in.replay = true
+		GLog("replaying input from %s\n", config.Replay)
+
+		if err := in.readFrame(); err != nil {
+			file.Close()
+			return nil, err
+		}
+	case config.Record != "":
+		file, err := os.Create(config.Record)
+		if err != nil {
+			GLogErr("ERROR: creating input record file %s: %s\n", config.Record, err)
+			return nil, err
+		}
+		in.file = file
+		in.writer = bufio.NewWriter(file)
+		in.encoder = json.NewEncoder(in.writer)
+		GLog("recording input to %s\n", config.Record)
+	}
+
+	if !in.replay {
+		in.setCallbacks()
+	}
+
+	return in, nil
+}
+
+func (in *Input) setCallbacks() {
+	in.window.SetKeyCallback(func(w *glfw.Window, key glfw.Key, scancode int,
+		action glfw.Action, mods glfw.ModifierKey) {
+		in.pending = append(in.pending, InputEvent{Type: eventKey, Key: int(key),
+			Scancode: scancode, Action: int(action), Mods: int(mods)})
+	})
+	in.window.SetMouseButtonCallback(func(w *glfw.Window, button glfw.MouseButton,
+		action glfw.Action, mods glfw.ModifierKey) {
+		in.pending = append(in.pending, InputEvent{Type: eventButton,
+			Button: int(button), Action: int(action), Mods: int(mods)})
+	})
+	in.window.SetCursorPosCallback(func(w *glfw.Window, x, y float64) {
+		in.pending = append(in.pending, InputEvent{Type: eventCursor, X: x, Y: y})
+	})
+	in.window.SetScrollCallback(func(w *glfw.Window, x, y float64) {
+		in.pending = append(in.pending, InputEvent{Type: eventScroll, X: x, Y: y})
+	})
+}
+
+// Time replaces glfw.GetTime in the main loop. Every value handed out is part
+// of the current frame, so a replay sees the same timestamps in the same order.
+func (in *Input) Time() float64 {
+	if !in.replay {
+		t := glfw.GetTime()
+		in.frame.Times = append(in.frame.Times, t)
+		return t
+	}
+
+	if len(in.frame.Times) == 0 {
+		return in.lastTime
+	}
+	t := in.frame.Times[0]
+	in.frame.Times = in.frame.Times[1:]
+	in.lastTime = t
+	return t
+}
+
+// Poll replaces glfw.PollEvents. It applies the events of the current frame
+// and, when recording, writes the frame out. At the end of a replay the
+// window is asked to close.
+func (in *Input) Poll() {
+	in.scrollX, in.scrollY = 0, 0
+
+	if in.replay {
+		glfw.PollEvents() // keep the window responsive, but ignore its input
+		in.apply(in.frame.Events)
+		if err := in.readFrame(); err != nil {
+			in.window.SetShouldClose(true)
+		}
+		return
+	}
+
+	glfw.PollEvents()
+	in.frame.Events = in.pending
+	in.pending = nil
+	in.apply(in.frame.Events)
+
+	if in.encoder != nil {
+		if err := in.encoder.Encode(&in.frame); err != nil {
+			GLogErr("ERROR: writing input record: %s\n", err)
+		}
+	}
+	in.frame = InputFrame{}
+}
+
+func (in *Input) readFrame() error {
+	if in.done {
+		return io.EOF
+	}
+
+	line, err := in.reader.ReadBytes('\n')
+	if len(line) == 0 && err != nil {
+		in.done = true
+		if err == io.EOF {
+			GLog("input replay finished\n")
+		} else {
+			GLogErr("ERROR: reading input replay: %s\n", err)
+		}
+		return err
+	}
+
+	in.frame = InputFrame{}
+	if err := json.Unmarshal(line, &in.frame); err != nil {
+		in.done = true
+		GLogErr("ERROR: decoding input replay: %s\n", err)
+		return errors.New("Replay: " + err.Error())
+	}
+
+	return nil
+}
+
+func (in *Input) apply(events []InputEvent) {
+	for _, e := range events {
+		switch e.Type {
+		case eventKey:
+			in.keys[glfw.Key(e.Key)] = glfw.Action(e.Action)
+		case eventButton:
+			in.buttons[glfw.MouseButton(e.Button)] = glfw.Action(e.Action)
+		case eventCursor:
+			in.cursorX, in.cursorY = e.X, e.Y
+		case eventScroll:
+			in.scrollX += e.X
+			in.scrollY += e.Y
+		}
+	}
+}
+
+// GetKey replaces window.GetKey.
+func (in *Input) GetKey(key glfw.Key) glfw.Action {
+	return in.keys[key]
+}
+
+// GetMouseButton replaces window.GetMouseButton.
+func (in *Input) GetMouseButton(button glfw.MouseButton) glfw.Action {
+	return in.buttons[button]
+}
+
+// GetCursorPos replaces window.GetCursorPos.
+func (in *Input) GetCursorPos() (x, y float64) {
+	return in.cursorX, in.cursorY
+}
+
+// Scroll returns the scroll offset accumulated during the last poll.
+func (in *Input) Scroll() (x, y float64) {
+	return in.scrollX, in.scrollY
+}
+
+func (in *Input) Close() error {
+	if in.file == nil {
+		return nil
+	}
+
+	if in.writer != nil {
+		if err := in.writer.Flush(); err != nil {
+			in.file.Close()
+			return err
+		}
+	}
+	err := in.file.Close()
+	in.file = nil
+
+	return err
+}
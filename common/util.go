@@ -25,6 +25,8 @@ type Config struct {
 	Fullscreen    bool
 	FPS           bool
 	Log           bool
+	Record        string
+	Replay        string
 }
 
 var (
@@ -41,7 +43,8 @@ func init() {
 	flag.BoolVar(&config.Core, "core", true, "Core Profile")
 	flag.BoolVar(&config.Forward, "forward", true, "Forward Compatible")
 	flag.BoolVar(&config.Log, "log", true, "Enable log")
-	flag.Parse()
+	flag.StringVar(&config.Record, "record", "", "Record input to file")
+	flag.StringVar(&config.Replay, "replay", "", "Replay input from file")
 }
 
 func WindowSize() (w, h int) {
@@ -49,6 +52,12 @@ func WindowSize() (w, h int) {
 }
 
 func StartGL(title string) (window *glfw.Window, err error) {
+	/* parsed here rather than in init, so importing packages (and their
+	tests and tools) can define flags of their own */
+	if !flag.Parsed() {
+		flag.Parse()
+	}
+
 	restartGLLog()
 
 	GLog("starting GLFW\n%s\n\n", glfw.GetVersionString())
@@ -206,28 +215,63 @@ func logGLParams() {
 		}
 		GLog("%s %d\n", names[i], p[0])
 	}
+	logCompressedFormats()
 
 	GLog("-----------------------------\n")
 }
 
+var extensions map[string]bool
+
+// HasExtension reports whether the current context supports an extension,
+// e.g. "GL_EXT_texture_filter_anisotropic".
+func HasExtension(name string) bool {
+	if extensions == nil {
+		extensions = make(map[string]bool)
+		var n int32
+		gl.GetIntegerv(gl.NUM_EXTENSIONS, &n)
+		for i := uint32(0); i < uint32(n); i++ {
+			extensions[gl.GoStr(gl.GetStringi(gl.EXTENSIONS, i))] = true
+		}
+	}
+	return extensions[name]
+}
+
 var (
 	prevSecs   float64
 	frameCount int
 	fps        float64
 )
 
+/* shown after the frame rate, see ShowStats */
+var frameStats string
+
+// ShowStats puts s, e.g. what the last frame drew, into the title next to
+// the frame rate ShowFPS shows.
+func ShowStats(s string) {
+	frameStats = s
+}
+
 func ShowFPS(window *glfw.Window) float64 {
+	return ShowFPSAt(window, glfw.GetTime())
+}
+
+// ShowFPSAt is ShowFPS with the time of the frame given, e.g. from
+// Input.Time so a replay counts with the recorded clock.
+func ShowFPSAt(window *glfw.Window, curSecs float64) float64 {
 	if !config.FPS {
 		return fps
 	}
 
-	curSecs := glfw.GetTime()
 	elapsedSecs := curSecs - prevSecs
 	if elapsedSecs > 0.25 {
 		prevSecs = curSecs
 		fps = float64(frameCount) / elapsedSecs
 		if window != nil {
-			window.SetTitle(config.Title + fmt.Sprintf(" @fps: %.2f", fps))
+			title := config.Title + fmt.Sprintf(" @fps: %.2f", fps)
+			if frameStats != "" {
+				title += ", " + frameStats
+			}
+			window.SetTitle(title)
 		}
 		frameCount = 0
 	}
@@ -458,6 +502,20 @@ func glType2String(typ uint32) string {
 		return "mat3"
 	case gl.FLOAT_MAT4:
 		return "mat4"
+	case gl.INT_VEC2:
+		return "ivec2"
+	case gl.INT_VEC3:
+		return "ivec3"
+	case gl.INT_VEC4:
+		return "ivec4"
+	case gl.UNSIGNED_INT:
+		return "uint"
+	case gl.UNSIGNED_INT_VEC2:
+		return "uvec2"
+	case gl.UNSIGNED_INT_VEC3:
+		return "uvec3"
+	case gl.UNSIGNED_INT_VEC4:
+		return "uvec4"
 	case gl.SAMPLER_2D:
 		return "sampler2D"
 	case gl.SAMPLER_3D:
@@ -471,3 +529,36 @@ func glType2String(typ uint32) string {
 
 	return "other"
 }
+
+// ActiveVar is an active attribute or uniform reported by a linked program.
+type ActiveVar struct {
+	Name     string
+	Type     uint32
+	Size     int32 // array length
+	Location int32
+}
+
+func (v ActiveVar) TypeName() string {
+	return glType2String(v.Type)
+}
+
+// ActiveAttribs returns the vertex attributes used by program, built-in
+// gl_ inputs included.
+func ActiveAttribs(program uint32) []ActiveVar {
+	var count, length int32
+	gl.GetProgramiv(program, gl.ACTIVE_ATTRIBUTES, &count)
+	gl.GetProgramiv(program, gl.ACTIVE_ATTRIBUTE_MAX_LENGTH, &length)
+
+	attribs := make([]ActiveVar, 0, count)
+	for i := int32(0); i < count; i++ {
+		var n int32
+		var v ActiveVar
+		name := make([]byte, length+1)
+		gl.GetActiveAttrib(program, uint32(i), length+1, &n, &v.Size, &v.Type, &name[0])
+		v.Name = string(name[:n])
+		v.Location = gl.GetAttribLocation(program, &name[0])
+		attribs = append(attribs, v)
+	}
+
+	return attribs
+}
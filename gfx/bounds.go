@@ -0,0 +1,143 @@
+package gfx
+
+import "math"
+
+// AABB is an axis aligned bounding box.
+type AABB struct {
+	Min, Max [3]float32
+}
+
+// Sphere is a bounding sphere.
+type Sphere struct {
+	Center [3]float32
+	Radius float32
+}
+
+// Center returns the middle of the box.
+func (b AABB) Center() [3]float32 {
+	return [3]float32{(b.Min[0] + b.Max[0]) / 2, (b.Min[1] + b.Max[1]) / 2, (b.Min[2] + b.Max[2]) / 2}
+}
+
+// Transform returns the box around b transformed by the affine matrix m.
+// It grows with rotation, but never misses a corner.
+func (b AABB) Transform(m [16]float32) AABB {
+	c := transformPoint(m, b.Center())
+	var out AABB
+	for i := 0; i < 3; i++ {
+		var e float32
+		for j := 0; j < 3; j++ {
+			e += float32(math.Abs(float64(m[j*4+i]))) * (b.Max[j] - b.Min[j]) / 2
+		}
+		out.Min[i], out.Max[i] = c[i]-e, c[i]+e
+	}
+	return out
+}
+
+// Transform returns the sphere around s transformed by the affine matrix
+// m, scaled by the largest scale of m.
+func (s Sphere) Transform(m [16]float32) Sphere {
+	scale := float32(0)
+	for c := 0; c < 3; c++ {
+		l := m[c*4]*m[c*4] + m[c*4+1]*m[c*4+1] + m[c*4+2]*m[c*4+2]
+		if l > scale {
+			scale = l
+		}
+	}
+	return Sphere{transformPoint(m, s.Center), s.Radius * float32(math.Sqrt(float64(scale)))}
+}
+
+// Bounds returns the bounding box and sphere of the mesh in model space.
+// ok is false for meshes built from raw data, which have no bounds.
+func (g *Mesh) Bounds() (box AABB, sphere Sphere, ok bool) {
+	box = AABB{g.Min, g.Max}
+	if g.Min == g.Max && g.Radius == 0 {
+		return box, sphere, false
+	}
+	sphere = Sphere{box.Center(), g.Radius}
+	if sphere.Radius == 0 {
+		// half the diagonal, for meshes that only know their box
+		d := sub3(g.Max, g.Min)
+		sphere.Radius = float32(math.Sqrt(float64(dot3(d, d)))) / 2
+	}
+	return box, sphere, true
+}
+
+// boundingRadius is the distance from center to the furthest of the
+// positions.
+func boundingRadius(positions []float32, center [3]float32) float32 {
+	max := float32(0)
+	for i := 0; i+2 < len(positions); i += 3 {
+		d := sub3([3]float32{positions[i], positions[i+1], positions[i+2]}, center)
+		if l := dot3(d, d); l > max {
+			max = l
+		}
+	}
+	return float32(math.Sqrt(float64(max)))
+}
+
+// Plane is n·p + d = 0 with a unit normal n pointing to the inside.
+type Plane [4]float32
+
+// Distance is the signed distance of p from the plane, positive inside.
+func (p Plane) Distance(v [3]float32) float32 {
+	return p[0]*v[0] + p[1]*v[1] + p[2]*v[2] + p[3]
+}
+
+// Frustum is the volume a camera sees, as the planes left, right,
+// bottom, top, near and far.
+type Frustum [6]Plane
+
+// NewFrustum extracts the planes of a projection * view matrix, giving
+// them in world space; with just a projection they are in view space.
+func NewFrustum(viewProj [16]float32) Frustum {
+	m := viewProj
+	row := func(i int) [4]float32 { return [4]float32{m[i], m[4+i], m[8+i], m[12+i]} }
+	r0, r1, r2, r3 := row(0), row(1), row(2), row(3)
+
+	var f Frustum
+	for i, r := range [6]struct {
+		row  [4]float32
+		sign float32
+	}{{r0, 1}, {r0, -1}, {r1, 1}, {r1, -1}, {r2, 1}, {r2, -1}} {
+		var p Plane
+		for k := 0; k < 4; k++ {
+			p[k] = r3[k] + r.sign*r.row[k]
+		}
+		l := float32(math.Sqrt(float64(p[0]*p[0] + p[1]*p[1] + p[2]*p[2])))
+		if l > 0 {
+			p = Plane{p[0] / l, p[1] / l, p[2] / l, p[3] / l}
+		}
+		f[i] = p
+	}
+	return f
+}
+
+// IntersectsSphere is false when s is entirely outside the frustum.
+func (f *Frustum) IntersectsSphere(s Sphere) bool {
+	for _, p := range f {
+		if p.Distance(s.Center) < -s.Radius {
+			return false
+		}
+	}
+	return true
+}
+
+// IntersectsBox is false when b is entirely outside the frustum. Boxes
+// near a corner outside of it can pass, as with any plane test.
+func (f *Frustum) IntersectsBox(b AABB) bool {
+	for _, p := range f {
+		// the corner furthest along the normal
+		var v [3]float32
+		for k := 0; k < 3; k++ {
+			if p[k] >= 0 {
+				v[k] = b.Max[k]
+			} else {
+				v[k] = b.Min[k]
+			}
+		}
+		if p.Distance(v) < 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,125 @@
+package gfx
+
+import (
+	"math"
+	"testing"
+)
+
+// box is the AABB around the sphere s.
+func box(s Sphere) AABB {
+	r := s.Radius
+	c := s.Center
+	return AABB{[3]float32{c[0] - r, c[1] - r, c[2] - r}, [3]float32{c[0] + r, c[1] + r, c[2] + r}}
+}
+
+func TestFrustum(t *testing.T) {
+	// looking down -Z from z = 5, so the sides are tan(30°)*5 = 2.89 from
+	// the origin
+	view := LookAt([3]float32{0, 0, 5}, [3]float32{}, [3]float32{0, 1, 0})
+	persp := NewFrustum(MulMat4(Perspective(60, 1, 0.1, 100), view))
+	ortho := NewFrustum(MulMat4(Ortho(-2, 2, -1, 1, 1, 10), view))
+
+	tests := []struct {
+		name    string
+		frustum Frustum
+		sphere  Sphere
+		want    bool
+	}{
+		{"centre", persp, Sphere{[3]float32{0, 0, 0}, 1}, true},
+		{"behind", persp, Sphere{[3]float32{0, 0, 10}, 1}, false},
+		{"across the near plane", persp, Sphere{[3]float32{0, 0, 4.95}, 0.1}, true},
+		{"beyond the far plane", persp, Sphere{[3]float32{0, 0, -200}, 1}, false},
+		{"across the far plane", persp, Sphere{[3]float32{0, 0, -95.5}, 1}, true},
+		{"right", persp, Sphere{[3]float32{20, 0, 0}, 1}, false},
+		{"across the right side", persp, Sphere{[3]float32{3.3, 0, 0}, 1}, true},
+		{"below", persp, Sphere{[3]float32{0, -5, 0}, 1}, false},
+		{"across the bottom", persp, Sphere{[3]float32{0, -3.3, 0}, 1}, true},
+		{"ortho inside", ortho, Sphere{[3]float32{1.5, 0.5, 0}, 0.1}, true},
+		{"ortho right", ortho, Sphere{[3]float32{2.5, 0, 0}, 0.2}, false},
+		{"ortho above", ortho, Sphere{[3]float32{0, 1.5, 0}, 0.2}, false},
+		{"ortho before the near plane", ortho, Sphere{[3]float32{0, 0, 4.5}, 0.2}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.frustum.IntersectsSphere(tt.sphere); got != tt.want {
+			t.Errorf("%s: sphere %v intersects %v, want %v", tt.name, tt.sphere, got, tt.want)
+		}
+		if got := tt.frustum.IntersectsBox(box(tt.sphere)); got != tt.want {
+			t.Errorf("%s: box %v intersects %v, want %v", tt.name, box(tt.sphere), got, tt.want)
+		}
+	}
+
+	// every plane of the perspective frustum has the origin inside
+	for i, p := range persp {
+		if l := math.Sqrt(float64(p[0]*p[0] + p[1]*p[1] + p[2]*p[2])); math.Abs(l-1) > 1e-5 {
+			t.Errorf("plane %d has a normal of length %g", i, l)
+		}
+		if d := p.Distance([3]float32{}); d <= 0 {
+			t.Errorf("plane %d has the origin %g outside", i, d)
+		}
+	}
+}
+
+func TestTransformBounds(t *testing.T) {
+	// rotate 90° about Z, scale by 2 and move by (1, 2, 3)
+	m := [16]float32{0, 2, 0, 0, -2, 0, 0, 0, 0, 0, 2, 0, 1, 2, 3, 1}
+	tests := []struct {
+		name    string
+		m       [16]float32
+		box     AABB
+		wantBox AABB
+		sphere  Sphere
+		want    Sphere
+	}{
+		{"identity", [16]float32{1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1},
+			AABB{[3]float32{0, 0, 0}, [3]float32{1, 2, 3}}, AABB{[3]float32{0, 0, 0}, [3]float32{1, 2, 3}},
+			Sphere{[3]float32{1, 0, 0}, 1}, Sphere{[3]float32{1, 0, 0}, 1}},
+		{"rotate scale move", m,
+			AABB{[3]float32{0, 0, 0}, [3]float32{1, 2, 3}}, AABB{[3]float32{-3, 2, 3}, [3]float32{1, 4, 9}},
+			Sphere{[3]float32{1, 0, 0}, 1}, Sphere{[3]float32{1, 4, 3}, 2}},
+		// the box grows to hold the rotated corners, the sphere takes the
+		// largest scale
+		{"rotate 45° and stretch", [16]float32{1, 1, 0, 0, -1, 1, 0, 0, 0, 0, 3, 0, 0, 0, 0, 1},
+			AABB{[3]float32{-1, -1, -1}, [3]float32{1, 1, 1}}, AABB{[3]float32{-2, -2, -3}, [3]float32{2, 2, 3}},
+			Sphere{[3]float32{}, 1}, Sphere{[3]float32{}, 3}},
+	}
+	for _, tt := range tests {
+		if b := tt.box.Transform(tt.m); b != tt.wantBox {
+			t.Errorf("%s: box %v, want %v", tt.name, b, tt.wantBox)
+		}
+		if s := tt.sphere.Transform(tt.m); s != tt.want {
+			t.Errorf("%s: sphere %v, want %v", tt.name, s, tt.want)
+		}
+	}
+}
+
+func TestMeshBounds(t *testing.T) {
+	tests := []struct {
+		name   string
+		mesh   *Mesh
+		ok     bool
+		radius float32
+	}{
+		{"box only", &Mesh{Min: [3]float32{-1, -1, -1}, Max: [3]float32{1, 1, 1}}, true, float32(math.Sqrt(3))},
+		{"radius", &Mesh{Min: [3]float32{-1, -1, -1}, Max: [3]float32{1, 1, 1}, Radius: 1}, true, 1},
+		{"point with a radius", &Mesh{Radius: 2}, true, 2},
+		{"raw", &Mesh{}, false, 0},
+	}
+	for _, tt := range tests {
+		box, sphere, ok := tt.mesh.Bounds()
+		if ok != tt.ok {
+			t.Errorf("%s: ok %v", tt.name, ok)
+			continue
+		}
+		if ok && (box != AABB{tt.mesh.Min, tt.mesh.Max} || sphere.Center != box.Center() ||
+			math.Abs(float64(sphere.Radius-tt.radius)) > 1e-6) {
+			t.Errorf("%s: box %v and sphere %v, want a radius of %g", tt.name, box, sphere, tt.radius)
+		}
+	}
+
+	if r := boundingRadius([]float32{1, 0, 0, 0, 3, 0, 0, 0, -2}, [3]float32{}); r != 3 {
+		t.Errorf("bounding radius %g, want 3", r)
+	}
+	if r := boundingRadius([]float32{1, 0, 0, 0, 3, 0}, [3]float32{0, 3, 0}); r != float32(math.Sqrt(10)) {
+		t.Errorf("bounding radius about (0, 3, 0) %g, want %g", r, math.Sqrt(10))
+	}
+}
@@ -0,0 +1,85 @@
+package gfx
+
+import (
+	"fmt"
+	"github.com/ginuerzh/anton-gocode/mesh"
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"io/ioutil"
+)
+
+// cacheAttribs maps mesh cache attribute names to the shader inputs
+// MeshLayout uses for them.
+var cacheAttribs = map[string]Attrib{
+	"position": {Name: "vertex_position", Location: PositionLoc},
+	"colour":   {Name: "vertex_colour", Location: ColourLoc},
+	"normal":   {Name: "vertex_normal", Location: NormalLoc},
+	"texcoord": {Name: "vertex_texcoord", Location: TexCoordLoc},
+	"tangent":  {Name: "vertex_tangent", Location: TangentLoc},
+}
+
+// LoadMeshCache reads a mesh cache file with a single read and copies its
+// vertex and index data into GL buffers as they are. Attributes without a
+// standard location, such as extra PLY properties, stay in the buffer but
+// are not enabled.
+func LoadMeshCache(filename string) (*Mesh, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	c, err := mesh.ParseCache(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	layout := VertexLayout{Strides: []int32{int32(c.Stride)}}
+	for _, a := range c.Attribs {
+		attrib, ok := cacheAttribs[a.Name]
+		if !ok {
+			continue
+		}
+		attrib.Size = int32(a.Size)
+		attrib.Type = a.Type
+		attrib.Normalized = a.Normalized
+		attrib.Offset = a.Offset
+		layout.Attribs = append(layout.Attribs, attrib)
+	}
+
+	g := &Mesh{
+		Mode:     gl.TRIANGLES,
+		Layout:   layout,
+		Vertices: int32(c.Vertices),
+		Count:    int32(c.Vertices),
+		Min:      c.Min,
+		Max:      c.Max,
+		Vbos:     make([]uint32, 1),
+	}
+	gl.GenBuffers(1, &g.Vbos[0])
+	gl.BindBuffer(gl.ARRAY_BUFFER, g.Vbos[0])
+	if len(c.VertexData) > 0 {
+		gl.BufferData(gl.ARRAY_BUFFER, len(c.VertexData), gl.Ptr(c.VertexData), gl.STATIC_DRAW)
+	}
+	g.Vao = layout.CreateVao(g.Vbos...)
+
+	if c.Indices > 0 {
+		gl.GenBuffers(1, &g.Ebo)
+		gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, g.Ebo)
+		gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, len(c.IndexData), gl.Ptr(c.IndexData), gl.STATIC_DRAW)
+		g.IndexType = gl.UNSIGNED_INT
+		if c.IndexSize == 2 {
+			g.IndexType = gl.UNSIGNED_SHORT
+		}
+		g.Count = int32(c.Indices)
+	}
+
+	for _, gr := range c.Groups {
+		g.Submeshes = append(g.Submeshes, Submesh{
+			Start:    gr.Start,
+			Count:    gr.Count,
+			Material: gr.Material,
+		})
+	}
+	if len(g.Submeshes) == 0 {
+		g.Submeshes = []Submesh{{Count: int(g.Count), Material: -1}}
+	}
+	return g, nil
+}
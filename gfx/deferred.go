@@ -0,0 +1,414 @@
+package gfx
+
+import (
+	"github.com/ginuerzh/anton-gocode/mesh"
+	"github.com/ginuerzh/anton-gocode/texture"
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"math"
+)
+
+// gbufferGLSL declares the G-buffer outputs of a geometry pass. Pull it
+// into the fragment shader with #include "gbuffer.glsl" and finish with
+// gbuffer_write, the material comes from pbr.glsl.
+const gbufferGLSL = `#include "pbr.glsl"
+
+layout(location = 0) out vec4 gbuffer_albedo;   // rgb albedo, a occlusion
+layout(location = 1) out vec4 gbuffer_normal;   // xyz view space normal, w view depth
+layout(location = 2) out vec4 gbuffer_material; // r metallic, g roughness
+layout(location = 3) out vec4 gbuffer_emissive; // rgb emissive
+
+// gbuffer_write stores a surface point at a view space position. The
+// view depth doubles as the mark of covered pixels, the background
+// keeps 0.
+void gbuffer_write(vec3 position, PBRSurface s) {
+	gbuffer_albedo = vec4(s.albedo, s.occlusion);
+	gbuffer_normal = vec4(s.normal, -position.z);
+	gbuffer_material = vec4(s.metallic, s.roughness, 0.0, 1.0);
+	gbuffer_emissive = vec4(s.emissive, 1.0);
+}
+
+// gbuffer_material_write evaluates pbr_material and stores it, dropping
+// points below the alpha cutoff.
+void gbuffer_material_write(vec3 position, vec3 normal, vec4 tangent, vec2 uv) {
+	PBRSurface s = pbr_surface(normal, tangent, uv);
+	if (s.alpha < pbr_material.alpha_cutoff) {
+		discard;
+	}
+	gbuffer_write(position, s);
+}
+`
+
+// deferredGLSL reads the G-buffer back in the lighting passes.
+const deferredGLSL = `#include "pbr.glsl"
+
+uniform sampler2D gbuffer_albedo_map;
+uniform sampler2D gbuffer_normal_map;
+uniform sampler2D gbuffer_material_map;
+uniform sampler2D gbuffer_emissive_map;
+uniform vec4 unproject; // proj[0][0], proj[1][1], proj[2][0], proj[2][1]
+
+out vec4 frag_colour;
+
+// gbuffer_read fetches the surface under the fragment and rebuilds its
+// view space position from the depth, false for the background.
+bool gbuffer_read(out PBRSurface s, out vec3 position) {
+	ivec2 p = ivec2(gl_FragCoord.xy);
+	vec4 normal = texelFetch(gbuffer_normal_map, p, 0);
+	if (normal.w <= 0.0) {
+		return false;
+	}
+	vec4 albedo = texelFetch(gbuffer_albedo_map, p, 0);
+	vec4 material = texelFetch(gbuffer_material_map, p, 0);
+	s.albedo = albedo.rgb;
+	s.alpha = 1.0;
+	s.normal = normalize(normal.xyz);
+	s.metallic = material.r;
+	s.roughness = clamp(material.g, 0.045, 1.0);
+	s.occlusion = albedo.a;
+	s.emissive = texelFetch(gbuffer_emissive_map, p, 0).rgb;
+
+	vec2 ndc = gl_FragCoord.xy / vec2(textureSize(gbuffer_normal_map, 0)) * 2.0 - 1.0;
+	float depth = normal.w;
+	position = vec3((ndc + unproject.zw) / unproject.xy * depth, -depth);
+	return true;
+}
+`
+
+func init() {
+	RegisterInclude("gbuffer.glsl", gbufferGLSL)
+	RegisterInclude("deferred.glsl", deferredGLSL)
+}
+
+// deferredAmbientFS lights the whole screen with the surroundings, the
+// emissive surfaces and the directional lights, which reach everything.
+const deferredAmbientFS = `#version 330
+
+#include "deferred.glsl"
+
+void main() {
+	PBRSurface s;
+	vec3 position;
+	if (!gbuffer_read(s, position)) {
+		discard;
+	}
+	vec3 v = normalize(-position);
+	vec3 colour = pbr_ambient(s.normal, v, s.albedo, s.metallic, s.roughness) * s.occlusion + s.emissive;
+	for (int i = 0; i < light_count; i++) {
+		if (int(lights[i].position.w) == DIRECTIONAL_LIGHT) {
+			colour += pbr_light(lights[i], position, s.normal, v, s.albedo, s.metallic, s.roughness) *
+				light_shadow(i, position, s.normal);
+		}
+	}
+	frag_colour = vec4(colour, 1.0);
+}
+`
+
+const deferredVolumeVS = `#version 330
+
+layout(location = 0) in vec3 vertex_position;
+
+uniform mat4 light_mvp;
+
+void main() {
+	gl_Position = light_mvp * vec4(vertex_position, 1.0);
+}
+`
+
+// deferredVolumeFS adds one point or spot light where its volume covers
+// the screen.
+const deferredVolumeFS = `#version 330
+
+#include "deferred.glsl"
+
+uniform int light_index;
+
+void main() {
+	PBRSurface s;
+	vec3 position;
+	if (!gbuffer_read(s, position)) {
+		discard;
+	}
+	vec3 v = normalize(-position);
+	Light light = lights[light_index];
+	frag_colour = vec4(pbr_light(light, position, s.normal, v, s.albedo, s.metallic, s.roughness), 1.0);
+}
+`
+
+// deferredPass is a lighting program and the locations it shares.
+type deferredPass struct {
+	program   uint32
+	unproject int32
+	ibl       IBLUniforms
+}
+
+func newDeferredPass(vs, fs string) (deferredPass, error) {
+	program, err := NewProgram(vs, fs)
+	if err != nil {
+		return deferredPass{}, err
+	}
+	if err := BindLighting(program); err != nil {
+		gl.DeleteProgram(program)
+		return deferredPass{}, err
+	}
+	gl.UseProgram(program)
+	for i, name := range []string{"gbuffer_albedo_map", "gbuffer_normal_map", "gbuffer_material_map", "gbuffer_emissive_map"} {
+		gl.Uniform1i(uniform(program, name), int32(i))
+	}
+	return deferredPass{
+		program:   program,
+		unproject: uniform(program, "unproject"),
+		ibl:       NewIBLUniforms(program),
+	}, nil
+}
+
+// use makes the pass current with the projection to unproject with.
+func (p *deferredPass) use(proj [16]float32) {
+	gl.UseProgram(p.program)
+	gl.Uniform4f(p.unproject, proj[0], proj[5], proj[8], proj[9])
+}
+
+// Deferred is a deferred shading renderer. The opaque geometry is drawn
+// once into a G-buffer of albedo, normals, material parameters and
+// depth, then every light only shades the pixels its volume covers, so
+// the cost grows with the lit area rather than with lights times
+// geometry. Transparent geometry is drawn forward afterwards, depth
+// tested against the opaque scene.
+//
+// A frame goes BeginGeometry, drawing with a program that includes
+// gbuffer.glsl, Light, optionally BeginForward and blended drawing, and
+// End, which draws the result into the framebuffer that was bound at
+// BeginGeometry.
+type Deferred struct {
+	// GBuffer holds the albedo, normal, material and emissive textures
+	// in Colour and the depth texture
+	GBuffer *RenderTarget
+	// Output holds the lit HDR colour
+	Output *texture.Texture
+
+	lightFBO uint32
+	ambient  deferredPass
+	volume   deferredPass
+	mvpLoc   int32
+	indexLoc int32
+	sphere   *Mesh
+	copy     *Pass
+
+	bound    int32
+	viewport [4]int32
+}
+
+// NewDeferred creates a deferred renderer of width x height pixels.
+func NewDeferred(width, height int32) (*Deferred, error) {
+	d := &Deferred{}
+	var err error
+	d.GBuffer, err = NewRenderTargetWith(width, height, TargetOptions{
+		Colour:       []int32{gl.RGBA8, gl.RGBA16F, gl.RGBA8, gl.RGBA16F},
+		Depth:        gl.DEPTH_COMPONENT24,
+		DepthTexture: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := d.createOutput(); err != nil {
+		d.Delete()
+		return nil, err
+	}
+
+	if d.ambient, err = newDeferredPass(fullscreenVS, deferredAmbientFS); err != nil {
+		d.Delete()
+		return nil, err
+	}
+	if d.volume, err = newDeferredPass(deferredVolumeVS, deferredVolumeFS); err != nil {
+		d.Delete()
+		return nil, err
+	}
+	d.mvpLoc = uniform(d.volume.program, "light_mvp")
+	d.indexLoc = uniform(d.volume.program, "light_index")
+
+	if d.sphere, err = UploadMesh(mesh.UVSphere(1, 16, 8)); err != nil {
+		d.Delete()
+		return nil, err
+	}
+	if d.copy, err = NewPass(copyFS, nil); err != nil {
+		d.Delete()
+		return nil, err
+	}
+	return d, nil
+}
+
+// createOutput makes the lighting framebuffer: the output colour over the
+// G-buffer depth, so light volumes and transparents test against it.
+func (d *Deferred) createOutput() error {
+	g := d.GBuffer
+	d.Output = newTargetTexture(g.Width, g.Height, gl.RGBA16F)
+	gl.GenFramebuffers(1, &d.lightFBO)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, d.lightFBO)
+	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, d.Output.ID, 0)
+	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.DEPTH_ATTACHMENT, gl.TEXTURE_2D, g.Depth.ID, 0)
+	err := checkFramebuffer("deferred output")
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+	return err
+}
+
+func (d *Deferred) deleteOutput() {
+	if d.lightFBO != 0 {
+		gl.DeleteFramebuffers(1, &d.lightFBO)
+		d.lightFBO = 0
+	}
+	if d.Output != nil {
+		d.Output.Delete()
+		d.Output = nil
+	}
+}
+
+// Resize changes the size of the buffers, if it differs.
+func (d *Deferred) Resize(width, height int32) error {
+	if width == d.GBuffer.Width && height == d.GBuffer.Height {
+		return nil
+	}
+	d.deleteOutput()
+	if err := d.GBuffer.Resize(width, height); err != nil {
+		return err
+	}
+	return d.createOutput()
+}
+
+// BeginGeometry binds and clears the G-buffer for the opaque geometry,
+// with depth testing and writing on and blending off.
+func (d *Deferred) BeginGeometry() {
+	gl.GetIntegerv(gl.FRAMEBUFFER_BINDING, &d.bound)
+	gl.GetIntegerv(gl.VIEWPORT, &d.viewport[0])
+
+	d.GBuffer.Bind()
+	gl.ClearColor(0, 0, 0, 0)
+	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
+	gl.Enable(gl.DEPTH_TEST)
+	gl.DepthFunc(gl.LESS)
+	gl.DepthMask(true)
+	gl.Disable(gl.BLEND)
+}
+
+// Light shades the G-buffer into Output. proj has to be a perspective
+// projection and the lights must have been uploaded with the same view
+// matrix. ibl may be nil, the ambient light
+// of lighting is used then. Point and spot lights without a Range get a
+// volume where their attenuation falls below 1/256.
+func (d *Deferred) Light(lighting *Lighting, ibl *IBL, view, proj [16]float32) {
+	gl.BindFramebuffer(gl.FRAMEBUFFER, d.lightFBO)
+	gl.Viewport(0, 0, d.GBuffer.Width, d.GBuffer.Height)
+	gl.ClearColor(0, 0, 0, 0)
+	gl.Clear(gl.COLOR_BUFFER_BIT)
+	lighting.Bind()
+	for i, t := range d.GBuffer.Colour {
+		t.Bind(uint32(i))
+	}
+
+	d.ambient.use(proj)
+	d.ambient.ibl.Set(ibl, view, 4)
+	drawFullscreen()
+
+	// the back faces of a volume cover every pixel whose surface is in
+	// front of them, inside the volume or not; only the first are lit
+	// further, the pixel shader does the rest
+	var frontFace int32
+	gl.GetIntegerv(gl.FRONT_FACE, &frontFace)
+	cull := gl.IsEnabled(gl.CULL_FACE)
+	gl.Enable(gl.BLEND)
+	gl.BlendFunc(gl.ONE, gl.ONE)
+	gl.Enable(gl.DEPTH_TEST)
+	gl.DepthFunc(gl.GEQUAL)
+	gl.DepthMask(false)
+	gl.Enable(gl.CULL_FACE)
+	gl.FrontFace(gl.CCW)
+	gl.CullFace(gl.FRONT)
+
+	d.volume.use(proj)
+	viewProj := MulMat4(proj, view)
+	n := len(lighting.Lights)
+	if n > MaxLights {
+		n = MaxLights
+	}
+	for i, light := range lighting.Lights[:n] {
+		if light.Type == DirectionalLight {
+			continue
+		}
+		// the sphere is a polygon, the scale takes its flat faces out to
+		// the range
+		r := lightReach(light) / float32(math.Cos(math.Pi/8))
+		p := light.Position
+		model := [16]float32{r, 0, 0, 0, 0, r, 0, 0, 0, 0, r, 0, p[0], p[1], p[2], 1}
+		mvp := MulMat4(viewProj, model)
+		gl.UniformMatrix4fv(d.mvpLoc, 1, false, &mvp[0])
+		gl.Uniform1i(d.indexLoc, int32(i))
+		d.sphere.Draw()
+	}
+
+	gl.CullFace(gl.BACK)
+	gl.FrontFace(uint32(frontFace))
+	if !cull {
+		gl.Disable(gl.CULL_FACE)
+	}
+	gl.DepthFunc(gl.LESS)
+	gl.DepthMask(true)
+	gl.Disable(gl.BLEND)
+}
+
+// lightReach is how far a point or spot light lights anything.
+func lightReach(l Light) float32 {
+	if l.Range > 0 {
+		return l.Range
+	}
+	// solve c + l d + q d² = 256 brightness
+	bright := l.Intensity * float32(math.Max(float64(l.Colour[0]), math.Max(float64(l.Colour[1]), float64(l.Colour[2]))))
+	c := float64(l.Constant - 256*bright)
+	switch {
+	case l.Quadratic > 0:
+		q, lin := float64(l.Quadratic), float64(l.Linear)
+		return float32((-lin + math.Sqrt(lin*lin-4*q*c)) / (2 * q))
+	case l.Linear > 0:
+		return float32(-c / float64(l.Linear))
+	}
+	return 1000
+}
+
+// BeginForward binds Output over the opaque depth for blended geometry:
+// depth tested without writing, alpha blending on. Use a forward shading
+// program, such as one with pbr.glsl.
+func (d *Deferred) BeginForward() {
+	gl.BindFramebuffer(gl.FRAMEBUFFER, d.lightFBO)
+	gl.Viewport(0, 0, d.GBuffer.Width, d.GBuffer.Height)
+	gl.Enable(gl.DEPTH_TEST)
+	gl.DepthFunc(gl.LESS)
+	gl.DepthMask(false)
+	gl.Enable(gl.BLEND)
+	gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+}
+
+// End draws Output into the framebuffer bound at BeginGeometry and brings
+// back its viewport. Depth writes are on and blending off afterwards.
+func (d *Deferred) End() {
+	gl.DepthMask(true)
+	gl.Disable(gl.BLEND)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, uint32(d.bound))
+	gl.Viewport(d.viewport[0], d.viewport[1], d.viewport[2], d.viewport[3])
+	d.copy.draw(d.Output)
+}
+
+// Delete frees the buffers and the programs.
+func (d *Deferred) Delete() {
+	d.deleteOutput()
+	if d.GBuffer != nil {
+		d.GBuffer.Delete()
+	}
+	for _, p := range []uint32{d.ambient.program, d.volume.program} {
+		if p != 0 {
+			gl.DeleteProgram(p)
+		}
+	}
+	if d.sphere != nil {
+		d.sphere.Delete()
+	}
+	if d.copy != nil {
+		d.copy.Delete()
+	}
+}
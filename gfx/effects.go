@@ -0,0 +1,338 @@
+package gfx
+
+import (
+	"github.com/ginuerzh/anton-gocode/texture"
+	"math"
+)
+
+const greyscaleFS = `#version 330
+
+in vec2 texcoord;
+
+uniform sampler2D source;
+uniform float strength;
+
+out vec4 frag_colour;
+
+void main() {
+	vec4 c = texture(source, texcoord);
+	float luma = dot(c.rgb, vec3(0.2126, 0.7152, 0.0722));
+	frag_colour = vec4(mix(c.rgb, vec3(luma), strength), c.a);
+}
+`
+
+// NewGreyscale creates a pass that desaturates towards the luminance.
+// Uniforms: strength, 0 to 1.
+func NewGreyscale() (*Pass, error) {
+	return NewPass(greyscaleFS, map[string]interface{}{"strength": float32(1)})
+}
+
+const vignetteFS = `#version 330
+
+in vec2 texcoord;
+
+uniform sampler2D source;
+uniform vec2 texel;
+uniform float radius;
+uniform float softness;
+uniform float strength;
+
+out vec4 frag_colour;
+
+void main() {
+	vec4 c = texture(source, texcoord);
+	// distance from the centre, round whatever the aspect ratio
+	vec2 p = texcoord - 0.5;
+	p.x *= texel.y / texel.x;
+	float v = smoothstep(radius, radius - softness, length(p));
+	frag_colour = vec4(c.rgb * mix(1.0, v, strength), c.a);
+}
+`
+
+// NewVignette creates a pass that darkens towards the corners.
+// Uniforms: radius where darkening starts, in units of the screen height,
+// softness of the falloff and strength, 0 to 1.
+func NewVignette() (*Pass, error) {
+	return NewPass(vignetteFS, map[string]interface{}{
+		"radius":   float32(0.75),
+		"softness": float32(0.45),
+		"strength": float32(1),
+	})
+}
+
+// fxaaFS is the FXAA 2 edge blur by Timothy Lottes, on luma computed from
+// the colour. It expects display encoded input, after tone mapping.
+const fxaaFS = `#version 330
+
+in vec2 texcoord;
+
+uniform sampler2D source;
+uniform vec2 texel;
+uniform float span_max;
+uniform float reduce_mul;
+uniform float reduce_min;
+
+out vec4 frag_colour;
+
+void main() {
+	vec3 nw = texture(source, texcoord + vec2(-1.0, -1.0) * texel).rgb;
+	vec3 ne = texture(source, texcoord + vec2(1.0, -1.0) * texel).rgb;
+	vec3 sw = texture(source, texcoord + vec2(-1.0, 1.0) * texel).rgb;
+	vec3 se = texture(source, texcoord + vec2(1.0, 1.0) * texel).rgb;
+	vec4 m = texture(source, texcoord);
+
+	vec3 weights = vec3(0.299, 0.587, 0.114);
+	float luma_nw = dot(nw, weights);
+	float luma_ne = dot(ne, weights);
+	float luma_sw = dot(sw, weights);
+	float luma_se = dot(se, weights);
+	float luma_m = dot(m.rgb, weights);
+	float luma_min = min(luma_m, min(min(luma_nw, luma_ne), min(luma_sw, luma_se)));
+	float luma_max = max(luma_m, max(max(luma_nw, luma_ne), max(luma_sw, luma_se)));
+
+	// blur along the edge, across the luma gradient
+	vec2 dir = vec2(-((luma_nw + luma_ne) - (luma_sw + luma_se)),
+		(luma_nw + luma_sw) - (luma_ne + luma_se));
+	float reduce = max((luma_nw + luma_ne + luma_sw + luma_se) * 0.25 * reduce_mul, reduce_min);
+	float scale = 1.0 / (min(abs(dir.x), abs(dir.y)) + reduce);
+	dir = clamp(dir * scale, vec2(-span_max), vec2(span_max)) * texel;
+
+	vec3 a = 0.5 * (texture(source, texcoord + dir * (1.0 / 3.0 - 0.5)).rgb +
+		texture(source, texcoord + dir * (2.0 / 3.0 - 0.5)).rgb);
+	vec3 b = a * 0.5 + 0.25 * (texture(source, texcoord - dir * 0.5).rgb +
+		texture(source, texcoord + dir * 0.5).rgb);
+
+	// the wide blur overshot if it left the local luma range
+	float luma_b = dot(b, weights);
+	frag_colour = vec4((luma_b < luma_min || luma_b > luma_max) ? a : b, m.a);
+}
+`
+
+// NewFXAA creates a fast approximate anti-aliasing pass. Run it after
+// tone mapping. Uniforms: span_max, the longest blur in texels,
+// reduce_mul and reduce_min, which keep it off flat areas.
+func NewFXAA() (*Pass, error) {
+	return NewPass(fxaaFS, map[string]interface{}{
+		"span_max":   float32(8),
+		"reduce_mul": float32(1.0 / 8),
+		"reduce_min": float32(1.0 / 128),
+	})
+}
+
+// maxBlurRadius is the size of the weights array in blurFS, less one.
+const maxBlurRadius = 31
+
+const blurFS = `#version 330
+
+in vec2 texcoord;
+
+uniform sampler2D source;
+uniform vec2 direction;
+uniform int radius;
+uniform float weights[32];
+
+out vec4 frag_colour;
+
+void main() {
+	vec4 sum = texture(source, texcoord) * weights[0];
+	for (int i = 1; i <= radius; i++) {
+		vec2 offset = direction * float(i);
+		sum += (texture(source, texcoord + offset) + texture(source, texcoord - offset)) * weights[i];
+	}
+	frag_colour = sum;
+}
+`
+
+// GaussianBlur blurs in two separable passes, horizontally into a scratch
+// target and vertically into the destination.
+type GaussianBlur struct {
+	Sigma float32 // standard deviation in texels, up to about 10
+
+	pass    *Pass
+	scratch *RenderTarget
+}
+
+// NewGaussianBlur creates a blur with a sigma of 2 texels.
+func NewGaussianBlur() (*GaussianBlur, error) {
+	pass, err := NewPass(blurFS, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &GaussianBlur{Sigma: 2, pass: pass}, nil
+}
+
+// gaussianWeights returns the normalised weights of the centre tap and
+// the taps on one side, covering three standard deviations.
+func gaussianWeights(sigma float32) []float32 {
+	radius := int(math.Ceil(3 * float64(sigma)))
+	if radius > maxBlurRadius {
+		radius = maxBlurRadius
+	}
+	if radius < 1 {
+		return []float32{1}
+	}
+	w := make([]float32, radius+1)
+	sum := float32(0)
+	for i := range w {
+		w[i] = float32(math.Exp(-float64(i*i) / (2 * float64(sigma*sigma))))
+		sum += w[i]
+		if i > 0 {
+			sum += w[i]
+		}
+	}
+	for i := range w {
+		w[i] /= sum
+	}
+	return w
+}
+
+// Apply blurs src into dst, which may have a different size.
+func (g *GaussianBlur) Apply(src *texture.Texture, dst *RenderTarget) error {
+	if err := ensureTarget(&g.scratch, src.Width, src.Height); err != nil {
+		return err
+	}
+	weights := gaussianWeights(g.Sigma)
+	g.pass.Uniforms["weights"] = weights
+	g.pass.Uniforms["radius"] = int32(len(weights) - 1)
+
+	g.pass.Uniforms["direction"] = [2]float32{1 / float32(src.Width), 0}
+	g.scratch.Bind()
+	g.pass.draw(src)
+
+	g.pass.Uniforms["direction"] = [2]float32{0, 1 / float32(src.Height)}
+	dst.Bind()
+	g.pass.draw(g.scratch.Colour[0])
+	return nil
+}
+
+// Delete frees the pass and the scratch target.
+func (g *GaussianBlur) Delete() {
+	g.pass.Delete()
+	if g.scratch != nil {
+		g.scratch.Delete()
+	}
+}
+
+// brightFS keeps what is brighter than the threshold, with a soft knee,
+// averaging four texels on the way down to half size.
+const brightFS = `#version 330
+
+in vec2 texcoord;
+
+uniform sampler2D source;
+uniform vec2 texel;
+uniform float threshold;
+uniform float knee;
+
+out vec4 frag_colour;
+
+void main() {
+	vec3 c = 0.25 * (texture(source, texcoord + vec2(-0.5, -0.5) * texel).rgb +
+		texture(source, texcoord + vec2(0.5, -0.5) * texel).rgb +
+		texture(source, texcoord + vec2(-0.5, 0.5) * texel).rgb +
+		texture(source, texcoord + vec2(0.5, 0.5) * texel).rgb);
+	float brightness = max(c.r, max(c.g, c.b));
+	float soft = clamp(brightness - threshold + knee, 0.0, 2.0 * knee);
+	soft = soft * soft / (4.0 * knee + 0.0001);
+	float contribution = max(soft, brightness - threshold) / max(brightness, 0.0001);
+	frag_colour = vec4(c * contribution, 1.0);
+}
+`
+
+const bloomFS = `#version 330
+
+in vec2 texcoord;
+
+uniform sampler2D source;
+uniform sampler2D bloom;
+uniform float intensity;
+
+out vec4 frag_colour;
+
+void main() {
+	vec4 c = texture(source, texcoord);
+	frag_colour = vec4(c.rgb + texture(bloom, texcoord).rgb * intensity, c.a);
+}
+`
+
+// Bloom makes bright areas glow: the parts above Threshold are blurred at
+// half size and added back. It belongs before tone mapping, on HDR input.
+type Bloom struct {
+	Threshold float32 // brightness where the glow starts
+	Knee      float32 // softness of the threshold
+	Intensity float32 // strength of the glow
+	Sigma     float32 // blur size in half size texels
+
+	bright    *Pass
+	composite *Pass
+	blur      *GaussianBlur
+	half      *RenderTarget
+	blurred   *RenderTarget
+}
+
+// NewBloom creates a bloom for HDR scenes, glowing above 1.
+func NewBloom() (*Bloom, error) {
+	bright, err := NewPass(brightFS, nil)
+	if err != nil {
+		return nil, err
+	}
+	composite, err := NewPass(bloomFS, nil)
+	if err != nil {
+		bright.Delete()
+		return nil, err
+	}
+	blur, err := NewGaussianBlur()
+	if err != nil {
+		bright.Delete()
+		composite.Delete()
+		return nil, err
+	}
+	return &Bloom{
+		Threshold: 1,
+		Knee:      0.5,
+		Intensity: 0.5,
+		Sigma:     4,
+		bright:    bright,
+		composite: composite,
+		blur:      blur,
+	}, nil
+}
+
+// Apply adds the glow of src to src into dst.
+func (b *Bloom) Apply(src *texture.Texture, dst *RenderTarget) error {
+	w, h := (src.Width+1)/2, (src.Height+1)/2
+	if err := ensureTarget(&b.half, w, h); err != nil {
+		return err
+	}
+	if err := ensureTarget(&b.blurred, w, h); err != nil {
+		return err
+	}
+
+	b.bright.Uniforms["threshold"] = b.Threshold
+	b.bright.Uniforms["knee"] = b.Knee
+	b.half.Bind()
+	b.bright.draw(src)
+
+	b.blur.Sigma = b.Sigma
+	if err := b.blur.Apply(b.half.Colour[0], b.blurred); err != nil {
+		return err
+	}
+
+	b.composite.Uniforms["bloom"] = b.blurred.Colour[0]
+	b.composite.Uniforms["intensity"] = b.Intensity
+	dst.Bind()
+	b.composite.draw(src)
+	return nil
+}
+
+// Delete frees the passes and the scratch targets.
+func (b *Bloom) Delete() {
+	b.bright.Delete()
+	b.composite.Delete()
+	b.blur.Delete()
+	for _, t := range []*RenderTarget{b.half, b.blurred} {
+		if t != nil {
+			t.Delete()
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package gfx
+
+import (
+	"github.com/go-gl/gl/v3.3-core/gl"
+)
+
+// fullscreenVS draws one triangle covering the screen from the vertex
+// index alone, no vertex data needed. texcoord runs 0 to 1 over the
+// visible part.
+const fullscreenVS = `#version 330
+
+out vec2 texcoord;
+
+void main() {
+	vec2 p = vec2((gl_VertexID << 1) & 2, gl_VertexID & 2);
+	texcoord = p;
+	gl_Position = vec4(p * 2.0 - 1.0, 0.0, 1.0);
+}
+`
+
+// fullscreenVao is empty, core profiles refuse to draw without one.
+var fullscreenVao uint32
+
+// drawFullscreen draws the fullscreen triangle with the current program.
+// Depth testing and culling are off while it draws and restored after.
+func drawFullscreen() {
+	if fullscreenVao == 0 {
+		gl.GenVertexArrays(1, &fullscreenVao)
+	}
+	depth, cull := gl.IsEnabled(gl.DEPTH_TEST), gl.IsEnabled(gl.CULL_FACE)
+	gl.Disable(gl.DEPTH_TEST)
+	gl.Disable(gl.CULL_FACE)
+
+	gl.BindVertexArray(fullscreenVao)
+	gl.DrawArrays(gl.TRIANGLES, 0, 3)
+
+	if depth {
+		gl.Enable(gl.DEPTH_TEST)
+	}
+	if cull {
+		gl.Enable(gl.CULL_FACE)
+	}
+}
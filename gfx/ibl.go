@@ -0,0 +1,600 @@
+package gfx
+
+import (
+	"fmt"
+	"github.com/ginuerzh/anton-gocode/common"
+	"github.com/ginuerzh/anton-gocode/texture"
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// brdfGLSL holds the microfacet terms shared by the shading and the IBL
+// precomputation, pulled in with #include "brdf.glsl".
+const brdfGLSL = `#ifndef PI
+#define PI 3.14159265359
+#endif
+
+float distribution_ggx(float n_dot_h, float roughness) {
+	float a = roughness * roughness;
+	float a2 = a * a;
+	float d = n_dot_h * n_dot_h * (a2 - 1.0) + 1.0;
+	return a2 / (PI * d * d);
+}
+
+float geometry_schlick_ggx(float n_dot_x, float k) {
+	return n_dot_x / (n_dot_x * (1.0 - k) + k);
+}
+
+// geometry_smith uses the k of Unreal's remapping for analytic lights
+float geometry_smith(float n_dot_v, float n_dot_l, float roughness) {
+	float r = roughness + 1.0;
+	float k = r * r / 8.0;
+	return geometry_schlick_ggx(n_dot_v, k) * geometry_schlick_ggx(n_dot_l, k);
+}
+
+vec3 fresnel_schlick(float cos_theta, vec3 f0) {
+	return f0 + (1.0 - f0) * pow(1.0 - cos_theta, 5.0);
+}
+
+vec3 fresnel_schlick_roughness(float cos_theta, vec3 f0, float roughness) {
+	return f0 + (max(vec3(1.0 - roughness), f0) - f0) * pow(1.0 - cos_theta, 5.0);
+}
+
+// hammersley is point i of n of a low discrepancy sequence on [0, 1)².
+vec2 hammersley(uint i, uint n) {
+	uint bits = i;
+	bits = (bits << 16u) | (bits >> 16u);
+	bits = ((bits & 0x55555555u) << 1u) | ((bits & 0xAAAAAAAAu) >> 1u);
+	bits = ((bits & 0x33333333u) << 2u) | ((bits & 0xCCCCCCCCu) >> 2u);
+	bits = ((bits & 0x0F0F0F0Fu) << 4u) | ((bits & 0xF0F0F0F0u) >> 4u);
+	bits = ((bits & 0x00FF00FFu) << 8u) | ((bits & 0xFF00FF00u) >> 8u);
+	return vec2(float(i) / float(n), float(bits) * 2.3283064365386963e-10);
+}
+
+// importance_sample_ggx turns xi into a half vector around n, distributed
+// like the GGX highlight of the roughness.
+vec3 importance_sample_ggx(vec2 xi, vec3 n, float roughness) {
+	float a = roughness * roughness;
+	float phi = 2.0 * PI * xi.x;
+	float cos_theta = sqrt((1.0 - xi.y) / (1.0 + (a * a - 1.0) * xi.y));
+	float sin_theta = sqrt(1.0 - cos_theta * cos_theta);
+	vec3 h = vec3(cos(phi) * sin_theta, sin(phi) * sin_theta, cos_theta);
+
+	vec3 up = abs(n.z) < 0.999 ? vec3(0.0, 0.0, 1.0) : vec3(1.0, 0.0, 0.0);
+	vec3 tangent = normalize(cross(up, n));
+	vec3 bitangent = cross(n, tangent);
+	return normalize(tangent * h.x + bitangent * h.y + n * h.z);
+}
+`
+
+// cubeFaceGLSL finds the direction of a texel while rendering into a cube
+// map face with the full screen triangle.
+const cubeFaceGLSL = `// cube_direction is the direction through texcoord of a face, 0 to 5
+// for +X, -X, +Y, -Y, +Z, -Z, following the cube map face selection
+vec3 cube_direction(int face, vec2 texcoord) {
+	vec2 c = texcoord * 2.0 - 1.0;
+	vec3 d;
+	if (face == 0) {
+		d = vec3(1.0, -c.y, -c.x);
+	} else if (face == 1) {
+		d = vec3(-1.0, -c.y, c.x);
+	} else if (face == 2) {
+		d = vec3(c.x, 1.0, c.y);
+	} else if (face == 3) {
+		d = vec3(c.x, -1.0, -c.y);
+	} else if (face == 4) {
+		d = vec3(c.x, -c.y, 1.0);
+	} else {
+		d = vec3(-c.x, -c.y, -1.0);
+	}
+	return normalize(d);
+}
+`
+
+func init() {
+	RegisterInclude("brdf.glsl", brdfGLSL)
+	RegisterInclude("cube_face.glsl", cubeFaceGLSL)
+}
+
+// irradianceFS integrates the cosine weighted light over the hemisphere
+// around each direction, for the diffuse part.
+const irradianceFS = `#version 330
+
+#include "brdf.glsl"
+#include "cube_face.glsl"
+
+in vec2 texcoord;
+
+uniform samplerCube environment;
+uniform int face;
+uniform float sample_delta; // step of both angles in radians
+uniform float source_lod;   // environment level matching the step
+
+out vec4 frag_colour;
+
+void main() {
+	vec3 n = cube_direction(face, texcoord);
+	vec3 up = abs(n.y) < 0.999 ? vec3(0.0, 1.0, 0.0) : vec3(0.0, 0.0, 1.0);
+	vec3 right = normalize(cross(up, n));
+	up = cross(n, right);
+
+	vec3 sum = vec3(0.0);
+	float count = 0.0;
+	for (float phi = 0.0; phi < 2.0 * PI; phi += sample_delta) {
+		for (float theta = 0.0; theta < 0.5 * PI; theta += sample_delta) {
+			vec3 t = vec3(sin(theta) * cos(phi), sin(theta) * sin(phi), cos(theta));
+			vec3 d = t.x * right + t.y * up + t.z * n;
+			sum += textureLod(environment, d, source_lod).rgb * cos(theta) * sin(theta);
+			count += 1.0;
+		}
+	}
+	frag_colour = vec4(PI * sum / count, 1.0);
+}
+`
+
+// prefilterFS convolves the environment with the GGX lobe of a roughness,
+// assuming the view along the normal. Samples with a low probability read
+// from smaller levels, which keeps bright spots from turning into dots.
+const prefilterFS = `#version 330
+
+#include "brdf.glsl"
+#include "cube_face.glsl"
+
+in vec2 texcoord;
+
+uniform samplerCube environment;
+uniform int face;
+uniform float roughness;
+uniform float environment_size; // face size of level 0
+uniform int sample_count;
+
+out vec4 frag_colour;
+
+void main() {
+	vec3 n = cube_direction(face, texcoord);
+	vec3 v = n;
+	float texel_angle = 4.0 * PI / (6.0 * environment_size * environment_size);
+
+	vec3 sum = vec3(0.0);
+	float weight = 0.0;
+	for (int i = 0; i < sample_count; i++) {
+		vec3 h = importance_sample_ggx(hammersley(uint(i), uint(sample_count)), n, roughness);
+		vec3 l = normalize(2.0 * dot(v, h) * h - v);
+		float n_dot_l = dot(n, l);
+		if (n_dot_l > 0.0) {
+			float n_dot_h = max(dot(n, h), 0.0);
+			float h_dot_v = max(dot(h, v), 0.0);
+			float pdf = distribution_ggx(n_dot_h, roughness) * n_dot_h / (4.0 * h_dot_v) + 0.0001;
+			float sample_angle = 1.0 / (float(sample_count) * pdf + 0.0001);
+			float lod = roughness == 0.0 ? 0.0 : max(0.5 * log2(sample_angle / texel_angle), 0.0);
+			sum += textureLod(environment, l, lod).rgb * n_dot_l;
+			weight += n_dot_l;
+		}
+	}
+	frag_colour = vec4(sum / max(weight, 0.0001), 1.0);
+}
+`
+
+// brdfFS integrates the split sum BRDF: the scale and bias to F0 by
+// n·v along x and roughness along y.
+const brdfFS = `#version 330
+
+#include "brdf.glsl"
+
+in vec2 texcoord;
+
+uniform int sample_count;
+
+out vec4 frag_colour;
+
+void main() {
+	float n_dot_v = max(texcoord.x, 0.001);
+	float roughness = texcoord.y;
+	vec3 v = vec3(sqrt(1.0 - n_dot_v * n_dot_v), 0.0, n_dot_v);
+	vec3 n = vec3(0.0, 0.0, 1.0);
+	// image based lighting remaps k differently from analytic lights
+	float k = roughness * roughness / 2.0;
+
+	float scale = 0.0;
+	float bias = 0.0;
+	for (int i = 0; i < sample_count; i++) {
+		vec3 h = importance_sample_ggx(hammersley(uint(i), uint(sample_count)), n, roughness);
+		vec3 l = normalize(2.0 * dot(v, h) * h - v);
+		float n_dot_l = max(l.z, 0.0);
+		if (n_dot_l > 0.0) {
+			float n_dot_h = max(h.z, 0.0);
+			float v_dot_h = max(dot(v, h), 0.0);
+			float g = geometry_schlick_ggx(n_dot_v, k) * geometry_schlick_ggx(n_dot_l, k);
+			float g_vis = g * v_dot_h / (n_dot_h * n_dot_v);
+			float fc = pow(1.0 - v_dot_h, 5.0);
+			scale += (1.0 - fc) * g_vis;
+			bias += fc * g_vis;
+		}
+	}
+	frag_colour = vec4(scale / float(sample_count), bias / float(sample_count), 0.0, 1.0);
+}
+`
+
+// IBLOptions sizes the precomputed maps of an IBL.
+type IBLOptions struct {
+	IrradianceSize    int32   // face size of the irradiance map
+	IrradianceStep    float32 // integration step in radians
+	PrefilteredSize   int32   // face size of the sharpest prefiltered level
+	PrefilteredLevels int32   // roughness steps from 0 to 1, one per level
+	BRDFSize          int32   // size of the BRDF lookup texture
+	Samples           int32   // GGX samples per texel
+}
+
+// DefaultIBLOptions are the sizes commonly used for real time IBL.
+func DefaultIBLOptions() IBLOptions {
+	return IBLOptions{
+		IrradianceSize:    32,
+		IrradianceStep:    0.025,
+		PrefilteredSize:   128,
+		PrefilteredLevels: 5,
+		BRDFSize:          512,
+		Samples:           1024,
+	}
+}
+
+// IBL is image based lighting from an environment cube map: the diffuse
+// irradiance, the specular environment prefiltered for increasing
+// roughness along its levels, and the BRDF lookup texture that completes
+// the split sum approximation.
+type IBL struct {
+	Environment *texture.Texture // the source, also usable as a skybox
+	Irradiance  *texture.Texture
+	Prefiltered *texture.Texture
+	BRDF        *texture.Texture
+	Intensity   float32
+}
+
+// NewIBL precomputes the maps from an environment cube map on the GPU.
+// The IBL takes over env and gives it a full mipmap chain, which the
+// filtering samples from.
+func NewIBL(env *texture.Texture, opts IBLOptions) (*IBL, error) {
+	env.GenerateMipmaps()
+	env.SetSampler(texture.Sampler{
+		MinFilter: gl.LINEAR_MIPMAP_LINEAR,
+		MagFilter: gl.LINEAR,
+		WrapS:     gl.CLAMP_TO_EDGE,
+		WrapT:     gl.CLAMP_TO_EDGE,
+		WrapR:     gl.CLAMP_TO_EDGE,
+	})
+	gl.Enable(gl.TEXTURE_CUBE_MAP_SEAMLESS)
+
+	ibl := &IBL{Environment: env, Intensity: 1}
+	var fbo uint32
+	gl.GenFramebuffers(1, &fbo)
+	defer gl.DeleteFramebuffers(1, &fbo)
+
+	var viewport [4]int32
+	var bound int32
+	gl.GetIntegerv(gl.VIEWPORT, &viewport[0])
+	gl.GetIntegerv(gl.FRAMEBUFFER_BINDING, &bound)
+	defer func() {
+		gl.BindFramebuffer(gl.FRAMEBUFFER, uint32(bound))
+		gl.Viewport(viewport[0], viewport[1], viewport[2], viewport[3])
+	}()
+
+	var err error
+	if ibl.Irradiance, err = ibl.renderIrradiance(fbo, opts); err == nil {
+		if ibl.Prefiltered, err = ibl.renderPrefiltered(fbo, opts); err == nil {
+			ibl.BRDF, err = renderBRDF(fbo, opts)
+		}
+	}
+	if err != nil {
+		ibl.Delete()
+		return nil, err
+	}
+	return ibl, nil
+}
+
+// newIBLCube creates an empty half float cube map with levels levels.
+func newIBLCube(size, levels int32) *texture.Texture {
+	t := &texture.Texture{
+		Target:         gl.TEXTURE_CUBE_MAP,
+		Width:          size,
+		Height:         size,
+		Levels:         levels,
+		InternalFormat: gl.RGB16F,
+	}
+	gl.GenTextures(1, &t.ID)
+	gl.BindTexture(gl.TEXTURE_CUBE_MAP, t.ID)
+	for l := int32(0); l < levels; l++ {
+		s := size >> uint(l)
+		if s < 1 {
+			s = 1
+		}
+		for f := uint32(0); f < 6; f++ {
+			gl.TexImage2D(gl.TEXTURE_CUBE_MAP_POSITIVE_X+f, l, gl.RGB16F, s, s, 0, gl.RGB, gl.FLOAT, nil)
+		}
+	}
+	gl.TexParameteri(gl.TEXTURE_CUBE_MAP, gl.TEXTURE_MAX_LEVEL, levels-1)
+	t.SetSampler(texture.Sampler{
+		MinFilter: gl.LINEAR_MIPMAP_LINEAR,
+		MagFilter: gl.LINEAR,
+		WrapS:     gl.CLAMP_TO_EDGE,
+		WrapT:     gl.CLAMP_TO_EDGE,
+		WrapR:     gl.CLAMP_TO_EDGE,
+	})
+	return t
+}
+
+// renderFaces draws the program in use into every face of a cube map
+// level, with the face index in faceLoc.
+func renderFaces(fbo uint32, t *texture.Texture, level int32, faceLoc int32) error {
+	size := t.Width >> uint(level)
+	if size < 1 {
+		size = 1
+	}
+	gl.BindFramebuffer(gl.FRAMEBUFFER, fbo)
+	gl.Viewport(0, 0, size, size)
+	for f := uint32(0); f < 6; f++ {
+		gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_CUBE_MAP_POSITIVE_X+f, t.ID, level)
+		if f == 0 {
+			if err := checkFramebuffer("IBL cube face"); err != nil {
+				return err
+			}
+		}
+		gl.Uniform1i(faceLoc, int32(f))
+		drawFullscreen()
+	}
+	return nil
+}
+
+func (ibl *IBL) renderIrradiance(fbo uint32, opts IBLOptions) (*texture.Texture, error) {
+	program, err := NewProgram(fullscreenVS, irradianceFS)
+	if err != nil {
+		return nil, err
+	}
+	defer gl.DeleteProgram(program)
+
+	// read from the level whose texels are about as large as the step
+	step := float64(opts.IrradianceStep)
+	size := float64(ibl.Environment.Width)
+	lod := 0.5 * math.Log2(step*step/(4*math.Pi/(6*size*size)))
+
+	t := newIBLCube(opts.IrradianceSize, 1)
+	gl.UseProgram(program)
+	gl.Uniform1i(uniform(program, "environment"), 0)
+	gl.Uniform1f(uniform(program, "sample_delta"), opts.IrradianceStep)
+	gl.Uniform1f(uniform(program, "source_lod"), float32(math.Max(lod, 0)))
+	ibl.Environment.Bind(0)
+	if err := renderFaces(fbo, t, 0, uniform(program, "face")); err != nil {
+		t.Delete()
+		return nil, err
+	}
+	return t, nil
+}
+
+func (ibl *IBL) renderPrefiltered(fbo uint32, opts IBLOptions) (*texture.Texture, error) {
+	program, err := NewProgram(fullscreenVS, prefilterFS)
+	if err != nil {
+		return nil, err
+	}
+	defer gl.DeleteProgram(program)
+
+	t := newIBLCube(opts.PrefilteredSize, opts.PrefilteredLevels)
+	gl.UseProgram(program)
+	gl.Uniform1i(uniform(program, "environment"), 0)
+	gl.Uniform1f(uniform(program, "environment_size"), float32(ibl.Environment.Width))
+	gl.Uniform1i(uniform(program, "sample_count"), opts.Samples)
+	ibl.Environment.Bind(0)
+	roughnessLoc := uniform(program, "roughness")
+	faceLoc := uniform(program, "face")
+	for l := int32(0); l < opts.PrefilteredLevels; l++ {
+		roughness := float32(0)
+		if opts.PrefilteredLevels > 1 {
+			roughness = float32(l) / float32(opts.PrefilteredLevels-1)
+		}
+		gl.Uniform1f(roughnessLoc, roughness)
+		if err := renderFaces(fbo, t, l, faceLoc); err != nil {
+			t.Delete()
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+func renderBRDF(fbo uint32, opts IBLOptions) (*texture.Texture, error) {
+	program, err := NewProgram(fullscreenVS, brdfFS)
+	if err != nil {
+		return nil, err
+	}
+	defer gl.DeleteProgram(program)
+
+	t := newTargetTexture(opts.BRDFSize, opts.BRDFSize, gl.RG16F)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, fbo)
+	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, t.ID, 0)
+	if err := checkFramebuffer("BRDF lookup"); err != nil {
+		t.Delete()
+		return nil, err
+	}
+	gl.Viewport(0, 0, t.Width, t.Height)
+	gl.UseProgram(program)
+	gl.Uniform1i(uniform(program, "sample_count"), opts.Samples)
+	drawFullscreen()
+	return t, nil
+}
+
+// LoadIBL loads an equirectangular panorama, see texture.LoadEquirect,
+// and computes its IBL with environment faces of faceSize texels. With a
+// cacheDir the maps are kept there as KTX files named after the panorama
+// and loaded from them as long as they are newer than it and of the
+// sizes asked for. Changing only the sample count does not invalidate
+// them.
+func LoadIBL(filename string, faceSize int, cacheDir string, opts IBLOptions) (*IBL, error) {
+	if cacheDir != "" {
+		if ibl, err := loadCachedIBL(filename, faceSize, cacheDir, opts); err == nil {
+			return ibl, nil
+		} else if !os.IsNotExist(err) {
+			common.GLog("IBL cache for %s not used: %v\n", filename, err)
+		}
+	}
+
+	env, err := texture.LoadEquirect(filename, faceSize, texture.Options{})
+	if err != nil {
+		return nil, err
+	}
+	ibl, err := NewIBL(env, opts)
+	if err != nil {
+		env.Delete()
+		return nil, err
+	}
+	if cacheDir != "" {
+		if err := ibl.Save(cacheDir, iblCacheName(filename)); err != nil {
+			common.GLogErr("ERROR: writing the IBL cache for %s: %v\n", filename, err)
+		}
+	}
+	return ibl, nil
+}
+
+// iblCacheName is the panorama file name without its extension.
+func iblCacheName(filename string) string {
+	base := filepath.Base(filename)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// iblFiles are the cache file names of the maps.
+func iblFiles(dir, name string) [4]string {
+	return [4]string{
+		filepath.Join(dir, name+".env.ktx"),
+		filepath.Join(dir, name+".irradiance.ktx"),
+		filepath.Join(dir, name+".prefiltered.ktx"),
+		filepath.Join(dir, name+".brdf.ktx"),
+	}
+}
+
+func loadCachedIBL(filename string, faceSize int, dir string, opts IBLOptions) (*IBL, error) {
+	src, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	files := iblFiles(dir, iblCacheName(filename))
+	var data [4]*texture.Data
+	for i, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			return nil, err
+		}
+		if info.ModTime().Before(src.ModTime()) {
+			return nil, fmt.Errorf("%s is older than %s", f, filename)
+		}
+		if data[i], err = texture.LoadData(f); err != nil {
+			return nil, err
+		}
+	}
+
+	want := [4][2]int{
+		{faceSize, 0},
+		{int(opts.IrradianceSize), 1},
+		{int(opts.PrefilteredSize), int(opts.PrefilteredLevels)},
+		{int(opts.BRDFSize), 1},
+	}
+	for i, d := range data {
+		if d.Width != want[i][0] || (want[i][1] > 0 && len(d.Levels) != want[i][1]) {
+			return nil, fmt.Errorf("%s has other sizes", files[i])
+		}
+	}
+
+	cube := texture.Options{Sampler: texture.Sampler{
+		MinFilter: gl.LINEAR_MIPMAP_LINEAR,
+		MagFilter: gl.LINEAR,
+	}}
+	flat := texture.Options{Sampler: texture.Sampler{
+		MinFilter: gl.LINEAR,
+		MagFilter: gl.LINEAR,
+		WrapS:     gl.CLAMP_TO_EDGE,
+		WrapT:     gl.CLAMP_TO_EDGE,
+	}}
+	ibl := &IBL{Intensity: 1}
+	targets := []**texture.Texture{&ibl.Environment, &ibl.Irradiance, &ibl.Prefiltered, &ibl.BRDF}
+	for i, d := range data {
+		opts := cube
+		if i == 3 {
+			opts = flat
+		}
+		if *targets[i], err = d.Upload(opts); err != nil {
+			ibl.Delete()
+			return nil, err
+		}
+	}
+	return ibl, nil
+}
+
+// Save writes the maps to dir as name.env.ktx, name.irradiance.ktx,
+// name.prefiltered.ktx and name.brdf.ktx, creating dir if needed.
+func (ibl *IBL) Save(dir, name string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	files := iblFiles(dir, name)
+	for i, t := range []*texture.Texture{ibl.Environment, ibl.Irradiance, ibl.Prefiltered, ibl.BRDF} {
+		format := uint32(gl.RGB)
+		if i == 3 {
+			format = gl.RG
+		}
+		if err := texture.SaveKTX(files[i], texture.ReadTexture(t, format, gl.HALF_FLOAT)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete frees the maps, the environment included.
+func (ibl *IBL) Delete() {
+	for _, t := range []*texture.Texture{ibl.Environment, ibl.Irradiance, ibl.Prefiltered, ibl.BRDF} {
+		if t != nil {
+			t.Delete()
+		}
+	}
+}
+
+// IBLUniforms holds the locations of the IBL uniforms of pbr.glsl.
+type IBLUniforms struct {
+	irradiance, prefiltered, brdf int32
+	lod, intensity, rotation      int32
+}
+
+// NewIBLUniforms looks up the IBL uniforms of a program that includes
+// pbr.glsl.
+func NewIBLUniforms(program uint32) IBLUniforms {
+	return IBLUniforms{
+		irradiance:  uniform(program, "irradiance_map"),
+		prefiltered: uniform(program, "prefiltered_map"),
+		brdf:        uniform(program, "brdf_lut"),
+		lod:         uniform(program, "prefiltered_lod"),
+		intensity:   uniform(program, "ibl_intensity"),
+		rotation:    uniform(program, "ibl_rotation"),
+	}
+}
+
+// Set binds the maps of ibl, which may be nil, to the three texture units
+// from unit on. The view matrix turns the view space lookups of the
+// shading back to the world space of the maps.
+func (u IBLUniforms) Set(ibl *IBL, view [16]float32, unit uint32) {
+	gl.Uniform1i(u.irradiance, int32(unit))
+	gl.Uniform1i(u.prefiltered, int32(unit+1))
+	gl.Uniform1i(u.brdf, int32(unit+2))
+	if ibl == nil {
+		gl.Uniform1f(u.intensity, 0)
+		return
+	}
+
+	// the inverse of the rotation is its transpose
+	rotation := [9]float32{
+		view[0], view[4], view[8],
+		view[1], view[5], view[9],
+		view[2], view[6], view[10],
+	}
+	gl.UniformMatrix3fv(u.rotation, 1, false, &rotation[0])
+	gl.Uniform1f(u.lod, float32(ibl.Prefiltered.Levels-1))
+	gl.Uniform1f(u.intensity, ibl.Intensity)
+	ibl.Irradiance.Bind(unit)
+	ibl.Prefiltered.Bind(unit + 1)
+	ibl.BRDF.Bind(unit + 2)
+}
@@ -0,0 +1,67 @@
+package gfx
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// includes holds the GLSL snippets shaders can pull in with a line like
+// #include "lighting.glsl". GLSL has no includes of its own, NewProgram
+// expands them before compiling.
+var includes = map[string]string{}
+
+// RegisterInclude makes a GLSL snippet available to #include.
+func RegisterInclude(name, source string) {
+	includes[name] = source
+}
+
+// ExpandIncludes replaces #include "name" lines with the registered
+// snippets, recursively. Every snippet is pulled in once, later includes
+// of it are dropped.
+func ExpandIncludes(source string) (string, error) {
+	return expandIncludes(source, map[string]bool{})
+}
+
+func expandIncludes(source string, seen map[string]bool) (string, error) {
+	if !strings.Contains(source, "#include") {
+		return source, nil
+	}
+	lines := strings.Split(source, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "#include") {
+			continue
+		}
+		name := strings.Trim(strings.TrimSpace(strings.TrimPrefix(trimmed, "#include")), `"<>`)
+		snippet, ok := includes[name]
+		if !ok {
+			return "", fmt.Errorf("line %d: unknown include %q", i+1, name)
+		}
+		if seen[name] {
+			lines[i] = ""
+			continue
+		}
+		seen[name] = true
+		expanded, err := expandIncludes(snippet, seen)
+		if err != nil {
+			return "", fmt.Errorf("%s: %v", name, err)
+		}
+		lines[i] = expanded
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// LoadProgram compiles a program from shader files, which may use the
+// registered includes.
+func LoadProgram(vertexFile, fragmentFile string) (uint32, error) {
+	vs, err := ioutil.ReadFile(vertexFile)
+	if err != nil {
+		return 0, err
+	}
+	fs, err := ioutil.ReadFile(fragmentFile)
+	if err != nil {
+		return 0, err
+	}
+	return NewProgram(string(vs), string(fs))
+}
@@ -0,0 +1,258 @@
+package gfx
+
+import (
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"unsafe"
+)
+
+/* per-instance attribute locations, after the vertex attributes */
+const (
+	InstanceModelLoc  = 5 // a mat4 takes four locations, 5 to 8
+	InstanceColourLoc = 9
+)
+
+// Instance is the per-instance data of an Instanced mesh, as the vertex
+// shader sees it: a mat4 instance_model and a vec4 instance_colour.
+type Instance struct {
+	Model  [16]float32
+	Colour [4]float32
+}
+
+const instanceSize = int(unsafe.Sizeof(Instance{}))
+
+// InstanceAttribs returns the attributes of Instance in buffer, advancing
+// once per instance. A mat4 input is fed as four vec4 columns at
+// consecutive locations.
+func InstanceAttribs(buffer int) []Attrib {
+	var attribs []Attrib
+	for col := 0; col < 4; col++ {
+		attribs = append(attribs, Attrib{
+			Location: InstanceModelLoc + uint32(col), Size: 4, Type: gl.FLOAT,
+			Buffer: buffer, Offset: col * 16, Divisor: 1,
+		})
+	}
+	attribs[0].Name = "instance_model"
+	return append(attribs, Attrib{
+		Name: "instance_colour", Location: InstanceColourLoc, Size: 4, Type: gl.FLOAT,
+		Buffer: buffer, Offset: 64, Divisor: 1,
+	})
+}
+
+// InstanceUpdate is how Instanced hands new instance data to the driver.
+type InstanceUpdate int
+
+const (
+	// OrphanInstances replaces the storage with glBufferData(nil) and
+	// fills it with glBufferSubData, so the driver never waits for the
+	// draws still reading the old data.
+	OrphanInstances InstanceUpdate = iota
+	// MapInstances maps the buffer with MAP_INVALIDATE_BUFFER_BIT, which
+	// orphans it as well, and copies straight into the mapping.
+	MapInstances
+	// RingInstances keeps several frames of instances in one buffer and
+	// writes each update into the next region with an unsynchronized
+	// mapping, waiting on a fence only if the GPU still reads it. It is
+	// what persistent mapping would do: glBufferStorage is GL 4.4 and not
+	// in the 3.3 bindings, so the region is mapped and unmapped each time.
+	RingInstances
+)
+
+// ringRegions is how many frames of instances RingInstances keeps.
+const ringRegions = 3
+
+// Instanced draws many copies of a mesh with one call. The mesh buffers
+// are shared, Instanced keeps a VAO of its own with the instance buffer
+// added, so a mesh can have several Instanced and still be drawn alone.
+//
+// Fill Instances, call Upload when they changed and Draw as often as
+// needed. The buffer grows by doubling when there are more instances
+// than it holds.
+type Instanced struct {
+	Mesh      *Mesh
+	Instances []Instance
+	Layout    VertexLayout // the mesh layout plus InstanceAttribs
+
+	update   InstanceUpdate
+	vao      uint32
+	vbo      uint32
+	capacity int   // instances per region
+	count    int32 // instances uploaded
+	region   int
+	fences   [ringRegions]uintptr
+}
+
+// NewInstanced prepares m for instancing, with room for capacity
+// instances to start with. Like CreateVao it leaves the VAO bound.
+func NewInstanced(m *Mesh, capacity int, update InstanceUpdate) *Instanced {
+	if capacity < 1 {
+		capacity = 1
+	}
+	in := &Instanced{Mesh: m, update: update}
+
+	buffers := m.Layout.Buffers()
+	in.Layout.Attribs = append(append([]Attrib(nil), m.Layout.Attribs...), InstanceAttribs(buffers)...)
+	for i := 0; i < buffers; i++ {
+		in.Layout.Strides = append(in.Layout.Strides, m.Layout.Stride(i))
+	}
+	in.Layout.Strides = append(in.Layout.Strides, int32(instanceSize))
+
+	gl.GenBuffers(1, &in.vbo)
+	in.allocate(capacity)
+	in.vao = in.Layout.CreateVao(append(append([]uint32(nil), m.Vbos...), in.vbo)...)
+	if m.Ebo != 0 {
+		gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, m.Ebo)
+	}
+	return in
+}
+
+func (in *Instanced) regions() int {
+	if in.update == RingInstances {
+		return ringRegions
+	}
+	return 1
+}
+
+// allocate gives the instance buffer new storage for capacity instances
+// per region. Pending fences refer to the old storage and are dropped.
+func (in *Instanced) allocate(capacity int) {
+	in.deleteFences()
+	in.capacity = capacity
+	in.region = 0
+	gl.BindBuffer(gl.ARRAY_BUFFER, in.vbo)
+	gl.BufferData(gl.ARRAY_BUFFER, capacity*instanceSize*in.regions(), nil, gl.STREAM_DRAW)
+}
+
+func (in *Instanced) deleteFences() {
+	for i, f := range in.fences {
+		if f != 0 {
+			gl.DeleteSync(f)
+			in.fences[i] = 0
+		}
+	}
+}
+
+// pointInstances points the instance attributes at the region starting
+// at offset.
+func (in *Instanced) pointInstances(offset int) {
+	l := VertexLayout{Attribs: InstanceAttribs(0), Strides: []int32{int32(instanceSize)}}
+	for i := range l.Attribs {
+		l.Attribs[i].Offset += offset
+	}
+	gl.BindVertexArray(in.vao)
+	l.Apply(in.vbo)
+}
+
+// Upload copies Instances into the instance buffer, growing it first if
+// they do not fit.
+func (in *Instanced) Upload() {
+	n := len(in.Instances)
+	in.count = int32(n)
+	if n == 0 {
+		return
+	}
+	if n > in.capacity {
+		capacity := in.capacity
+		for capacity < n {
+			capacity *= 2
+		}
+		in.allocate(capacity)
+	}
+	size := n * instanceSize
+
+	gl.BindBuffer(gl.ARRAY_BUFFER, in.vbo)
+	switch in.update {
+	case OrphanInstances:
+		gl.BufferData(gl.ARRAY_BUFFER, in.capacity*instanceSize, nil, gl.STREAM_DRAW)
+		gl.BufferSubData(gl.ARRAY_BUFFER, 0, size, gl.Ptr(&in.Instances[0]))
+	case MapInstances:
+		p := gl.MapBufferRange(gl.ARRAY_BUFFER, 0, size, gl.MAP_WRITE_BIT|gl.MAP_INVALIDATE_BUFFER_BIT)
+		in.copyTo(p, size)
+	case RingInstances:
+		in.region = (in.region + 1) % ringRegions
+		if f := in.fences[in.region]; f != 0 {
+			// a second is plenty, the region was drawn two frames ago
+			gl.ClientWaitSync(f, gl.SYNC_FLUSH_COMMANDS_BIT, 1e9)
+			gl.DeleteSync(f)
+			in.fences[in.region] = 0
+		}
+		offset := in.region * in.capacity * instanceSize
+		p := gl.MapBufferRange(gl.ARRAY_BUFFER, offset, size,
+			gl.MAP_WRITE_BIT|gl.MAP_INVALIDATE_RANGE_BIT|gl.MAP_UNSYNCHRONIZED_BIT)
+		in.copyTo(p, size)
+		in.pointInstances(offset)
+	}
+	gl.BindBuffer(gl.ARRAY_BUFFER, 0)
+}
+
+// copyTo copies size bytes of Instances into the mapping at p and unmaps
+// the buffer.
+func (in *Instanced) copyTo(p unsafe.Pointer, size int) {
+	if p == nil {
+		return
+	}
+	dst := (*[1 << 30]byte)(p)[:size:size]
+	src := (*[1 << 30]byte)(unsafe.Pointer(&in.Instances[0]))[:size:size]
+	copy(dst, src)
+	gl.UnmapBuffer(gl.ARRAY_BUFFER)
+}
+
+// Count is the number of instances the last Upload left in the buffer.
+func (in *Instanced) Count() int {
+	return int(in.count)
+}
+
+// Draw draws the whole mesh once per uploaded instance.
+func (in *Instanced) Draw() {
+	if in.count == 0 {
+		return
+	}
+	m := in.Mesh
+	gl.BindVertexArray(in.vao)
+	if m.IndexType == 0 {
+		gl.DrawArraysInstanced(m.Mode, 0, m.Count, in.count)
+	} else {
+		gl.DrawElementsInstanced(m.Mode, m.Count, m.IndexType, gl.PtrOffset(0), in.count)
+	}
+	in.fence()
+}
+
+// DrawSubmesh draws one submesh of the mesh once per uploaded instance.
+func (in *Instanced) DrawSubmesh(i int) {
+	if in.count == 0 {
+		return
+	}
+	m := in.Mesh
+	s := m.Submeshes[i]
+	gl.BindVertexArray(in.vao)
+	if m.IndexType == 0 {
+		gl.DrawArraysInstanced(m.Mode, int32(s.Start)+s.BaseVertex, int32(s.Count), in.count)
+	} else {
+		gl.DrawElementsInstancedBaseVertex(m.Mode, int32(s.Count), m.IndexType,
+			gl.PtrOffset(s.Start*m.indexSize()), in.count, s.BaseVertex)
+	}
+	in.fence()
+}
+
+// fence marks the current region as read by the draws issued so far.
+func (in *Instanced) fence() {
+	if in.update != RingInstances {
+		return
+	}
+	if f := in.fences[in.region]; f != 0 {
+		gl.DeleteSync(f)
+	}
+	in.fences[in.region] = gl.FenceSync(gl.SYNC_GPU_COMMANDS_COMPLETE, 0)
+}
+
+// Delete frees the VAO and the instance buffer, the mesh stays.
+func (in *Instanced) Delete() {
+	in.deleteFences()
+	if in.vbo != 0 {
+		gl.DeleteBuffers(1, &in.vbo)
+		in.vbo = 0
+	}
+	if in.vao != 0 {
+		gl.DeleteVertexArrays(1, &in.vao)
+		in.vao = 0
+	}
+}
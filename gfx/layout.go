@@ -0,0 +1,202 @@
+package gfx
+
+import (
+	"fmt"
+	"github.com/ginuerzh/anton-gocode/common"
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"strings"
+)
+
+// Attrib describes one vertex attribute and where it lives in its buffer.
+type Attrib struct {
+	Name       string // shader input name, checked by Validate when set
+	Location   uint32
+	Size       int32  // components, 1 to 4
+	Type       uint32 // gl.FLOAT, gl.UNSIGNED_BYTE, gl.INT, ...
+	Normalized bool   // map integer data to [0, 1] or [-1, 1]
+	Integer    bool   // feed an int/uint shader input through VertexAttribIPointer
+	Buffer     int    // index into the buffers handed to Apply
+	Offset     int    // byte offset inside a vertex
+	Divisor    uint32 // instancing, 0 advances per vertex
+}
+
+// VertexLayout describes how the attributes of a vertex are spread over one
+// or more buffers.
+type VertexLayout struct {
+	Attribs []Attrib
+	Strides []int32 // bytes between vertices, per buffer
+}
+
+// Interleaved packs the attributes one after another in a single buffer,
+// filling in offsets and the stride.
+func Interleaved(attribs ...Attrib) VertexLayout {
+	l := VertexLayout{Attribs: attribs}
+	offset := 0
+	for i := range l.Attribs {
+		a := &l.Attribs[i]
+		a.Buffer = 0
+		a.Offset = offset
+		offset += a.Bytes()
+	}
+	l.Strides = []int32{int32(offset)}
+	return l
+}
+
+// Planar puts every attribute tightly packed into a buffer of its own, in
+// the order given.
+func Planar(attribs ...Attrib) VertexLayout {
+	l := VertexLayout{Attribs: attribs}
+	for i := range l.Attribs {
+		a := &l.Attribs[i]
+		a.Buffer = i
+		a.Offset = 0
+		l.Strides = append(l.Strides, int32(a.Bytes()))
+	}
+	return l
+}
+
+func typeSize(typ uint32) int {
+	switch typ {
+	case gl.BYTE, gl.UNSIGNED_BYTE:
+		return 1
+	case gl.SHORT, gl.UNSIGNED_SHORT, gl.HALF_FLOAT:
+		return 2
+	case gl.DOUBLE:
+		return 8
+	}
+	return 4
+}
+
+// Bytes is the size of one attribute value.
+func (a Attrib) Bytes() int {
+	switch a.Type {
+	case gl.INT_2_10_10_10_REV, gl.UNSIGNED_INT_2_10_10_10_REV:
+		return 4
+	}
+	return typeSize(a.Type) * int(a.Size)
+}
+
+// Buffers returns how many buffers the layout needs.
+func (l *VertexLayout) Buffers() int {
+	n := len(l.Strides)
+	for _, a := range l.Attribs {
+		if a.Buffer+1 > n {
+			n = a.Buffer + 1
+		}
+	}
+	return n
+}
+
+// Stride returns the distance between two vertices of a buffer.
+func (l *VertexLayout) Stride(buffer int) int32 {
+	if buffer < len(l.Strides) {
+		return l.Strides[buffer]
+	}
+	return 0
+}
+
+// Apply sets up the attribute pointers of the bound VAO.
+func (l *VertexLayout) Apply(buffers ...uint32) {
+	for _, a := range l.Attribs {
+		gl.BindBuffer(gl.ARRAY_BUFFER, buffers[a.Buffer])
+		gl.EnableVertexAttribArray(a.Location)
+		if a.Integer {
+			gl.VertexAttribIPointer(a.Location, a.Size, a.Type,
+				l.Stride(a.Buffer), gl.PtrOffset(a.Offset))
+		} else {
+			gl.VertexAttribPointer(a.Location, a.Size, a.Type, a.Normalized,
+				l.Stride(a.Buffer), gl.PtrOffset(a.Offset))
+		}
+		gl.VertexAttribDivisor(a.Location, a.Divisor)
+	}
+}
+
+// CreateVao creates a VAO for the layout with buffers as sources. The VAO
+// is left bound so an element buffer can be attached.
+func (l *VertexLayout) CreateVao(buffers ...uint32) (vao uint32) {
+	gl.GenVertexArrays(1, &vao)
+	gl.BindVertexArray(vao)
+	l.Apply(buffers...)
+
+	return
+}
+
+func isIntegerType(typ uint32) bool {
+	switch typ {
+	case gl.INT, gl.INT_VEC2, gl.INT_VEC3, gl.INT_VEC4,
+		gl.UNSIGNED_INT, gl.UNSIGNED_INT_VEC2, gl.UNSIGNED_INT_VEC3, gl.UNSIGNED_INT_VEC4:
+		return true
+	}
+	return false
+}
+
+// locationsUsed is how many consecutive locations a shader input takes.
+func locationsUsed(typ uint32) int32 {
+	switch typ {
+	case gl.FLOAT_MAT2:
+		return 2
+	case gl.FLOAT_MAT3:
+		return 3
+	case gl.FLOAT_MAT4:
+		return 4
+	}
+	return 1
+}
+
+// Validate checks the layout against the attributes program actually uses:
+// every input must be fed, names must sit at the described locations and
+// integer inputs need integer attributes. Attributes must name one of the
+// buffers in Strides.
+func (l *VertexLayout) Validate(program uint32) error {
+	byLoc := make(map[uint32]Attrib)
+	for _, a := range l.Attribs {
+		if _, ok := byLoc[a.Location]; ok {
+			return fmt.Errorf("layout: location %d used twice", a.Location)
+		}
+		if a.Buffer < 0 || a.Buffer >= len(l.Strides) || a.Size < 1 || a.Size > 4 {
+			return fmt.Errorf("layout: bad attribute at location %d", a.Location)
+		}
+		byLoc[a.Location] = a
+	}
+
+	var errs []string
+	for _, v := range common.ActiveAttribs(program) {
+		if strings.HasPrefix(v.Name, "gl_") {
+			continue
+		}
+		for i := int32(0); i < locationsUsed(v.Type)*v.Size; i++ {
+			loc := uint32(v.Location + i)
+			a, ok := byLoc[loc]
+			if !ok {
+				errs = append(errs, fmt.Sprintf("%s %s at location %d is not fed",
+					v.TypeName(), v.Name, loc))
+				continue
+			}
+			if a.Name != "" && i == 0 && a.Name != v.Name {
+				errs = append(errs, fmt.Sprintf("location %d is %s in the program, %s in the layout",
+					loc, v.Name, a.Name))
+			}
+			if isIntegerType(v.Type) != a.Integer {
+				errs = append(errs, fmt.Sprintf("%s %s at location %d: integer mismatch",
+					v.TypeName(), v.Name, loc))
+			}
+		}
+	}
+	for _, a := range l.Attribs {
+		if a.Name == "" {
+			continue
+		}
+		loc := gl.GetAttribLocation(program, gl.Str(a.Name+"\x00"))
+		if loc >= 0 && uint32(loc) != a.Location {
+			errs = append(errs, fmt.Sprintf("%s is at location %d in the program, %d in the layout",
+				a.Name, loc, a.Location))
+		}
+	}
+
+	if len(errs) > 0 {
+		err := fmt.Errorf("layout does not match program %d:\n%s", program, strings.Join(errs, "\n"))
+		common.GLogErr("ERROR: %s\n", err)
+		return err
+	}
+	return nil
+}
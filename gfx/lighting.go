@@ -0,0 +1,318 @@
+package gfx
+
+import (
+	"errors"
+	"github.com/ginuerzh/anton-gocode/mesh"
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"math"
+)
+
+// MaxLights is the size of the light array in the Lighting block.
+const MaxLights = 64
+
+// LightingBinding is the uniform buffer binding point of the Lighting
+// block.
+const LightingBinding = 0
+
+// lightsGLSL declares the Lighting block and how light reaches a point,
+// shared by the shading models. Lighting happens in view space: the
+// lights are uploaded transformed by the view matrix and the camera sits
+// at the origin.
+const lightsGLSL = `#define MAX_LIGHTS 64
+#define DIRECTIONAL_LIGHT 0
+#define POINT_LIGHT 1
+#define SPOT_LIGHT 2
+
+struct Light {
+	vec4 position;    // xyz in view space, w the light type
+	vec4 direction;   // xyz the way the light shines, w cos of the outer cone
+	vec4 colour;      // rgb times intensity, a cos of the inner cone
+	vec4 attenuation; // constant, linear, quadratic, range
+};
+
+layout(std140) uniform Lighting {
+	vec4 ambient_light;
+	int light_count;
+	Light lights[MAX_LIGHTS];
+};
+
+float light_attenuation(Light light, float dist) {
+	vec4 a = light.attenuation;
+	float att = 1.0 / max(a.x + a.y * dist + a.z * dist * dist, 0.0001);
+	// fade out to nothing at the range, so nothing pops when a light is
+	// culled there
+	if (a.w > 0.0) {
+		float f = clamp(1.0 - pow(dist / a.w, 4.0), 0.0, 1.0);
+		att *= f * f;
+	}
+	return att;
+}
+
+// light_incoming returns the direction towards the light and how much of
+// it reaches position.
+float light_incoming(Light light, vec3 position, out vec3 l) {
+	int kind = int(light.position.w);
+	if (kind == DIRECTIONAL_LIGHT) {
+		l = -normalize(light.direction.xyz);
+		return 1.0;
+	}
+	vec3 d = light.position.xyz - position;
+	float dist = length(d);
+	l = d / dist;
+	float att = light_attenuation(light, dist);
+	if (kind == SPOT_LIGHT) {
+		float cos_angle = dot(-l, normalize(light.direction.xyz));
+		att *= smoothstep(light.direction.w, light.colour.a, cos_angle);
+	}
+	return att;
+}
+
+#ifndef LIGHT_SHADOW
+// light_shadow is how much of light i reaches position, unshadowed unless
+// shadows.glsl is included first.
+float light_shadow(int i, vec3 position, vec3 normal) {
+	return 1.0;
+}
+#endif
+`
+
+// lightingGLSL is the Blinn-Phong forward lighting, pulled into shaders
+// with #include "lighting.glsl".
+const lightingGLSL = `#include "lights.glsl"
+
+struct MaterialParams {
+	vec3 ambient;
+	vec3 diffuse;
+	vec3 specular;
+	vec3 emissive;
+	float shininess;
+};
+
+uniform MaterialParams material;
+
+// blinn_phong_light is the diffuse and specular light of one light.
+vec3 blinn_phong_light(Light light, vec3 position, vec3 n, vec3 v, vec3 albedo) {
+	vec3 l;
+	float att = light_incoming(light, position, l);
+	float n_dot_l = max(dot(n, l), 0.0);
+	if (att <= 0.0 || n_dot_l <= 0.0) {
+		return vec3(0.0);
+	}
+	vec3 h = normalize(l + v);
+	float spec = pow(max(dot(n, h), 0.0), material.shininess);
+	return (material.diffuse * albedo * n_dot_l + material.specular * spec) * light.colour.rgb * att;
+}
+
+// blinn_phong shades a point in view space with all lights. albedo
+// multiplies the diffuse material colour, vec3(1.0) without a texture.
+vec3 blinn_phong(vec3 position, vec3 normal, vec3 albedo) {
+	vec3 n = normalize(normal);
+	vec3 v = normalize(-position);
+	vec3 colour = material.emissive + material.ambient * albedo * ambient_light.rgb;
+	for (int i = 0; i < light_count; i++) {
+		colour += blinn_phong_light(lights[i], position, n, v, albedo) * light_shadow(i, position, n);
+	}
+	return colour;
+}
+`
+
+func init() {
+	RegisterInclude("lights.glsl", lightsGLSL)
+	RegisterInclude("lighting.glsl", lightingGLSL)
+}
+
+// LightType selects how a Light shines.
+type LightType int32
+
+const (
+	DirectionalLight LightType = iota // parallel rays along Direction
+	PointLight                        // from Position in all directions
+	SpotLight                         // from Position in a cone around Direction
+)
+
+// Light is a light source in world space.
+type Light struct {
+	Type      LightType
+	Position  [3]float32 // point and spot lights
+	Direction [3]float32 // the way directional and spot lights shine
+	Colour    [3]float32
+	Intensity float32
+	// Constant, Linear and Quadratic attenuate point and spot lights
+	// with distance d as 1 / (c + l d + q d²)
+	Constant, Linear, Quadratic float32
+	// Range is where the light fades out completely, 0 for never
+	Range float32
+	// InnerCone and OuterCone are the half angles of a spot light in
+	// radians, full brightness inside the first, none outside the second
+	InnerCone, OuterCone float32
+}
+
+// NewDirectionalLight creates a light like the sun.
+func NewDirectionalLight(direction, colour [3]float32) Light {
+	return Light{Type: DirectionalLight, Direction: direction, Colour: colour, Intensity: 1}
+}
+
+// NewPointLight creates a light at position reaching as far as rng, with
+// attenuation terms picked for that range.
+func NewPointLight(position, colour [3]float32, rng float32) Light {
+	return Light{
+		Type:      PointLight,
+		Position:  position,
+		Colour:    colour,
+		Intensity: 1,
+		Constant:  1,
+		Linear:    4.5 / rng,
+		Quadratic: 75 / (rng * rng),
+		Range:     rng,
+	}
+}
+
+// NewSpotLight creates a point light limited to a cone of outer half
+// angle cone, softened over the outer quarter.
+func NewSpotLight(position, direction, colour [3]float32, rng, cone float32) Light {
+	l := NewPointLight(position, colour, rng)
+	l.Type = SpotLight
+	l.Direction = direction
+	l.InnerCone, l.OuterCone = cone*0.75, cone
+	return l
+}
+
+// Lighting holds the lights of a scene and the uniform buffer the shaders
+// read them from.
+type Lighting struct {
+	Ambient [3]float32
+	Lights  []Light // only the first MaxLights are used
+
+	ubo uint32
+}
+
+// lightingSize is the std140 size of the Lighting block: ambient, the
+// count padded to a vec4 and 4 vec4 per light.
+const lightingSize = (4 + 4 + MaxLights*16) * 4
+
+// NewLighting creates the uniform buffer and binds it to LightingBinding.
+func NewLighting() *Lighting {
+	l := &Lighting{Ambient: [3]float32{0.1, 0.1, 0.1}}
+	gl.GenBuffers(1, &l.ubo)
+	gl.BindBuffer(gl.UNIFORM_BUFFER, l.ubo)
+	gl.BufferData(gl.UNIFORM_BUFFER, lightingSize, nil, gl.DYNAMIC_DRAW)
+	gl.BindBuffer(gl.UNIFORM_BUFFER, 0)
+	l.Bind()
+	return l
+}
+
+// Upload transforms the lights into view space and copies them into the
+// uniform buffer. Call it whenever the lights or the camera moved.
+func (l *Lighting) Upload(view [16]float32) {
+	n := len(l.Lights)
+	if n > MaxLights {
+		n = MaxLights
+	}
+	var data [lightingSize / 4]float32
+	copy(data[:3], l.Ambient[:])
+	// light_count is an int, stored bit for bit
+	data[4] = math.Float32frombits(uint32(n))
+	for i, light := range l.Lights[:n] {
+		d := data[8+i*16:]
+		p := transformPoint(view, light.Position)
+		dir := transformVector(view, light.Direction)
+		copy(d[0:3], p[:])
+		d[3] = float32(light.Type)
+		copy(d[4:7], dir[:])
+		d[7] = float32(math.Cos(float64(light.OuterCone)))
+		for k := 0; k < 3; k++ {
+			d[8+k] = light.Colour[k] * light.Intensity
+		}
+		d[11] = float32(math.Cos(float64(light.InnerCone)))
+		d[12], d[13], d[14], d[15] = light.Constant, light.Linear, light.Quadratic, light.Range
+	}
+
+	gl.BindBuffer(gl.UNIFORM_BUFFER, l.ubo)
+	gl.BufferSubData(gl.UNIFORM_BUFFER, 0, lightingSize, gl.Ptr(&data[0]))
+	gl.BindBuffer(gl.UNIFORM_BUFFER, 0)
+}
+
+// Bind binds the buffer to LightingBinding again, in case something else
+// took the binding point.
+func (l *Lighting) Bind() {
+	gl.BindBufferBase(gl.UNIFORM_BUFFER, LightingBinding, l.ubo)
+}
+
+// Delete frees the uniform buffer.
+func (l *Lighting) Delete() {
+	gl.DeleteBuffers(1, &l.ubo)
+}
+
+// BindLighting connects the Lighting block of a program to
+// LightingBinding.
+func BindLighting(program uint32) error {
+	index := gl.GetUniformBlockIndex(program, gl.Str("Lighting\x00"))
+	if index == gl.INVALID_INDEX {
+		return errors.New("program has no Lighting uniform block")
+	}
+	gl.UniformBlockBinding(program, index, LightingBinding)
+	return nil
+}
+
+// Material holds the Blinn-Phong parameters of the material uniform of
+// lighting.glsl.
+type Material struct {
+	Ambient   [3]float32
+	Diffuse   [3]float32
+	Specular  [3]float32
+	Emissive  [3]float32
+	Shininess float32 // specular exponent
+}
+
+// DefaultMaterial is a matt light grey.
+func DefaultMaterial() Material {
+	return Material{
+		Ambient:   [3]float32{1, 1, 1},
+		Diffuse:   [3]float32{0.8, 0.8, 0.8},
+		Specular:  [3]float32{0.2, 0.2, 0.2},
+		Shininess: 16,
+	}
+}
+
+// MaterialFromMTL converts a Wavefront material. MTL exponents of 0 or 1,
+// as in files without Ns, would light the whole surface, they become 16.
+func MaterialFromMTL(m mesh.Material) Material {
+	shininess := m.Shininess
+	if shininess <= 1 {
+		shininess = 16
+	}
+	return Material{
+		Ambient:   m.Ambient,
+		Diffuse:   m.Diffuse,
+		Specular:  m.Specular,
+		Emissive:  m.Emissive,
+		Shininess: shininess,
+	}
+}
+
+// MaterialUniforms holds the locations of the material uniform of a
+// program.
+type MaterialUniforms struct {
+	ambient, diffuse, specular, emissive, shininess int32
+}
+
+// NewMaterialUniforms looks up the material uniform of a program that
+// includes lighting.glsl.
+func NewMaterialUniforms(program uint32) MaterialUniforms {
+	return MaterialUniforms{
+		ambient:   uniform(program, "material.ambient"),
+		diffuse:   uniform(program, "material.diffuse"),
+		specular:  uniform(program, "material.specular"),
+		emissive:  uniform(program, "material.emissive"),
+		shininess: uniform(program, "material.shininess"),
+	}
+}
+
+// Set uploads a material into the program in use.
+func (u MaterialUniforms) Set(m Material) {
+	gl.Uniform3f(u.ambient, m.Ambient[0], m.Ambient[1], m.Ambient[2])
+	gl.Uniform3f(u.diffuse, m.Diffuse[0], m.Diffuse[1], m.Diffuse[2])
+	gl.Uniform3f(u.specular, m.Specular[0], m.Specular[1], m.Specular[2])
+	gl.Uniform3f(u.emissive, m.Emissive[0], m.Emissive[1], m.Emissive[2])
+	gl.Uniform1f(u.shininess, m.Shininess)
+}
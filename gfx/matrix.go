@@ -0,0 +1,149 @@
+package gfx
+
+import (
+	"math"
+)
+
+// Matrices are column-major [16]float32, as GL and m32 store them.
+
+// MulMat4 returns a * b.
+func MulMat4(a, b [16]float32) [16]float32 {
+	var m [16]float32
+	for c := 0; c < 4; c++ {
+		for r := 0; r < 4; r++ {
+			m[c*4+r] = a[r]*b[c*4] + a[4+r]*b[c*4+1] + a[8+r]*b[c*4+2] + a[12+r]*b[c*4+3]
+		}
+	}
+	return m
+}
+
+// NormalMatrix returns the inverse transpose of the upper 3x3 of a
+// model-view matrix, column-major for UniformMatrix3fv. It takes normals
+// to view space without skewing them under non-uniform scaling.
+func NormalMatrix(modelView [16]float32) [9]float32 {
+	c0 := [3]float32{modelView[0], modelView[1], modelView[2]}
+	c1 := [3]float32{modelView[4], modelView[5], modelView[6]}
+	c2 := [3]float32{modelView[8], modelView[9], modelView[10]}
+
+	// the columns of the inverse transpose are the cross products of the
+	// columns over the determinant
+	n0, n1, n2 := cross3(c1, c2), cross3(c2, c0), cross3(c0, c1)
+	det := c0[0]*n0[0] + c0[1]*n0[1] + c0[2]*n0[2]
+	if det == 0 {
+		return [9]float32{1, 0, 0, 0, 1, 0, 0, 0, 1}
+	}
+	var m [9]float32
+	for i := 0; i < 3; i++ {
+		m[i], m[3+i], m[6+i] = n0[i]/det, n1[i]/det, n2[i]/det
+	}
+	return m
+}
+
+func cross3(a, b [3]float32) [3]float32 {
+	return [3]float32{a[1]*b[2] - a[2]*b[1], a[2]*b[0] - a[0]*b[2], a[0]*b[1] - a[1]*b[0]}
+}
+
+// transformPoint applies m to a point.
+func transformPoint(m [16]float32, p [3]float32) [3]float32 {
+	var out [3]float32
+	for r := 0; r < 3; r++ {
+		out[r] = m[r]*p[0] + m[4+r]*p[1] + m[8+r]*p[2] + m[12+r]
+	}
+	return out
+}
+
+// transformVector applies m to a direction, ignoring the translation.
+func transformVector(m [16]float32, v [3]float32) [3]float32 {
+	var out [3]float32
+	for r := 0; r < 3; r++ {
+		out[r] = m[r]*v[0] + m[4+r]*v[1] + m[8+r]*v[2]
+	}
+	return out
+}
+
+// Ident returns the identity matrix.
+func Ident() [16]float32 {
+	return [16]float32{1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1}
+}
+
+// InvertMat4 returns the inverse of m, or the identity if m is singular.
+func InvertMat4(m [16]float32) [16]float32 {
+	var inv [16]float32
+	inv[0] = m[5]*m[10]*m[15] - m[5]*m[11]*m[14] - m[9]*m[6]*m[15] + m[9]*m[7]*m[14] + m[13]*m[6]*m[11] - m[13]*m[7]*m[10]
+	inv[4] = -m[4]*m[10]*m[15] + m[4]*m[11]*m[14] + m[8]*m[6]*m[15] - m[8]*m[7]*m[14] - m[12]*m[6]*m[11] + m[12]*m[7]*m[10]
+	inv[8] = m[4]*m[9]*m[15] - m[4]*m[11]*m[13] - m[8]*m[5]*m[15] + m[8]*m[7]*m[13] + m[12]*m[5]*m[11] - m[12]*m[7]*m[9]
+	inv[12] = -m[4]*m[9]*m[14] + m[4]*m[10]*m[13] + m[8]*m[5]*m[14] - m[8]*m[6]*m[13] - m[12]*m[5]*m[10] + m[12]*m[6]*m[9]
+	inv[1] = -m[1]*m[10]*m[15] + m[1]*m[11]*m[14] + m[9]*m[2]*m[15] - m[9]*m[3]*m[14] - m[13]*m[2]*m[11] + m[13]*m[3]*m[10]
+	inv[5] = m[0]*m[10]*m[15] - m[0]*m[11]*m[14] - m[8]*m[2]*m[15] + m[8]*m[3]*m[14] + m[12]*m[2]*m[11] - m[12]*m[3]*m[10]
+	inv[9] = -m[0]*m[9]*m[15] + m[0]*m[11]*m[13] + m[8]*m[1]*m[15] - m[8]*m[3]*m[13] - m[12]*m[1]*m[11] + m[12]*m[3]*m[9]
+	inv[13] = m[0]*m[9]*m[14] - m[0]*m[10]*m[13] - m[8]*m[1]*m[14] + m[8]*m[2]*m[13] + m[12]*m[1]*m[10] - m[12]*m[2]*m[9]
+	inv[2] = m[1]*m[6]*m[15] - m[1]*m[7]*m[14] - m[5]*m[2]*m[15] + m[5]*m[3]*m[14] + m[13]*m[2]*m[7] - m[13]*m[3]*m[6]
+	inv[6] = -m[0]*m[6]*m[15] + m[0]*m[7]*m[14] + m[4]*m[2]*m[15] - m[4]*m[3]*m[14] - m[12]*m[2]*m[7] + m[12]*m[3]*m[6]
+	inv[10] = m[0]*m[5]*m[15] - m[0]*m[7]*m[13] - m[4]*m[1]*m[15] + m[4]*m[3]*m[13] + m[12]*m[1]*m[7] - m[12]*m[3]*m[5]
+	inv[14] = -m[0]*m[5]*m[14] + m[0]*m[6]*m[13] + m[4]*m[1]*m[14] - m[4]*m[2]*m[13] - m[12]*m[1]*m[6] + m[12]*m[2]*m[5]
+	inv[3] = -m[1]*m[6]*m[11] + m[1]*m[7]*m[10] + m[5]*m[2]*m[11] - m[5]*m[3]*m[10] - m[9]*m[2]*m[7] + m[9]*m[3]*m[6]
+	inv[7] = m[0]*m[6]*m[11] - m[0]*m[7]*m[10] - m[4]*m[2]*m[11] + m[4]*m[3]*m[10] + m[8]*m[2]*m[7] - m[8]*m[3]*m[6]
+	inv[11] = -m[0]*m[5]*m[11] + m[0]*m[7]*m[9] + m[4]*m[1]*m[11] - m[4]*m[3]*m[9] - m[8]*m[1]*m[7] + m[8]*m[3]*m[5]
+	inv[15] = m[0]*m[5]*m[10] - m[0]*m[6]*m[9] - m[4]*m[1]*m[10] + m[4]*m[2]*m[9] + m[8]*m[1]*m[6] - m[8]*m[2]*m[5]
+
+	det := m[0]*inv[0] + m[1]*inv[4] + m[2]*inv[8] + m[3]*inv[12]
+	if det == 0 {
+		return Ident()
+	}
+	for i := range inv {
+		inv[i] /= det
+	}
+	return inv
+}
+
+// LookAt returns a view matrix at eye looking towards target.
+func LookAt(eye, target, up [3]float32) [16]float32 {
+	f := normalize3(sub3(target, eye))
+	s := normalize3(cross3(f, up))
+	u := cross3(s, f)
+	return [16]float32{
+		s[0], u[0], -f[0], 0,
+		s[1], u[1], -f[1], 0,
+		s[2], u[2], -f[2], 0,
+		-dot3(s, eye), -dot3(u, eye), dot3(f, eye), 1,
+	}
+}
+
+// Ortho returns an orthographic projection of the box between left and
+// right, bottom and top, near and far in front of the eye.
+func Ortho(left, right, bottom, top, near, far float32) [16]float32 {
+	return [16]float32{
+		2 / (right - left), 0, 0, 0,
+		0, 2 / (top - bottom), 0, 0,
+		0, 0, -2 / (far - near), 0,
+		-(right + left) / (right - left), -(top + bottom) / (top - bottom), -(far + near) / (far - near), 1,
+	}
+}
+
+// Perspective returns a perspective projection with a vertical field of
+// view in degrees, like m32.Perspective.
+func Perspective(fovy, aspect, near, far float32) [16]float32 {
+	f := float32(1 / math.Tan(float64(fovy)*math.Pi/360))
+	return [16]float32{
+		f / aspect, 0, 0, 0,
+		0, f, 0, 0,
+		0, 0, (far + near) / (near - far), -1,
+		0, 0, 2 * far * near / (near - far), 0,
+	}
+}
+
+func sub3(a, b [3]float32) [3]float32 {
+	return [3]float32{a[0] - b[0], a[1] - b[1], a[2] - b[2]}
+}
+
+func dot3(a, b [3]float32) float32 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+}
+
+func normalize3(v [3]float32) [3]float32 {
+	l := float32(math.Sqrt(float64(dot3(v, v))))
+	if l == 0 {
+		return v
+	}
+	return [3]float32{v[0] / l, v[1] / l, v[2] / l}
+}
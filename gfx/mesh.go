@@ -0,0 +1,289 @@
+package gfx
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/ginuerzh/anton-gocode/mesh"
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"unsafe"
+)
+
+/* attribute locations shared by the shaders */
+const (
+	PositionLoc = 0
+	ColourLoc   = 1
+	NormalLoc   = 2
+	TexCoordLoc = 3
+	TangentLoc  = 4
+)
+
+// Submesh is a range of the element buffer drawn with one material.
+// BaseVertex is added to every index, so several meshes can share buffers
+// while keeping small indices.
+type Submesh struct {
+	Start      int // first index
+	Count      int // number of indices
+	BaseVertex int32
+	Material   int
+}
+
+// Mesh owns a VAO, its vertex buffers and an optional element buffer.
+// Indices are stored as uint16 whenever they fit, uint32 otherwise.
+type Mesh struct {
+	Vao       uint32
+	Vbos      []uint32
+	Ebo       uint32
+	Mode      uint32 // gl.TRIANGLES unless changed
+	IndexType uint32 // gl.UNSIGNED_SHORT, gl.UNSIGNED_INT or 0 without indices
+	Count     int32  // indices, or vertices when not indexed
+	Vertices  int32
+	Submeshes []Submesh
+	Layout    VertexLayout
+	Min, Max  [3]float32 // bounding box, zero when built from raw data
+	Radius    float32    // of the bounding sphere around the box centre, 0 if unknown
+}
+
+// NewMesh creates a mesh from one slice of vertex data per buffer of the
+// layout ([]float32, []uint8, ...) and an optional index list. Like
+// CreateVao it leaves the VAO bound.
+func NewMesh(layout VertexLayout, indices []uint32, data ...interface{}) (*Mesh, error) {
+	if len(data) != layout.Buffers() {
+		return nil, fmt.Errorf("layout needs %d buffers, got %d", layout.Buffers(), len(data))
+	}
+
+	sizes := make([]int, len(data))
+	for i, d := range data {
+		if sizes[i] = binary.Size(d); sizes[i] <= 0 {
+			return nil, fmt.Errorf("buffer %d: unsupported vertex data %T", i, d)
+		}
+	}
+
+	g := &Mesh{Mode: gl.TRIANGLES, Layout: layout}
+	if stride := layout.Stride(0); stride > 0 {
+		g.Vertices = int32(sizes[0] / int(stride))
+	}
+
+	g.Vbos = make([]uint32, len(data))
+	gl.GenBuffers(int32(len(data)), &g.Vbos[0])
+	for i, d := range data {
+		gl.BindBuffer(gl.ARRAY_BUFFER, g.Vbos[i])
+		gl.BufferData(gl.ARRAY_BUFFER, sizes[i], gl.Ptr(d), gl.STATIC_DRAW)
+	}
+	g.Vao = layout.CreateVao(g.Vbos...)
+
+	g.Count = g.Vertices
+	if len(indices) > 0 {
+		g.setIndices(indices)
+	}
+	g.Submeshes = []Submesh{{Count: int(g.Count), Material: -1}}
+
+	return g, nil
+}
+
+// setIndices creates the element buffer of the bound VAO.
+func (g *Mesh) setIndices(indices []uint32) {
+	max := uint32(0)
+	for _, i := range indices {
+		if i > max {
+			max = i
+		}
+	}
+
+	gl.GenBuffers(1, &g.Ebo)
+	gl.BindBuffer(gl.ELEMENT_ARRAY_BUFFER, g.Ebo)
+	if max <= 0xffff {
+		short := make([]uint16, len(indices))
+		for k, i := range indices {
+			short[k] = uint16(i)
+		}
+		gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, len(short)*2, gl.Ptr(short), gl.STATIC_DRAW)
+		g.IndexType = gl.UNSIGNED_SHORT
+	} else {
+		gl.BufferData(gl.ELEMENT_ARRAY_BUFFER, len(indices)*4, gl.Ptr(indices), gl.STATIC_DRAW)
+		g.IndexType = gl.UNSIGNED_INT
+	}
+	g.Count = int32(len(indices))
+}
+
+func (g *Mesh) indexSize() int {
+	if g.IndexType == gl.UNSIGNED_SHORT {
+		return 2
+	}
+	return 4
+}
+
+// MeshLayout returns the planar layout UploadMesh uses for m, with the
+// matching vertex data: positions at PositionLoc, colours at ColourLoc,
+// normals at NormalLoc, texcoords at TexCoordLoc and tangents at
+// TangentLoc, skipping missing attributes. Attribute names follow the
+// vertex_position, vertex_colour naming of the example shaders.
+func MeshLayout(m *mesh.Mesh) (VertexLayout, []interface{}) {
+	sources := []struct {
+		attrib Attrib
+		data   []float32
+	}{
+		{Attrib{Name: "vertex_position", Location: PositionLoc, Size: 3}, m.Positions},
+		{Attrib{Name: "vertex_colour", Location: ColourLoc, Size: 3}, m.Colours},
+		{Attrib{Name: "vertex_normal", Location: NormalLoc, Size: 3}, m.Normals},
+		{Attrib{Name: "vertex_texcoord", Location: TexCoordLoc, Size: 2}, m.TexCoords},
+		{Attrib{Name: "vertex_tangent", Location: TangentLoc, Size: 4}, m.Tangents},
+	}
+
+	var attribs []Attrib
+	var data []interface{}
+	for _, s := range sources {
+		if len(s.data) == 0 {
+			continue
+		}
+		s.attrib.Type = gl.FLOAT
+		attribs = append(attribs, s.attrib)
+		data = append(data, s.data)
+	}
+	return Planar(attribs...), data
+}
+
+// UploadMesh copies m into GL buffers, one submesh per group.
+func UploadMesh(m *mesh.Mesh) (*Mesh, error) {
+	return UploadMeshes(m)
+}
+
+// UploadMeshes packs several meshes with the same attributes into one set
+// of buffers. Every group of every mesh becomes a submesh whose BaseVertex
+// points at the first vertex of its mesh.
+func UploadMeshes(meshes ...*mesh.Mesh) (*Mesh, error) {
+	if len(meshes) == 0 {
+		return nil, errors.New("no meshes")
+	}
+
+	layout, _ := MeshLayout(meshes[0])
+	merged := &mesh.Mesh{}
+	var subs []Submesh
+	for k, m := range meshes {
+		l, _ := MeshLayout(m)
+		if len(l.Attribs) != len(layout.Attribs) {
+			return nil, fmt.Errorf("mesh %d has different attributes", k)
+		}
+		for i := range l.Attribs {
+			if l.Attribs[i] != layout.Attribs[i] {
+				return nil, fmt.Errorf("mesh %d has different attributes", k)
+			}
+		}
+
+		base := int32(merged.VertexCount())
+		start := len(merged.Indices)
+		groups := m.Groups
+		if len(groups) == 0 {
+			groups = []mesh.Group{{Material: -1, Count: len(m.Indices)}}
+		}
+		for _, gr := range groups {
+			subs = append(subs, Submesh{
+				Start:      start + gr.Start,
+				Count:      gr.Count,
+				BaseVertex: base,
+				Material:   gr.Material,
+			})
+		}
+
+		if len(meshes) == 1 {
+			merged = m
+			break
+		}
+		merged.Positions = append(merged.Positions, m.Positions...)
+		merged.Colours = append(merged.Colours, m.Colours...)
+		merged.Normals = append(merged.Normals, m.Normals...)
+		merged.TexCoords = append(merged.TexCoords, m.TexCoords...)
+		merged.Tangents = append(merged.Tangents, m.Tangents...)
+		merged.Indices = append(merged.Indices, m.Indices...)
+	}
+
+	_, data := MeshLayout(merged)
+	g, err := NewMesh(layout, merged.Indices, data...)
+	if err != nil {
+		return nil, err
+	}
+	if len(merged.Indices) > 0 {
+		g.Submeshes = subs
+	}
+	g.Min, g.Max = merged.Bounds(0, len(merged.Indices))
+	g.Radius = boundingRadius(merged.Positions, AABB{g.Min, g.Max}.Center())
+	return g, nil
+}
+
+// Draw draws the whole mesh. Meshes packed by UploadMeshes need their base
+// vertices, draw them with DrawAll instead.
+func (g *Mesh) Draw() {
+	gl.BindVertexArray(g.Vao)
+	if g.IndexType == 0 {
+		gl.DrawArrays(g.Mode, 0, g.Count)
+		return
+	}
+	gl.DrawElements(g.Mode, g.Count, g.IndexType, gl.PtrOffset(0))
+}
+
+// DrawSubmesh draws one submesh, e.g. after switching to its material.
+func (g *Mesh) DrawSubmesh(i int) {
+	s := g.Submeshes[i]
+	gl.BindVertexArray(g.Vao)
+	if g.IndexType == 0 {
+		gl.DrawArrays(g.Mode, int32(s.Start)+s.BaseVertex, int32(s.Count))
+		return
+	}
+	gl.DrawElementsBaseVertex(g.Mode, int32(s.Count), g.IndexType,
+		gl.PtrOffset(s.Start*g.indexSize()), s.BaseVertex)
+}
+
+// DrawSubmeshes draws the given submeshes with a single multi-draw call,
+// all of them when none are given.
+func (g *Mesh) DrawSubmeshes(which ...int) {
+	if len(which) == 0 {
+		which = make([]int, len(g.Submeshes))
+		for i := range which {
+			which[i] = i
+		}
+	}
+	if len(which) == 0 {
+		return
+	}
+	if g.IndexType == 0 {
+		for _, i := range which {
+			g.DrawSubmesh(i)
+		}
+		return
+	}
+
+	counts := make([]int32, len(which))
+	offsets := make([]unsafe.Pointer, len(which))
+	bases := make([]int32, len(which))
+	for k, i := range which {
+		s := g.Submeshes[i]
+		counts[k] = int32(s.Count)
+		offsets[k] = gl.PtrOffset(s.Start * g.indexSize())
+		bases[k] = s.BaseVertex
+	}
+
+	gl.BindVertexArray(g.Vao)
+	gl.MultiDrawElementsBaseVertex(g.Mode, &counts[0], g.IndexType,
+		&offsets[0], int32(len(which)), &bases[0])
+}
+
+// DrawAll draws every submesh.
+func (g *Mesh) DrawAll() {
+	g.DrawSubmeshes()
+}
+
+// Delete frees the VAO and all buffers of the mesh.
+func (g *Mesh) Delete() {
+	if len(g.Vbos) > 0 {
+		gl.DeleteBuffers(int32(len(g.Vbos)), &g.Vbos[0])
+		g.Vbos = nil
+	}
+	if g.Ebo != 0 {
+		gl.DeleteBuffers(1, &g.Ebo)
+		g.Ebo = 0
+	}
+	if g.Vao != 0 {
+		gl.DeleteVertexArrays(1, &g.Vao)
+		g.Vao = 0
+	}
+}
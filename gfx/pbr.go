@@ -0,0 +1,253 @@
+package gfx
+
+import (
+	"github.com/ginuerzh/anton-gocode/texture"
+	"github.com/go-gl/gl/v3.3-core/gl"
+)
+
+// pbrGLSL is metallic-roughness shading after the glTF 2.0 reference:
+// Cook-Torrance with the GGX distribution, Smith-Schlick geometry and
+// Schlick Fresnel for the lights, and image based lighting from the maps
+// of an IBL for the surroundings. Pull it in with #include "pbr.glsl",
+// after shadows.glsl if there are shadows.
+const pbrGLSL = `#include "lights.glsl"
+#include "brdf.glsl"
+
+#define PBR_BASE_COLOUR_MAP 1
+#define PBR_METALLIC_ROUGHNESS_MAP 2
+#define PBR_NORMAL_MAP 4
+#define PBR_OCCLUSION_MAP 8
+#define PBR_EMISSIVE_MAP 16
+
+struct PBRMaterialParams {
+	vec4 base_colour;
+	vec3 emissive;
+	float metallic;
+	float roughness;
+	float normal_scale;
+	float occlusion_strength;
+	float alpha_cutoff; // 0 unless the alpha mode is MASK
+	int maps;           // PBR_*_MAP bits of the maps in use
+};
+
+uniform PBRMaterialParams pbr_material;
+uniform sampler2D base_colour_map;
+uniform sampler2D metallic_roughness_map; // roughness in g, metallic in b
+uniform sampler2D normal_map;
+uniform sampler2D occlusion_map;
+uniform sampler2D emissive_map;
+
+uniform samplerCube irradiance_map;
+uniform samplerCube prefiltered_map;
+uniform sampler2D brdf_lut;
+uniform float prefiltered_lod; // the level for roughness 1
+uniform float ibl_intensity;   // 0 without an IBL, ambient_light is used then
+uniform mat3 ibl_rotation;     // view space to world space
+
+// pbr_light is the light reflected towards v from one light.
+vec3 pbr_light(Light light, vec3 position, vec3 n, vec3 v, vec3 albedo, float metallic, float roughness) {
+	vec3 l;
+	float att = light_incoming(light, position, l);
+	float n_dot_l = max(dot(n, l), 0.0);
+	if (att <= 0.0 || n_dot_l <= 0.0) {
+		return vec3(0.0);
+	}
+	vec3 h = normalize(l + v);
+	float n_dot_v = max(dot(n, v), 0.0001);
+	vec3 f0 = mix(vec3(0.04), albedo, metallic);
+	vec3 f = fresnel_schlick(max(dot(h, v), 0.0), f0);
+	float d = distribution_ggx(max(dot(n, h), 0.0), roughness);
+	float g = geometry_smith(n_dot_v, n_dot_l, roughness);
+	vec3 specular = d * g * f / (4.0 * n_dot_v * n_dot_l + 0.0001);
+	vec3 kd = (1.0 - f) * (1.0 - metallic);
+	return (kd * albedo / PI + specular) * light.colour.rgb * att * n_dot_l;
+}
+
+// pbr_ambient is the light of the surroundings, from the IBL maps.
+vec3 pbr_ambient(vec3 n, vec3 v, vec3 albedo, float metallic, float roughness) {
+	if (ibl_intensity <= 0.0) {
+		return ambient_light.rgb * albedo * (1.0 - metallic * 0.9);
+	}
+	float n_dot_v = max(dot(n, v), 0.0);
+	vec3 f0 = mix(vec3(0.04), albedo, metallic);
+	vec3 f = fresnel_schlick_roughness(n_dot_v, f0, roughness);
+	vec3 kd = (1.0 - f) * (1.0 - metallic);
+	vec3 diffuse = texture(irradiance_map, ibl_rotation * n).rgb * albedo;
+	vec3 r = ibl_rotation * reflect(-v, n);
+	vec3 prefiltered = textureLod(prefiltered_map, r, roughness * prefiltered_lod).rgb;
+	vec2 brdf = texture(brdf_lut, vec2(n_dot_v, roughness)).rg;
+	return (kd * diffuse + prefiltered * (f0 * brdf.x + brdf.y)) * ibl_intensity;
+}
+
+// pbr shades a point in view space with all lights and the surroundings.
+vec3 pbr(vec3 position, vec3 normal, vec3 albedo, float metallic, float roughness, float occlusion) {
+	vec3 n = normalize(normal);
+	vec3 v = normalize(-position);
+	// below about 0.05 the highlights of point lights vanish
+	roughness = clamp(roughness, 0.045, 1.0);
+	vec3 colour = pbr_ambient(n, v, albedo, metallic, roughness) * occlusion;
+	for (int i = 0; i < light_count; i++) {
+		colour += pbr_light(lights[i], position, n, v, albedo, metallic, roughness) * light_shadow(i, position, n);
+	}
+	return colour;
+}
+
+// PBRSurface is a point of a surface with its material evaluated.
+struct PBRSurface {
+	vec3 albedo;
+	float alpha;
+	vec3 normal; // view space, normal mapped
+	float metallic;
+	float roughness;
+	float occlusion;
+	vec3 emissive;
+};
+
+// pbr_surface evaluates pbr_material and its maps at a point. The tangent
+// has the bitangent sign in w, it is only used with a normal map. Back
+// faces of double sided materials get the normal of their side.
+PBRSurface pbr_surface(vec3 normal, vec4 tangent, vec2 uv) {
+	PBRSurface s;
+	int maps = pbr_material.maps;
+	vec4 base = pbr_material.base_colour;
+	if ((maps & PBR_BASE_COLOUR_MAP) != 0) {
+		base *= texture(base_colour_map, uv);
+	}
+	s.albedo = base.rgb;
+	s.alpha = base.a;
+
+	s.metallic = pbr_material.metallic;
+	s.roughness = pbr_material.roughness;
+	if ((maps & PBR_METALLIC_ROUGHNESS_MAP) != 0) {
+		vec4 mr = texture(metallic_roughness_map, uv);
+		s.roughness *= mr.g;
+		s.metallic *= mr.b;
+	}
+
+	vec3 n = normalize(gl_FrontFacing ? normal : -normal);
+	if ((maps & PBR_NORMAL_MAP) != 0 && dot(tangent.xyz, tangent.xyz) > 0.0) {
+		vec3 t = normalize(tangent.xyz - n * dot(n, tangent.xyz));
+		vec3 b = cross(n, t) * (tangent.w < 0.0 ? -1.0 : 1.0);
+		vec3 m = texture(normal_map, uv).xyz * 2.0 - 1.0;
+		m.xy *= pbr_material.normal_scale;
+		n = normalize(mat3(t, b, n) * m);
+	}
+	s.normal = n;
+
+	s.occlusion = 1.0;
+	if ((maps & PBR_OCCLUSION_MAP) != 0) {
+		s.occlusion = mix(1.0, texture(occlusion_map, uv).r, pbr_material.occlusion_strength);
+	}
+	s.emissive = pbr_material.emissive;
+	if ((maps & PBR_EMISSIVE_MAP) != 0) {
+		s.emissive *= texture(emissive_map, uv).rgb;
+	}
+	return s;
+}
+
+// pbr_material_shade shades a point with pbr_material and its maps,
+// dropping it below the alpha cutoff.
+vec4 pbr_material_shade(vec3 position, vec3 normal, vec4 tangent, vec2 uv) {
+	PBRSurface s = pbr_surface(normal, tangent, uv);
+	if (s.alpha < pbr_material.alpha_cutoff) {
+		discard;
+	}
+	vec3 colour = pbr(position, s.normal, s.albedo, s.metallic, s.roughness, s.occlusion);
+	return vec4(colour + s.emissive, s.alpha);
+}
+`
+
+func init() {
+	RegisterInclude("pbr.glsl", pbrGLSL)
+}
+
+// PBRMaterial holds the metallic-roughness parameters of the pbr_material
+// uniform of pbr.glsl, the same as a glTF material. The maps multiply the
+// factors and may be nil. Colour maps should be sRGB textures.
+type PBRMaterial struct {
+	BaseColour        [4]float32
+	Metallic          float32
+	Roughness         float32
+	Emissive          [3]float32
+	NormalScale       float32
+	OcclusionStrength float32
+	AlphaCutoff       float32 // fragments with less alpha are dropped, 0 for none
+
+	BaseColourMap        *texture.Texture
+	MetallicRoughnessMap *texture.Texture // roughness in green, metallic in blue
+	NormalMap            *texture.Texture // tangent space
+	OcclusionMap         *texture.Texture // in red
+	EmissiveMap          *texture.Texture
+}
+
+// DefaultPBRMaterial is a rough white dielectric.
+func DefaultPBRMaterial() PBRMaterial {
+	return PBRMaterial{
+		BaseColour:        [4]float32{1, 1, 1, 1},
+		Roughness:         0.8,
+		NormalScale:       1,
+		OcclusionStrength: 1,
+	}
+}
+
+// PBRUniforms holds the locations of the pbr_material uniform and the
+// material maps of a program.
+type PBRUniforms struct {
+	baseColour, emissive, metallic, roughness   int32
+	normalScale, occlusionStrength, alphaCutoff int32
+	maps                                        int32
+	samplers                                    [5]int32
+}
+
+// NewPBRUniforms looks up the material uniforms of a program that
+// includes pbr.glsl.
+func NewPBRUniforms(program uint32) PBRUniforms {
+	return PBRUniforms{
+		baseColour:        uniform(program, "pbr_material.base_colour"),
+		emissive:          uniform(program, "pbr_material.emissive"),
+		metallic:          uniform(program, "pbr_material.metallic"),
+		roughness:         uniform(program, "pbr_material.roughness"),
+		normalScale:       uniform(program, "pbr_material.normal_scale"),
+		occlusionStrength: uniform(program, "pbr_material.occlusion_strength"),
+		alphaCutoff:       uniform(program, "pbr_material.alpha_cutoff"),
+		maps:              uniform(program, "pbr_material.maps"),
+		samplers: [5]int32{
+			uniform(program, "base_colour_map"),
+			uniform(program, "metallic_roughness_map"),
+			uniform(program, "normal_map"),
+			uniform(program, "occlusion_map"),
+			uniform(program, "emissive_map"),
+		},
+	}
+}
+
+/* texture units of PBR programs: material maps from 0, the IBL maps after them */
+const (
+	PBRMaterialUnit = 0
+	PBRIBLUnit      = 5
+)
+
+// Set uploads a material into the program in use and binds its maps to
+// the five texture units from unit on.
+func (u PBRUniforms) Set(m PBRMaterial, unit uint32) {
+	gl.Uniform4f(u.baseColour, m.BaseColour[0], m.BaseColour[1], m.BaseColour[2], m.BaseColour[3])
+	gl.Uniform3f(u.emissive, m.Emissive[0], m.Emissive[1], m.Emissive[2])
+	gl.Uniform1f(u.metallic, m.Metallic)
+	gl.Uniform1f(u.roughness, m.Roughness)
+	gl.Uniform1f(u.normalScale, m.NormalScale)
+	gl.Uniform1f(u.occlusionStrength, m.OcclusionStrength)
+	gl.Uniform1f(u.alphaCutoff, m.AlphaCutoff)
+
+	maps := int32(0)
+	// the maps in the order of the PBR_*_MAP bits
+	for i, t := range []*texture.Texture{
+		m.BaseColourMap, m.MetallicRoughnessMap, m.NormalMap, m.OcclusionMap, m.EmissiveMap,
+	} {
+		gl.Uniform1i(u.samplers[i], int32(unit)+int32(i))
+		if t != nil {
+			t.Bind(unit + uint32(i))
+			maps |= 1 << uint(i)
+		}
+	}
+	gl.Uniform1i(u.maps, maps)
+}
@@ -0,0 +1,351 @@
+package gfx
+
+import (
+	"flag"
+	"fmt"
+	"github.com/ginuerzh/anton-gocode/texture"
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"strconv"
+	"strings"
+)
+
+var postEffects string
+
+func init() {
+	flag.StringVar(&postEffects, "post", "",
+		"post-processing effects in order, comma separated: aces[:stops], reinhard[:stops], "+
+			"bloom[:threshold], blur[:sigma], fxaa, greyscale[:strength], vignette[:radius]")
+}
+
+// Effect is a step of a PostStack. Apply reads src and renders the
+// result into dst, with as many passes and scratch targets as it needs.
+type Effect interface {
+	Apply(src *texture.Texture, dst *RenderTarget) error
+	Delete()
+}
+
+// copyFS draws its source as it is.
+const copyFS = `#version 330
+
+in vec2 texcoord;
+
+uniform sampler2D source;
+
+out vec4 frag_colour;
+
+void main() {
+	frag_colour = texture(source, texcoord);
+}
+`
+
+// Pass is a full screen fragment shader effect. Its shader gets texcoord
+// from the vertex shader and the input as sampler2D source, with texel
+// set to the size of one source texel. Uniforms holds the parameters,
+// set on every draw: float32, int32, bool, [2]float32, [3]float32,
+// [4]float32, []float32 or *texture.Texture, which is bound to the next
+// free texture unit.
+type Pass struct {
+	Uniforms map[string]interface{}
+
+	program   uint32
+	locs      map[string]int32
+	sourceLoc int32
+	texelLoc  int32
+}
+
+// NewPass compiles a fragment shader into a pass.
+func NewPass(fragment string, uniforms map[string]interface{}) (*Pass, error) {
+	program, err := NewProgram(fullscreenVS, fragment)
+	if err != nil {
+		return nil, err
+	}
+	if uniforms == nil {
+		uniforms = make(map[string]interface{})
+	}
+	return &Pass{
+		Uniforms:  uniforms,
+		program:   program,
+		locs:      make(map[string]int32),
+		sourceLoc: uniform(program, "source"),
+		texelLoc:  uniform(program, "texel"),
+	}, nil
+}
+
+// Apply draws the pass into dst.
+func (p *Pass) Apply(src *texture.Texture, dst *RenderTarget) error {
+	dst.Bind()
+	p.draw(src)
+	return nil
+}
+
+// draw runs the shader over the current viewport.
+func (p *Pass) draw(src *texture.Texture) {
+	gl.UseProgram(p.program)
+	gl.Uniform1i(p.sourceLoc, 0)
+	gl.Uniform2f(p.texelLoc, 1/float32(src.Width), 1/float32(src.Height))
+	src.Bind(0)
+
+	unit := uint32(1)
+	for name, v := range p.Uniforms {
+		loc, ok := p.locs[name]
+		if !ok {
+			loc = uniform(p.program, name)
+			p.locs[name] = loc
+		}
+		switch v := v.(type) {
+		case float32:
+			gl.Uniform1f(loc, v)
+		case int32:
+			gl.Uniform1i(loc, v)
+		case bool:
+			b := int32(0)
+			if v {
+				b = 1
+			}
+			gl.Uniform1i(loc, b)
+		case [2]float32:
+			gl.Uniform2f(loc, v[0], v[1])
+		case [3]float32:
+			gl.Uniform3f(loc, v[0], v[1], v[2])
+		case [4]float32:
+			gl.Uniform4f(loc, v[0], v[1], v[2], v[3])
+		case []float32:
+			if len(v) > 0 {
+				gl.Uniform1fv(loc, int32(len(v)), &v[0])
+			}
+		case *texture.Texture:
+			gl.Uniform1i(loc, int32(unit))
+			v.Bind(unit)
+			unit++
+		default:
+			panic(fmt.Sprintf("pass uniform %s: unsupported type %T", name, v))
+		}
+	}
+	drawFullscreen()
+}
+
+// Delete frees the program.
+func (p *Pass) Delete() {
+	gl.DeleteProgram(p.program)
+}
+
+// ensureTarget creates a half float colour only target in *t, or resizes
+// the one there.
+func ensureTarget(t **RenderTarget, width, height int32) error {
+	if *t == nil {
+		r, err := NewRenderTargetWith(width, height, TargetOptions{Colour: []int32{gl.RGBA16F}})
+		if err != nil {
+			return err
+		}
+		*t = r
+		return nil
+	}
+	return (*t).Resize(width, height)
+}
+
+// PostStack renders a scene offscreen and runs it through a list of
+// effects on the way to the window, ping-ponging between two targets.
+// The scene target is half float, so tone mapping can be one of the
+// effects. A nil *PostStack draws straight into the window, so examples
+// can call Begin and End whether post-processing is on or not.
+type PostStack struct {
+	Effects []Effect
+	Scene   *RenderTarget
+
+	ping   [2]*RenderTarget
+	output *Pass
+	width  int
+	height int
+}
+
+// NewPostStack creates a stack whose scene target has a depth-stencil
+// buffer and samples, multisampled above 1.
+func NewPostStack(width, height int, samples int32, effects ...Effect) (*PostStack, error) {
+	scene, err := NewRenderTargetWith(int32(width), int32(height), TargetOptions{
+		Colour:  []int32{gl.RGBA16F},
+		Depth:   gl.DEPTH24_STENCIL8,
+		Samples: samples,
+	})
+	if err != nil {
+		return nil, err
+	}
+	output, err := NewPass(copyFS, nil)
+	if err != nil {
+		scene.Delete()
+		return nil, err
+	}
+	return &PostStack{
+		Effects: effects,
+		Scene:   scene,
+		output:  output,
+		width:   width,
+		height:  height,
+	}, nil
+}
+
+// NewPostStackFromFlags builds a stack from the -post flag, or returns
+// nil when it is empty.
+func NewPostStackFromFlags(width, height int) (*PostStack, error) {
+	if postEffects == "" {
+		return nil, nil
+	}
+	effects, err := ParseEffects(postEffects)
+	if err != nil {
+		return nil, err
+	}
+	s, err := NewPostStack(width, height, 4, effects...)
+	if err != nil {
+		for _, e := range effects {
+			e.Delete()
+		}
+		return nil, err
+	}
+	return s, nil
+}
+
+// ParseEffects creates effects from a comma separated list of names, each
+// optionally followed by a colon and its main parameter, e.g.
+// "bloom:1.5,aces:0.5,fxaa".
+func ParseEffects(list string) ([]Effect, error) {
+	var effects []Effect
+	fail := func(err error) ([]Effect, error) {
+		for _, e := range effects {
+			e.Delete()
+		}
+		return nil, err
+	}
+	for _, spec := range strings.Split(list, ",") {
+		name, arg := strings.TrimSpace(spec), ""
+		if i := strings.IndexByte(name, ':'); i >= 0 {
+			name, arg = name[:i], name[i+1:]
+		}
+		var param *float64
+		if arg != "" {
+			v, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return fail(fmt.Errorf("effect %s: %v", name, err))
+			}
+			param = &v
+		}
+
+		var e Effect
+		var err error
+		switch name {
+		case "aces", "reinhard":
+			var t *ToneMap
+			if t, err = NewToneMap(); err == nil {
+				if name == "reinhard" {
+					t.Operator = ToneReinhard
+				}
+				if param != nil {
+					t.Exposure = float32(*param)
+				}
+				e = t
+			}
+		case "bloom":
+			var b *Bloom
+			if b, err = NewBloom(); err == nil {
+				if param != nil {
+					b.Threshold = float32(*param)
+				}
+				e = b
+			}
+		case "blur":
+			var b *GaussianBlur
+			if b, err = NewGaussianBlur(); err == nil {
+				if param != nil {
+					b.Sigma = float32(*param)
+				}
+				e = b
+			}
+		case "fxaa":
+			e, err = NewFXAA()
+		case "greyscale", "grayscale":
+			var p *Pass
+			if p, err = NewGreyscale(); err == nil {
+				if param != nil {
+					p.Uniforms["strength"] = float32(*param)
+				}
+				e = p
+			}
+		case "vignette":
+			var p *Pass
+			if p, err = NewVignette(); err == nil {
+				if param != nil {
+					p.Uniforms["radius"] = float32(*param)
+				}
+				e = p
+			}
+		default:
+			err = fmt.Errorf("unknown effect %q", name)
+		}
+		if err != nil {
+			return fail(err)
+		}
+		effects = append(effects, e)
+	}
+	return effects, nil
+}
+
+// Begin resizes the scene target to the window if needed and binds it;
+// clearing it is left to the caller, with its own clear colour. On a nil
+// stack it binds the window.
+func (s *PostStack) Begin(width, height int) error {
+	if s == nil {
+		BindDefault(width, height)
+		return nil
+	}
+	s.width, s.height = width, height
+	if err := s.Scene.Resize(int32(width), int32(height)); err != nil {
+		return err
+	}
+	s.Scene.Bind()
+	return nil
+}
+
+// End runs the effects over the scene and draws the result into the
+// window. The framebuffer sRGB conversion is off for the final copy,
+// effects like the tone mapping encode on their own.
+func (s *PostStack) End() error {
+	if s == nil {
+		return nil
+	}
+	s.Scene.Resolve()
+	src := s.Scene.Colour[0]
+	for i, e := range s.Effects {
+		dst := &s.ping[i%2]
+		if err := ensureTarget(dst, int32(s.width), int32(s.height)); err != nil {
+			return err
+		}
+		if err := e.Apply(src, *dst); err != nil {
+			return err
+		}
+		src = (*dst).Colour[0]
+	}
+
+	srgb := gl.IsEnabled(gl.FRAMEBUFFER_SRGB)
+	gl.Disable(gl.FRAMEBUFFER_SRGB)
+	BindDefault(s.width, s.height)
+	s.output.draw(src)
+	if srgb {
+		gl.Enable(gl.FRAMEBUFFER_SRGB)
+	}
+	return nil
+}
+
+// Delete frees the targets and the effects.
+func (s *PostStack) Delete() {
+	if s == nil {
+		return
+	}
+	for _, e := range s.Effects {
+		e.Delete()
+	}
+	for _, t := range s.ping {
+		if t != nil {
+			t.Delete()
+		}
+	}
+	s.Scene.Delete()
+	s.output.Delete()
+}
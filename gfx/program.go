@@ -0,0 +1,54 @@
+package gfx
+
+import (
+	"fmt"
+	"github.com/ginuerzh/anton-gocode/common"
+	"github.com/go-gl/gl/v3.3-core/gl"
+)
+
+// NewProgram compiles and links a vertex and a fragment shader given as
+// source, the way the renderers in this package ship their shaders.
+// #include lines are expanded first, see RegisterInclude.
+func NewProgram(vertex, fragment string) (uint32, error) {
+	vertex, err := ExpandIncludes(vertex)
+	if err != nil {
+		return 0, fmt.Errorf("vertex shader: %v", err)
+	}
+	fragment, err = ExpandIncludes(fragment)
+	if err != nil {
+		return 0, fmt.Errorf("fragment shader: %v", err)
+	}
+
+	vs, err := common.CreateShader(gl.VERTEX_SHADER, []byte(vertex+"\x00"))
+	if err != nil {
+		return 0, err
+	}
+	defer gl.DeleteShader(vs)
+
+	fs, err := common.CreateShader(gl.FRAGMENT_SHADER, []byte(fragment+"\x00"))
+	if err != nil {
+		return 0, err
+	}
+	defer gl.DeleteShader(fs)
+
+	// CreateProgram validates the program, which fails in core profiles
+	// without a bound VAO
+	var bound int32
+	gl.GetIntegerv(gl.VERTEX_ARRAY_BINDING, &bound)
+	if bound == 0 {
+		var vao uint32
+		gl.GenVertexArrays(1, &vao)
+		gl.BindVertexArray(vao)
+		defer func() {
+			gl.BindVertexArray(0)
+			gl.DeleteVertexArrays(1, &vao)
+		}()
+	}
+
+	return common.CreateProgram(vs, fs)
+}
+
+// uniform looks up a uniform location by its Go string name.
+func uniform(program uint32, name string) int32 {
+	return gl.GetUniformLocation(program, gl.Str(name+"\x00"))
+}
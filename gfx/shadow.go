@@ -0,0 +1,526 @@
+package gfx
+
+import (
+	"fmt"
+	"github.com/ginuerzh/anton-gocode/texture"
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"math"
+)
+
+// MaxCascades is the size of the cascade arrays in shadows.glsl.
+const MaxCascades = 4
+
+const shadowDepthVS = `#version 330
+
+layout(location = 0) in vec3 vertex_position;
+
+uniform mat4 light_mvp;
+
+void main() {
+	gl_Position = light_mvp * vec4(vertex_position, 1.0);
+}
+`
+
+const shadowDepthFS = `#version 330
+
+void main() {
+}
+`
+
+// shadowsGLSL adds shadows to lights.glsl, include it first:
+//
+//	#include "shadows.glsl"
+//	#include "lighting.glsl"
+//
+// It replaces light_shadow, which lights.glsl otherwise defines as
+// always lit. Positions and normals are in view space, like the lighting.
+const shadowsGLSL = `#define LIGHT_SHADOW
+#define MAX_CASCADES 4
+
+uniform sampler2DArrayShadow cascade_maps;
+uniform mat4 cascade_matrices[MAX_CASCADES]; // view space to shadow map
+uniform float cascade_splits[MAX_CASCADES];  // far view depth of each cascade
+uniform int cascade_count;                   // 0 without cascades
+uniform int cascade_light;
+
+uniform sampler2DShadow spot_map;
+uniform mat4 spot_matrix;
+uniform bool spot_shadow;
+uniform int spot_light;
+
+uniform float shadow_normal_offset;
+
+// 3x3 taps of the hardware 2x2 comparison, a smooth 4x4 texel filter
+float cascade_pcf(vec3 coord, int cascade) {
+	vec2 texel = 1.0 / vec2(textureSize(cascade_maps, 0).xy);
+	float lit = 0.0;
+	for (int x = -1; x <= 1; x++) {
+		for (int y = -1; y <= 1; y++) {
+			lit += texture(cascade_maps, vec4(coord.xy + vec2(x, y) * texel, float(cascade), coord.z));
+		}
+	}
+	return lit / 9.0;
+}
+
+float spot_pcf(vec3 coord) {
+	vec2 texel = 1.0 / vec2(textureSize(spot_map, 0));
+	float lit = 0.0;
+	for (int x = -1; x <= 1; x++) {
+		for (int y = -1; y <= 1; y++) {
+			lit += texture(spot_map, vec3(coord.xy + vec2(x, y) * texel, coord.z));
+		}
+	}
+	return lit / 9.0;
+}
+
+// cascade_index picks the cascade covering a view space position, or -1
+// beyond the shadow distance.
+int cascade_index(vec3 position) {
+	float depth = -position.z;
+	for (int i = 0; i < cascade_count; i++) {
+		if (depth <= cascade_splits[i]) {
+			return i;
+		}
+	}
+	return -1;
+}
+
+float light_shadow(int light, vec3 position, vec3 normal) {
+	// pushing the lookup out along the normal fights acne on surfaces at
+	// grazing angles, where depth bias alone fails
+	vec4 p = vec4(position + normalize(normal) * shadow_normal_offset, 1.0);
+	if (light == cascade_light && cascade_count > 0) {
+		int c = cascade_index(position);
+		if (c < 0) {
+			return 1.0;
+		}
+		vec4 coord = cascade_matrices[c] * p;
+		return cascade_pcf(coord.xyz, c);
+	}
+	if (spot_shadow && light == spot_light) {
+		vec4 coord = spot_matrix * p;
+		coord.xyz /= coord.w;
+		if (coord.w <= 0.0 || coord.z > 1.0) {
+			return 1.0;
+		}
+		return spot_pcf(coord.xyz);
+	}
+	return 1.0;
+}
+`
+
+func init() {
+	RegisterInclude("shadows.glsl", shadowsGLSL)
+}
+
+// shadowBias maps clip space to texture space, [-1, 1] to [0, 1].
+var shadowBias = [16]float32{0.5, 0, 0, 0, 0, 0.5, 0, 0, 0, 0, 0.5, 0, 0.5, 0.5, 0.5, 1}
+
+// ShadowPass draws shadow casters into a shadow map during Render.
+type ShadowPass struct {
+	ViewProj [16]float32 // light view and projection of the current map
+	mvpLoc   int32
+}
+
+// Draw draws a mesh, all submeshes, with a model matrix.
+func (p *ShadowPass) Draw(m *Mesh, model [16]float32) {
+	mvp := MulMat4(p.ViewProj, model)
+	gl.UniformMatrix4fv(p.mvpLoc, 1, false, &mvp[0])
+	m.DrawAll()
+}
+
+// shadowDepth is the depth only program and the bias settings shared by
+// the shadow maps.
+type shadowDepth struct {
+	// SlopeBias and ConstantBias go to glPolygonOffset: the depth offset
+	// grows with the slope of the polygon seen from the light
+	SlopeBias, ConstantBias float32
+	// NormalOffset moves the lookup along the surface normal, in view
+	// space units
+	NormalOffset float32
+
+	program uint32
+	pass    ShadowPass
+}
+
+func (d *shadowDepth) init() error {
+	program, err := NewProgram(shadowDepthVS, shadowDepthFS)
+	if err != nil {
+		return err
+	}
+	d.program = program
+	d.pass.mvpLoc = uniform(program, "light_mvp")
+	d.SlopeBias, d.ConstantBias = 2, 2
+	d.NormalOffset = 0.02
+	return nil
+}
+
+// begin binds the depth program and the bias for drawing into fbo.
+func (d *shadowDepth) begin(fbo uint32, size int32) {
+	gl.BindFramebuffer(gl.FRAMEBUFFER, fbo)
+	gl.Viewport(0, 0, size, size)
+	gl.Clear(gl.DEPTH_BUFFER_BIT)
+	gl.Enable(gl.POLYGON_OFFSET_FILL)
+	gl.PolygonOffset(d.SlopeBias, d.ConstantBias)
+	gl.UseProgram(d.program)
+}
+
+func (d *shadowDepth) end() {
+	gl.Disable(gl.POLYGON_OFFSET_FILL)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+}
+
+// setCompare makes a depth texture answer depth comparisons, filtered
+// over 2x2 texels, and treats everything outside it as lit.
+func setCompare(target, id uint32) {
+	gl.BindTexture(target, id)
+	gl.TexParameteri(target, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.TexParameteri(target, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(target, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_BORDER)
+	gl.TexParameteri(target, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_BORDER)
+	border := [4]float32{1, 1, 1, 1}
+	gl.TexParameterfv(target, gl.TEXTURE_BORDER_COLOR, &border[0])
+	gl.TexParameteri(target, gl.TEXTURE_COMPARE_MODE, gl.COMPARE_REF_TO_TEXTURE)
+	gl.TexParameteri(target, gl.TEXTURE_COMPARE_FUNC, gl.LEQUAL)
+}
+
+// CascadedShadowMap shadows a directional light over the view frustum
+// with several maps, each covering a slice of the frustum further away
+// than the last, so the resolution goes where the camera looks.
+type CascadedShadowMap struct {
+	shadowDepth
+	Light     int     // index of the directional light in Lighting.Lights
+	Cascades  int     // 1 to MaxCascades
+	Size      int32   // texels along each side of a map
+	Distance  float32 // how far from the camera shadows reach
+	Lambda    float32 // 0 for even splits, 1 for logarithmic
+	CasterPad float32 // how far behind a cascade casters are caught
+
+	Maps     *texture.Texture // depth texture array, one layer per cascade
+	Splits   [MaxCascades]float32
+	Matrices [MaxCascades][16]float32 // light view projections
+
+	fbos    []uint32
+	invView [16]float32
+	debug   *Pass
+}
+
+// NewCascadedShadowMap creates cascades of size x size texels for the
+// directional light at index light.
+func NewCascadedShadowMap(light, cascades int, size int32) (*CascadedShadowMap, error) {
+	if cascades < 1 || cascades > MaxCascades {
+		return nil, fmt.Errorf("%d shadow cascades, want 1 to %d", cascades, MaxCascades)
+	}
+	c := &CascadedShadowMap{
+		Light:     light,
+		Cascades:  cascades,
+		Size:      size,
+		Distance:  50,
+		Lambda:    0.75,
+		CasterPad: 50,
+		invView:   Ident(),
+	}
+	if err := c.init(); err != nil {
+		return nil, err
+	}
+
+	c.Maps = &texture.Texture{
+		Target:         gl.TEXTURE_2D_ARRAY,
+		Width:          size,
+		Height:         size,
+		Levels:         1,
+		InternalFormat: gl.DEPTH_COMPONENT24,
+	}
+	gl.GenTextures(1, &c.Maps.ID)
+	gl.BindTexture(gl.TEXTURE_2D_ARRAY, c.Maps.ID)
+	gl.TexImage3D(gl.TEXTURE_2D_ARRAY, 0, gl.DEPTH_COMPONENT24, size, size, int32(cascades), 0,
+		gl.DEPTH_COMPONENT, gl.FLOAT, nil)
+	setCompare(gl.TEXTURE_2D_ARRAY, c.Maps.ID)
+
+	c.fbos = make([]uint32, cascades)
+	gl.GenFramebuffers(int32(cascades), &c.fbos[0])
+	for i, fbo := range c.fbos {
+		gl.BindFramebuffer(gl.FRAMEBUFFER, fbo)
+		gl.FramebufferTextureLayer(gl.FRAMEBUFFER, gl.DEPTH_ATTACHMENT, c.Maps.ID, 0, int32(i))
+		gl.DrawBuffer(gl.NONE)
+		gl.ReadBuffer(gl.NONE)
+		if err := checkFramebuffer(fmt.Sprintf("shadow cascade %d", i)); err != nil {
+			c.Delete()
+			return nil, err
+		}
+	}
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+	return c, nil
+}
+
+// Update fits the cascades to the camera: its view matrix, vertical field
+// of view in degrees, aspect ratio and near plane, and the direction the
+// light shines.
+func (c *CascadedShadowMap) Update(view [16]float32, fovy, aspect, near float32, direction [3]float32) {
+	c.invView = InvertMat4(view)
+	far := c.Distance
+	n := c.Cascades
+
+	// the practical split scheme blends logarithmic splits, which keep
+	// the texel density even, with uniform ones, which spend less on the
+	// near slices
+	for i := 0; i < n; i++ {
+		f := float64(i+1) / float64(n)
+		log := float64(near) * math.Pow(float64(far/near), f)
+		uniform := float64(near) + float64(far-near)*f
+		c.Splits[i] = float32(float64(c.Lambda)*log + (1-float64(c.Lambda))*uniform)
+	}
+
+	tanY := float32(math.Tan(float64(fovy) * math.Pi / 360))
+	tanX := tanY * aspect
+	dir := normalize3(direction)
+	up := [3]float32{0, 1, 0}
+	if math.Abs(float64(dir[1])) > 0.99 {
+		up = [3]float32{0, 0, 1}
+	}
+
+	start := near
+	for i := 0; i < n; i++ {
+		end := c.Splits[i]
+
+		// a bounding sphere of the slice does not change size as the
+		// camera turns, so the shadows do not shimmer
+		var corners [8][3]float32
+		var centre [3]float32
+		for k := 0; k < 8; k++ {
+			d := start
+			if k >= 4 {
+				d = end
+			}
+			x, y := tanX*d, tanY*d
+			if k&1 != 0 {
+				x = -x
+			}
+			if k&2 != 0 {
+				y = -y
+			}
+			corners[k] = transformPoint(c.invView, [3]float32{x, y, -d})
+			for j := range centre {
+				centre[j] += corners[k][j] / 8
+			}
+		}
+		radius := float32(0)
+		for _, p := range corners {
+			d := sub3(p, centre)
+			if l := float32(math.Sqrt(float64(dot3(d, d)))); l > radius {
+				radius = l
+			}
+		}
+		radius = float32(math.Ceil(float64(radius)*16)) / 16
+
+		back := radius + c.CasterPad
+		eye := sub3(centre, [3]float32{dir[0] * back, dir[1] * back, dir[2] * back})
+		lightView := LookAt(eye, centre, up)
+		proj := Ortho(-radius, radius, -radius, radius, 0, back+radius)
+
+		// move in whole texels only, or the shadow edges crawl
+		vp := MulMat4(proj, lightView)
+		half := float32(c.Size) / 2
+		origin := transformPoint(vp, [3]float32{0, 0, 0})
+		ox, oy := origin[0]*half, origin[1]*half
+		proj[12] += (float32(math.Floor(float64(ox)+0.5)) - ox) / half
+		proj[13] += (float32(math.Floor(float64(oy)+0.5)) - oy) / half
+
+		c.Matrices[i] = MulMat4(proj, lightView)
+		start = end
+	}
+}
+
+// Render draws the casters into every cascade. draw is called once per
+// cascade and should draw all casters with the pass. The window
+// framebuffer is bound afterwards, with the viewport left at the map
+// size.
+func (c *CascadedShadowMap) Render(draw func(p *ShadowPass)) {
+	for i, fbo := range c.fbos {
+		c.begin(fbo, c.Size)
+		c.pass.ViewProj = c.Matrices[i]
+		draw(&c.pass)
+	}
+	c.end()
+}
+
+const shadowDebugFS = `#version 330
+
+in vec2 texcoord;
+
+uniform sampler2DArray source;
+uniform int layer;
+
+out vec4 frag_colour;
+
+void main() {
+	float d = texture(source, vec3(texcoord, float(layer))).r;
+	frag_colour = vec4(vec3(d), 1.0);
+}
+`
+
+// DrawDebug shows the depth of each cascade in a row of size x size
+// squares along the bottom of the current framebuffer, starting at x, y.
+// The viewport is left on the last square.
+func (c *CascadedShadowMap) DrawDebug(x, y, size int32) error {
+	if c.debug == nil {
+		debug, err := NewPass(shadowDebugFS, nil)
+		if err != nil {
+			return err
+		}
+		c.debug = debug
+	}
+	// comparison off, to read the raw depth
+	gl.BindTexture(gl.TEXTURE_2D_ARRAY, c.Maps.ID)
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_COMPARE_MODE, gl.NONE)
+	for i := 0; i < c.Cascades; i++ {
+		gl.Viewport(x+int32(i)*(size+4), y, size, size)
+		c.debug.Uniforms["layer"] = int32(i)
+		c.debug.draw(c.Maps)
+	}
+	gl.BindTexture(gl.TEXTURE_2D_ARRAY, c.Maps.ID)
+	gl.TexParameteri(gl.TEXTURE_2D_ARRAY, gl.TEXTURE_COMPARE_MODE, gl.COMPARE_REF_TO_TEXTURE)
+	return nil
+}
+
+// Delete frees the maps and the programs.
+func (c *CascadedShadowMap) Delete() {
+	if len(c.fbos) > 0 {
+		gl.DeleteFramebuffers(int32(len(c.fbos)), &c.fbos[0])
+		c.fbos = nil
+	}
+	if c.Maps != nil {
+		c.Maps.Delete()
+	}
+	if c.debug != nil {
+		c.debug.Delete()
+	}
+	gl.DeleteProgram(c.program)
+}
+
+// SpotShadowMap shadows a spot light with one perspective map covering
+// its cone.
+type SpotShadowMap struct {
+	shadowDepth
+	Light  int // index of the spot light in Lighting.Lights
+	Size   int32
+	Near   float32
+	Target *RenderTarget
+	Matrix [16]float32 // light view projection
+
+	invView [16]float32
+}
+
+// NewSpotShadowMap creates a size x size map for the spot light at index
+// light.
+func NewSpotShadowMap(light int, size int32) (*SpotShadowMap, error) {
+	s := &SpotShadowMap{Light: light, Size: size, Near: 0.1, invView: Ident()}
+	if err := s.init(); err != nil {
+		return nil, err
+	}
+	target, err := NewRenderTargetWith(size, size, TargetOptions{
+		Depth:        gl.DEPTH_COMPONENT24,
+		DepthTexture: true,
+	})
+	if err != nil {
+		gl.DeleteProgram(s.program)
+		return nil, err
+	}
+	s.Target = target
+	setCompare(gl.TEXTURE_2D, target.Depth.ID)
+	return s, nil
+}
+
+// Update points the map along the light, seen from a camera with the
+// given view matrix.
+func (s *SpotShadowMap) Update(light Light, view [16]float32) {
+	s.invView = InvertMat4(view)
+	dir := normalize3(light.Direction)
+	up := [3]float32{0, 1, 0}
+	if math.Abs(float64(dir[1])) > 0.99 {
+		up = [3]float32{0, 0, 1}
+	}
+	far := light.Range
+	if far <= s.Near {
+		far = 100
+	}
+	fovy := 2 * light.OuterCone * 180 / math.Pi
+	target := [3]float32{light.Position[0] + dir[0], light.Position[1] + dir[1], light.Position[2] + dir[2]}
+	s.Matrix = MulMat4(Perspective(fovy, 1, s.Near, far), LookAt(light.Position, target, up))
+}
+
+// Render draws the casters into the map.
+func (s *SpotShadowMap) Render(draw func(p *ShadowPass)) {
+	s.begin(s.Target.FBO, s.Size)
+	s.pass.ViewProj = s.Matrix
+	draw(&s.pass)
+	s.end()
+}
+
+// Delete frees the map and the program.
+func (s *SpotShadowMap) Delete() {
+	s.Target.Delete()
+	gl.DeleteProgram(s.program)
+}
+
+// ShadowUniforms holds the locations of the shadows.glsl uniforms of a
+// program.
+type ShadowUniforms struct {
+	cascadeMaps, cascadeMatrices, cascadeSplits, cascadeCount, cascadeLight int32
+	spotMap, spotMatrix, spotShadow, spotLight                              int32
+	normalOffset                                                            int32
+}
+
+// NewShadowUniforms looks up the shadow uniforms of a program that
+// includes shadows.glsl.
+func NewShadowUniforms(program uint32) ShadowUniforms {
+	return ShadowUniforms{
+		cascadeMaps:     uniform(program, "cascade_maps"),
+		cascadeMatrices: uniform(program, "cascade_matrices"),
+		cascadeSplits:   uniform(program, "cascade_splits"),
+		cascadeCount:    uniform(program, "cascade_count"),
+		cascadeLight:    uniform(program, "cascade_light"),
+		spotMap:         uniform(program, "spot_map"),
+		spotMatrix:      uniform(program, "spot_matrix"),
+		spotShadow:      uniform(program, "spot_shadow"),
+		spotLight:       uniform(program, "spot_light"),
+		normalOffset:    uniform(program, "shadow_normal_offset"),
+	}
+}
+
+// Set binds the shadow maps, either may be nil, to texture units unit and
+// unit + 1 and uploads their matrices into the program in use. It has to
+// be called even without shadows, the two samplers must not share a unit.
+func (u ShadowUniforms) Set(cascades *CascadedShadowMap, spot *SpotShadowMap, unit uint32) {
+	gl.Uniform1i(u.cascadeMaps, int32(unit))
+	gl.Uniform1i(u.spotMap, int32(unit+1))
+
+	if cascades != nil {
+		var matrices [MaxCascades * 16]float32
+		for i := 0; i < cascades.Cascades; i++ {
+			m := MulMat4(shadowBias, MulMat4(cascades.Matrices[i], cascades.invView))
+			copy(matrices[i*16:], m[:])
+		}
+		gl.UniformMatrix4fv(u.cascadeMatrices, MaxCascades, false, &matrices[0])
+		gl.Uniform1fv(u.cascadeSplits, MaxCascades, &cascades.Splits[0])
+		gl.Uniform1i(u.cascadeCount, int32(cascades.Cascades))
+		gl.Uniform1i(u.cascadeLight, int32(cascades.Light))
+		gl.Uniform1f(u.normalOffset, cascades.NormalOffset)
+		cascades.Maps.Bind(unit)
+	} else {
+		gl.Uniform1i(u.cascadeCount, 0)
+	}
+
+	if spot != nil {
+		m := MulMat4(shadowBias, MulMat4(spot.Matrix, spot.invView))
+		gl.UniformMatrix4fv(u.spotMatrix, 1, false, &m[0])
+		gl.Uniform1i(u.spotShadow, 1)
+		gl.Uniform1i(u.spotLight, int32(spot.Light))
+		if cascades == nil {
+			gl.Uniform1f(u.normalOffset, spot.NormalOffset)
+		}
+		spot.Target.Depth.Bind(unit + 1)
+	} else {
+		gl.Uniform1i(u.spotShadow, 0)
+	}
+}
@@ -0,0 +1,112 @@
+package gfx
+
+import (
+	"github.com/ginuerzh/anton-gocode/texture"
+	"github.com/go-gl/gl/v3.3-core/gl"
+)
+
+const skyboxVS = `#version 330
+
+layout(location = 0) in vec3 vertex_position;
+
+uniform mat4 view, proj;
+
+out vec3 texcoords;
+
+void main() {
+	texcoords = vertex_position;
+	// w for z puts the sky on the far plane after the perspective divide
+	gl_Position = (proj * view * vec4(vertex_position, 1.0)).xyww;
+}
+`
+
+const skyboxFS = `#version 330
+
+in vec3 texcoords;
+
+uniform samplerCube cube;
+
+out vec4 frag_colour;
+
+void main() {
+	frag_colour = texture(cube, texcoords);
+}
+`
+
+// Skybox draws a cube map around the camera, behind everything else.
+type Skybox struct {
+	Cube    *texture.Texture
+	program uint32
+	mesh    *Mesh
+	viewLoc int32
+	projLoc int32
+	cubeLoc int32
+}
+
+// NewSkybox creates a skybox showing cube. The skybox does not own the
+// cube map, Delete leaves it alone.
+func NewSkybox(cube *texture.Texture) (*Skybox, error) {
+	points := []float32{
+		-1, 1, -1, -1, -1, -1, 1, -1, -1, 1, -1, -1, 1, 1, -1, -1, 1, -1,
+		-1, -1, 1, -1, -1, -1, -1, 1, -1, -1, 1, -1, -1, 1, 1, -1, -1, 1,
+		1, -1, -1, 1, -1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, 1, -1, -1,
+		-1, -1, 1, -1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, 1, -1, -1, 1,
+		-1, 1, -1, 1, 1, -1, 1, 1, 1, 1, 1, 1, -1, 1, 1, -1, 1, -1,
+		-1, -1, -1, -1, -1, 1, 1, -1, -1, 1, -1, -1, -1, -1, 1, 1, -1, 1,
+	}
+	layout := Planar(Attrib{Name: "vertex_position", Location: PositionLoc, Size: 3, Type: gl.FLOAT})
+	mesh, err := NewMesh(layout, nil, points)
+	if err != nil {
+		return nil, err
+	}
+
+	program, err := NewProgram(skyboxVS, skyboxFS)
+	if err != nil {
+		mesh.Delete()
+		return nil, err
+	}
+
+	return &Skybox{
+		Cube:    cube,
+		program: program,
+		mesh:    mesh,
+		viewLoc: uniform(program, "view"),
+		projLoc: uniform(program, "proj"),
+		cubeLoc: uniform(program, "cube"),
+	}, nil
+}
+
+// Draw renders the skybox with the camera rotation only, so it never
+// comes closer. Drawn last, after the opaque geometry, only the
+// uncovered pixels pay for it. The depth test and culling state of the
+// caller is restored, the skybox program stays in use.
+func (s *Skybox) Draw(view, proj [16]float32) {
+	view[12], view[13], view[14] = 0, 0, 0
+
+	var depthFunc int32
+	gl.GetIntegerv(gl.DEPTH_FUNC, &depthFunc)
+	cull := gl.IsEnabled(gl.CULL_FACE)
+
+	gl.DepthFunc(gl.LEQUAL)
+	gl.DepthMask(false)
+	gl.Disable(gl.CULL_FACE)
+
+	gl.UseProgram(s.program)
+	gl.UniformMatrix4fv(s.viewLoc, 1, false, &view[0])
+	gl.UniformMatrix4fv(s.projLoc, 1, false, &proj[0])
+	gl.Uniform1i(s.cubeLoc, 0)
+	s.Cube.Bind(0)
+	s.mesh.Draw()
+
+	gl.DepthMask(true)
+	gl.DepthFunc(uint32(depthFunc))
+	if cull {
+		gl.Enable(gl.CULL_FACE)
+	}
+}
+
+// Delete frees the program and the cube geometry.
+func (s *Skybox) Delete() {
+	s.mesh.Delete()
+	gl.DeleteProgram(s.program)
+}
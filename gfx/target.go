@@ -0,0 +1,353 @@
+package gfx
+
+import (
+	"errors"
+	"fmt"
+	"github.com/ginuerzh/anton-gocode/common"
+	"github.com/ginuerzh/anton-gocode/texture"
+	"github.com/go-gl/gl/v3.3-core/gl"
+)
+
+// TargetOptions describes the attachments of a RenderTarget.
+type TargetOptions struct {
+	// Colour lists the internal formats of the colour attachments, in
+	// draw buffer order: gl.RGBA8, gl.RGBA16F, gl.R32UI, ...
+	Colour []int32
+	// Depth is a depth or depth-stencil format, gl.DEPTH_COMPONENT24,
+	// gl.DEPTH24_STENCIL8, ..., or 0 for none.
+	Depth int32
+	// DepthTexture keeps depth in a texture that can be sampled, instead
+	// of a renderbuffer.
+	DepthTexture bool
+	// Samples above 1 render into multisampled renderbuffers, which
+	// Resolve copies into the textures.
+	Samples int32
+}
+
+// RenderTarget is a framebuffer object to render into instead of the
+// window. Multisampled targets render into a second framebuffer of
+// renderbuffers, the textures only hold the result after Resolve.
+type RenderTarget struct {
+	FBO           uint32
+	Colour        []*texture.Texture
+	Depth         *texture.Texture // with DepthTexture
+	Width, Height int32
+	Options       TargetOptions
+
+	depthRB  uint32 // depth renderbuffer without DepthTexture
+	msFBO    uint32
+	msColour []uint32
+	msDepth  uint32
+	drawBufs []uint32
+	stencil  bool
+}
+
+// NewRenderTarget creates a width x height target with a colour texture
+// of the given internal format, e.g. gl.RGBA16F for HDR rendering, and a
+// depth renderbuffer.
+func NewRenderTarget(width, height, format int32) (*RenderTarget, error) {
+	return NewRenderTargetWith(width, height, TargetOptions{
+		Colour: []int32{format},
+		Depth:  gl.DEPTH_COMPONENT24,
+	})
+}
+
+// NewRenderTargetWith creates a target with the attachments of opts.
+func NewRenderTargetWith(width, height int32, opts TargetOptions) (*RenderTarget, error) {
+	if len(opts.Colour) == 0 && opts.Depth == 0 {
+		return nil, errors.New("render target without attachments")
+	}
+	var maxBuffers int32
+	gl.GetIntegerv(gl.MAX_COLOR_ATTACHMENTS, &maxBuffers)
+	if int32(len(opts.Colour)) > maxBuffers {
+		return nil, fmt.Errorf("render target with %d colour attachments, the maximum is %d",
+			len(opts.Colour), maxBuffers)
+	}
+	if opts.Samples > 1 {
+		var maxSamples int32
+		gl.GetIntegerv(gl.MAX_SAMPLES, &maxSamples)
+		if opts.Samples > maxSamples {
+			opts.Samples = maxSamples
+		}
+	}
+
+	r := &RenderTarget{Options: opts}
+	if err := r.create(width, height); err != nil {
+		r.Delete()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *RenderTarget) create(width, height int32) error {
+	r.Width, r.Height = width, height
+	opts := r.Options
+	r.stencil = opts.Depth == gl.DEPTH24_STENCIL8 || opts.Depth == gl.DEPTH32F_STENCIL8
+	depthPoint := uint32(gl.DEPTH_ATTACHMENT)
+	if r.stencil {
+		depthPoint = gl.DEPTH_STENCIL_ATTACHMENT
+	}
+
+	gl.GenFramebuffers(1, &r.FBO)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, r.FBO)
+	r.drawBufs = r.drawBufs[:0]
+	for i, format := range opts.Colour {
+		t := newTargetTexture(width, height, format)
+		gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0+uint32(i), gl.TEXTURE_2D, t.ID, 0)
+		r.Colour = append(r.Colour, t)
+		r.drawBufs = append(r.drawBufs, gl.COLOR_ATTACHMENT0+uint32(i))
+	}
+	switch {
+	case opts.Depth != 0 && opts.DepthTexture:
+		r.Depth = newTargetTexture(width, height, opts.Depth)
+		gl.FramebufferTexture2D(gl.FRAMEBUFFER, depthPoint, gl.TEXTURE_2D, r.Depth.ID, 0)
+	case opts.Depth != 0 && opts.Samples <= 1:
+		r.depthRB = newRenderbuffer(width, height, opts.Depth, 0)
+		gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, depthPoint, gl.RENDERBUFFER, r.depthRB)
+	}
+	r.setDrawBuffers()
+	if err := checkFramebuffer("render target"); err != nil {
+		return err
+	}
+
+	if opts.Samples > 1 {
+		gl.GenFramebuffers(1, &r.msFBO)
+		gl.BindFramebuffer(gl.FRAMEBUFFER, r.msFBO)
+		for i, format := range opts.Colour {
+			rb := newRenderbuffer(width, height, format, opts.Samples)
+			gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0+uint32(i), gl.RENDERBUFFER, rb)
+			r.msColour = append(r.msColour, rb)
+		}
+		if opts.Depth != 0 {
+			r.msDepth = newRenderbuffer(width, height, opts.Depth, opts.Samples)
+			gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, depthPoint, gl.RENDERBUFFER, r.msDepth)
+		}
+		r.setDrawBuffers()
+		if err := checkFramebuffer("multisampled render target"); err != nil {
+			return err
+		}
+	}
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+	return nil
+}
+
+// setDrawBuffers routes the fragment outputs of the bound framebuffer to
+// its colour attachments, or to nothing for depth only targets.
+func (r *RenderTarget) setDrawBuffers() {
+	if len(r.drawBufs) == 0 {
+		gl.DrawBuffer(gl.NONE)
+		gl.ReadBuffer(gl.NONE)
+		return
+	}
+	gl.DrawBuffers(int32(len(r.drawBufs)), &r.drawBufs[0])
+}
+
+// newTargetTexture creates an empty single level texture to render into.
+func newTargetTexture(width, height, internal int32) *texture.Texture {
+	t := &texture.Texture{
+		Target:         gl.TEXTURE_2D,
+		Width:          width,
+		Height:         height,
+		Levels:         1,
+		InternalFormat: internal,
+	}
+	format, typ := pixelFormat(internal)
+	gl.GenTextures(1, &t.ID)
+	gl.BindTexture(gl.TEXTURE_2D, t.ID)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, internal, width, height, 0, format, typ, nil)
+
+	// integer textures cannot be filtered
+	filter := int32(gl.LINEAR)
+	if format == gl.RED_INTEGER || format == gl.RG_INTEGER || format == gl.RGBA_INTEGER {
+		filter = gl.NEAREST
+	}
+	t.SetSampler(texture.Sampler{
+		MinFilter: filter,
+		MagFilter: filter,
+		WrapS:     gl.CLAMP_TO_EDGE,
+		WrapT:     gl.CLAMP_TO_EDGE,
+	})
+	return t
+}
+
+// pixelFormat picks a pixel format and type that go with an internal
+// format, as TexImage2D wants them even without data.
+func pixelFormat(internal int32) (uint32, uint32) {
+	switch internal {
+	case gl.DEPTH_COMPONENT16, gl.DEPTH_COMPONENT24, gl.DEPTH_COMPONENT32, gl.DEPTH_COMPONENT32F:
+		return gl.DEPTH_COMPONENT, gl.FLOAT
+	case gl.DEPTH24_STENCIL8:
+		return gl.DEPTH_STENCIL, gl.UNSIGNED_INT_24_8
+	case gl.DEPTH32F_STENCIL8:
+		return gl.DEPTH_STENCIL, gl.FLOAT_32_UNSIGNED_INT_24_8_REV
+	case gl.R8, gl.R16F, gl.R32F:
+		return gl.RED, gl.FLOAT
+	case gl.RG8, gl.RG16F, gl.RG32F:
+		return gl.RG, gl.FLOAT
+	case gl.R32UI:
+		return gl.RED_INTEGER, gl.UNSIGNED_INT
+	case gl.R32I:
+		return gl.RED_INTEGER, gl.INT
+	case gl.RG32UI:
+		return gl.RG_INTEGER, gl.UNSIGNED_INT
+	case gl.RGBA32UI:
+		return gl.RGBA_INTEGER, gl.UNSIGNED_INT
+	}
+	return gl.RGBA, gl.FLOAT
+}
+
+func newRenderbuffer(width, height, format, samples int32) uint32 {
+	var rb uint32
+	gl.GenRenderbuffers(1, &rb)
+	gl.BindRenderbuffer(gl.RENDERBUFFER, rb)
+	if samples > 1 {
+		gl.RenderbufferStorageMultisample(gl.RENDERBUFFER, samples, uint32(format), width, height)
+	} else {
+		gl.RenderbufferStorage(gl.RENDERBUFFER, uint32(format), width, height)
+	}
+	return rb
+}
+
+// FramebufferStatus names a CheckFramebufferStatus result.
+func FramebufferStatus(status uint32) string {
+	switch status {
+	case gl.FRAMEBUFFER_COMPLETE:
+		return "GL_FRAMEBUFFER_COMPLETE"
+	case gl.FRAMEBUFFER_UNDEFINED:
+		return "GL_FRAMEBUFFER_UNDEFINED: the default framebuffer does not exist"
+	case gl.FRAMEBUFFER_INCOMPLETE_ATTACHMENT:
+		return "GL_FRAMEBUFFER_INCOMPLETE_ATTACHMENT: an attachment is not renderable or has no size"
+	case gl.FRAMEBUFFER_INCOMPLETE_MISSING_ATTACHMENT:
+		return "GL_FRAMEBUFFER_INCOMPLETE_MISSING_ATTACHMENT: nothing is attached"
+	case gl.FRAMEBUFFER_INCOMPLETE_DRAW_BUFFER:
+		return "GL_FRAMEBUFFER_INCOMPLETE_DRAW_BUFFER: a draw buffer has no attachment"
+	case gl.FRAMEBUFFER_INCOMPLETE_READ_BUFFER:
+		return "GL_FRAMEBUFFER_INCOMPLETE_READ_BUFFER: the read buffer has no attachment"
+	case gl.FRAMEBUFFER_UNSUPPORTED:
+		return "GL_FRAMEBUFFER_UNSUPPORTED: the combination of formats is not supported"
+	case gl.FRAMEBUFFER_INCOMPLETE_MULTISAMPLE:
+		return "GL_FRAMEBUFFER_INCOMPLETE_MULTISAMPLE: attachments differ in sample count"
+	case gl.FRAMEBUFFER_INCOMPLETE_LAYER_TARGETS:
+		return "GL_FRAMEBUFFER_INCOMPLETE_LAYER_TARGETS: attachments differ in layering"
+	}
+	return fmt.Sprintf("unknown framebuffer status 0x%x", status)
+}
+
+// checkFramebuffer checks the bound framebuffer, logging why it is
+// incomplete.
+func checkFramebuffer(what string) error {
+	status := gl.CheckFramebufferStatus(gl.FRAMEBUFFER)
+	if status == gl.FRAMEBUFFER_COMPLETE {
+		return nil
+	}
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+	msg := FramebufferStatus(status)
+	common.GLogErr("ERROR: %s incomplete: %s\n", what, msg)
+	return fmt.Errorf("%s incomplete: %s", what, msg)
+}
+
+// Bind makes the target the draw framebuffer and covers it with the
+// viewport. Multisampled targets bind their renderbuffers.
+func (r *RenderTarget) Bind() {
+	if r.msFBO != 0 {
+		gl.BindFramebuffer(gl.FRAMEBUFFER, r.msFBO)
+	} else {
+		gl.BindFramebuffer(gl.FRAMEBUFFER, r.FBO)
+	}
+	gl.Viewport(0, 0, r.Width, r.Height)
+}
+
+// BindDefault switches back to drawing into the window.
+func BindDefault(width, height int) {
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+	gl.Viewport(0, 0, int32(width), int32(height))
+}
+
+// Resolve copies the multisampled attachments into the textures. It does
+// nothing for targets without samples. The framebuffer binding is reset
+// to the window.
+func (r *RenderTarget) Resolve() {
+	if r.msFBO == 0 {
+		return
+	}
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, r.msFBO)
+	gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, r.FBO)
+
+	// one attachment at a time, a blit copies the read buffer into every
+	// draw buffer
+	for _, buf := range r.drawBufs {
+		gl.ReadBuffer(buf)
+		gl.DrawBuffer(buf)
+		gl.BlitFramebuffer(0, 0, r.Width, r.Height, 0, 0, r.Width, r.Height,
+			gl.COLOR_BUFFER_BIT, gl.NEAREST)
+	}
+	if r.Depth != nil {
+		mask := uint32(gl.DEPTH_BUFFER_BIT)
+		if r.stencil {
+			mask |= gl.STENCIL_BUFFER_BIT
+		}
+		gl.BlitFramebuffer(0, 0, r.Width, r.Height, 0, 0, r.Width, r.Height, mask, gl.NEAREST)
+	}
+	if len(r.drawBufs) > 0 {
+		gl.DrawBuffers(int32(len(r.drawBufs)), &r.drawBufs[0])
+		gl.BindFramebuffer(gl.READ_FRAMEBUFFER, r.msFBO)
+		gl.ReadBuffer(gl.COLOR_ATTACHMENT0)
+	}
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+}
+
+// BlitToDefault copies the first colour attachment into the window,
+// stretched to width x height. Multisampled targets are resolved into
+// their textures first, a multisampled read framebuffer cannot be blitted
+// into the multisampled window StartGL asks for.
+func (r *RenderTarget) BlitToDefault(width, height int) {
+	r.Resolve()
+	filter := uint32(gl.LINEAR)
+	if r.Width == int32(width) && r.Height == int32(height) {
+		filter = gl.NEAREST
+	}
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, r.FBO)
+	gl.ReadBuffer(gl.COLOR_ATTACHMENT0)
+	gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, 0)
+	gl.BlitFramebuffer(0, 0, r.Width, r.Height, 0, 0, int32(width), int32(height),
+		gl.COLOR_BUFFER_BIT, filter)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+}
+
+// Resize recreates the attachments at a new size, their contents are
+// lost. Textures taken from the target before are deleted.
+func (r *RenderTarget) Resize(width, height int32) error {
+	if width == r.Width && height == r.Height {
+		return nil
+	}
+	r.Delete()
+	return r.create(width, height)
+}
+
+// Delete frees the framebuffers and all their attachments.
+func (r *RenderTarget) Delete() {
+	for _, t := range r.Colour {
+		t.Delete()
+	}
+	r.Colour = nil
+	if r.Depth != nil {
+		r.Depth.Delete()
+		r.Depth = nil
+	}
+	if len(r.msColour) > 0 {
+		gl.DeleteRenderbuffers(int32(len(r.msColour)), &r.msColour[0])
+		r.msColour = nil
+	}
+	for _, rb := range []*uint32{&r.depthRB, &r.msDepth} {
+		if *rb != 0 {
+			gl.DeleteRenderbuffers(1, rb)
+			*rb = 0
+		}
+	}
+	for _, fbo := range []*uint32{&r.FBO, &r.msFBO} {
+		if *fbo != 0 {
+			gl.DeleteFramebuffers(1, fbo)
+			*fbo = 0
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package gfx
+
+import (
+	"github.com/ginuerzh/anton-gocode/texture"
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"math"
+)
+
+const toneMapFS = `#version 330
+
+in vec2 texcoord;
+
+uniform sampler2D hdr;
+uniform float exposure;
+uniform float white;
+uniform int operator;
+uniform bool encode;
+
+out vec4 frag_colour;
+
+vec3 reinhard(vec3 c) {
+	if (white > 0.0) {
+		return c * (1.0 + c / (white * white)) / (1.0 + c);
+	}
+	return c / (1.0 + c);
+}
+
+// Stephen Hill's fit of the ACES reference rendering and output
+// transforms, with the sRGB to ACES and back matrices around it
+const mat3 aces_in = mat3(
+	0.59719, 0.07600, 0.02840,
+	0.35458, 0.90834, 0.13383,
+	0.04823, 0.01566, 0.83777);
+const mat3 aces_out = mat3(
+	1.60475, -0.10208, -0.00327,
+	-0.53108, 1.10813, -0.07276,
+	-0.07367, -0.00605, 1.07602);
+
+vec3 aces(vec3 c) {
+	c = aces_in * c;
+	vec3 a = c * (c + 0.0245786) - 0.000090537;
+	vec3 b = c * (0.983729 * c + 0.4329510) + 0.238081;
+	return aces_out * (a / b);
+}
+
+vec3 srgb(vec3 c) {
+	vec3 lo = 12.92 * c;
+	vec3 hi = 1.055 * pow(c, vec3(1.0 / 2.4)) - 0.055;
+	return mix(lo, hi, step(vec3(0.0031308), c));
+}
+
+void main() {
+	vec3 c = texture(hdr, texcoord).rgb * exposure;
+	if (operator == 1) {
+		c = reinhard(c);
+	} else if (operator == 2) {
+		c = aces(c);
+	}
+	c = clamp(c, 0.0, 1.0);
+	if (encode) {
+		c = srgb(c);
+	}
+	frag_colour = vec4(c, 1.0);
+}
+`
+
+// ToneMapper is the curve that maps HDR colours to the displayable range.
+type ToneMapper int32
+
+const (
+	ToneClamp    ToneMapper = iota // exposure only, clipped at 1
+	ToneReinhard                   // c / (1 + c), or extended with White
+	ToneACES                       // fitted ACES filmic curve
+)
+
+// ToneMap draws an HDR texture to the current framebuffer, mapped to
+// [0, 1] and encoded as sRGB.
+type ToneMap struct {
+	Operator ToneMapper
+	Exposure float32 // in stops, 0 leaves the brightness alone
+	White    float32 // smallest value Reinhard maps to white, 0 for none
+
+	program     uint32
+	hdrLoc      int32
+	exposureLoc int32
+	whiteLoc    int32
+	operatorLoc int32
+	encodeLoc   int32
+}
+
+// NewToneMap creates the tone mapping pass with the ACES curve.
+func NewToneMap() (*ToneMap, error) {
+	program, err := NewProgram(fullscreenVS, toneMapFS)
+	if err != nil {
+		return nil, err
+	}
+	return &ToneMap{
+		Operator:    ToneACES,
+		program:     program,
+		hdrLoc:      uniform(program, "hdr"),
+		exposureLoc: uniform(program, "exposure"),
+		whiteLoc:    uniform(program, "white"),
+		operatorLoc: uniform(program, "operator"),
+		encodeLoc:   uniform(program, "encode"),
+	}, nil
+}
+
+// Draw tone maps src over the whole viewport. With gl.FRAMEBUFFER_SRGB
+// enabled the framebuffer does the sRGB encoding, which then has to be
+// sRGB capable, otherwise the shader does it.
+func (t *ToneMap) Draw(src *texture.Texture) {
+	t.draw(src, !gl.IsEnabled(gl.FRAMEBUFFER_SRGB))
+}
+
+// Apply tone maps src into dst as a post-processing effect. The result is
+// always sRGB encoded by the shader, ready for display.
+func (t *ToneMap) Apply(src *texture.Texture, dst *RenderTarget) error {
+	dst.Bind()
+	t.draw(src, true)
+	return nil
+}
+
+func (t *ToneMap) draw(src *texture.Texture, encode bool) {
+	e := int32(0)
+	if encode {
+		e = 1
+	}
+	gl.UseProgram(t.program)
+	gl.Uniform1i(t.hdrLoc, 0)
+	gl.Uniform1f(t.exposureLoc, float32(math.Exp2(float64(t.Exposure))))
+	gl.Uniform1f(t.whiteLoc, t.White)
+	gl.Uniform1i(t.operatorLoc, int32(t.Operator))
+	gl.Uniform1i(t.encodeLoc, e)
+	src.Bind(0)
+	drawFullscreen()
+}
+
+// Delete frees the program.
+func (t *ToneMap) Delete() {
+	gl.DeleteProgram(t.program)
+}
@@ -0,0 +1,240 @@
+package gltf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+/* accessor component types */
+const (
+	typeByte          = 5120
+	typeUnsignedByte  = 5121
+	typeShort         = 5122
+	typeUnsignedShort = 5123
+	typeUnsignedInt   = 5125
+	typeFloat         = 5126
+)
+
+var componentSizes = map[int]int{
+	typeByte:          1,
+	typeUnsignedByte:  1,
+	typeShort:         2,
+	typeUnsignedShort: 2,
+	typeUnsignedInt:   4,
+	typeFloat:         4,
+}
+
+var typeComponents = map[string]int{
+	"SCALAR": 1,
+	"VEC2":   2,
+	"VEC3":   3,
+	"VEC4":   4,
+	"MAT2":   4,
+	"MAT3":   9,
+	"MAT4":   16,
+}
+
+// component decodes one value, normalizing integers into [0, 1] or [-1, 1]
+// when asked to.
+func component(b []byte, ctype int, normalized bool) float32 {
+	switch ctype {
+	case typeByte:
+		v := float32(int8(b[0]))
+		if normalized {
+			return float32(math.Max(float64(v/127), -1))
+		}
+		return v
+	case typeUnsignedByte:
+		v := float32(b[0])
+		if normalized {
+			return v / 255
+		}
+		return v
+	case typeShort:
+		v := float32(int16(binary.LittleEndian.Uint16(b)))
+		if normalized {
+			return float32(math.Max(float64(v/32767), -1))
+		}
+		return v
+	case typeUnsignedShort:
+		v := float32(binary.LittleEndian.Uint16(b))
+		if normalized {
+			return v / 65535
+		}
+		return v
+	case typeUnsignedInt:
+		v := float32(binary.LittleEndian.Uint32(b))
+		if normalized {
+			return v / 4294967295
+		}
+		return v
+	case typeFloat:
+		return math.Float32frombits(binary.LittleEndian.Uint32(b))
+	}
+	return 0
+}
+
+func index(b []byte, ctype int) uint32 {
+	switch ctype {
+	case typeUnsignedByte:
+		return uint32(b[0])
+	case typeUnsignedShort:
+		return uint32(binary.LittleEndian.Uint16(b))
+	case typeUnsignedInt:
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+// view returns the bytes of a buffer view starting at offset, and the
+// distance between two elements.
+func (l *loader) view(i, offset, elemSize int) ([]byte, int, error) {
+	if i < 0 || i >= len(l.doc.BufferViews) {
+		return nil, 0, fmt.Errorf("bufferView %d out of range", i)
+	}
+	bv := l.doc.BufferViews[i]
+	if bv.Buffer < 0 || bv.Buffer >= len(l.buffers) {
+		return nil, 0, fmt.Errorf("bufferView %d: buffer %d out of range", i, bv.Buffer)
+	}
+	buf := l.buffers[bv.Buffer]
+	if bv.ByteOffset < 0 || bv.ByteLength < 0 || bv.ByteOffset > len(buf) || bv.ByteLength > len(buf)-bv.ByteOffset {
+		return nil, 0, fmt.Errorf("bufferView %d exceeds buffer %d", i, bv.Buffer)
+	}
+	if bv.ByteStride < 0 {
+		return nil, 0, fmt.Errorf("bufferView %d: bad byteStride %d", i, bv.ByteStride)
+	}
+	data := buf[bv.ByteOffset : bv.ByteOffset+bv.ByteLength]
+	if offset < 0 || offset > len(data) {
+		return nil, 0, fmt.Errorf("bufferView %d: offset %d out of range", i, offset)
+	}
+
+	stride := bv.ByteStride
+	if stride == 0 {
+		stride = elemSize
+	}
+	return data[offset:], stride, nil
+}
+
+// fits reports whether count elements of size bytes, stride bytes apart,
+// fit into n bytes. Huge counts do not overflow.
+func fits(count, stride, size, n int) bool {
+	switch {
+	case count < 0:
+		return false
+	case count == 0:
+		return true
+	case size > n:
+		return false
+	}
+	return stride == 0 || count-1 <= (n-size)/stride
+}
+
+// floats decodes an accessor into a flat float32 slice and also returns the
+// number of components per element.
+func (l *loader) floats(i int) ([]float32, int, error) {
+	if i < 0 || i >= len(l.doc.Accessors) {
+		return nil, 0, fmt.Errorf("accessor %d out of range", i)
+	}
+	a := l.doc.Accessors[i]
+	csize, ok := componentSizes[a.ComponentType]
+	if !ok {
+		return nil, 0, fmt.Errorf("accessor %d: bad componentType %d", i, a.ComponentType)
+	}
+	n, ok := typeComponents[a.Type]
+	if !ok {
+		return nil, 0, fmt.Errorf("accessor %d: bad type %q", i, a.Type)
+	}
+	if a.Count < 0 {
+		return nil, 0, fmt.Errorf("accessor %d: bad count %d", i, a.Count)
+	}
+
+	var data []byte
+	stride := 0
+	if a.BufferView != nil {
+		var err error
+		if data, stride, err = l.view(*a.BufferView, a.ByteOffset, csize*n); err != nil {
+			return nil, 0, fmt.Errorf("accessor %d: %v", i, err)
+		}
+		if !fits(a.Count, stride, csize*n, len(data)) {
+			return nil, 0, fmt.Errorf("accessor %d exceeds its bufferView", i)
+		}
+	}
+	out := make([]float32, a.Count*n)
+	if a.BufferView != nil {
+		for e := 0; e < a.Count; e++ {
+			for c := 0; c < n; c++ {
+				out[e*n+c] = component(data[e*stride+c*csize:], a.ComponentType, a.Normalized)
+			}
+		}
+	}
+
+	if s := a.Sparse; s != nil {
+		isize := componentSizes[s.Indices.ComponentType]
+		idata, _, err := l.view(s.Indices.BufferView, s.Indices.ByteOffset, isize)
+		if err != nil {
+			return nil, 0, fmt.Errorf("accessor %d: sparse indices: %v", i, err)
+		}
+		vdata, _, err := l.view(s.Values.BufferView, s.Values.ByteOffset, csize*n)
+		if err != nil {
+			return nil, 0, fmt.Errorf("accessor %d: sparse values: %v", i, err)
+		}
+		if isize == 0 || !fits(s.Count, isize, isize, len(idata)) || !fits(s.Count, csize*n, csize*n, len(vdata)) {
+			return nil, 0, fmt.Errorf("accessor %d: bad sparse data", i)
+		}
+		for k := 0; k < s.Count; k++ {
+			e := int(index(idata[k*isize:], s.Indices.ComponentType))
+			if e >= a.Count {
+				return nil, 0, fmt.Errorf("accessor %d: sparse index %d out of range", i, e)
+			}
+			for c := 0; c < n; c++ {
+				out[e*n+c] = component(vdata[(k*n+c)*csize:], a.ComponentType, a.Normalized)
+			}
+		}
+	}
+
+	return out, n, nil
+}
+
+// indices decodes a SCALAR accessor of unsigned integers.
+func (l *loader) indices(i int) ([]uint32, error) {
+	if i < 0 || i >= len(l.doc.Accessors) {
+		return nil, fmt.Errorf("accessor %d out of range", i)
+	}
+	a := l.doc.Accessors[i]
+	switch a.ComponentType {
+	case typeUnsignedByte, typeUnsignedShort, typeUnsignedInt:
+	default:
+		return nil, fmt.Errorf("accessor %d: indices must be unsigned integers", i)
+	}
+	if a.Type != "SCALAR" {
+		return nil, fmt.Errorf("accessor %d: indices must be SCALAR", i)
+	}
+
+	// float32 cannot hold every uint32, so go through the raw bytes
+	if a.Sparse == nil && a.BufferView != nil {
+		csize := componentSizes[a.ComponentType]
+		data, stride, err := l.view(*a.BufferView, a.ByteOffset, csize)
+		if err != nil {
+			return nil, fmt.Errorf("accessor %d: %v", i, err)
+		}
+		if !fits(a.Count, stride, csize, len(data)) {
+			return nil, fmt.Errorf("accessor %d exceeds its bufferView", i)
+		}
+		out := make([]uint32, a.Count)
+		for e := range out {
+			out[e] = index(data[e*stride:], a.ComponentType)
+		}
+		return out, nil
+	}
+
+	f, _, err := l.floats(i)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]uint32, len(f))
+	for e, v := range f {
+		out[e] = uint32(v)
+	}
+	return out, nil
+}
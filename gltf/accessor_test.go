@@ -0,0 +1,124 @@
+package gltf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestAccessors(t *testing.T) {
+	// floats 0..5, bytes 0, 127, 255, 128, shorts 1, 2, 3 and a sparse
+	// index 1 with the value 9
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, []float32{0, 1, 2, 3, 4, 5})
+	b.Write([]byte{0, 127, 255, 128})
+	binary.Write(&b, binary.LittleEndian, []uint16{1, 2, 3, 0})
+	binary.Write(&b, binary.LittleEndian, []uint32{1})
+	binary.Write(&b, binary.LittleEndian, []float32{9})
+	views := []bufferViewDef{
+		{ByteOffset: 0, ByteLength: 24},
+		{ByteOffset: 0, ByteLength: 24, ByteStride: 12},
+		{ByteOffset: 24, ByteLength: 4},
+		{ByteOffset: 28, ByteLength: 6},
+		{ByteOffset: 36, ByteLength: 4},
+		{ByteOffset: 40, ByteLength: 4},
+		{ByteOffset: -4, ByteLength: 8},
+		{ByteOffset: 8, ByteLength: int(^uint(0) >> 1)},
+		{ByteOffset: 0, ByteLength: 24, ByteStride: -4},
+	}
+	view := func(i int) *int { return &i }
+	sparse := func(count int) *sparseDef {
+		s := &sparseDef{Count: count}
+		s.Indices.BufferView, s.Indices.ComponentType = 4, typeUnsignedInt
+		s.Values.BufferView = 5
+		return s
+	}
+	huge := int(^uint(0) >> 1)
+
+	tests := []struct {
+		name string
+		acc  accessorDef
+		want []float32 // nil for an error
+	}{
+		{"vec3", accessorDef{BufferView: view(0), ComponentType: typeFloat, Count: 2, Type: "VEC3"}, []float32{0, 1, 2, 3, 4, 5}},
+		{"offset", accessorDef{BufferView: view(0), ByteOffset: 8, ComponentType: typeFloat, Count: 4, Type: "SCALAR"}, []float32{2, 3, 4, 5}},
+		{"stride", accessorDef{BufferView: view(1), ComponentType: typeFloat, Count: 2, Type: "VEC2"}, []float32{0, 1, 3, 4}},
+		{"normalized bytes", accessorDef{BufferView: view(2), ComponentType: typeUnsignedByte, Normalized: true, Count: 4, Type: "SCALAR"}, []float32{0, 127.0 / 255, 1, 128.0 / 255}},
+		{"normalized signed bytes", accessorDef{BufferView: view(2), ComponentType: typeByte, Normalized: true, Count: 4, Type: "SCALAR"}, []float32{0, 1, -1.0 / 127, -1}},
+		{"shorts", accessorDef{BufferView: view(3), ComponentType: typeUnsignedShort, Count: 3, Type: "SCALAR"}, []float32{1, 2, 3}},
+		{"zeros", accessorDef{ComponentType: typeFloat, Count: 2, Type: "VEC2"}, []float32{0, 0, 0, 0}},
+		{"sparse", accessorDef{ComponentType: typeFloat, Count: 3, Type: "SCALAR", Sparse: sparse(1)}, []float32{0, 9, 0}},
+		{"sparse over data", accessorDef{BufferView: view(0), ComponentType: typeFloat, Count: 2, Type: "SCALAR", Sparse: sparse(1)}, []float32{0, 9}},
+		{"empty", accessorDef{BufferView: view(0), ComponentType: typeFloat, Count: 0, Type: "VEC3"}, []float32{}},
+
+		{"past the view", accessorDef{BufferView: view(0), ComponentType: typeFloat, Count: 3, Type: "VEC3"}, nil},
+		{"offset past the view", accessorDef{BufferView: view(0), ByteOffset: 28, ComponentType: typeFloat, Count: 1, Type: "SCALAR"}, nil},
+		{"negative offset", accessorDef{BufferView: view(0), ByteOffset: -4, ComponentType: typeFloat, Count: 1, Type: "SCALAR"}, nil},
+		{"negative count", accessorDef{BufferView: view(0), ComponentType: typeFloat, Count: -1, Type: "SCALAR"}, nil},
+		{"huge count", accessorDef{BufferView: view(0), ComponentType: typeFloat, Count: huge / 2, Type: "VEC4"}, nil},
+		{"negative view offset", accessorDef{BufferView: view(6), ComponentType: typeFloat, Count: 1, Type: "SCALAR"}, nil},
+		{"huge view length", accessorDef{BufferView: view(7), ComponentType: typeFloat, Count: 1, Type: "SCALAR"}, nil},
+		{"negative stride", accessorDef{BufferView: view(8), ComponentType: typeFloat, Count: 2, Type: "SCALAR"}, nil},
+		{"missing view", accessorDef{BufferView: view(20), ComponentType: typeFloat, Count: 1, Type: "SCALAR"}, nil},
+		{"sparse past the data", accessorDef{ComponentType: typeFloat, Count: 3, Type: "SCALAR", Sparse: sparse(2)}, nil},
+		{"negative sparse count", accessorDef{ComponentType: typeFloat, Count: 3, Type: "SCALAR", Sparse: sparse(-1)}, nil},
+		{"sparse index out of range", accessorDef{ComponentType: typeFloat, Count: 1, Type: "SCALAR", Sparse: sparse(1)}, nil},
+		{"bad type", accessorDef{BufferView: view(0), ComponentType: typeFloat, Count: 1, Type: "VEC5"}, nil},
+		{"bad component type", accessorDef{BufferView: view(0), ComponentType: 5130, Count: 1, Type: "SCALAR"}, nil},
+	}
+	for _, tt := range tests {
+		l := &loader{buffers: [][]byte{b.Bytes()}}
+		l.doc.BufferViews = views
+		l.doc.Accessors = []accessorDef{tt.acc}
+		f, _, err := l.floats(0)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("%s: decoded %v", tt.name, f)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(f, tt.want) {
+			t.Errorf("%s: decoded %v, %v, want %v", tt.name, f, err, tt.want)
+		}
+	}
+}
+
+func TestIndices(t *testing.T) {
+	var b bytes.Buffer
+	b.Write([]byte{0, 1, 2, 0})
+	binary.Write(&b, binary.LittleEndian, []uint16{3, 4, 5, 0})
+	binary.Write(&b, binary.LittleEndian, []uint32{70000, 1})
+	views := []bufferViewDef{{ByteOffset: 0, ByteLength: 4}, {ByteOffset: 4, ByteLength: 8}, {ByteOffset: 12, ByteLength: 8}}
+	view := func(i int) *int { return &i }
+
+	tests := []struct {
+		name string
+		acc  accessorDef
+		want []uint32
+	}{
+		{"bytes", accessorDef{BufferView: view(0), ComponentType: typeUnsignedByte, Count: 3, Type: "SCALAR"}, []uint32{0, 1, 2}},
+		{"shorts", accessorDef{BufferView: view(1), ComponentType: typeUnsignedShort, Count: 3, Type: "SCALAR"}, []uint32{3, 4, 5}},
+		{"ints", accessorDef{BufferView: view(2), ComponentType: typeUnsignedInt, Count: 2, Type: "SCALAR"}, []uint32{70000, 1}},
+		{"past the view", accessorDef{BufferView: view(2), ComponentType: typeUnsignedInt, Count: 3, Type: "SCALAR"}, nil},
+		{"negative count", accessorDef{BufferView: view(1), ComponentType: typeUnsignedShort, Count: -2, Type: "SCALAR"}, nil},
+		{"negative offset", accessorDef{BufferView: view(1), ByteOffset: -2, ComponentType: typeUnsignedShort, Count: 1, Type: "SCALAR"}, nil},
+		{"floats", accessorDef{BufferView: view(2), ComponentType: typeFloat, Count: 2, Type: "SCALAR"}, nil},
+		{"vectors", accessorDef{BufferView: view(2), ComponentType: typeUnsignedShort, Count: 1, Type: "VEC2"}, nil},
+	}
+	for _, tt := range tests {
+		l := &loader{buffers: [][]byte{b.Bytes()}}
+		l.doc.BufferViews = views
+		l.doc.Accessors = []accessorDef{tt.acc}
+		idx, err := l.indices(0)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("%s: decoded %v", tt.name, idx)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(idx, tt.want) {
+			t.Errorf("%s: decoded %v, %v, want %v", tt.name, idx, err, tt.want)
+		}
+	}
+}
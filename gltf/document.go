@@ -0,0 +1,129 @@
+package gltf
+
+/* JSON schema of a glTF 2.0 asset, only the parts we import */
+
+type document struct {
+	Asset struct {
+		Version    string `json:"version"`
+		MinVersion string `json:"minVersion"`
+	} `json:"asset"`
+	ExtensionsRequired []string `json:"extensionsRequired"`
+
+	Scene       *int            `json:"scene"`
+	Scenes      []sceneDef      `json:"scenes"`
+	Nodes       []nodeDef       `json:"nodes"`
+	Meshes      []meshDef       `json:"meshes"`
+	Accessors   []accessorDef   `json:"accessors"`
+	BufferViews []bufferViewDef `json:"bufferViews"`
+	Buffers     []bufferDef     `json:"buffers"`
+	Materials   []materialDef   `json:"materials"`
+	Textures    []textureDef    `json:"textures"`
+	Images      []imageDef      `json:"images"`
+	Samplers    []samplerDef    `json:"samplers"`
+}
+
+type sceneDef struct {
+	Name  string `json:"name"`
+	Nodes []int  `json:"nodes"`
+}
+
+type nodeDef struct {
+	Name        string       `json:"name"`
+	Children    []int        `json:"children"`
+	Mesh        *int         `json:"mesh"`
+	Matrix      *[16]float32 `json:"matrix"`
+	Translation *[3]float32  `json:"translation"`
+	Rotation    *[4]float32  `json:"rotation"`
+	Scale       *[3]float32  `json:"scale"`
+}
+
+type meshDef struct {
+	Name       string         `json:"name"`
+	Primitives []primitiveDef `json:"primitives"`
+}
+
+type primitiveDef struct {
+	Attributes map[string]int `json:"attributes"`
+	Indices    *int           `json:"indices"`
+	Material   *int           `json:"material"`
+	Mode       *int           `json:"mode"`
+}
+
+type accessorDef struct {
+	BufferView    *int       `json:"bufferView"`
+	ByteOffset    int        `json:"byteOffset"`
+	ComponentType int        `json:"componentType"`
+	Normalized    bool       `json:"normalized"`
+	Count         int        `json:"count"`
+	Type          string     `json:"type"`
+	Sparse        *sparseDef `json:"sparse"`
+}
+
+type sparseDef struct {
+	Count   int `json:"count"`
+	Indices struct {
+		BufferView    int `json:"bufferView"`
+		ByteOffset    int `json:"byteOffset"`
+		ComponentType int `json:"componentType"`
+	} `json:"indices"`
+	Values struct {
+		BufferView int `json:"bufferView"`
+		ByteOffset int `json:"byteOffset"`
+	} `json:"values"`
+}
+
+type bufferViewDef struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+	ByteStride int `json:"byteStride"`
+}
+
+type bufferDef struct {
+	URI        string `json:"uri"`
+	ByteLength int    `json:"byteLength"`
+}
+
+type textureInfo struct {
+	Index    int      `json:"index"`
+	TexCoord int      `json:"texCoord"`
+	Scale    *float32 `json:"scale"`    // normalTexture only
+	Strength *float32 `json:"strength"` // occlusionTexture only
+}
+
+type materialDef struct {
+	Name                 string `json:"name"`
+	PbrMetallicRoughness *struct {
+		BaseColorFactor          *[4]float32  `json:"baseColorFactor"`
+		BaseColorTexture         *textureInfo `json:"baseColorTexture"`
+		MetallicFactor           *float32     `json:"metallicFactor"`
+		RoughnessFactor          *float32     `json:"roughnessFactor"`
+		MetallicRoughnessTexture *textureInfo `json:"metallicRoughnessTexture"`
+	} `json:"pbrMetallicRoughness"`
+	NormalTexture    *textureInfo `json:"normalTexture"`
+	OcclusionTexture *textureInfo `json:"occlusionTexture"`
+	EmissiveTexture  *textureInfo `json:"emissiveTexture"`
+	EmissiveFactor   [3]float32   `json:"emissiveFactor"`
+	AlphaMode        string       `json:"alphaMode"`
+	AlphaCutoff      *float32     `json:"alphaCutoff"`
+	DoubleSided      bool         `json:"doubleSided"`
+}
+
+type textureDef struct {
+	Sampler *int `json:"sampler"`
+	Source  *int `json:"source"`
+}
+
+type imageDef struct {
+	Name       string `json:"name"`
+	URI        string `json:"uri"`
+	MimeType   string `json:"mimeType"`
+	BufferView *int   `json:"bufferView"`
+}
+
+type samplerDef struct {
+	MagFilter int `json:"magFilter"`
+	MinFilter int `json:"minFilter"`
+	WrapS     int `json:"wrapS"`
+	WrapT     int `json:"wrapT"`
+}
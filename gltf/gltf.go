@@ -0,0 +1,299 @@
+// Package gltf imports glTF 2.0 assets (.gltf with external or embedded
+// buffers, and binary .glb) into meshes, materials, textures and a node
+// hierarchy.
+package gltf
+
+import (
+	"github.com/ginuerzh/anton-gocode/gfx"
+	"github.com/ginuerzh/anton-gocode/mesh"
+	"github.com/ginuerzh/anton-gocode/texture"
+	"image"
+	"math"
+)
+
+// Model is an imported glTF asset.
+type Model struct {
+	Name      string
+	Nodes     []*Node // every node, in file order
+	Roots     []*Node // root nodes of the default scene
+	Meshes    []*Mesh
+	Materials []Material
+	Textures  []Texture
+}
+
+// Node is an element of the scene hierarchy with a local TRS transform.
+type Node struct {
+	Name        string
+	Parent      *Node
+	Children    []*Node
+	Mesh        *Mesh
+	Translation [3]float32
+	Rotation    [4]float32 // unit quaternion x, y, z, w
+	Scale       [3]float32
+}
+
+type Mesh struct {
+	Name       string
+	Primitives []*Primitive
+}
+
+// Primitive is a part of a mesh drawn with a single material. GL is nil until
+// the model is uploaded.
+type Primitive struct {
+	Mesh     *mesh.Mesh
+	Material int // index into Model.Materials, -1 for the default material
+	GL       *gfx.Mesh
+}
+
+// Material holds the metallic-roughness PBR parameters of a glTF material.
+type Material struct {
+	Name                     string
+	BaseColorFactor          [4]float32
+	BaseColorTexture         *TextureRef
+	MetallicFactor           float32
+	RoughnessFactor          float32
+	MetallicRoughnessTexture *TextureRef
+	NormalTexture            *TextureRef
+	NormalScale              float32
+	OcclusionTexture         *TextureRef
+	OcclusionStrength        float32
+	EmissiveTexture          *TextureRef
+	EmissiveFactor           [3]float32
+	AlphaMode                string // OPAQUE, MASK or BLEND
+	AlphaCutoff              float32
+	DoubleSided              bool
+}
+
+// TextureRef points a material slot at a texture and a texcoord set.
+type TextureRef struct {
+	Texture  int // index into Model.Textures
+	TexCoord int
+}
+
+// Texture is a decoded image together with its GL sampler settings. Zero
+// filters mean the asset left them to the implementation. GL is nil until
+// the model is uploaded.
+type Texture struct {
+	Name      string
+	Image     image.Image
+	MagFilter int32
+	MinFilter int32
+	WrapS     int32
+	WrapT     int32
+	GL        *texture.Texture
+}
+
+// DefaultMaterial is used by primitives without a material.
+func DefaultMaterial() Material {
+	return Material{
+		BaseColorFactor:   [4]float32{1, 1, 1, 1},
+		MetallicFactor:    1,
+		RoughnessFactor:   1,
+		NormalScale:       1,
+		OcclusionStrength: 1,
+		AlphaMode:         "OPAQUE",
+		AlphaCutoff:       0.5,
+	}
+}
+
+// Local returns the column-major T * R * S matrix of the node.
+func (n *Node) Local() [16]float32 {
+	x, y, z, w := n.Rotation[0], n.Rotation[1], n.Rotation[2], n.Rotation[3]
+	sx, sy, sz := n.Scale[0], n.Scale[1], n.Scale[2]
+
+	return [16]float32{
+		(1 - 2*(y*y+z*z)) * sx, 2 * (x*y + z*w) * sx, 2 * (x*z - y*w) * sx, 0,
+		2 * (x*y - z*w) * sy, (1 - 2*(x*x+z*z)) * sy, 2 * (y*z + x*w) * sy, 0,
+		2 * (x*z + y*w) * sz, 2 * (y*z - x*w) * sz, (1 - 2*(x*x+y*y)) * sz, 0,
+		n.Translation[0], n.Translation[1], n.Translation[2], 1,
+	}
+}
+
+// World returns the node matrix including all of its parents.
+func (n *Node) World() [16]float32 {
+	m := n.Local()
+	for p := n.Parent; p != nil; p = p.Parent {
+		m = mul4(p.Local(), m)
+	}
+	return m
+}
+
+// Walk visits the default scene depth first, handing each node its world
+// matrix.
+func (m *Model) Walk(fn func(n *Node, world [16]float32)) {
+	var walk func(n *Node, parent [16]float32)
+	walk = func(n *Node, parent [16]float32) {
+		world := mul4(parent, n.Local())
+		fn(n, world)
+		for _, c := range n.Children {
+			walk(c, world)
+		}
+	}
+
+	ident := [16]float32{1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1}
+	for _, r := range m.Roots {
+		walk(r, ident)
+	}
+}
+
+// Upload copies every primitive into GL buffers and every texture into a
+// GL texture. Base colour and emissive textures are sRGB.
+func (m *Model) Upload() error {
+	srgb := make([]bool, len(m.Textures))
+	for _, mat := range m.Materials {
+		for _, ref := range []*TextureRef{mat.BaseColorTexture, mat.EmissiveTexture} {
+			if ref != nil && ref.Texture >= 0 && ref.Texture < len(srgb) {
+				srgb[ref.Texture] = true
+			}
+		}
+	}
+	for i := range m.Textures {
+		t := &m.Textures[i]
+		if t.GL != nil || t.Image == nil {
+			continue
+		}
+		t.GL = texture.FromImage(t.Image, t.options(srgb[i]))
+	}
+
+	for _, me := range m.Meshes {
+		for _, p := range me.Primitives {
+			if p.GL != nil {
+				continue
+			}
+			g, err := gfx.UploadMesh(p.Mesh)
+			if err != nil {
+				m.Delete()
+				return err
+			}
+			p.GL = g
+		}
+	}
+	return nil
+}
+
+// Delete frees the GL objects created by Upload.
+func (m *Model) Delete() {
+	for _, me := range m.Meshes {
+		for _, p := range me.Primitives {
+			if p.GL != nil {
+				p.GL.Delete()
+				p.GL = nil
+			}
+		}
+	}
+	for i := range m.Textures {
+		if t := &m.Textures[i]; t.GL != nil {
+			t.GL.Delete()
+			t.GL = nil
+		}
+	}
+}
+
+// options turns the glTF sampler into texture options. glTF texcoords
+// start at the top row, so the image is not flipped.
+func (t *Texture) options(srgb bool) texture.Options {
+	s := texture.DefaultSampler()
+	if t.MinFilter != 0 {
+		s.MinFilter = t.MinFilter
+	}
+	if t.MagFilter != 0 {
+		s.MagFilter = t.MagFilter
+	}
+	s.WrapS, s.WrapT = t.WrapS, t.WrapT
+	return texture.Options{SRGB: srgb, Mipmaps: true, Sampler: s}
+}
+
+// PBRMaterial converts material i, or the default material for -1, into
+// the parameters of pbr.glsl, with the textures of the model once it is
+// uploaded. Only the first texcoord set is supported.
+func (m *Model) PBRMaterial(i int) gfx.PBRMaterial {
+	mat := DefaultMaterial()
+	if i >= 0 && i < len(m.Materials) {
+		mat = m.Materials[i]
+	}
+	tex := func(ref *TextureRef) *texture.Texture {
+		if ref == nil || ref.Texture < 0 || ref.Texture >= len(m.Textures) {
+			return nil
+		}
+		return m.Textures[ref.Texture].GL
+	}
+	p := gfx.PBRMaterial{
+		BaseColour:           mat.BaseColorFactor,
+		Metallic:             mat.MetallicFactor,
+		Roughness:            mat.RoughnessFactor,
+		Emissive:             mat.EmissiveFactor,
+		NormalScale:          mat.NormalScale,
+		OcclusionStrength:    mat.OcclusionStrength,
+		BaseColourMap:        tex(mat.BaseColorTexture),
+		MetallicRoughnessMap: tex(mat.MetallicRoughnessTexture),
+		NormalMap:            tex(mat.NormalTexture),
+		OcclusionMap:         tex(mat.OcclusionTexture),
+		EmissiveMap:          tex(mat.EmissiveTexture),
+	}
+	if mat.AlphaMode == "MASK" {
+		p.AlphaCutoff = mat.AlphaCutoff
+	}
+	return p
+}
+
+func mul4(a, b [16]float32) (m [16]float32) {
+	for c := 0; c < 4; c++ {
+		for r := 0; r < 4; r++ {
+			var s float32
+			for k := 0; k < 4; k++ {
+				s += a[k*4+r] * b[c*4+k]
+			}
+			m[c*4+r] = s
+		}
+	}
+	return
+}
+
+// decompose splits a column-major affine matrix without shear into TRS.
+func decompose(m [16]float32, n *Node) {
+	n.Translation = [3]float32{m[12], m[13], m[14]}
+
+	col := func(c int) [3]float32 { return [3]float32{m[c*4], m[c*4+1], m[c*4+2]} }
+	length := func(v [3]float32) float32 {
+		return float32(math.Sqrt(float64(v[0]*v[0] + v[1]*v[1] + v[2]*v[2])))
+	}
+	c0, c1, c2 := col(0), col(1), col(2)
+	sx, sy, sz := length(c0), length(c1), length(c2)
+
+	det := c0[0]*(c1[1]*c2[2]-c2[1]*c1[2]) -
+		c1[0]*(c0[1]*c2[2]-c2[1]*c0[2]) +
+		c2[0]*(c0[1]*c1[2]-c1[1]*c0[2])
+	if det < 0 {
+		sx = -sx
+	}
+	n.Scale = [3]float32{sx, sy, sz}
+
+	if sx == 0 || sy == 0 || sz == 0 {
+		n.Rotation = [4]float32{0, 0, 0, 1}
+		return
+	}
+	r := [9]float32{
+		c0[0] / sx, c0[1] / sx, c0[2] / sx,
+		c1[0] / sy, c1[1] / sy, c1[2] / sy,
+		c2[0] / sz, c2[1] / sz, c2[2] / sz,
+	}
+
+	// rotation matrix to quaternion, r is column-major
+	var q [4]float32
+	trace := r[0] + r[4] + r[8]
+	switch {
+	case trace > 0:
+		s := float32(math.Sqrt(float64(trace+1))) * 2
+		q = [4]float32{(r[5] - r[7]) / s, (r[6] - r[2]) / s, (r[1] - r[3]) / s, s / 4}
+	case r[0] > r[4] && r[0] > r[8]:
+		s := float32(math.Sqrt(float64(1+r[0]-r[4]-r[8]))) * 2
+		q = [4]float32{s / 4, (r[3] + r[1]) / s, (r[6] + r[2]) / s, (r[5] - r[7]) / s}
+	case r[4] > r[8]:
+		s := float32(math.Sqrt(float64(1+r[4]-r[0]-r[8]))) * 2
+		q = [4]float32{(r[3] + r[1]) / s, s / 4, (r[7] + r[5]) / s, (r[6] - r[2]) / s}
+	default:
+		s := float32(math.Sqrt(float64(1+r[8]-r[0]-r[4]))) * 2
+		q = [4]float32{(r[6] + r[2]) / s, (r[7] + r[5]) / s, s / 4, (r[1] - r[3]) / s}
+	}
+	n.Rotation = q
+}
@@ -0,0 +1,500 @@
+package gltf
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/ginuerzh/anton-gocode/mesh"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	glbMagic     = 0x46546C67 // "glTF"
+	glbChunkJSON = 0x4E4F534A
+	glbChunkBIN  = 0x004E4942
+)
+
+/* primitive modes */
+const (
+	modeTriangles     = 4
+	modeTriangleStrip = 5
+	modeTriangleFan   = 6
+)
+
+type loader struct {
+	filename string
+	dir      string
+	doc      document
+	buffers  [][]byte
+	glbBin   []byte
+	model    *Model
+}
+
+// Load imports a .gltf or .glb file. The format is detected from the file
+// contents, not the extension.
+func Load(filename string) (*Model, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &loader{
+		filename: filename,
+		dir:      filepath.Dir(filename),
+		model: &Model{
+			Name: strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename)),
+		},
+	}
+
+	if err := l.load(data); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return l.model, nil
+}
+
+func (l *loader) load(data []byte) error {
+	if len(data) >= 4 && binary.LittleEndian.Uint32(data) == glbMagic {
+		var err error
+		if data, err = l.glb(data); err != nil {
+			return err
+		}
+	}
+
+	if err := json.Unmarshal(data, &l.doc); err != nil {
+		return err
+	}
+	if !strings.HasPrefix(l.doc.Asset.Version, "2.") {
+		return fmt.Errorf("unsupported glTF version %q", l.doc.Asset.Version)
+	}
+	if len(l.doc.ExtensionsRequired) > 0 {
+		return fmt.Errorf("required extensions not supported: %s",
+			strings.Join(l.doc.ExtensionsRequired, ", "))
+	}
+
+	if err := l.loadBuffers(); err != nil {
+		return err
+	}
+	if err := l.loadTextures(); err != nil {
+		return err
+	}
+	if err := l.loadMaterials(); err != nil {
+		return err
+	}
+	if err := l.loadMeshes(); err != nil {
+		return err
+	}
+	return l.loadNodes()
+}
+
+// glb splits a binary container into its JSON and BIN chunks.
+func (l *loader) glb(data []byte) ([]byte, error) {
+	if len(data) < 12 {
+		return nil, errors.New("truncated GLB header")
+	}
+	if v := binary.LittleEndian.Uint32(data[4:]); v != 2 {
+		return nil, fmt.Errorf("unsupported GLB version %d", v)
+	}
+	if n := binary.LittleEndian.Uint32(data[8:]); int(n) < len(data) {
+		data = data[:n]
+	}
+
+	var js []byte
+	for off := 12; off+8 <= len(data); {
+		n := int(binary.LittleEndian.Uint32(data[off:]))
+		typ := binary.LittleEndian.Uint32(data[off+4:])
+		off += 8
+		if n < 0 || off+n > len(data) {
+			return nil, errors.New("truncated GLB chunk")
+		}
+		switch typ {
+		case glbChunkJSON:
+			js = data[off : off+n]
+		case glbChunkBIN:
+			if l.glbBin == nil {
+				l.glbBin = data[off : off+n]
+			}
+		}
+		off += (n + 3) &^ 3
+	}
+	if js == nil {
+		return nil, errors.New("GLB without JSON chunk")
+	}
+	return js, nil
+}
+
+// uri returns the bytes of a data URI or of a file next to the asset.
+func (l *loader) uri(uri string) ([]byte, error) {
+	if strings.HasPrefix(uri, "data:") {
+		i := strings.Index(uri, ",")
+		if i < 0 || !strings.HasSuffix(uri[:i], ";base64") {
+			return nil, errors.New("only base64 data URIs are supported")
+		}
+		return base64.StdEncoding.DecodeString(uri[i+1:])
+	}
+
+	name, err := url.PathUnescape(uri)
+	if err != nil {
+		name = uri
+	}
+	return ioutil.ReadFile(filepath.Join(l.dir, filepath.FromSlash(name)))
+}
+
+func (l *loader) loadBuffers() error {
+	for i, b := range l.doc.Buffers {
+		var data []byte
+		if b.URI == "" {
+			if i != 0 || l.glbBin == nil {
+				return fmt.Errorf("buffer %d has no data", i)
+			}
+			data = l.glbBin
+		} else {
+			var err error
+			if data, err = l.uri(b.URI); err != nil {
+				return fmt.Errorf("buffer %d: %v", i, err)
+			}
+		}
+		if len(data) < b.ByteLength {
+			return fmt.Errorf("buffer %d: %d bytes, expected %d", i, len(data), b.ByteLength)
+		}
+		l.buffers = append(l.buffers, data)
+	}
+	return nil
+}
+
+func (l *loader) loadTextures() error {
+	images := make([]image.Image, len(l.doc.Images))
+	for i, im := range l.doc.Images {
+		var data []byte
+		var err error
+		switch {
+		case im.BufferView != nil:
+			data, _, err = l.view(*im.BufferView, 0, 1)
+			if err == nil {
+				data = data[:l.doc.BufferViews[*im.BufferView].ByteLength]
+			}
+		case im.URI != "":
+			data, err = l.uri(im.URI)
+		default:
+			err = errors.New("no data")
+		}
+		if err != nil {
+			return fmt.Errorf("image %d: %v", i, err)
+		}
+
+		if images[i], _, err = image.Decode(bytes.NewReader(data)); err != nil {
+			return fmt.Errorf("image %d: %v", i, err)
+		}
+	}
+
+	for i, t := range l.doc.Textures {
+		tex := Texture{WrapS: 10497, WrapT: 10497} // REPEAT
+		if t.Source != nil {
+			if *t.Source < 0 || *t.Source >= len(images) {
+				return fmt.Errorf("texture %d: image %d out of range", i, *t.Source)
+			}
+			tex.Image = images[*t.Source]
+			tex.Name = l.doc.Images[*t.Source].Name
+		}
+		if t.Sampler != nil {
+			if *t.Sampler < 0 || *t.Sampler >= len(l.doc.Samplers) {
+				return fmt.Errorf("texture %d: sampler %d out of range", i, *t.Sampler)
+			}
+			s := l.doc.Samplers[*t.Sampler]
+			tex.MagFilter = int32(s.MagFilter)
+			tex.MinFilter = int32(s.MinFilter)
+			if s.WrapS != 0 {
+				tex.WrapS = int32(s.WrapS)
+			}
+			if s.WrapT != 0 {
+				tex.WrapT = int32(s.WrapT)
+			}
+		}
+		l.model.Textures = append(l.model.Textures, tex)
+	}
+	return nil
+}
+
+// textureRef checks the texture index of t, which may be nil.
+func (l *loader) textureRef(t *textureInfo) (*TextureRef, error) {
+	if t == nil {
+		return nil, nil
+	}
+	if t.Index < 0 || t.Index >= len(l.model.Textures) {
+		return nil, fmt.Errorf("texture %d out of range", t.Index)
+	}
+	return &TextureRef{Texture: t.Index, TexCoord: t.TexCoord}, nil
+}
+
+func (l *loader) loadMaterials() error {
+	for i, md := range l.doc.Materials {
+		m := DefaultMaterial()
+		m.Name = md.Name
+		var err error
+		ref := func(t *textureInfo) *TextureRef {
+			r, e := l.textureRef(t)
+			if err == nil {
+				err = e
+			}
+			return r
+		}
+		if pbr := md.PbrMetallicRoughness; pbr != nil {
+			if pbr.BaseColorFactor != nil {
+				m.BaseColorFactor = *pbr.BaseColorFactor
+			}
+			if pbr.MetallicFactor != nil {
+				m.MetallicFactor = *pbr.MetallicFactor
+			}
+			if pbr.RoughnessFactor != nil {
+				m.RoughnessFactor = *pbr.RoughnessFactor
+			}
+			m.BaseColorTexture = ref(pbr.BaseColorTexture)
+			m.MetallicRoughnessTexture = ref(pbr.MetallicRoughnessTexture)
+		}
+		if t := md.NormalTexture; t != nil {
+			m.NormalTexture = ref(t)
+			if t.Scale != nil {
+				m.NormalScale = *t.Scale
+			}
+		}
+		if t := md.OcclusionTexture; t != nil {
+			m.OcclusionTexture = ref(t)
+			if t.Strength != nil {
+				m.OcclusionStrength = *t.Strength
+			}
+		}
+		m.EmissiveTexture = ref(md.EmissiveTexture)
+		m.EmissiveFactor = md.EmissiveFactor
+		if md.AlphaMode != "" {
+			m.AlphaMode = md.AlphaMode
+		}
+		if md.AlphaCutoff != nil {
+			m.AlphaCutoff = *md.AlphaCutoff
+		}
+		m.DoubleSided = md.DoubleSided
+		if err != nil {
+			return fmt.Errorf("material %d: %v", i, err)
+		}
+
+		l.model.Materials = append(l.model.Materials, m)
+	}
+	return nil
+}
+
+func (l *loader) loadMeshes() error {
+	for i, md := range l.doc.Meshes {
+		me := &Mesh{Name: md.Name}
+		for j, pd := range md.Primitives {
+			p, err := l.primitive(pd)
+			if err != nil {
+				return fmt.Errorf("mesh %d primitive %d: %v", i, j, err)
+			}
+			if p == nil {
+				continue
+			}
+			p.Mesh.Name = md.Name
+			me.Primitives = append(me.Primitives, p)
+		}
+		l.model.Meshes = append(l.model.Meshes, me)
+	}
+	return nil
+}
+
+// primitive converts one glTF primitive into an indexed triangle mesh.
+// Points and lines are skipped.
+func (l *loader) primitive(pd primitiveDef) (*Primitive, error) {
+	mode := modeTriangles
+	if pd.Mode != nil {
+		mode = *pd.Mode
+	}
+	if mode != modeTriangles && mode != modeTriangleStrip && mode != modeTriangleFan {
+		return nil, nil
+	}
+
+	pos, ok := pd.Attributes["POSITION"]
+	if !ok {
+		return nil, errors.New("no POSITION attribute")
+	}
+
+	m := &mesh.Mesh{}
+	var err error
+	if m.Positions, err = l.attribute(pos, 3); err != nil {
+		return nil, err
+	}
+	count := len(m.Positions) / 3
+
+	optional := []struct {
+		name string
+		size int
+		dst  *[]float32
+	}{
+		{"NORMAL", 3, &m.Normals},
+		{"TEXCOORD_0", 2, &m.TexCoords},
+		{"COLOR_0", 3, &m.Colours},
+		{"TANGENT", 4, &m.Tangents},
+	}
+	for _, o := range optional {
+		i, ok := pd.Attributes[o.name]
+		if !ok {
+			continue
+		}
+		if *o.dst, err = l.attribute(i, o.size); err != nil {
+			return nil, err
+		}
+		if len(*o.dst) != count*o.size {
+			return nil, fmt.Errorf("%s has a different count than POSITION", o.name)
+		}
+	}
+
+	var idx []uint32
+	if pd.Indices != nil {
+		if idx, err = l.indices(*pd.Indices); err != nil {
+			return nil, err
+		}
+		for _, i := range idx {
+			if int(i) >= count {
+				return nil, fmt.Errorf("index %d out of range", i)
+			}
+		}
+	} else {
+		idx = make([]uint32, count)
+		for i := range idx {
+			idx[i] = uint32(i)
+		}
+	}
+
+	switch mode {
+	case modeTriangles:
+		m.Indices = idx[:len(idx)/3*3]
+	case modeTriangleStrip:
+		for i := 0; i+2 < len(idx); i++ {
+			if i%2 == 0 {
+				m.Indices = append(m.Indices, idx[i], idx[i+1], idx[i+2])
+			} else {
+				m.Indices = append(m.Indices, idx[i+1], idx[i], idx[i+2])
+			}
+		}
+	case modeTriangleFan:
+		for i := 1; i+1 < len(idx); i++ {
+			m.Indices = append(m.Indices, idx[0], idx[i], idx[i+1])
+		}
+	}
+
+	p := &Primitive{Mesh: m, Material: -1}
+	if pd.Material != nil {
+		if *pd.Material < 0 || *pd.Material >= len(l.model.Materials) {
+			return nil, fmt.Errorf("material %d out of range", *pd.Material)
+		}
+		p.Material = *pd.Material
+	}
+	m.Groups = []mesh.Group{{Material: p.Material, Count: len(m.Indices)}}
+
+	return p, nil
+}
+
+// attribute reads an accessor and reshapes it to size components, dropping
+// extra ones (e.g. the alpha of COLOR_0).
+func (l *loader) attribute(i, size int) ([]float32, error) {
+	f, n, err := l.floats(i)
+	if err != nil {
+		return nil, err
+	}
+	if n == size {
+		return f, nil
+	}
+	if n < size {
+		return nil, fmt.Errorf("accessor %d has %d components, need %d", i, n, size)
+	}
+
+	count := len(f) / n
+	out := make([]float32, count*size)
+	for e := 0; e < count; e++ {
+		copy(out[e*size:e*size+size], f[e*n:])
+	}
+	return out, nil
+}
+
+func (l *loader) loadNodes() error {
+	nodes := make([]*Node, len(l.doc.Nodes))
+	for i, nd := range l.doc.Nodes {
+		n := &Node{
+			Name:     nd.Name,
+			Rotation: [4]float32{0, 0, 0, 1},
+			Scale:    [3]float32{1, 1, 1},
+		}
+		if nd.Matrix != nil {
+			decompose(*nd.Matrix, n)
+		}
+		if nd.Translation != nil {
+			n.Translation = *nd.Translation
+		}
+		if nd.Rotation != nil {
+			n.Rotation = *nd.Rotation
+		}
+		if nd.Scale != nil {
+			n.Scale = *nd.Scale
+		}
+		if nd.Mesh != nil {
+			if *nd.Mesh < 0 || *nd.Mesh >= len(l.model.Meshes) {
+				return fmt.Errorf("node %d: mesh %d out of range", i, *nd.Mesh)
+			}
+			n.Mesh = l.model.Meshes[*nd.Mesh]
+		}
+		nodes[i] = n
+	}
+
+	for i, nd := range l.doc.Nodes {
+		for _, c := range nd.Children {
+			if c < 0 || c >= len(nodes) || c == i {
+				return fmt.Errorf("node %d: child %d out of range", i, c)
+			}
+			if nodes[c].Parent != nil {
+				return fmt.Errorf("node %d has more than one parent", c)
+			}
+			nodes[c].Parent = nodes[i]
+			nodes[i].Children = append(nodes[i].Children, nodes[c])
+		}
+	}
+	// a node may not be its own ancestor
+	for i, n := range nodes {
+		steps := 0
+		for p := n.Parent; p != nil; p = p.Parent {
+			if steps++; steps > len(nodes) {
+				return fmt.Errorf("node %d is part of a cycle", i)
+			}
+		}
+	}
+	l.model.Nodes = nodes
+
+	switch {
+	case len(l.doc.Scenes) > 0:
+		s := 0
+		if l.doc.Scene != nil {
+			s = *l.doc.Scene
+		}
+		if s < 0 || s >= len(l.doc.Scenes) {
+			return fmt.Errorf("scene %d out of range", s)
+		}
+		for _, i := range l.doc.Scenes[s].Nodes {
+			if i < 0 || i >= len(nodes) {
+				return fmt.Errorf("scene %d: node %d out of range", s, i)
+			}
+			l.model.Roots = append(l.model.Roots, nodes[i])
+		}
+	default:
+		for _, n := range nodes {
+			if n.Parent == nil {
+				l.model.Roots = append(l.model.Roots, n)
+			}
+		}
+	}
+	return nil
+}
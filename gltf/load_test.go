@@ -0,0 +1,101 @@
+package gltf
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// triangle is a one triangle asset, with %s standing for the uri of its
+// buffer.
+const triangle = `{"asset": {"version": "2.0"}, "scene": 0, "scenes": [{"nodes": [0]}],
+	"nodes": [{"mesh": 0, "children": [1], "translation": [1, 2, 3]}, {"name": "child"}],
+	"meshes": [{"primitives": [{"attributes": {"POSITION": 1}, "indices": 0}]}],
+	"buffers": [{%s"byteLength": 44}],
+	"bufferViews": [{"buffer": 0, "byteLength": 6}, {"buffer": 0, "byteOffset": 8, "byteLength": 36}],
+	"accessors": [{"bufferView": 0, "componentType": 5123, "count": 3, "type": "SCALAR"},
+		{"bufferView": 1, "componentType": 5126, "count": 3, "type": "VEC3"}]}`
+
+func triangleBin() []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, []uint16{0, 1, 2, 0})
+	binary.Write(&b, binary.LittleEndian, []float32{0, 0, 0, 1, 0, 0, 0, 1, 0})
+	return b.Bytes()
+}
+
+// glb wraps a JSON and a BIN chunk into a binary container.
+func glb(js string, bin []byte) []byte {
+	for len(js)%4 != 0 {
+		js += " "
+	}
+	var b bytes.Buffer
+	w := func(v ...uint32) { binary.Write(&b, binary.LittleEndian, v) }
+	w(glbMagic, 2, uint32(12+8+len(js)+8+len(bin)))
+	w(uint32(len(js)), glbChunkJSON)
+	b.WriteString(js)
+	w(uint32(len(bin)), glbChunkBIN)
+	b.Write(bin)
+	return b.Bytes()
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gltf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	uri := `"uri": "data:application/octet-stream;base64,` + base64.StdEncoding.EncodeToString(triangleBin()) + `", `
+	embedded := strings.Replace(triangle, "%s", uri, 1)
+	packed := glb(strings.Replace(triangle, "%s", "", 1), triangleBin())
+
+	tests := []struct {
+		name, file string
+		data       []byte
+		ok         bool
+	}{
+		{"data uri", "a.gltf", []byte(embedded), true},
+		{"binary", "a.glb", packed, true},
+		{"binary named gltf", "b.gltf", packed, true},
+		{"truncated binary", "c.glb", packed[:len(packed)-8], false},
+		{"binary without JSON", "d.glb", glb("", nil)[:12], false},
+		{"version 1", "e.gltf", []byte(strings.Replace(embedded, `"2.0"`, `"1.0"`, 1)), false},
+		{"required extension", "f.gltf", []byte(strings.Replace(embedded, `"scene": 0`, `"extensionsRequired": ["KHR_draco_mesh_compression"]`, 1)), false},
+		{"missing buffer file", "g.gltf", []byte(strings.Replace(triangle, "%s", `"uri": "missing.bin", `, 1)), false},
+		{"bad texture", "h.gltf", []byte(strings.Replace(embedded, `"scene": 0`, `"materials": [{"pbrMetallicRoughness": {"baseColorTexture": {"index": 3}}}]`, 1)), false},
+	}
+	for _, tt := range tests {
+		p := filepath.Join(dir, tt.file)
+		if err := ioutil.WriteFile(p, tt.data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		m, err := Load(p)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: error %v", tt.name, err)
+			continue
+		}
+		if !tt.ok {
+			continue
+		}
+		if len(m.Roots) != 1 || len(m.Nodes) != 2 || m.Nodes[1].Parent != m.Nodes[0] || m.Nodes[1].Name != "child" {
+			t.Errorf("%s: nodes %+v", tt.name, m.Nodes)
+			continue
+		}
+		pm := m.Roots[0].Mesh.Primitives[0].Mesh
+		if want := []float32{0, 0, 0, 1, 0, 0, 0, 1, 0}; !reflect.DeepEqual(pm.Positions, want) {
+			t.Errorf("%s: positions %v, want %v", tt.name, pm.Positions, want)
+		}
+		if want := []uint32{0, 1, 2}; !reflect.DeepEqual(pm.Indices, want) {
+			t.Errorf("%s: indices %v, want %v", tt.name, pm.Indices, want)
+		}
+		if w := m.Nodes[1].World(); w[12] != 1 || w[13] != 2 || w[14] != 3 {
+			t.Errorf("%s: child at %v, want the translation of its parent", tt.name, w[12:15])
+		}
+	}
+}
@@ -0,0 +1,495 @@
+package mesh
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+/*
+Binary mesh cache, a preprocessed mesh that loads with one read and goes
+into GL buffers without any parsing. Everything is little endian and 4 byte
+aligned:
+
+	header, 68 bytes
+		magic       "AMSH"
+		version     uint32
+		flags       uint32, CacheCompressed
+		vertices    uint32
+		indices     uint32
+		index size  uint32, 2 or 4
+		attribs     uint32
+		groups      uint32
+		materials   uint32
+		min, max    3 float32 each, bounds of the whole mesh
+		size        uint32, payload bytes (uncompressed)
+		stored      uint32, payload bytes in the file
+	payload, zlib compressed when flagged
+		stride      uint32
+		attribs     name, size, GL type, normalized, offset
+		groups      name, start, count, material, min, max
+		materials   name
+		vertices    interleaved, vertices * stride bytes
+		indices     indices * index size bytes, padded
+
+Strings are a uint32 length followed by the bytes, padded. Only material
+names are kept, the rest of the material lives with the renderer.
+*/
+
+const (
+	CacheMagic   = "AMSH"
+	CacheVersion = 1
+
+	CacheCompressed = 1 << 0
+
+	cacheHeaderSize = 68
+	cacheFloat      = 0x1406 // GL_FLOAT
+)
+
+// CacheAttrib describes one attribute of the interleaved vertex data. Names
+// are position, normal, texcoord, colour, tangent, or the key of an Extra
+// attribute.
+type CacheAttrib struct {
+	Name       string
+	Size       int    // components
+	Type       uint32 // GL type of the components
+	Normalized bool
+	Offset     int // bytes into the vertex
+}
+
+// CacheGroup is a group together with its bounding box.
+type CacheGroup struct {
+	Group
+	Min, Max [3]float32
+}
+
+// Cache is a parsed mesh cache. VertexData and IndexData point into the
+// loaded file and can be handed to GL as they are.
+type Cache struct {
+	Version   int
+	Vertices  int
+	Indices   int
+	IndexSize int
+	Min, Max  [3]float32
+	Stride    int
+	Attribs   []CacheAttrib
+	Groups    []CacheGroup
+	Materials []string
+
+	VertexData []byte
+	IndexData  []byte
+}
+
+type cacheWriter struct {
+	bytes.Buffer
+}
+
+func (w *cacheWriter) u32(v uint32) {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], v)
+	w.Write(b[:])
+}
+
+func (w *cacheWriter) f32(v float32) {
+	w.u32(math.Float32bits(v))
+}
+
+func (w *cacheWriter) str(s string) {
+	w.u32(uint32(len(s)))
+	w.WriteString(s)
+	w.pad()
+}
+
+func (w *cacheWriter) pad() {
+	for w.Len()%4 != 0 {
+		w.WriteByte(0)
+	}
+}
+
+// WriteCache stores m in the cache format, zlib compressing the payload if
+// asked to. Indices are stored as uint16 when they fit.
+func WriteCache(out io.Writer, m *Mesh, compress bool) error {
+	n := m.VertexCount()
+	sources := []struct {
+		name string
+		data []float32
+		size int
+	}{
+		{"position", m.Positions, 3},
+		{"normal", m.Normals, 3},
+		{"texcoord", m.TexCoords, 2},
+		{"colour", m.Colours, 3},
+		{"tangent", m.Tangents, 4},
+	}
+	extra := make([]string, 0, len(m.Extra))
+	for k := range m.Extra {
+		extra = append(extra, k)
+	}
+	sort.Strings(extra)
+	for _, k := range extra {
+		sources = append(sources, struct {
+			name string
+			data []float32
+			size int
+		}{k, m.Extra[k], 1})
+	}
+
+	var attribs []CacheAttrib
+	stride := 0
+	for _, s := range sources {
+		if len(s.data) == 0 {
+			continue
+		}
+		if len(s.data) != n*s.size {
+			return fmt.Errorf("%s: %d values for %d vertices", s.name, len(s.data), n)
+		}
+		attribs = append(attribs, CacheAttrib{s.name, s.size, cacheFloat, false, stride})
+		stride += s.size * 4
+	}
+
+	indexSize := 2
+	for _, i := range m.Indices {
+		if int(i) >= n {
+			return fmt.Errorf("index %d out of range", i)
+		}
+		if i > 0xffff {
+			indexSize = 4
+		}
+	}
+
+	groups := m.Groups
+	if len(groups) == 0 {
+		groups = []Group{{Material: -1, Count: len(m.Indices)}}
+	}
+
+	var p cacheWriter
+	p.u32(uint32(stride))
+	for _, a := range attribs {
+		p.str(a.Name)
+		p.u32(uint32(a.Size))
+		p.u32(a.Type)
+		p.u32(0)
+		p.u32(uint32(a.Offset))
+	}
+	for _, g := range groups {
+		p.str(g.Object + "\x00" + g.Name)
+		p.u32(uint32(g.Start))
+		p.u32(uint32(g.Count))
+		p.u32(uint32(int32(g.Material)))
+		min, max := m.Bounds(g.Start, g.Count)
+		for _, f := range append(min[:], max[:]...) {
+			p.f32(f)
+		}
+	}
+	for _, mat := range m.Materials {
+		p.str(mat.Name)
+	}
+	for v := 0; v < n; v++ {
+		for _, s := range sources {
+			if len(s.data) == 0 {
+				continue
+			}
+			for _, f := range s.data[v*s.size : (v+1)*s.size] {
+				p.f32(f)
+			}
+		}
+	}
+	for _, i := range m.Indices {
+		if indexSize == 2 {
+			p.WriteByte(byte(i))
+			p.WriteByte(byte(i >> 8))
+		} else {
+			p.u32(i)
+		}
+	}
+	p.pad()
+
+	payload := p.Bytes()
+	flags := uint32(0)
+	if compress {
+		var z bytes.Buffer
+		zw := zlib.NewWriter(&z)
+		zw.Write(payload)
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		payload = z.Bytes()
+		flags |= CacheCompressed
+	}
+
+	var h cacheWriter
+	h.WriteString(CacheMagic)
+	for _, v := range []int{CacheVersion, int(flags), n, len(m.Indices), indexSize,
+		len(attribs), len(groups), len(m.Materials)} {
+		h.u32(uint32(v))
+	}
+	min, max := m.Bounds(0, len(m.Indices))
+	for _, f := range append(min[:], max[:]...) {
+		h.f32(f)
+	}
+	h.u32(uint32(p.Len()))
+	h.u32(uint32(len(payload)))
+
+	if _, err := out.Write(h.Bytes()); err != nil {
+		return err
+	}
+	_, err := out.Write(payload)
+	return err
+}
+
+// SaveCache writes m to a cache file.
+func SaveCache(filename string, m *Mesh, compress bool) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := WriteCache(f, m, compress); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+type cacheReader struct {
+	data []byte
+	off  int
+	err  error
+}
+
+func (r *cacheReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.off+n > len(r.data) {
+		r.err = errors.New("mesh cache is truncated")
+		return nil
+	}
+	b := r.data[r.off : r.off+n]
+	r.off += n
+	return b
+}
+
+func (r *cacheReader) u32() uint32 {
+	b := r.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+func (r *cacheReader) f32() float32 {
+	return math.Float32frombits(r.u32())
+}
+
+func (r *cacheReader) vec3() (v [3]float32) {
+	for k := range v {
+		v[k] = r.f32()
+	}
+	return
+}
+
+func (r *cacheReader) str() string {
+	n := int(r.u32())
+	s := string(r.bytes(n))
+	r.bytes((4 - n%4) % 4)
+	return s
+}
+
+// ParseCache parses a whole cache file held in data.
+func ParseCache(data []byte) (*Cache, error) {
+	if len(data) < cacheHeaderSize || string(data[:4]) != CacheMagic {
+		return nil, errors.New("not a mesh cache")
+	}
+	r := &cacheReader{data: data, off: 4}
+	c := &Cache{Version: int(r.u32())}
+	if c.Version > CacheVersion {
+		return nil, fmt.Errorf("mesh cache version %d is newer than %d", c.Version, CacheVersion)
+	}
+	flags := r.u32()
+	c.Vertices, c.Indices, c.IndexSize = int(r.u32()), int(r.u32()), int(r.u32())
+	attribs, groups, materials := int(r.u32()), int(r.u32()), int(r.u32())
+	c.Min, c.Max = r.vec3(), r.vec3()
+	size, stored := int(r.u32()), int(r.u32())
+
+	payload := r.bytes(stored)
+	if r.err != nil {
+		return nil, r.err
+	}
+	if c.IndexSize != 2 && c.IndexSize != 4 {
+		return nil, fmt.Errorf("mesh cache: bad index size %d", c.IndexSize)
+	}
+
+	// attributes, groups and materials take at least 20, 40 and 4 bytes,
+	// and zlib packs at most about 1032 bytes into one, so counts the
+	// payload cannot hold are rejected before anything is allocated
+	room := stored
+	if flags&CacheCompressed != 0 {
+		room = stored * 1032
+	}
+	need := 4 + attribs*20 + groups*40 + materials*4 + c.Indices*c.IndexSize
+	if size < 0 || size > room || need > size {
+		return nil, errors.New("mesh cache is truncated")
+	}
+	if flags&CacheCompressed != 0 {
+		zr, err := zlib.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		payload = make([]byte, size)
+		if _, err := io.ReadFull(zr, payload); err != nil {
+			return nil, fmt.Errorf("mesh cache: %v", err)
+		}
+	}
+	r = &cacheReader{data: payload}
+	c.Stride = int(r.u32())
+	for i := 0; i < attribs && r.err == nil; i++ {
+		a := CacheAttrib{Name: r.str()}
+		a.Size, a.Type = int(r.u32()), r.u32()
+		a.Normalized, a.Offset = r.u32() != 0, int(r.u32())
+		size := cacheTypeSize(a.Type)
+		if size == 0 || a.Size < 1 || a.Size > 4 {
+			return nil, fmt.Errorf("mesh cache: bad attribute %s", a.Name)
+		}
+		if a.Offset+a.Size*size > c.Stride {
+			return nil, fmt.Errorf("mesh cache: attribute %s outside the vertex", a.Name)
+		}
+		c.Attribs = append(c.Attribs, a)
+	}
+	for i := 0; i < groups && r.err == nil; i++ {
+		var g CacheGroup
+		g.Object, g.Name = splitGroupName(r.str())
+		g.Start, g.Count, g.Material = int(r.u32()), int(r.u32()), int(int32(r.u32()))
+		g.Min, g.Max = r.vec3(), r.vec3()
+		if g.Start < 0 || g.Count < 0 || g.Start+g.Count > c.Indices {
+			return nil, fmt.Errorf("mesh cache: group %q out of range", g.Name)
+		}
+		c.Groups = append(c.Groups, g)
+	}
+	for i := 0; i < materials && r.err == nil; i++ {
+		c.Materials = append(c.Materials, r.str())
+	}
+	if c.Stride > 0 && c.Vertices > len(payload)/c.Stride {
+		return nil, errors.New("mesh cache is truncated")
+	}
+	c.VertexData = r.bytes(c.Vertices * c.Stride)
+	c.IndexData = r.bytes(c.Indices * c.IndexSize)
+	if r.err != nil {
+		return nil, r.err
+	}
+	for k := 0; k < c.Indices; k++ {
+		if i := c.index(k); i >= c.Vertices {
+			return nil, fmt.Errorf("mesh cache: index %d out of range", i)
+		}
+	}
+	return c, nil
+}
+
+// index returns index k of the index data.
+func (c *Cache) index(k int) int {
+	if c.IndexSize == 2 {
+		return int(binary.LittleEndian.Uint16(c.IndexData[k*2:]))
+	}
+	return int(binary.LittleEndian.Uint32(c.IndexData[k*4:]))
+}
+
+// cacheTypeSize is the byte size of a GL component type, 0 for unknown
+// ones.
+func cacheTypeSize(typ uint32) int {
+	switch typ {
+	case 0x1400, 0x1401: // GL_BYTE, GL_UNSIGNED_BYTE
+		return 1
+	case 0x1402, 0x1403, 0x140B: // GL_SHORT, GL_UNSIGNED_SHORT, GL_HALF_FLOAT
+		return 2
+	case 0x1404, 0x1405, cacheFloat: // GL_INT, GL_UNSIGNED_INT
+		return 4
+	}
+	return 0
+}
+
+func splitGroupName(s string) (object, name string) {
+	if i := strings.IndexByte(s, 0); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	return "", s
+}
+
+// ReadCache reads a mesh cache back into a Mesh.
+func ReadCache(r io.Reader) (*Mesh, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	c, err := ParseCache(data)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Mesh{}
+	for _, a := range c.Attribs {
+		if a.Type != cacheFloat || a.Normalized {
+			return nil, fmt.Errorf("mesh cache: attribute %s is not float", a.Name)
+		}
+		values := make([]float32, 0, c.Vertices*a.Size)
+		for v := 0; v < c.Vertices; v++ {
+			off := v*c.Stride + a.Offset
+			for k := 0; k < a.Size; k++ {
+				bits := binary.LittleEndian.Uint32(c.VertexData[off+k*4:])
+				values = append(values, math.Float32frombits(bits))
+			}
+		}
+		switch a.Name {
+		case "position":
+			m.Positions = values
+		case "normal":
+			m.Normals = values
+		case "texcoord":
+			m.TexCoords = values
+		case "colour":
+			m.Colours = values
+		case "tangent":
+			m.Tangents = values
+		default:
+			if m.Extra == nil {
+				m.Extra = make(map[string][]float32)
+			}
+			m.Extra[a.Name] = values
+		}
+	}
+
+	m.Indices = make([]uint32, c.Indices)
+	for k := range m.Indices {
+		m.Indices[k] = uint32(c.index(k))
+	}
+	for _, g := range c.Groups {
+		m.Groups = append(m.Groups, g.Group)
+	}
+	for _, name := range c.Materials {
+		m.Materials = append(m.Materials, defaultMaterial(name))
+	}
+	return m, nil
+}
+
+// LoadCache reads a mesh cache file.
+func LoadCache(filename string) (*Mesh, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	m, err := ReadCache(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	m.Name = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	return m, nil
+}
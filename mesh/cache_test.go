@@ -0,0 +1,96 @@
+package mesh
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestCacheRoundTrip(t *testing.T) {
+	merged := Merge(Cube(1, 1), UVSphere(1, 8, 4))
+	merged.Groups[1].Object, merged.Groups[1].Name = "ball", "sphere"
+	merged.Materials = []Material{defaultMaterial("metal")}
+	merged.Groups[0].Material = 0
+	scan := Plane(2, 2, 4, 4)
+	scan.TexCoords, scan.Tangents = nil, nil
+	scan.Extra = map[string][]float32{"confidence": make([]float32, scan.VertexCount())}
+	for i := range scan.Extra["confidence"] {
+		scan.Extra["confidence"][i] = float32(i) / 10
+	}
+	big := Plane(1, 1, 300, 300) // more than 65536 vertices, uint32 indices
+
+	tests := []struct {
+		name     string
+		mesh     *Mesh
+		compress bool
+	}{
+		{"cube", Cube(1, 2), false},
+		{"compressed cube", Cube(1, 2), true},
+		{"groups and materials", merged, true},
+		{"extra attribute", scan, false},
+		{"wide indices", big, true},
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		if err := WriteCache(&b, tt.mesh, tt.compress); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		m, err := ReadCache(&b)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		want := tt.mesh
+		if !reflect.DeepEqual(m.Positions, want.Positions) || !reflect.DeepEqual(m.Normals, want.Normals) ||
+			!reflect.DeepEqual(m.TexCoords, want.TexCoords) || !reflect.DeepEqual(m.Tangents, want.Tangents) ||
+			!reflect.DeepEqual(m.Extra, want.Extra) {
+			t.Errorf("%s: vertex attributes differ", tt.name)
+		}
+		if !reflect.DeepEqual(m.Indices, want.Indices) {
+			t.Errorf("%s: indices differ", tt.name)
+		}
+		if !reflect.DeepEqual(m.Groups, want.Groups) {
+			t.Errorf("%s: groups %+v, want %+v", tt.name, m.Groups, want.Groups)
+		}
+		if len(m.Materials) != len(want.Materials) {
+			t.Errorf("%s: %d materials, want %d", tt.name, len(m.Materials), len(want.Materials))
+		}
+	}
+}
+
+func TestParseCacheErrors(t *testing.T) {
+	var plain, packed bytes.Buffer
+	WriteCache(&plain, Cube(1, 1), false)
+	WriteCache(&packed, Cube(1, 1), true)
+	// patch returns a copy of a cache with header word i set to v
+	patch := func(b *bytes.Buffer, i int, v uint32) []byte {
+		d := append([]byte(nil), b.Bytes()...)
+		binary.LittleEndian.PutUint32(d[i*4:], v)
+		return d
+	}
+	const version, vertices, indices, indexSize, attribs, groups, size, stored = 1, 3, 4, 5, 6, 7, 15, 16
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"not a cache", []byte("OBJ\n" + string(make([]byte, cacheHeaderSize)))},
+		{"newer version", patch(&plain, version, CacheVersion+1)},
+		{"bad index size", patch(&plain, indexSize, 3)},
+		{"truncated", plain.Bytes()[:plain.Len()-8]},
+		{"stored past the end", patch(&plain, stored, 1<<30)},
+		{"huge unpacked size", patch(&packed, size, 0xffffffff)},
+		{"huge attribute count", patch(&plain, attribs, 0xffffffff)},
+		{"huge group count", patch(&packed, groups, 1<<28)},
+		{"huge index count", patch(&packed, indices, 1<<30)},
+		{"vertices past the payload", patch(&plain, vertices, 1<<20)},
+		{"indices out of range", patch(&plain, vertices, 4)},
+	}
+	for _, tt := range tests {
+		if c, err := ParseCache(tt.data); err == nil {
+			t.Errorf("%s: parsed %d vertices and %d indices", tt.name, c.Vertices, c.Indices)
+		}
+	}
+}
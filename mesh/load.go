@@ -0,0 +1,22 @@
+package mesh
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Load reads a mesh file, choosing the importer by file extension.
+func Load(filename string) (*Mesh, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".obj":
+		return LoadOBJ(filename)
+	case ".ply":
+		return LoadPLY(filename)
+	case ".stl":
+		return LoadSTL(filename)
+	case ".mesh":
+		return LoadCache(filename)
+	}
+	return nil, fmt.Errorf("%s: unsupported mesh format", filename)
+}
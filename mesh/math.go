@@ -0,0 +1,52 @@
+package mesh
+
+import (
+	"math"
+)
+
+func vec3(a []float32, i uint32) [3]float32 {
+	return [3]float32{a[i*3], a[i*3+1], a[i*3+2]}
+}
+
+func sub(a, b [3]float32) [3]float32 {
+	return [3]float32{a[0] - b[0], a[1] - b[1], a[2] - b[2]}
+}
+
+func add(a, b [3]float32) [3]float32 {
+	return [3]float32{a[0] + b[0], a[1] + b[1], a[2] + b[2]}
+}
+
+func scale(a [3]float32, s float32) [3]float32 {
+	return [3]float32{a[0] * s, a[1] * s, a[2] * s}
+}
+
+func dot(a, b [3]float32) float32 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+}
+
+func cross(a, b [3]float32) [3]float32 {
+	return [3]float32{
+		a[1]*b[2] - a[2]*b[1],
+		a[2]*b[0] - a[0]*b[2],
+		a[0]*b[1] - a[1]*b[0],
+	}
+}
+
+func length(a [3]float32) float32 {
+	return float32(math.Sqrt(float64(dot(a, a))))
+}
+
+func normalize(a [3]float32) [3]float32 {
+	l := length(a)
+	if l == 0 {
+		return a
+	}
+	return scale(a, 1/l)
+}
+
+// faceNormal returns the unnormalized normal of a triangle, its length is
+// twice the triangle area.
+func faceNormal(positions []float32, a, b, c uint32) [3]float32 {
+	p0 := vec3(positions, a)
+	return cross(sub(vec3(positions, b), p0), sub(vec3(positions, c), p0))
+}
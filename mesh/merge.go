@@ -0,0 +1,97 @@
+package mesh
+
+// Transform applies a column-major affine matrix to the positions, the
+// inverse transpose to the normals and the upper 3x3 to the tangents.
+func (m *Mesh) Transform(mat [16]float32) {
+	for i := 0; i+2 < len(m.Positions); i += 3 {
+		p := [3]float32{m.Positions[i], m.Positions[i+1], m.Positions[i+2]}
+		for r := 0; r < 3; r++ {
+			m.Positions[i+r] = mat[r]*p[0] + mat[4+r]*p[1] + mat[8+r]*p[2] + mat[12+r]
+		}
+	}
+
+	// rows of the inverse are the cross products of the columns over the
+	// determinant; the sign of the determinant is all the transpose needs
+	c0 := [3]float32{mat[0], mat[1], mat[2]}
+	c1 := [3]float32{mat[4], mat[5], mat[6]}
+	c2 := [3]float32{mat[8], mat[9], mat[10]}
+	n0, n1, n2 := cross(c1, c2), cross(c2, c0), cross(c0, c1)
+	mirror := dot(c0, n0) < 0 // negative determinant
+	if mirror {
+		n0, n1, n2 = scale(n0, -1), scale(n1, -1), scale(n2, -1)
+	}
+	for i := 0; i+2 < len(m.Normals); i += 3 {
+		n := [3]float32{m.Normals[i], m.Normals[i+1], m.Normals[i+2]}
+		n = normalize(add(add(scale(n0, n[0]), scale(n1, n[1])), scale(n2, n[2])))
+		copy(m.Normals[i:i+3], n[:])
+	}
+	// mirroring flips the bitangent and turns the triangles inside out
+	for i := 0; i+3 < len(m.Tangents); i += 4 {
+		t := [3]float32{m.Tangents[i], m.Tangents[i+1], m.Tangents[i+2]}
+		t = normalize(add(add(scale(c0, t[0]), scale(c1, t[1])), scale(c2, t[2])))
+		copy(m.Tangents[i:i+3], t[:])
+		if mirror {
+			m.Tangents[i+3] = -m.Tangents[i+3]
+		}
+	}
+	if mirror {
+		for i := 0; i+2 < len(m.Indices); i += 3 {
+			m.Indices[i+1], m.Indices[i+2] = m.Indices[i+2], m.Indices[i+1]
+		}
+	}
+}
+
+// Merge concatenates meshes into a new one. Attributes missing from any of
+// them are dropped, groups and materials are carried over.
+func Merge(meshes ...*Mesh) *Mesh {
+	out := &Mesh{}
+	all := func(get func(m *Mesh) []float32) bool {
+		for _, m := range meshes {
+			if len(get(m)) == 0 {
+				return false
+			}
+		}
+		return len(meshes) > 0
+	}
+	normals := all(func(m *Mesh) []float32 { return m.Normals })
+	texcoords := all(func(m *Mesh) []float32 { return m.TexCoords })
+	colours := all(func(m *Mesh) []float32 { return m.Colours })
+	tangents := all(func(m *Mesh) []float32 { return m.Tangents })
+
+	for _, m := range meshes {
+		base := uint32(out.VertexCount())
+		start := len(out.Indices)
+		materials := len(out.Materials)
+
+		out.Positions = append(out.Positions, m.Positions...)
+		if normals {
+			out.Normals = append(out.Normals, m.Normals...)
+		}
+		if texcoords {
+			out.TexCoords = append(out.TexCoords, m.TexCoords...)
+		}
+		if colours {
+			out.Colours = append(out.Colours, m.Colours...)
+		}
+		if tangents {
+			out.Tangents = append(out.Tangents, m.Tangents...)
+		}
+		for _, i := range m.Indices {
+			out.Indices = append(out.Indices, base+i)
+		}
+		out.Materials = append(out.Materials, m.Materials...)
+
+		groups := m.Groups
+		if len(groups) == 0 {
+			groups = []Group{{Material: -1, Count: len(m.Indices)}}
+		}
+		for _, g := range groups {
+			g.Start += start
+			if g.Material >= 0 {
+				g.Material += materials
+			}
+			out.Groups = append(out.Groups, g)
+		}
+	}
+	return out
+}
@@ -0,0 +1,106 @@
+package mesh
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTransform(t *testing.T) {
+	s, c := float32(math.Sin(0.5)), float32(math.Cos(0.5))
+	tests := []struct {
+		name   string
+		mat    [16]float32
+		mirror bool
+	}{
+		{"identity", [16]float32{1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1}, false},
+		{"translate", [16]float32{1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, 3, -2, 1, 1}, false},
+		{"rotate y", [16]float32{c, 0, -s, 0, 0, 1, 0, 0, s, 0, c, 0, 0, 0, 0, 1}, false},
+		{"non-uniform scale", [16]float32{2, 0, 0, 0, 0, 0.5, 0, 0, 0, 0, 3, 0, 0, 0, 0, 1}, false},
+		{"mirror x", [16]float32{-1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1}, true},
+		{"negative scale", [16]float32{-2, 0, 0, 0, 0, -2, 0, 0, 0, 0, -2, 0, 1, 1, 1, 1}, true},
+	}
+
+	for _, tt := range tests {
+		m := Torus(2, 0.5, 12, 8)
+		before := append([]uint32(nil), m.Indices...)
+		m.Transform(tt.mat)
+
+		swapped := m.Indices[1] == before[2] && m.Indices[2] == before[1]
+		if swapped != tt.mirror {
+			t.Errorf("%s: winding swapped %v, want %v", tt.name, swapped, tt.mirror)
+		}
+		for i := 0; i+2 < len(m.Indices); i += 3 {
+			fn := faceNormal(m.Positions, m.Indices[i], m.Indices[i+1], m.Indices[i+2])
+			if dot(fn, vec3(m.Normals, m.Indices[i])) <= 0 {
+				t.Errorf("%s: triangle %d is wound against its normals", tt.name, i/3)
+				break
+			}
+		}
+
+		// bitangent signs match those built afresh, tangents stay
+		// perpendicular to the normals
+		ref := m.clone()
+		ref.Tangents = nil
+		if err := ref.GenerateTangents(); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < m.VertexCount(); i++ {
+			tan := [3]float32{m.Tangents[i*4], m.Tangents[i*4+1], m.Tangents[i*4+2]}
+			if d := dot(tan, vec3(m.Normals, uint32(i))); math.Abs(float64(d)) > 1e-4 {
+				t.Errorf("%s: tangent %d is off the normal by %g", tt.name, i, d)
+				break
+			}
+			if m.Tangents[i*4+3] != ref.Tangents[i*4+3] {
+				t.Errorf("%s: bitangent sign %d is %g, want %g", tt.name, i, m.Tangents[i*4+3], ref.Tangents[i*4+3])
+				break
+			}
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a := Cube(1, 1)
+	a.Materials = []Material{defaultMaterial("a")}
+	a.Groups[0].Material = 0
+	b := Plane(1, 1, 1, 1)
+	b.Materials = []Material{defaultMaterial("b")}
+	b.Groups[0].Material = 0
+	c := Plane(1, 1, 1, 1)
+	c.TexCoords, c.Tangents, c.Groups = nil, nil, nil
+
+	tests := []struct {
+		name      string
+		meshes    []*Mesh
+		texcoords bool
+		groups    []Group
+	}{
+		{"all attributes", []*Mesh{a, b}, true, []Group{
+			{Material: 0, Start: 0, Count: 36}, {Material: 1, Start: 36, Count: 6}}},
+		{"missing texcoords", []*Mesh{a, c}, false, []Group{
+			{Material: 0, Start: 0, Count: 36}, {Material: -1, Start: 36, Count: 6}}},
+	}
+	for _, tt := range tests {
+		m := Merge(tt.meshes...)
+		vertices := 0
+		for _, k := range tt.meshes {
+			vertices += k.VertexCount()
+		}
+		if m.VertexCount() != vertices || len(m.Normals) != vertices*3 {
+			t.Errorf("%s: %d vertices, want %d with normals", tt.name, m.VertexCount(), vertices)
+		}
+		if (len(m.TexCoords) > 0) != tt.texcoords || (len(m.Tangents) > 0) != tt.texcoords {
+			t.Errorf("%s: texcoords and tangents kept %v, want %v", tt.name, len(m.TexCoords) > 0, tt.texcoords)
+		}
+		if len(m.Groups) != len(tt.groups) {
+			t.Fatalf("%s: groups %+v", tt.name, m.Groups)
+		}
+		for i, g := range tt.groups {
+			if m.Groups[i] != g {
+				t.Errorf("%s: group %d is %+v, want %+v", tt.name, i, m.Groups[i], g)
+			}
+		}
+		if last := m.Indices[len(m.Indices)-1]; int(last) < tt.meshes[0].VertexCount() {
+			t.Errorf("%s: indices of the second mesh are not offset", tt.name)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package mesh
+
+// Mesh is an indexed triangle mesh. Every attribute is kept in its own
+// array, all of them indexed by the same vertex number, which matches the
+// one-buffer-per-attribute layout used by the examples.
+type Mesh struct {
+	Name      string
+	Positions []float32            // x, y, z
+	Normals   []float32            // x, y, z, optional
+	TexCoords []float32            // u, v, optional
+	Colours   []float32            // r, g, b, optional
+	Tangents  []float32            // x, y, z, bitangent sign, optional
+	Indices   []uint32             // three per triangle
+	Extra     map[string][]float32 // other per-vertex scalars, e.g. PLY confidence
+	Groups    []Group
+	Materials []Material
+}
+
+// Group is a run of triangles sharing an object, a group and a material.
+type Group struct {
+	Object   string
+	Name     string
+	Material int // index into Mesh.Materials, -1 if none
+	Start    int // first index
+	Count    int // number of indices
+}
+
+// Material holds the Wavefront MTL parameters of a material. Texture maps
+// are file names already resolved against the directory of the MTL file.
+type Material struct {
+	Name        string
+	Ambient     [3]float32
+	Diffuse     [3]float32
+	Specular    [3]float32
+	Emissive    [3]float32
+	Shininess   float32
+	Opacity     float32
+	Illum       int
+	AmbientMap  string
+	DiffuseMap  string
+	SpecularMap string
+	EmissiveMap string
+	BumpMap     string
+	AlphaMap    string
+}
+
+func defaultMaterial(name string) Material {
+	return Material{
+		Name:      name,
+		Ambient:   [3]float32{0.2, 0.2, 0.2},
+		Diffuse:   [3]float32{0.8, 0.8, 0.8},
+		Specular:  [3]float32{1.0, 1.0, 1.0},
+		Shininess: 1.0,
+		Opacity:   1.0,
+		Illum:     2,
+	}
+}
+
+func (m *Mesh) VertexCount() int {
+	return len(m.Positions) / 3
+}
+
+func (m *Mesh) TriangleCount() int {
+	return len(m.Indices) / 3
+}
+
+// Material returns the index of the named material, or -1.
+func (m *Mesh) Material(name string) int {
+	for i := range m.Materials {
+		if m.Materials[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// FromArrays wraps raw vertex positions, like the point arrays of the
+// examples, into a mesh. Without indices every three vertices form a
+// triangle.
+func FromArrays(positions []float32, indices []uint32) *Mesh {
+	m := &Mesh{Positions: positions, Indices: indices}
+	if indices == nil {
+		m.Indices = make([]uint32, m.VertexCount())
+		for i := range m.Indices {
+			m.Indices[i] = uint32(i)
+		}
+	}
+	m.Groups = []Group{{Material: -1, Count: len(m.Indices)}}
+	return m
+}
+
+// remap replaces the vertices by copies of the old vertices listed in src,
+// and the index list by indices into the new vertices.
+func (m *Mesh) remap(src []uint32, indices []uint32) {
+	pick := func(a []float32, size int) []float32 {
+		if len(a) == 0 {
+			return a
+		}
+		out := make([]float32, 0, len(src)*size)
+		for _, i := range src {
+			out = append(out, a[int(i)*size:int(i+1)*size]...)
+		}
+		return out
+	}
+
+	m.Positions = pick(m.Positions, 3)
+	m.Normals = pick(m.Normals, 3)
+	m.TexCoords = pick(m.TexCoords, 2)
+	m.Colours = pick(m.Colours, 3)
+	m.Tangents = pick(m.Tangents, 4)
+	for k, a := range m.Extra {
+		m.Extra[k] = pick(a, 1)
+	}
+	m.Indices = indices
+}
+
+// clone copies the mesh deep enough that remap and index changes on the
+// copy leave m alone.
+func (m *Mesh) clone() *Mesh {
+	c := *m
+	c.Indices = append([]uint32(nil), m.Indices...)
+	c.Groups = append([]Group(nil), m.Groups...)
+	if m.Extra != nil {
+		c.Extra = make(map[string][]float32, len(m.Extra))
+		for k, a := range m.Extra {
+			c.Extra[k] = a
+		}
+	}
+	return &c
+}
+
+// Bounds returns the axis aligned bounding box of the vertices used by
+// indices[start:start+count].
+func (m *Mesh) Bounds(start, count int) (min, max [3]float32) {
+	first := true
+	for _, i := range m.Indices[start : start+count] {
+		p := vec3(m.Positions, i)
+		if first {
+			min, max, first = p, p, false
+			continue
+		}
+		for k := 0; k < 3; k++ {
+			if p[k] < min[k] {
+				min[k] = p[k]
+			}
+			if p[k] > max[k] {
+				max[k] = p[k]
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,171 @@
+package mesh
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// LoadMTL reads a Wavefront material library.
+func LoadMTL(filename string) ([]Material, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadMTL(file, filename)
+}
+
+// ReadMTL parses MTL data from r. Texture map names are resolved relative to
+// the directory of filename.
+func ReadMTL(r io.Reader, filename string) ([]Material, error) {
+	var materials []Material
+	var cur *Material
+
+	dir := filepath.Dir(filename)
+	line := 0
+	errorf := func(format string, a ...interface{}) error {
+		return fmt.Errorf("%s:%d: %s", filename, line, fmt.Sprintf(format, a...))
+	}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if i := strings.IndexByte(text, '#'); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+
+		key, args := fields[0], fields[1:]
+		if key == "newmtl" {
+			materials = append(materials, defaultMaterial(strings.Join(args, " ")))
+			cur = &materials[len(materials)-1]
+			continue
+		}
+		if cur == nil {
+			return nil, errorf("%s before newmtl", key)
+		}
+
+		var err error
+		switch strings.ToLower(key) {
+		case "ka":
+			cur.Ambient, err = mtlColour(args)
+		case "kd":
+			cur.Diffuse, err = mtlColour(args)
+		case "ks":
+			cur.Specular, err = mtlColour(args)
+		case "ke":
+			cur.Emissive, err = mtlColour(args)
+		case "ns":
+			cur.Shininess, err = mtlFloat(args)
+		case "d":
+			cur.Opacity, err = mtlFloat(args)
+		case "tr":
+			var tr float32
+			tr, err = mtlFloat(args)
+			cur.Opacity = 1 - tr
+		case "illum":
+			if len(args) > 0 {
+				cur.Illum, err = strconv.Atoi(args[0])
+			}
+		case "map_ka":
+			cur.AmbientMap = mtlMap(dir, args)
+		case "map_kd":
+			cur.DiffuseMap = mtlMap(dir, args)
+		case "map_ks":
+			cur.SpecularMap = mtlMap(dir, args)
+		case "map_ke":
+			cur.EmissiveMap = mtlMap(dir, args)
+		case "map_bump", "bump", "norm":
+			cur.BumpMap = mtlMap(dir, args)
+		case "map_d":
+			cur.AlphaMap = mtlMap(dir, args)
+		}
+		if err != nil {
+			return nil, errorf("%s: %v", key, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	return materials, nil
+}
+
+func mtlFloat(args []string) (float32, error) {
+	if len(args) == 0 {
+		return 0, fmt.Errorf("missing value")
+	}
+	f, err := strconv.ParseFloat(args[0], 32)
+	return float32(f), err
+}
+
+func mtlColour(args []string) (c [3]float32, err error) {
+	if len(args) > 0 && (args[0] == "spectral" || args[0] == "xyz") {
+		return c, fmt.Errorf("%s colours are not supported", args[0])
+	}
+	if len(args) == 0 {
+		return c, fmt.Errorf("missing value")
+	}
+	for i := 0; i < 3; i++ {
+		s := args[0]
+		if i < len(args) {
+			s = args[i]
+		}
+		f, err := strconv.ParseFloat(s, 32)
+		if err != nil {
+			return c, err
+		}
+		c[i] = float32(f)
+	}
+	return c, nil
+}
+
+// mtlMapOptions is the number of arguments of each texture option, or
+// minus the most for -o, -s and -t, which take one to three numbers.
+var mtlMapOptions = map[string]int{
+	"-blendu": 1, "-blendv": 1, "-bm": 1, "-boost": 1, "-cc": 1, "-clamp": 1,
+	"-imfchan": 1, "-mm": 2, "-texres": 1, "-type": 1,
+	"-o": -3, "-s": -3, "-t": -3,
+}
+
+// mtlMap skips the texture options (-bm 1, -o u v w, ...) and returns the
+// file name, the rest of the statement, which may contain spaces.
+func mtlMap(dir string, args []string) string {
+	for len(args) > 1 {
+		n, ok := mtlMapOptions[args[0]]
+		if !ok {
+			break
+		}
+		args = args[1:]
+		for i := 0; i < n || i < -n; i++ {
+			if len(args) <= 1 {
+				break
+			}
+			if n < 0 {
+				if _, err := strconv.ParseFloat(args[0], 32); err != nil {
+					break
+				}
+			}
+			args = args[1:]
+		}
+	}
+	if len(args) == 0 {
+		return ""
+	}
+	name := strings.Join(args, " ")
+	name = strings.Replace(name, "\\", "/", -1)
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(dir, filepath.FromSlash(name))
+}
@@ -0,0 +1,122 @@
+package mesh
+
+import (
+	"math"
+)
+
+// GenerateFlatNormals gives every triangle its own three vertices with the
+// face normal, so all edges are hard. Existing tangents are regenerated.
+func (m *Mesh) GenerateFlatNormals() {
+	src := make([]uint32, len(m.Indices))
+	indices := make([]uint32, len(m.Indices))
+	for k, i := range m.Indices {
+		src[k] = i
+		indices[k] = uint32(k)
+	}
+	m.remap(src, indices)
+
+	m.Normals = make([]float32, len(m.Positions))
+	for t := 0; t+2 < len(m.Indices); t += 3 {
+		n := normalize(faceNormal(m.Positions, m.Indices[t], m.Indices[t+1], m.Indices[t+2]))
+		for _, i := range m.Indices[t : t+3] {
+			copy(m.Normals[i*3:i*3+3], n[:])
+		}
+	}
+	m.updateTangents()
+}
+
+// updateTangents regenerates tangents that were there before the normals
+// changed, as the old ones are no longer perpendicular to them. Without
+// texcoords to build new ones they are dropped.
+func (m *Mesh) updateTangents() {
+	if len(m.Tangents) == 0 {
+		return
+	}
+	if err := m.GenerateTangents(); err != nil {
+		m.Tangents = nil
+	}
+}
+
+// cornerAngle returns the angle of a triangle at corner a.
+func cornerAngle(positions []float32, a, b, c uint32) float32 {
+	e1 := normalize(sub(vec3(positions, b), vec3(positions, a)))
+	e2 := normalize(sub(vec3(positions, c), vec3(positions, a)))
+	d := float64(dot(e1, e2))
+	return float32(math.Acos(math.Max(-1, math.Min(1, d))))
+}
+
+// GenerateNormals computes smooth normals, weighting the faces around a
+// vertex by their angle at that vertex. Faces whose normals differ by more
+// than creaseAngle (radians) are not smoothed together; vertices along such
+// creases are split. Vertices at the same position are smoothed together
+// even when they differ in other attributes, so texture seams stay
+// invisible. A creaseAngle of math.Pi or more smooths everything.
+// Existing tangents are regenerated to match the new normals.
+func (m *Mesh) GenerateNormals(creaseAngle float32) {
+	tris := m.TriangleCount()
+	faces := make([][3]float32, tris)
+	for t := range faces {
+		i := m.Indices[t*3 : t*3+3]
+		faces[t] = normalize(faceNormal(m.Positions, i[0], i[1], i[2]))
+	}
+
+	// corners around every position
+	type corner struct {
+		face   int
+		weight float32
+	}
+	around := make(map[[3]float32][]corner)
+	for t := 0; t < tris; t++ {
+		i := m.Indices[t*3 : t*3+3]
+		for k := 0; k < 3; k++ {
+			p := vec3(m.Positions, i[k])
+			w := cornerAngle(m.Positions, i[k], i[(k+1)%3], i[(k+2)%3])
+			around[p] = append(around[p], corner{t, w})
+		}
+	}
+
+	cos := float32(math.Cos(float64(creaseAngle)))
+	if creaseAngle >= math.Pi {
+		cos = -2
+	}
+
+	// normal of every corner, then one vertex per distinct (vertex, normal)
+	type key struct {
+		vertex uint32
+		normal [3]float32
+	}
+	vertices := make(map[key]uint32)
+	var src []uint32
+	var normals []float32
+	indices := make([]uint32, len(m.Indices))
+
+	for t := 0; t < tris; t++ {
+		for k := 0; k < 3; k++ {
+			v := m.Indices[t*3+k]
+			var n [3]float32
+			for _, c := range around[vec3(m.Positions, v)] {
+				if c.face == t || dot(faces[c.face], faces[t]) >= cos {
+					n = add(n, scale(faces[c.face], c.weight))
+				}
+			}
+			n = normalize(n)
+			if n == ([3]float32{}) {
+				n = faces[t]
+			}
+
+			kk := key{v, n}
+			i, ok := vertices[kk]
+			if !ok {
+				i = uint32(len(src))
+				vertices[kk] = i
+				src = append(src, v)
+				normals = append(normals, n[:]...)
+			}
+			indices[t*3+k] = i
+		}
+	}
+
+	m.remap(src, indices)
+	m.Normals = normals
+	m.updateTangents()
+}
@@ -0,0 +1,143 @@
+package mesh
+
+import (
+	"math"
+	"testing"
+)
+
+// flipU mirrors the texture coordinates of m horizontally.
+func flipU(m *Mesh) *Mesh {
+	for i := 0; i < len(m.TexCoords); i += 2 {
+		m.TexCoords[i] = 1 - m.TexCoords[i]
+	}
+	return m
+}
+
+func TestGenerateNormals(t *testing.T) {
+	outward := func(p [3]float32) [3]float32 { return normalize(p) }
+	// away from the Y axis
+	radial := func(p [3]float32) [3]float32 { return normalize([3]float32{p[0], 0, p[2]}) }
+	// away from the middle of the tube of Torus(2, ...)
+	tube := func(p [3]float32) [3]float32 { return normalize(sub(p, scale(radial(p), 2))) }
+	tests := []struct {
+		name     string
+		mesh     *Mesh
+		crease   float32                       // radians, -1 for flat normals
+		vertices int                           // 0 for no more than the mesh had
+		want     func(p [3]float32) [3]float32 // expected normal at p, nil for the face normal
+		tol      float64
+	}{
+		{"cube creased", Cube(2, 1), math.Pi / 3, 24, nil, 1e-5},
+		{"cube smoothed", Cube(2, 1), math.Pi, 24, outward, 1e-5},
+		{"sphere", UVSphere(1, 32, 16), math.Pi / 3, 0, outward, 1e-2},
+		{"torus", Torus(2, 0.5, 48, 24), math.Pi / 3, 0, tube, 1e-2},
+		{"flat cube", Cube(2, 1), -1, 36, nil, 1e-5},
+		{"flat sphere", UVSphere(1, 8, 4), -1, (4*8*2 - 2*8) * 3, nil, 1e-5},
+		// a crease below the angle between the side faces splits every
+		// vertex of the sides
+		{"cylinder creased", Cylinder(1, 2, 16, 1, false), 0.1, 16 * 2 * 2, nil, 1e-5},
+		{"cylinder smoothed", Cylinder(1, 2, 16, 1, false), math.Pi / 3, 0, radial, 1e-5},
+	}
+	for _, tt := range tests {
+		m := tt.mesh
+		vertices := m.VertexCount()
+		m.Normals = nil
+		if tt.crease < 0 {
+			m.GenerateFlatNormals()
+		} else {
+			m.GenerateNormals(tt.crease)
+		}
+		if len(m.Normals) != m.VertexCount()*3 ||
+			tt.vertices != 0 && m.VertexCount() != tt.vertices || tt.vertices == 0 && m.VertexCount() > vertices {
+			t.Errorf("%s: %d vertices with %d normals, want %d", tt.name, m.VertexCount(), len(m.Normals)/3, tt.vertices)
+			continue
+		}
+
+	check:
+		for i := 0; i+2 < len(m.Indices); i += 3 {
+			fn := normalize(faceNormal(m.Positions, m.Indices[i], m.Indices[i+1], m.Indices[i+2]))
+			for _, v := range m.Indices[i : i+3] {
+				n, w := vec3(m.Normals, v), fn
+				if tt.want != nil {
+					w = tt.want(vec3(m.Positions, v))
+				}
+				if dot(fn, n) <= 0 || float64(length(sub(n, w))) > tt.tol {
+					t.Errorf("%s: normal %d of triangle %d is %v, want %v", tt.name, v, i/3, n, w)
+					break check
+				}
+			}
+		}
+	}
+}
+
+func TestGenerateTangents(t *testing.T) {
+	noUV := Cube(1, 1)
+	noUV.TexCoords = nil
+
+	tests := []struct {
+		name    string
+		mesh    *Mesh
+		tangent [3]float32 // of every vertex, zero to compare with the primitive
+		sign    float32
+		tol     float64 // negative to only check the tangents are perpendicular
+		ok      bool
+	}{
+		{"plane", Plane(2, 2, 2, 2), [3]float32{1, 0, 0}, 1, 1e-5, true},
+		{"mirrored plane", flipU(Plane(2, 2, 2, 2)), [3]float32{-1, 0, 0}, -1, 1e-5, true},
+		{"cube", Cube(1, 1), [3]float32{}, 1, 1e-5, true},
+		{"sphere", UVSphere(1, 16, 8), [3]float32{}, 1, -1, true},
+		{"no texcoords", noUV, [3]float32{}, 0, 0, false},
+	}
+	for _, tt := range tests {
+		m := tt.mesh
+		want := append([]float32(nil), m.Tangents...)
+		m.Tangents = nil
+		if err := m.GenerateTangents(); (err == nil) != tt.ok {
+			t.Errorf("%s: error %v", tt.name, err)
+			continue
+		}
+		if !tt.ok {
+			continue
+		}
+		if len(m.Tangents) != m.VertexCount()*4 {
+			t.Errorf("%s: %d tangents for %d vertices", tt.name, len(m.Tangents)/4, m.VertexCount())
+			continue
+		}
+		for i := 0; i < m.VertexCount(); i++ {
+			tan := [3]float32{m.Tangents[i*4], m.Tangents[i*4+1], m.Tangents[i*4+2]}
+			w := tt.tangent
+			if w == ([3]float32{}) {
+				w = [3]float32{want[i*4], want[i*4+1], want[i*4+2]}
+			}
+			if d := dot(tan, vec3(m.Normals, uint32(i))); math.Abs(float64(d)) > 1e-4 {
+				t.Errorf("%s: tangent %d is off the normal by %g", tt.name, i, d)
+				break
+			}
+			if d := length(sub(tan, w)); tt.tol >= 0 && float64(d) > tt.tol || m.Tangents[i*4+3] != tt.sign {
+				t.Errorf("%s: tangent %d is %v, want %v with sign %g", tt.name, i, m.Tangents[i*4:i*4+4], w, tt.sign)
+				break
+			}
+		}
+	}
+}
+
+func TestNormalsUpdateTangents(t *testing.T) {
+	m := UVSphere(1, 8, 4)
+	m.GenerateFlatNormals()
+	if len(m.Tangents) != m.VertexCount()*4 {
+		t.Fatalf("%d tangents for %d vertices", len(m.Tangents)/4, m.VertexCount())
+	}
+	for i := 0; i < m.VertexCount(); i++ {
+		tan := [3]float32{m.Tangents[i*4], m.Tangents[i*4+1], m.Tangents[i*4+2]}
+		if d := dot(tan, vec3(m.Normals, uint32(i))); math.Abs(float64(d)) > 1e-4 {
+			t.Fatalf("tangent %d is off the new normal by %g", i, d)
+		}
+	}
+
+	// without texcoords the stale tangents go
+	m.TexCoords = nil
+	m.GenerateNormals(1)
+	if m.Tangents != nil {
+		t.Errorf("%d tangents kept without texcoords", len(m.Tangents)/4)
+	}
+}
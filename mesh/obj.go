@@ -0,0 +1,373 @@
+package mesh
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type objVertex struct {
+	v, vt, vn int
+	smooth    int // smoothing group, or a unique negative id for flat faces
+}
+
+type objParser struct {
+	filename string
+	dir      string
+	line     int
+
+	v, vt, vn []float32
+	vc        []float32 // optional "v x y z r g b" colours
+	hasColour bool
+
+	mesh     *Mesh
+	cache    map[objVertex]uint32
+	noNormal []bool // output vertices that need a generated normal
+	hasUV    bool
+
+	object   string
+	group    string
+	material int
+	smooth   int
+	faces    int
+}
+
+// LoadOBJ reads a Wavefront OBJ file and the MTL libraries it references.
+// Faces are triangulated, vertices sharing the same position, texcoord,
+// normal and smoothing group are merged, and missing normals are generated
+// per smoothing group.
+func LoadOBJ(filename string) (*Mesh, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadOBJ(file, filename)
+}
+
+// ReadOBJ parses OBJ data from r. filename is used in error messages and to
+// resolve mtllib statements.
+func ReadOBJ(r io.Reader, filename string) (*Mesh, error) {
+	p := &objParser{
+		filename: filename,
+		dir:      filepath.Dir(filename),
+		mesh:     &Mesh{Name: strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))},
+		cache:    make(map[objVertex]uint32),
+		material: -1,
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	var stmt string
+	for scanner.Scan() {
+		p.line++
+		text := scanner.Text()
+		if strings.HasSuffix(text, "\\") {
+			stmt += strings.TrimSuffix(text, "\\") + " "
+			continue
+		}
+		stmt += text
+		if err := p.statement(stmt); err != nil {
+			return nil, err
+		}
+		stmt = ""
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	if err := p.statement(stmt); err != nil {
+		return nil, err
+	}
+
+	p.finish()
+	return p.mesh, nil
+}
+
+func (p *objParser) errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("%s:%d: %s", p.filename, p.line, fmt.Sprintf(format, a...))
+}
+
+func (p *objParser) statement(stmt string) error {
+	if i := strings.IndexByte(stmt, '#'); i >= 0 {
+		stmt = stmt[:i]
+	}
+	fields := strings.Fields(stmt)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	args := fields[1:]
+	switch fields[0] {
+	case "v":
+		f, err := p.floats(args, 3, 7)
+		if err != nil {
+			return err
+		}
+		p.v = append(p.v, f[0], f[1], f[2])
+		if len(f) == 4 {
+			// homogeneous w, only meaningful for rational curves
+			if f[3] != 0 && f[3] != 1 {
+				n := len(p.v)
+				p.v[n-3] /= f[3]
+				p.v[n-2] /= f[3]
+				p.v[n-1] /= f[3]
+			}
+		}
+		if len(f) >= 6 {
+			p.hasColour = true
+			p.vc = append(p.vc, f[len(f)-3], f[len(f)-2], f[len(f)-1])
+		} else {
+			p.vc = append(p.vc, 1, 1, 1)
+		}
+	case "vt":
+		f, err := p.floats(args, 1, 3)
+		if err != nil {
+			return err
+		}
+		if len(f) == 1 {
+			f = append(f, 0)
+		}
+		p.vt = append(p.vt, f[0], f[1])
+	case "vn":
+		f, err := p.floats(args, 3, 3)
+		if err != nil {
+			return err
+		}
+		p.vn = append(p.vn, f[0], f[1], f[2])
+	case "f", "fo":
+		return p.face(args)
+	case "o":
+		p.object = strings.Join(args, " ")
+		p.group = ""
+	case "g":
+		p.group = strings.Join(args, " ")
+	case "s":
+		if len(args) == 0 || args[0] == "off" {
+			p.smooth = 0
+			break
+		}
+		s, err := strconv.Atoi(args[0])
+		if err != nil {
+			return p.errorf("bad smoothing group %q", args[0])
+		}
+		p.smooth = s
+	case "usemtl":
+		name := strings.Join(args, " ")
+		p.material = p.mesh.Material(name)
+		if p.material < 0 {
+			p.mesh.Materials = append(p.mesh.Materials, defaultMaterial(name))
+			p.material = len(p.mesh.Materials) - 1
+		}
+	case "mtllib":
+		for _, name := range args {
+			// a missing library leaves the default materials of usemtl
+			if err := p.mtllib(name); err != nil {
+				log.Printf("%v, using default materials", err)
+			}
+		}
+	default:
+		// l, p, curves and surfaces are not supported
+	}
+
+	return nil
+}
+
+func (p *objParser) floats(args []string, min, max int) ([]float32, error) {
+	if len(args) < min {
+		return nil, p.errorf("expected %d values, got %d", min, len(args))
+	}
+	if len(args) > max {
+		args = args[:max]
+	}
+
+	f := make([]float32, len(args))
+	for i, a := range args {
+		v, err := strconv.ParseFloat(a, 32)
+		if err != nil {
+			return nil, p.errorf("bad number %q", a)
+		}
+		f[i] = float32(v)
+	}
+	return f, nil
+}
+
+// index converts a 1-based, possibly negative OBJ index to a 0-based one.
+func (p *objParser) index(s string, count int) (int, error) {
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, p.errorf("bad index %q", s)
+	}
+	switch {
+	case i > 0:
+		i--
+	case i < 0:
+		i += count
+	default:
+		return 0, p.errorf("index 0 is not allowed")
+	}
+	if i < 0 || i >= count {
+		return 0, p.errorf("index %s out of range", s)
+	}
+	return i, nil
+}
+
+func (p *objParser) face(args []string) error {
+	if len(args) < 3 {
+		return p.errorf("face with %d vertices", len(args))
+	}
+
+	p.faces++
+	smooth := p.smooth
+	if smooth == 0 {
+		smooth = -p.faces
+	}
+
+	corners := make([]uint32, len(args))
+	for i, arg := range args {
+		parts := strings.Split(arg, "/")
+		if len(parts) > 3 {
+			return p.errorf("bad face vertex %q", arg)
+		}
+
+		key := objVertex{vt: -1, vn: -1}
+		var err error
+		if key.v, err = p.index(parts[0], len(p.v)/3); err != nil {
+			return err
+		}
+		if len(parts) > 1 && parts[1] != "" {
+			if key.vt, err = p.index(parts[1], len(p.vt)/2); err != nil {
+				return err
+			}
+		}
+		if len(parts) > 2 && parts[2] != "" {
+			if key.vn, err = p.index(parts[2], len(p.vn)/3); err != nil {
+				return err
+			}
+		} else {
+			key.smooth = smooth
+		}
+
+		corners[i] = p.vertex(key)
+	}
+
+	p.beginGroup()
+	m := p.mesh
+	for _, tri := range triangulate(m.Positions, corners) {
+		m.Indices = append(m.Indices, tri[0], tri[1], tri[2])
+	}
+	m.Groups[len(m.Groups)-1].Count = len(m.Indices) - m.Groups[len(m.Groups)-1].Start
+
+	return nil
+}
+
+func (p *objParser) vertex(key objVertex) uint32 {
+	if i, ok := p.cache[key]; ok {
+		return i
+	}
+
+	m := p.mesh
+	i := uint32(m.VertexCount())
+	p.cache[key] = i
+
+	m.Positions = append(m.Positions, p.v[key.v*3:key.v*3+3]...)
+	m.Colours = append(m.Colours, p.vc[key.v*3:key.v*3+3]...)
+	if key.vt >= 0 {
+		m.TexCoords = append(m.TexCoords, p.vt[key.vt*2:key.vt*2+2]...)
+		p.hasUV = true
+	} else {
+		m.TexCoords = append(m.TexCoords, 0, 0)
+	}
+	if key.vn >= 0 {
+		m.Normals = append(m.Normals, p.vn[key.vn*3:key.vn*3+3]...)
+	} else {
+		m.Normals = append(m.Normals, 0, 0, 0)
+	}
+	p.noNormal = append(p.noNormal, key.vn < 0)
+
+	return i
+}
+
+func (p *objParser) beginGroup() {
+	m := p.mesh
+	if n := len(m.Groups); n > 0 {
+		g := &m.Groups[n-1]
+		if g.Object == p.object && g.Name == p.group && g.Material == p.material {
+			return
+		}
+		if g.Count == 0 {
+			m.Groups = m.Groups[:n-1]
+		}
+	}
+
+	m.Groups = append(m.Groups, Group{
+		Object:   p.object,
+		Name:     p.group,
+		Material: p.material,
+		Start:    len(m.Indices),
+	})
+}
+
+func (p *objParser) mtllib(name string) error {
+	filename := name
+	if !filepath.IsAbs(filename) {
+		filename = filepath.Join(p.dir, filename)
+	}
+
+	materials, err := LoadMTL(filename)
+	if err != nil {
+		return p.errorf("mtllib: %v", err)
+	}
+
+	for _, mtl := range materials {
+		if i := p.mesh.Material(mtl.Name); i >= 0 {
+			p.mesh.Materials[i] = mtl
+			continue
+		}
+		p.mesh.Materials = append(p.mesh.Materials, mtl)
+	}
+	return nil
+}
+
+func (p *objParser) finish() {
+	m := p.mesh
+	if !p.hasUV {
+		m.TexCoords = nil
+	}
+	if !p.hasColour {
+		m.Colours = nil
+	}
+
+	missing := false
+	for _, b := range p.noNormal {
+		missing = missing || b
+	}
+	if !missing {
+		return
+	}
+
+	// Vertices without a normal were only merged inside a smoothing group,
+	// so averaging the face normals around them smooths exactly that group.
+	generated := make([]float32, len(m.Normals))
+	for t := 0; t+2 < len(m.Indices); t += 3 {
+		n := faceNormal(m.Positions, m.Indices[t], m.Indices[t+1], m.Indices[t+2])
+		for _, i := range m.Indices[t : t+3] {
+			generated[i*3] += n[0]
+			generated[i*3+1] += n[1]
+			generated[i*3+2] += n[2]
+		}
+	}
+	for i, b := range p.noNormal {
+		if !b {
+			continue
+		}
+		n := normalize([3]float32{generated[i*3], generated[i*3+1], generated[i*3+2]})
+		copy(m.Normals[i*3:i*3+3], n[:])
+	}
+}
@@ -0,0 +1,192 @@
+package mesh
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const objQuad = "v 0 0 0\nv 1 0 0\nv 1 1 0\nv 0 1 0\n"
+
+func TestReadOBJ(t *testing.T) {
+	tests := []struct {
+		name      string
+		src       string
+		vertices  int
+		triangles int
+		groups    []Group // Start and Count are not compared
+		area      float64 // of all triangles, 0 to skip
+		err       string  // start of the error after the file name
+	}{
+		{"triangle", objQuad + "f 1 2 3\n", 3, 1, []Group{{Material: -1}}, 0.5, ""},
+		{"quad", objQuad + "f 1 2 3 4\n", 4, 2, []Group{{Material: -1}}, 1, ""},
+		{"negative indices", objQuad + "f -4 -3 -2 -1\n", 4, 2, nil, 1, ""},
+		{"all attributes", objQuad + "vt 0 0\nvt 1 1\nvn 0 0 1\nf 1/1/1 2/2/1 3/1/1 4/2/1\n", 4, 2, nil, 1, ""},
+		{"positions and normals", objQuad + "vn 0 0 1\nf 1//1 2//1 3//1\n", 3, 1, nil, 0.5, ""},
+		{"line continuation", objQuad + "f 1 2 \\\n 3 4\n", 4, 2, nil, 1, ""},
+		{"comments", "# a quad\n" + objQuad + "f 1 2 3 4 # two triangles\n", 4, 2, nil, 1, ""},
+		{"smooth group shares vertices", objQuad + "v 2 0 0\ns 1\nf 1 2 3\nf 2 5 3\n", 4, 2, nil, 1, ""},
+		{"flat faces split vertices", objQuad + "v 2 0 0\ns off\nf 1 2 3\nf 2 5 3\n", 6, 2, nil, 1, ""},
+		{"concave polygon", "v 0 0 0\nv 2 0 0\nv 2 1 0\nv 1 1 0\nv 1 2 0\nv 0 2 0\nf 1 2 3 4 5 6\n", 6, 4, nil, 3, ""},
+		{"groups", objQuad + "o box\ng top\nusemtl red\nf 1 2 3\ng bottom\nf 1 3 4\nusemtl blue\nf 1 2 4\n", 9, 3, []Group{
+			{Object: "box", Name: "top", Material: 0}, {Object: "box", Name: "bottom", Material: 0}, {Object: "box", Name: "bottom", Material: 1}}, 0, ""},
+		{"empty groups dropped", objQuad + "g a\ng b\nf 1 2 3\n", 3, 1, []Group{{Name: "b", Material: -1}}, 0, ""},
+		{"missing library", "mtllib nope.mtl\nusemtl red\n" + objQuad + "f 1 2 3\n", 3, 1, []Group{{Material: 0}}, 0, ""},
+		{"curves ignored", objQuad + "l 1 2\ncurv 0 1 1 2\nf 1 2 3\n", 3, 1, nil, 0, ""},
+
+		{"index out of range", objQuad + "f 1 2 5\n", 0, 0, nil, 0, "5: index 5 out of range"},
+		{"negative index out of range", objQuad + "f 1 2 -5\n", 0, 0, nil, 0, "5: index -5 out of range"},
+		{"index 0", objQuad + "f 0 1 2\n", 0, 0, nil, 0, "5: index 0 is not allowed"},
+		{"bad number", "v 0 0 x\n", 0, 0, nil, 0, "1: bad number"},
+		{"short vertex", "v 0 0\n", 0, 0, nil, 0, "1: expected 3 values"},
+		{"two corners", objQuad + "\nf 1 2\n", 0, 0, nil, 0, "6: face with 2 vertices"},
+		{"missing texcoord", objQuad + "f 1/1 2/1 3/1\n", 0, 0, nil, 0, "5: index 1 out of range"},
+		{"bad smoothing group", "s x\n", 0, 0, nil, 0, "1: bad smoothing group"},
+	}
+	for _, tt := range tests {
+		m, err := ReadOBJ(strings.NewReader(tt.src), "/nonexistent/test.obj")
+		if tt.err != "" {
+			if err == nil || !strings.HasPrefix(err.Error(), "/nonexistent/test.obj:"+tt.err) {
+				t.Errorf("%s: error %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if m.VertexCount() != tt.vertices || m.TriangleCount() != tt.triangles {
+			t.Errorf("%s: %d vertices and %d triangles, want %d and %d",
+				tt.name, m.VertexCount(), m.TriangleCount(), tt.vertices, tt.triangles)
+		}
+		if tt.groups != nil {
+			var groups []Group
+			for _, g := range m.Groups {
+				g.Start, g.Count = 0, 0
+				groups = append(groups, g)
+			}
+			if !reflect.DeepEqual(groups, tt.groups) {
+				t.Errorf("%s: groups %+v, want %+v", tt.name, groups, tt.groups)
+			}
+		}
+
+		// every triangle faces +z like the normals, and together they
+		// cover the polygons
+		var area float64
+		for i := 0; i+2 < len(m.Indices); i += 3 {
+			fn := faceNormal(m.Positions, m.Indices[i], m.Indices[i+1], m.Indices[i+2])
+			area += float64(fn[2]) / 2
+			if fn[2] <= 0 || dot(fn, vec3(m.Normals, m.Indices[i])) <= 0 {
+				t.Errorf("%s: triangle %d faces %v", tt.name, i/3, fn)
+			}
+		}
+		if tt.area > 0 && math.Abs(area-tt.area) > 1e-5 {
+			t.Errorf("%s: triangles cover %g, want %g", tt.name, area, tt.area)
+		}
+	}
+}
+
+func TestOBJMaterials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "obj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	mtl := "newmtl red\nKd 1 0 0\nmap_Kd -bm 1 tex\\red.png\nnewmtl blue\nKd 0 0 1\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "a.mtl"), []byte(mtl), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// usemtl before the library keeps its slot, the library fills it in
+	src := "usemtl blue\nmtllib a.mtl\n" + objQuad + "f 1 2 3\nusemtl red\nf 1 3 4\n"
+	m, err := ReadOBJ(strings.NewReader(src), filepath.Join(dir, "a.obj"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Materials) != 2 || m.Materials[0].Name != "blue" || m.Materials[1].Name != "red" {
+		t.Fatalf("materials %+v", m.Materials)
+	}
+	if m.Materials[0].Diffuse != [3]float32{0, 0, 1} {
+		t.Errorf("blue has diffuse %v", m.Materials[0].Diffuse)
+	}
+	if want := filepath.Join(dir, "tex", "red.png"); m.Materials[1].DiffuseMap != want {
+		t.Errorf("red has map %q, want %q", m.Materials[1].DiffuseMap, want)
+	}
+	if m.Groups[0].Material != 0 || m.Groups[1].Material != 1 {
+		t.Errorf("groups %+v", m.Groups)
+	}
+}
+
+func TestReadMTL(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []Material
+		err  string
+	}{
+		{"colours", "newmtl a\nKa 0.1 0.2 0.3\nKd 0.5\nKs 1 1 1\nKe 0 0 0.5\nNs 64\nillum 2\n", []Material{func() Material {
+			m := defaultMaterial("a")
+			m.Ambient, m.Diffuse, m.Specular, m.Emissive = [3]float32{0.1, 0.2, 0.3}, [3]float32{0.5, 0.5, 0.5}, [3]float32{1, 1, 1}, [3]float32{0, 0, 0.5}
+			m.Shininess, m.Illum = 64, 2
+			return m
+		}()}, ""},
+		{"dissolve", "newmtl a\nd 0.25\nnewmtl b\nTr 0.25\n", []Material{func() Material {
+			m := defaultMaterial("a")
+			m.Opacity = 0.25
+			return m
+		}(), func() Material {
+			m := defaultMaterial("b")
+			m.Opacity = 0.75
+			return m
+		}()}, ""},
+		{"maps", "newmtl a\nmap_Kd d.png\nmap_Ks -s 2 2 s.png\nbump -bm 0.5 n.png\nmap_d a.png\n", []Material{func() Material {
+			m := defaultMaterial("a")
+			m.DiffuseMap, m.SpecularMap = filepath.Join("dir", "d.png"), filepath.Join("dir", "s.png")
+			m.BumpMap, m.AlphaMap = filepath.Join("dir", "n.png"), filepath.Join("dir", "a.png")
+			return m
+		}()}, ""},
+		{"name with spaces", "newmtl my material\n", []Material{defaultMaterial("my material")}, ""},
+
+		{"before newmtl", "Kd 1 1 1\n", nil, "1: Kd before newmtl"},
+		{"spectral", "newmtl a\n\nKd spectral red.rfl\n", nil, "3: Kd: spectral colours are not supported"},
+		{"missing value", "newmtl a\nNs\n", nil, "2: Ns: missing value"},
+		{"bad number", "newmtl a\nd half\n", nil, "2: d: "},
+	}
+	for _, tt := range tests {
+		materials, err := ReadMTL(strings.NewReader(tt.src), filepath.Join("dir", "x.mtl"))
+		if tt.err != "" {
+			if err == nil || !strings.HasPrefix(err.Error(), filepath.Join("dir", "x.mtl")+":"+tt.err) {
+				t.Errorf("%s: error %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(materials, tt.want) {
+			t.Errorf("%s: read %+v, %v, want %+v", tt.name, materials, err, tt.want)
+		}
+	}
+}
+
+func TestMTLMap(t *testing.T) {
+	tests := []struct {
+		args, want string
+	}{
+		{"plain.png", "d/plain.png"},
+		{"my tex.png", "d/my tex.png"},
+		{"-bm 1 -o 0.5 0.5 my tex.png", "d/my tex.png"},
+		{"-o 1 tex.png", "d/tex.png"},
+		{"-clamp on -mm 0 1 a b c.png", "d/a b c.png"},
+		{"textures\\wood.png", "d/textures/wood.png"},
+		{"/abs/wood.png", "/abs/wood.png"},
+		{"-bm", "d/-bm"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := mtlMap("d", strings.Fields(tt.args)); got != filepath.FromSlash(tt.want) {
+			t.Errorf("%q: %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package mesh
+
+import (
+	"fmt"
+	"math"
+)
+
+// VertexCacheSize is the FIFO post-transform cache ACMR is measured with.
+const VertexCacheSize = 16
+
+// Stats describes a mesh after one step of the optimization pipeline.
+type Stats struct {
+	Step      string
+	Vertices  int
+	Triangles int
+	ACMR      float32 // vertex shader runs per triangle, 0.5 is ideal, 3 is no reuse
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf("%-8s %7d vertices %7d triangles  ACMR %.3f",
+		s.Step, s.Vertices, s.Triangles, s.ACMR)
+}
+
+// Stats measures the mesh for step.
+func (m *Mesh) Stats(step string) Stats {
+	return Stats{step, m.VertexCount(), m.TriangleCount(), ACMR(m.Indices, VertexCacheSize)}
+}
+
+// Optimize welds identical vertices, reorders triangles for the vertex cache
+// and vertices for fetch locality, returning the stats after every step.
+func (m *Mesh) Optimize() []Stats {
+	stats := []Stats{m.Stats("input")}
+	m.Weld(0)
+	stats = append(stats, m.Stats("weld"))
+	m.OptimizeVertexCache()
+	stats = append(stats, m.Stats("cache"))
+	m.OptimizeVertexFetch()
+	stats = append(stats, m.Stats("fetch"))
+	return stats
+}
+
+// ACMR simulates a FIFO vertex cache of cacheSize entries and returns the
+// average number of cache misses per triangle.
+func ACMR(indices []uint32, cacheSize int) float32 {
+	if len(indices) < 3 {
+		return 0
+	}
+	fifo := make([]uint32, cacheSize)
+	in := make(map[uint32]bool)
+	head, misses := 0, 0
+	for _, i := range indices {
+		if in[i] {
+			continue
+		}
+		misses++
+		if len(in) == cacheSize {
+			delete(in, fifo[head])
+		}
+		fifo[head] = i
+		in[i] = true
+		head = (head + 1) % cacheSize
+	}
+	return float32(misses) / float32(len(indices)/3)
+}
+
+// Weld merges vertices whose attributes all agree within epsilon, exactly
+// when epsilon is 0, and returns how many vertices were removed.
+func (m *Mesh) Weld(epsilon float32) int {
+	n := m.VertexCount()
+	attribs := []struct {
+		data []float32
+		size int
+	}{{m.Positions, 3}, {m.Normals, 3}, {m.TexCoords, 2}, {m.Colours, 3}, {m.Tangents, 4}}
+	for _, a := range m.Extra {
+		attribs = append(attribs, struct {
+			data []float32
+			size int
+		}{a, 1})
+	}
+
+	keys := make(map[string]uint32, n)
+	remap := make([]uint32, n)
+	var src []uint32
+	buf := make([]byte, 0, 64)
+	for v := 0; v < n; v++ {
+		buf = buf[:0]
+		for _, a := range attribs {
+			if len(a.data) == 0 {
+				continue
+			}
+			for _, f := range a.data[v*a.size : (v+1)*a.size] {
+				var q uint32
+				if epsilon > 0 {
+					q = uint32(int32(math.Floor(float64(f/epsilon) + 0.5)))
+				} else {
+					q = math.Float32bits(f + 0) // +0 folds -0 into 0
+				}
+				buf = append(buf, byte(q), byte(q>>8), byte(q>>16), byte(q>>24))
+			}
+		}
+		i, ok := keys[string(buf)]
+		if !ok {
+			i = uint32(len(src))
+			keys[string(buf)] = i
+			src = append(src, uint32(v))
+		}
+		remap[v] = i
+	}
+
+	indices := make([]uint32, len(m.Indices))
+	for k, i := range m.Indices {
+		indices[k] = remap[i]
+	}
+	m.remap(src, indices)
+	return n - len(src)
+}
+
+// OptimizeVertexFetch renumbers the vertices in the order the triangles
+// first use them, so vertex data is read front to back. Unused vertices
+// are dropped.
+func (m *Mesh) OptimizeVertexFetch() {
+	remap := make(map[uint32]uint32)
+	var src []uint32
+	indices := make([]uint32, len(m.Indices))
+	for k, i := range m.Indices {
+		j, ok := remap[i]
+		if !ok {
+			j = uint32(len(src))
+			remap[i] = j
+			src = append(src, i)
+		}
+		indices[k] = j
+	}
+	m.remap(src, indices)
+}
+
+// OptimizeVertexCache reorders the triangles of every group with Tom
+// Forsyth's linear-speed vertex cache optimisation. Groups stay in place.
+func (m *Mesh) OptimizeVertexCache() {
+	groups := m.Groups
+	if len(groups) == 0 {
+		groups = []Group{{Count: len(m.Indices)}}
+	}
+	for _, g := range groups {
+		forsyth(m.Indices[g.Start:g.Start+g.Count], m.VertexCount())
+	}
+}
+
+/* scoring constants from Forsyth's article */
+const (
+	forsythCacheSize  = 32
+	forsythDecayPower = 1.5
+	forsythLastTri    = 0.75
+	forsythValenceScl = 2.0
+	forsythValencePow = 0.5
+)
+
+func forsythScore(cachePos, remaining int) float32 {
+	if remaining == 0 {
+		return -1
+	}
+	score := float32(0)
+	if cachePos >= 0 {
+		if cachePos < 3 {
+			score = forsythLastTri
+		} else {
+			s := 1 - float64(cachePos-3)/float64(forsythCacheSize-3)
+			score = float32(math.Pow(s, forsythDecayPower))
+		}
+	}
+	return score + forsythValenceScl*float32(math.Pow(float64(remaining), -forsythValencePow))
+}
+
+// forsyth reorders the triangles of indices in place.
+func forsyth(indices []uint32, vertices int) {
+	tris := len(indices) / 3
+	if tris < 2 {
+		return
+	}
+
+	remaining := make([]int, vertices)
+	for _, i := range indices {
+		remaining[i]++
+	}
+	offsets := make([]int, vertices+1)
+	for v := 0; v < vertices; v++ {
+		offsets[v+1] = offsets[v] + remaining[v]
+	}
+	adjacency := make([]int, len(indices))
+	fill := append([]int(nil), offsets[:vertices]...)
+	for k, i := range indices {
+		adjacency[fill[i]] = k / 3
+		fill[i]++
+	}
+
+	cachePos := make([]int, vertices)
+	scores := make([]float32, vertices)
+	for v := range cachePos {
+		cachePos[v] = -1
+		scores[v] = forsythScore(-1, remaining[v])
+	}
+	added := make([]bool, tris)
+	triScore := make([]float32, tris)
+	for t := range triScore {
+		for _, i := range indices[t*3 : t*3+3] {
+			triScore[t] += scores[i]
+		}
+	}
+
+	out := make([]uint32, 0, len(indices))
+	cache := make([]uint32, 0, forsythCacheSize+3)
+	best, scan := -1, 0
+	for len(out) < len(indices) {
+		if best < 0 {
+			// nothing in the cache is connected, take the best of the rest
+			var bestScore float32 = -1
+			for ; scan < tris && added[scan]; scan++ {
+			}
+			for t := scan; t < tris; t++ {
+				if !added[t] && triScore[t] > bestScore {
+					best, bestScore = t, triScore[t]
+				}
+			}
+		}
+
+		t := best
+		added[t] = true
+		tri := indices[t*3 : t*3+3]
+		out = append(out, tri...)
+
+		// remove the triangle from its vertices
+		for _, i := range tri {
+			adj := adjacency[offsets[i] : offsets[i]+remaining[i]]
+			for k, a := range adj {
+				if a == t {
+					adj[k] = adj[len(adj)-1]
+					break
+				}
+			}
+			remaining[i]--
+		}
+
+		// move its vertices to the front of the LRU cache
+		next := make([]uint32, 0, cap(cache))
+		for k, i := range tri {
+			if k == 0 || i != tri[0] && (k == 1 || i != tri[1]) {
+				next = append(next, i)
+			}
+		}
+		for _, i := range cache {
+			if i != tri[0] && i != tri[1] && i != tri[2] {
+				next = append(next, i)
+			}
+		}
+		cache = next
+		for k, i := range cache {
+			if k < forsythCacheSize {
+				cachePos[i] = k
+			} else {
+				cachePos[i] = -1
+			}
+		}
+
+		// rescore the affected vertices and their triangles
+		best = -1
+		var bestScore float32 = -1
+		for _, i := range cache {
+			s := forsythScore(cachePos[i], remaining[i])
+			d := s - scores[i]
+			scores[i] = s
+			for _, a := range adjacency[offsets[i] : offsets[i]+remaining[i]] {
+				triScore[a] += d
+			}
+		}
+		if len(cache) > forsythCacheSize {
+			cache = cache[:forsythCacheSize]
+		}
+		for _, i := range cache {
+			for _, a := range adjacency[offsets[i] : offsets[i]+remaining[i]] {
+				if triScore[a] > bestScore {
+					best, bestScore = a, triScore[a]
+				}
+			}
+		}
+	}
+	copy(indices, out)
+}
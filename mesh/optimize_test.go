@@ -0,0 +1,147 @@
+package mesh
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// positionsOnly strips every attribute but the positions so Weld can join
+// vertices across seams and creases.
+func positionsOnly(m *Mesh) *Mesh {
+	m.Normals, m.TexCoords, m.Tangents = nil, nil, nil
+	return m
+}
+
+// shuffled puts the triangles of m in random order, the worst case for the
+// vertex cache.
+func shuffled(m *Mesh) *Mesh {
+	r := rand.New(rand.NewSource(1))
+	for i := m.TriangleCount() - 1; i > 0; i-- {
+		j := r.Intn(i + 1)
+		for k := 0; k < 3; k++ {
+			m.Indices[i*3+k], m.Indices[j*3+k] = m.Indices[j*3+k], m.Indices[i*3+k]
+		}
+	}
+	return m
+}
+
+// triangles counts the triangles of m by their corner positions, starting
+// from the smallest corner so rotated triangles compare equal.
+func triangles(m *Mesh) map[[9]float32]int {
+	less := func(a, b [3]float32) bool {
+		for i := range a {
+			if a[i] != b[i] {
+				return a[i] < b[i]
+			}
+		}
+		return false
+	}
+	tris := make(map[[9]float32]int)
+	for i := 0; i+2 < len(m.Indices); i += 3 {
+		p := [3][3]float32{vec3(m.Positions, m.Indices[i]), vec3(m.Positions, m.Indices[i+1]), vec3(m.Positions, m.Indices[i+2])}
+		for less(p[1], p[0]) || less(p[2], p[0]) {
+			p[0], p[1], p[2] = p[1], p[2], p[0]
+		}
+		var k [9]float32
+		copy(k[:], append(append(p[0][:], p[1][:]...), p[2][:]...))
+		tris[k]++
+	}
+	return tris
+}
+
+func TestWeld(t *testing.T) {
+	tests := []struct {
+		name    string
+		mesh    *Mesh
+		epsilon float32
+		removed int
+	}{
+		// faces keep their own normals
+		{"cube", Cube(2, 1), 0, 0},
+		{"cube positions", positionsOnly(Cube(2, 1)), 0, 24 - 8},
+		{"split cube positions", positionsOnly(Cube(2, 2)), 0, 6*9 - (8 + 12 + 6)},
+		{"flat sphere positions", positionsOnly(func() *Mesh { m := UVSphere(1, 8, 4); m.GenerateFlatNormals(); return m }()), 1e-5, (4*8*2-2*8)*3 - (3*8 + 2)},
+		{"plane", Plane(2, 2, 2, 2), 0, 0},
+	}
+	for _, tt := range tests {
+		m := tt.mesh
+		before, want := m.VertexCount(), triangles(m)
+		if n := m.Weld(tt.epsilon); n != tt.removed || m.VertexCount() != before-n {
+			t.Errorf("%s: removed %d of %d vertices leaving %d, want %d", tt.name, n, before, m.VertexCount(), tt.removed)
+			continue
+		}
+		if len(triangles(m)) != len(want) {
+			t.Errorf("%s: %d distinct triangles after welding, want %d", tt.name, len(triangles(m)), len(want))
+		}
+	}
+}
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		name string
+		mesh *Mesh
+		acmr float32 // at most after the cache step
+	}{
+		{"shuffled icosphere", shuffled(positionsOnly(Icosphere(1, 4))), 0.8},
+		{"uv sphere", UVSphere(1, 32, 16), 0.8},
+		{"shuffled torus", shuffled(Torus(2, 0.5, 32, 16)), 0.8},
+		{"cube", Cube(2, 4), 1},
+	}
+	for _, tt := range tests {
+		m := tt.mesh
+		want := triangles(m)
+		stats := m.Optimize()
+		if len(stats) != 4 || stats[3].Step != "fetch" {
+			t.Errorf("%s: stats %v", tt.name, stats)
+			continue
+		}
+		if stats[2].ACMR > tt.acmr || stats[2].ACMR > stats[1].ACMR || stats[3].ACMR != stats[2].ACMR {
+			t.Errorf("%s: ACMR %g after welding, %g after the cache, %g after fetch, want at most %g",
+				tt.name, stats[1].ACMR, stats[2].ACMR, stats[3].ACMR, tt.acmr)
+		}
+
+		got := triangles(m)
+		for k, n := range want {
+			if got[k] != n {
+				t.Errorf("%s: triangle %v is there %d times, want %d", tt.name, k, got[k], n)
+				break
+			}
+		}
+
+		// fetch order: every index is at most one past the highest so far
+		next := uint32(0)
+		for k, i := range m.Indices {
+			if i > next {
+				t.Errorf("%s: index %d is %d before %d was used", tt.name, k, i, next)
+				break
+			}
+			if i == next {
+				next++
+			}
+		}
+		if int(next) != m.VertexCount() {
+			t.Errorf("%s: %d of %d vertices used", tt.name, next, m.VertexCount())
+		}
+	}
+}
+
+func TestACMR(t *testing.T) {
+	tests := []struct {
+		name    string
+		indices []uint32
+		cache   int
+		want    float32
+	}{
+		{"empty", nil, 16, 0},
+		{"one triangle", []uint32{0, 1, 2}, 16, 3},
+		{"strip", []uint32{0, 1, 2, 2, 1, 3, 2, 3, 4}, 16, 5.0 / 3},
+		// with three entries the first triangle is gone when it comes back
+		{"evicted", []uint32{0, 1, 2, 3, 4, 5, 0, 1, 2}, 3, 3},
+		{"kept", []uint32{0, 1, 2, 3, 4, 5, 0, 1, 2}, 6, 2},
+	}
+	for _, tt := range tests {
+		if got := ACMR(tt.indices, tt.cache); got != tt.want {
+			t.Errorf("%s: ACMR %g, want %g", tt.name, got, tt.want)
+		}
+	}
+}
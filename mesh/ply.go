@@ -0,0 +1,365 @@
+package mesh
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type plyProperty struct {
+	name      string
+	typ       string
+	list      bool
+	countType string
+}
+
+type plyElement struct {
+	name       string
+	count      int
+	properties []plyProperty
+}
+
+// plyReader reads property values from either an ASCII or a binary body.
+type plyReader struct {
+	r     *bufio.Reader
+	order binary.ByteOrder // nil for ASCII
+	words []string
+	line  int
+	buf   [8]byte
+}
+
+var plyTypes = map[string]string{
+	"char": "int8", "int8": "int8",
+	"uchar": "uint8", "uint8": "uint8",
+	"short": "int16", "int16": "int16",
+	"ushort": "uint16", "uint16": "uint16",
+	"int": "int32", "int32": "int32",
+	"uint": "uint32", "uint32": "uint32",
+	"float": "float32", "float32": "float32",
+	"double": "float64", "float64": "float64",
+}
+
+// LoadPLY reads an ASCII or binary (little or big endian) PLY file.
+func LoadPLY(filename string) (*Mesh, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	m, err := ReadPLY(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	m.Name = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	return m, nil
+}
+
+// ReadPLY parses PLY data. Vertex properties x/y/z, nx/ny/nz,
+// red/green/blue and u/v (or s/t, texture_u/texture_v) are mapped to the
+// mesh attributes, any other vertex property ends up in Mesh.Extra. Faces
+// are read from the vertex_indices (or vertex_index) list and triangulated.
+func ReadPLY(r io.Reader) (*Mesh, error) {
+	pr := &plyReader{r: bufio.NewReader(r)}
+
+	elements, err := pr.header()
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Mesh{}
+	for _, e := range elements {
+		switch e.name {
+		case "vertex":
+			err = pr.vertices(e, m)
+		case "face":
+			err = pr.faces(e, m)
+		default:
+			err = pr.skip(e)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("element %s: %v", e.name, err)
+		}
+	}
+
+	if len(m.Positions) == 0 {
+		return nil, errors.New("no vertices")
+	}
+	m.Groups = []Group{{Material: -1, Count: len(m.Indices)}}
+	return m, nil
+}
+
+func (pr *plyReader) header() ([]plyElement, error) {
+	var elements []plyElement
+	format := ""
+
+	for n := 0; ; n++ {
+		text, err := pr.r.ReadString('\n')
+		if err != nil {
+			return nil, errors.New("truncated header")
+		}
+		pr.line++
+		f := strings.Fields(text)
+		if n == 0 {
+			if len(f) != 1 || f[0] != "ply" {
+				return nil, errors.New("not a PLY file")
+			}
+			continue
+		}
+		if len(f) == 0 {
+			continue
+		}
+
+		switch f[0] {
+		case "format":
+			if len(f) < 2 {
+				return nil, errors.New("bad format line")
+			}
+			format = f[1]
+		case "comment", "obj_info":
+		case "element":
+			if len(f) != 3 {
+				return nil, fmt.Errorf("line %d: bad element", pr.line)
+			}
+			count, err := strconv.Atoi(f[2])
+			if err != nil || count < 0 {
+				return nil, fmt.Errorf("line %d: bad element count %q", pr.line, f[2])
+			}
+			elements = append(elements, plyElement{name: f[1], count: count})
+		case "property":
+			if len(elements) == 0 {
+				return nil, fmt.Errorf("line %d: property before element", pr.line)
+			}
+			var p plyProperty
+			switch {
+			case len(f) == 5 && f[1] == "list":
+				p = plyProperty{name: f[4], typ: plyTypes[f[3]], list: true, countType: plyTypes[f[2]]}
+				if p.countType == "" {
+					return nil, fmt.Errorf("line %d: bad type %q", pr.line, f[2])
+				}
+			case len(f) == 3:
+				p = plyProperty{name: f[2], typ: plyTypes[f[1]]}
+			default:
+				return nil, fmt.Errorf("line %d: bad property", pr.line)
+			}
+			if p.typ == "" {
+				return nil, fmt.Errorf("line %d: bad type in %q", pr.line, strings.TrimSpace(text))
+			}
+			e := &elements[len(elements)-1]
+			e.properties = append(e.properties, p)
+		case "end_header":
+			switch format {
+			case "ascii":
+			case "binary_little_endian":
+				pr.order = binary.LittleEndian
+			case "binary_big_endian":
+				pr.order = binary.BigEndian
+			default:
+				return nil, fmt.Errorf("unsupported format %q", format)
+			}
+			return elements, nil
+		default:
+			return nil, fmt.Errorf("line %d: unknown header keyword %q", pr.line, f[0])
+		}
+	}
+}
+
+// value reads one scalar of the given PLY type.
+func (pr *plyReader) value(typ string) (float64, error) {
+	if pr.order == nil {
+		for len(pr.words) == 0 {
+			text, err := pr.r.ReadString('\n')
+			if text == "" && err != nil {
+				return 0, io.ErrUnexpectedEOF
+			}
+			pr.line++
+			pr.words = strings.Fields(text)
+		}
+		w := pr.words[0]
+		pr.words = pr.words[1:]
+		v, err := strconv.ParseFloat(w, 64)
+		if err != nil {
+			return 0, fmt.Errorf("line %d: bad number %q", pr.line, w)
+		}
+		return v, nil
+	}
+
+	size := map[string]int{"int8": 1, "uint8": 1, "int16": 2, "uint16": 2,
+		"int32": 4, "uint32": 4, "float32": 4, "float64": 8}[typ]
+	b := pr.buf[:size]
+	if _, err := io.ReadFull(pr.r, b); err != nil {
+		return 0, io.ErrUnexpectedEOF
+	}
+	switch typ {
+	case "int8":
+		return float64(int8(b[0])), nil
+	case "uint8":
+		return float64(b[0]), nil
+	case "int16":
+		return float64(int16(pr.order.Uint16(b))), nil
+	case "uint16":
+		return float64(pr.order.Uint16(b)), nil
+	case "int32":
+		return float64(int32(pr.order.Uint32(b))), nil
+	case "uint32":
+		return float64(pr.order.Uint32(b)), nil
+	case "float32":
+		return float64(math.Float32frombits(pr.order.Uint32(b))), nil
+	default:
+		return math.Float64frombits(pr.order.Uint64(b)), nil
+	}
+}
+
+// list reads a list property.
+func (pr *plyReader) list(p plyProperty) ([]float64, error) {
+	n, err := pr.value(p.countType)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 || n > 1<<20 {
+		return nil, fmt.Errorf("bad list length %v", n)
+	}
+	out := make([]float64, int(n))
+	for i := range out {
+		if out[i], err = pr.value(p.typ); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func (pr *plyReader) skip(e plyElement) error {
+	for i := 0; i < e.count; i++ {
+		for _, p := range e.properties {
+			var err error
+			if p.list {
+				_, err = pr.list(p)
+			} else {
+				_, err = pr.value(p.typ)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (pr *plyReader) vertices(e plyElement, m *Mesh) error {
+	// destination array and component for every scalar property
+	type slot struct {
+		dst   *[]float32
+		comp  int
+		size  int
+		scale float64
+	}
+	colourScale := func(typ string) float64 {
+		switch typ {
+		case "uint8":
+			return 1.0 / 255
+		case "uint16":
+			return 1.0 / 65535
+		}
+		return 1
+	}
+
+	slots := make([]*slot, len(e.properties))
+	extra := make(map[string]*[]float32)
+	for i, p := range e.properties {
+		if p.list {
+			continue
+		}
+		switch p.name {
+		case "x", "y", "z":
+			slots[i] = &slot{&m.Positions, int(p.name[0] - 'x'), 3, 1}
+		case "nx", "ny", "nz":
+			slots[i] = &slot{&m.Normals, int(p.name[1] - 'x'), 3, 1}
+		case "red", "green", "blue", "r", "g", "b", "diffuse_red", "diffuse_green", "diffuse_blue":
+			c := strings.TrimPrefix(p.name, "diffuse_")
+			comp := map[byte]int{'r': 0, 'g': 1, 'b': 2}[c[0]]
+			slots[i] = &slot{&m.Colours, comp, 3, colourScale(p.typ)}
+		case "u", "s", "texture_u", "texture_s":
+			slots[i] = &slot{&m.TexCoords, 0, 2, 1}
+		case "v", "t", "texture_v", "texture_t":
+			slots[i] = &slot{&m.TexCoords, 1, 2, 1}
+		default:
+			dst := new([]float32)
+			extra[p.name] = dst
+			slots[i] = &slot{dst, 0, 1, 1}
+		}
+	}
+
+	for _, s := range slots {
+		if s != nil && len(*s.dst) == 0 {
+			*s.dst = make([]float32, e.count*s.size)
+		}
+	}
+
+	for v := 0; v < e.count; v++ {
+		for i, p := range e.properties {
+			if p.list {
+				if _, err := pr.list(p); err != nil {
+					return err
+				}
+				continue
+			}
+			f, err := pr.value(p.typ)
+			if err != nil {
+				return err
+			}
+			s := slots[i]
+			(*s.dst)[v*s.size+s.comp] = float32(f * s.scale)
+		}
+	}
+
+	if len(m.Positions) == 0 {
+		return errors.New("no x, y, z properties")
+	}
+	if len(extra) > 0 {
+		m.Extra = make(map[string][]float32)
+		for name, dst := range extra {
+			m.Extra[name] = *dst
+		}
+	}
+	return nil
+}
+
+func (pr *plyReader) faces(e plyElement, m *Mesh) error {
+	count := uint32(m.VertexCount())
+	for f := 0; f < e.count; f++ {
+		for _, p := range e.properties {
+			if !p.list {
+				if _, err := pr.value(p.typ); err != nil {
+					return err
+				}
+				continue
+			}
+			l, err := pr.list(p)
+			if err != nil {
+				return err
+			}
+			if p.name != "vertex_indices" && p.name != "vertex_index" {
+				continue
+			}
+
+			poly := make([]uint32, len(l))
+			for i, v := range l {
+				if v < 0 || uint32(v) >= count {
+					return fmt.Errorf("face %d: index %v out of range", f, v)
+				}
+				poly[i] = uint32(v)
+			}
+			for _, t := range triangulate(m.Positions, poly) {
+				m.Indices = append(m.Indices, t[0], t[1], t[2])
+			}
+		}
+	}
+	return nil
+}
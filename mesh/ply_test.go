@@ -0,0 +1,91 @@
+package mesh
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// plyBinary is a header followed by the values written in order.
+func plyBinary(order binary.ByteOrder, header string, values ...interface{}) string {
+	var b bytes.Buffer
+	b.WriteString(header)
+	for _, v := range values {
+		binary.Write(&b, order, v)
+	}
+	return b.String()
+}
+
+func TestReadPLY(t *testing.T) {
+	xyz := "property float x\nproperty float y\nproperty float z\n"
+	faces := "element face 1\nproperty list uchar int vertex_indices\n"
+	square := "0 0 0\n1 0 0\n1 1 0\n0 1 0\n"
+	binaryTriangle := "element vertex 3\nproperty double x\nproperty double y\nproperty double z\n" +
+		"element face 1\nproperty uchar flags\nproperty list uchar ushort vertex_index\nend_header\n"
+
+	tests := []struct {
+		name      string
+		src       string
+		positions []float32
+		indices   []uint32
+		colours   []float32
+		texcoords []float32
+		extra     map[string][]float32
+		err       string
+	}{
+		{"ascii", "ply\nformat ascii 1.0\ncomment a square\nelement vertex 4\n" + xyz + faces + "end_header\n" + square + "4 0 1 2 3\n",
+			[]float32{0, 0, 0, 1, 0, 0, 1, 1, 0, 0, 1, 0}, []uint32{0, 1, 2, 0, 2, 3}, nil, nil, nil, ""},
+		{"values across lines", "ply\nformat ascii 1.0\nelement vertex 3\n" + xyz + faces + "end_header\n0 0 0 1\n0 0 0 1 0\n3 0\n1 2\n",
+			[]float32{0, 0, 0, 1, 0, 0, 0, 1, 0}, []uint32{0, 1, 2}, nil, nil, nil, ""},
+		{"colours, texcoords and extras", "ply\nformat ascii 1.0\nelement vertex 3\n" + xyz +
+			"property uchar red\nproperty uchar green\nproperty uchar blue\nproperty float s\nproperty float t\nproperty float confidence\n" +
+			"end_header\n0 0 0 255 0 0 0 0 0.5\n1 0 0 0 255 0 1 0 0.5\n0 1 0 0 0 255 0 1 1\n",
+			[]float32{0, 0, 0, 1, 0, 0, 0, 1, 0}, nil, []float32{1, 0, 0, 0, 1, 0, 0, 0, 1}, []float32{0, 0, 1, 0, 0, 1},
+			map[string][]float32{"confidence": {0.5, 0.5, 1}}, ""},
+		{"other elements skipped", "ply\nformat ascii 1.0\nelement material 2\nproperty list uchar float values\nelement vertex 3\n" + xyz +
+			"end_header\n2 0.5 0.5\n0\n0 0 0\n1 0 0\n0 1 0\n", []float32{0, 0, 0, 1, 0, 0, 0, 1, 0}, nil, nil, nil, nil, ""},
+		{"big endian", plyBinary(binary.BigEndian, "ply\nformat binary_big_endian 1.0\n"+binaryTriangle,
+			[]float64{0, 0, 0, 1, 0, 0, 0, 1, 0}, []uint8{7, 3}, []uint16{0, 1, 2}),
+			[]float32{0, 0, 0, 1, 0, 0, 0, 1, 0}, []uint32{0, 1, 2}, nil, nil, nil, ""},
+		{"little endian", plyBinary(binary.LittleEndian, "ply\nformat binary_little_endian 1.0\n"+binaryTriangle,
+			[]float64{0, 0, 0, 1, 0, 0, 0, 1, 0}, []uint8{7, 3}, []uint16{0, 1, 2}),
+			[]float32{0, 0, 0, 1, 0, 0, 0, 1, 0}, []uint32{0, 1, 2}, nil, nil, nil, ""},
+
+		{"not ply", "obj\n", nil, nil, nil, nil, nil, "not a PLY file"},
+		{"truncated header", "ply\nformat ascii 1.0\nelement vertex 3\n", nil, nil, nil, nil, nil, "truncated header"},
+		{"unknown format", "ply\nformat binary_middle_endian 1.0\nelement vertex 0\n" + xyz + "end_header\n", nil, nil, nil, nil, nil, "unsupported format"},
+		{"bad type", "ply\nformat ascii 1.0\nelement vertex 1\nproperty float128 x\nend_header\n", nil, nil, nil, nil, nil, "line 4: bad type"},
+		{"negative count", "ply\nformat ascii 1.0\nelement vertex -1\nend_header\n", nil, nil, nil, nil, nil, "line 3: bad element count"},
+		{"property first", "ply\nformat ascii 1.0\nproperty float x\nend_header\n", nil, nil, nil, nil, nil, "line 3: property before element"},
+		{"no positions", "ply\nformat ascii 1.0\nelement vertex 1\nproperty float nx\nend_header\n0\n", nil, nil, nil, nil, nil, "element vertex: no x, y, z properties"},
+		{"bad number", "ply\nformat ascii 1.0\nelement vertex 1\n" + xyz + "end_header\n0 zero 0\n", nil, nil, nil, nil, nil, "element vertex: line 8: bad number"},
+		{"truncated body", "ply\nformat ascii 1.0\nelement vertex 4\n" + xyz + "end_header\n" + square[:12], nil, nil, nil, nil, nil, "element vertex: unexpected EOF"},
+		{"index out of range", "ply\nformat ascii 1.0\nelement vertex 4\n" + xyz + faces + "end_header\n" + square + "3 0 1 4\n", nil, nil, nil, nil, nil, "element face: face 0: index 4 out of range"},
+		{"huge list", "ply\nformat ascii 1.0\nelement vertex 4\n" + xyz + faces + "end_header\n" + square + "200 0 1 2\n", nil, nil, nil, nil, nil, "element face: unexpected EOF"},
+		{"no vertices", "ply\nformat ascii 1.0\nelement face 0\nproperty list uchar int vertex_indices\nend_header\n", nil, nil, nil, nil, nil, "no vertices"},
+	}
+	for _, tt := range tests {
+		m, err := ReadPLY(strings.NewReader(tt.src))
+		if tt.err != "" {
+			if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+				t.Errorf("%s: error %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(m.Positions, tt.positions) || !reflect.DeepEqual(m.Indices, tt.indices) {
+			t.Errorf("%s: positions %v and indices %v, want %v and %v", tt.name, m.Positions, m.Indices, tt.positions, tt.indices)
+		}
+		if !reflect.DeepEqual(m.Colours, tt.colours) || !reflect.DeepEqual(m.TexCoords, tt.texcoords) || !reflect.DeepEqual(m.Extra, tt.extra) {
+			t.Errorf("%s: colours %v, texcoords %v, extra %v", tt.name, m.Colours, m.TexCoords, m.Extra)
+		}
+		if len(m.Groups) != 1 || m.Groups[0].Count != len(m.Indices) {
+			t.Errorf("%s: groups %+v", tt.name, m.Groups)
+		}
+	}
+}
@@ -0,0 +1,392 @@
+package mesh
+
+import (
+	"math"
+)
+
+/*
+Procedural primitives. All of them are centred on the origin with Y up,
+wind their triangles counter-clockwise seen from outside and come with
+normals, texcoords and tangents (bitangent sign +1).
+*/
+
+type builder struct {
+	m *Mesh
+}
+
+func newBuilder(name string) *builder {
+	return &builder{m: &Mesh{Name: name}}
+}
+
+func (b *builder) vertex(p, n [3]float32, u, v float32, t [3]float32) uint32 {
+	m := b.m
+	i := uint32(m.VertexCount())
+	m.Positions = append(m.Positions, p[:]...)
+	m.Normals = append(m.Normals, n[:]...)
+	m.TexCoords = append(m.TexCoords, u, v)
+	m.Tangents = append(m.Tangents, t[0], t[1], t[2], 1)
+	return i
+}
+
+func (b *builder) triangle(i, j, k uint32) {
+	b.m.Indices = append(b.m.Indices, i, j, k)
+}
+
+func (b *builder) mesh() *Mesh {
+	b.m.Groups = []Group{{Material: -1, Count: len(b.m.Indices)}}
+	return b.m
+}
+
+// grid adds a subdivided parallelogram spanned by du and dv from origin.
+// cross(du, dv) must point along n.
+func (b *builder) grid(origin, du, dv, n [3]float32, segU, segV int) {
+	base := uint32(b.m.VertexCount())
+	t := normalize(du)
+	for j := 0; j <= segV; j++ {
+		for i := 0; i <= segU; i++ {
+			s := float32(i) / float32(segU)
+			r := float32(j) / float32(segV)
+			p := add(origin, add(scale(du, s), scale(dv, r)))
+			b.vertex(p, n, s, r, t)
+		}
+	}
+
+	row := uint32(segU + 1)
+	for j := uint32(0); j < uint32(segV); j++ {
+		for i := uint32(0); i < uint32(segU); i++ {
+			a := base + j*row + i
+			b.triangle(a, a+1, a+row+1)
+			b.triangle(a, a+row+1, a+row)
+		}
+	}
+}
+
+// profilePoint is a point of a curve in the (radius, y) half plane with its
+// outward normal.
+type profilePoint struct {
+	r, y   float32
+	nr, ny float32
+}
+
+// lathe revolves a profile, given from bottom to top, around the Y axis.
+// v follows the arc length of the profile, u goes once around starting at
+// -Z, which keeps the texture seam at the back.
+func (b *builder) lathe(profile []profilePoint, segments int) {
+	base := uint32(b.m.VertexCount())
+
+	lengths := make([]float32, len(profile))
+	for i := 1; i < len(profile); i++ {
+		dr := profile[i].r - profile[i-1].r
+		dy := profile[i].y - profile[i-1].y
+		lengths[i] = lengths[i-1] + float32(math.Sqrt(float64(dr*dr+dy*dy)))
+	}
+	total := lengths[len(lengths)-1]
+	if total == 0 {
+		total = 1
+	}
+
+	for i, pp := range profile {
+		for j := 0; j <= segments; j++ {
+			// the last column repeats the first exactly, closing the seam
+			theta := math.Pi + 2*math.Pi*float64(j%segments)/float64(segments)
+			sin, cos := float32(math.Sin(theta)), float32(math.Cos(theta))
+			p := [3]float32{pp.r * sin, pp.y, pp.r * cos}
+			n := normalize([3]float32{pp.nr * sin, pp.ny, pp.nr * cos})
+			t := [3]float32{cos, 0, -sin}
+			b.vertex(p, n, float32(j)/float32(segments), lengths[i]/total, t)
+		}
+	}
+
+	row := uint32(segments + 1)
+	for i := uint32(0); i+1 < uint32(len(profile)); i++ {
+		for j := uint32(0); j < uint32(segments); j++ {
+			a := base + i*row + j
+			// skip the half of the quad that collapses on the axis
+			if profile[i].r != 0 {
+				b.triangle(a, a+1, a+row+1)
+			}
+			if profile[i+1].r != 0 {
+				b.triangle(a, a+row+1, a+row)
+			}
+		}
+	}
+}
+
+// disc adds a flat cap at height y facing up or down.
+func (b *builder) disc(radius, y float32, segments int, up bool) {
+	n := [3]float32{0, -1, 0}
+	flip := float32(1)
+	if up {
+		n[1] = 1
+		flip = -1
+	}
+
+	centre := b.vertex([3]float32{0, y, 0}, n, 0.5, 0.5, [3]float32{1, 0, 0})
+	for j := 0; j <= segments; j++ {
+		theta := 2 * math.Pi * float64(j) / float64(segments)
+		sin, cos := float32(math.Sin(theta)), float32(math.Cos(theta))
+		b.vertex([3]float32{radius * sin, y, radius * cos}, n,
+			0.5+sin/2, 0.5+flip*cos/2, [3]float32{1, 0, 0})
+	}
+	for j := uint32(1); j <= uint32(segments); j++ {
+		if up {
+			b.triangle(centre, centre+j, centre+j+1)
+		} else {
+			b.triangle(centre, centre+j+1, centre+j)
+		}
+	}
+}
+
+func atLeast(n, min int) int {
+	if n < min {
+		return min
+	}
+	return n
+}
+
+// Plane is a width x depth grid in the XZ plane facing +Y.
+func Plane(width, depth float32, segX, segZ int) *Mesh {
+	b := newBuilder("plane")
+	b.grid([3]float32{-width / 2, 0, depth / 2},
+		[3]float32{width, 0, 0}, [3]float32{0, 0, -depth}, [3]float32{0, 1, 0},
+		atLeast(segX, 1), atLeast(segZ, 1))
+	return b.mesh()
+}
+
+// Cube is an axis aligned cube with every face split into segments x
+// segments quads. Faces do not share vertices so the edges stay sharp.
+func Cube(size float32, segments int) *Mesh {
+	segments = atLeast(segments, 1)
+	faces := []struct{ n, u, v [3]float32 }{
+		{[3]float32{1, 0, 0}, [3]float32{0, 0, -1}, [3]float32{0, 1, 0}},
+		{[3]float32{-1, 0, 0}, [3]float32{0, 0, 1}, [3]float32{0, 1, 0}},
+		{[3]float32{0, 1, 0}, [3]float32{1, 0, 0}, [3]float32{0, 0, -1}},
+		{[3]float32{0, -1, 0}, [3]float32{1, 0, 0}, [3]float32{0, 0, 1}},
+		{[3]float32{0, 0, 1}, [3]float32{1, 0, 0}, [3]float32{0, 1, 0}},
+		{[3]float32{0, 0, -1}, [3]float32{-1, 0, 0}, [3]float32{0, 1, 0}},
+	}
+
+	b := newBuilder("cube")
+	h := size / 2
+	for _, f := range faces {
+		origin := sub(sub(scale(f.n, h), scale(f.u, h)), scale(f.v, h))
+		b.grid(origin, scale(f.u, size), scale(f.v, size), f.n, segments, segments)
+	}
+	return b.mesh()
+}
+
+// UVSphere is a sphere of segments slices around Y and rings stacks from
+// pole to pole.
+func UVSphere(radius float32, segments, rings int) *Mesh {
+	segments, rings = atLeast(segments, 3), atLeast(rings, 2)
+
+	profile := make([]profilePoint, rings+1)
+	for i := range profile {
+		phi := math.Pi * (float64(i)/float64(rings) - 0.5)
+		c, s := float32(math.Cos(phi)), float32(math.Sin(phi))
+		if i == 0 || i == rings {
+			c = 0
+		}
+		profile[i] = profilePoint{radius * c, radius * s, c, s}
+	}
+
+	b := newBuilder("sphere")
+	b.lathe(profile, segments)
+	return b.mesh()
+}
+
+// Icosphere is a subdivided icosahedron projected onto the sphere, giving
+// evenly sized triangles. Vertices on the texture seam and at the poles are
+// duplicated so texcoords do not wrap across triangles.
+func Icosphere(radius float32, subdivisions int) *Mesh {
+	t := float32((1 + math.Sqrt(5)) / 2)
+	pos := [][3]float32{
+		{-1, t, 0}, {1, t, 0}, {-1, -t, 0}, {1, -t, 0},
+		{0, -1, t}, {0, 1, t}, {0, -1, -t}, {0, 1, -t},
+		{t, 0, -1}, {t, 0, 1}, {-t, 0, -1}, {-t, 0, 1},
+	}
+	for i := range pos {
+		pos[i] = normalize(pos[i])
+	}
+	tris := [][3]uint32{
+		{0, 11, 5}, {0, 5, 1}, {0, 1, 7}, {0, 7, 10}, {0, 10, 11},
+		{1, 5, 9}, {5, 11, 4}, {11, 10, 2}, {10, 7, 6}, {7, 1, 8},
+		{3, 9, 4}, {3, 4, 2}, {3, 2, 6}, {3, 6, 8}, {3, 8, 9},
+		{4, 9, 5}, {2, 4, 11}, {6, 2, 10}, {8, 6, 7}, {9, 8, 1},
+	}
+
+	for s := 0; s < subdivisions; s++ {
+		mid := make(map[[2]uint32]uint32)
+		midpoint := func(a, b uint32) uint32 {
+			key := [2]uint32{a, b}
+			if b < a {
+				key = [2]uint32{b, a}
+			}
+			if i, ok := mid[key]; ok {
+				return i
+			}
+			pos = append(pos, normalize(scale(add(pos[a], pos[b]), 0.5)))
+			i := uint32(len(pos) - 1)
+			mid[key] = i
+			return i
+		}
+
+		next := make([][3]uint32, 0, len(tris)*4)
+		for _, tr := range tris {
+			a := midpoint(tr[0], tr[1])
+			b := midpoint(tr[1], tr[2])
+			c := midpoint(tr[2], tr[0])
+			next = append(next,
+				[3]uint32{tr[0], a, c}, [3]uint32{tr[1], b, a},
+				[3]uint32{tr[2], c, b}, [3]uint32{a, b, c})
+		}
+		tris = next
+	}
+
+	// same parametrization as the lathe: x = r sin(theta), z = r cos(theta)
+	// with theta = pi + 2 pi u
+	uv := func(p [3]float32) (float32, float32) {
+		u := float32(math.Atan2(float64(p[0]), float64(p[2]))/(2*math.Pi)) + 0.5
+		if u >= 1 {
+			u--
+		}
+		v := float32(math.Asin(float64(p[1]))/math.Pi) + 0.5
+		return u, v
+	}
+
+	b := newBuilder("icosphere")
+	type key struct {
+		i uint32
+		u float32
+	}
+	cache := make(map[key]uint32)
+	for _, tr := range tris {
+		var us, vs [3]float32
+		pole := -1
+		for k, i := range tr {
+			us[k], vs[k] = uv(pos[i])
+			if abs(pos[i][1]) > 0.999999 {
+				pole = k
+			}
+		}
+		// triangles crossing the seam get the low side shifted past 1
+		lo, hi := float32(1), float32(0)
+		for k, u := range us {
+			if k == pole {
+				continue
+			}
+			lo = float32(math.Min(float64(lo), float64(u)))
+			hi = float32(math.Max(float64(hi), float64(u)))
+		}
+		if hi-lo > 0.5 {
+			for k := range us {
+				if k != pole && us[k] < 0.5 {
+					us[k]++
+				}
+			}
+		}
+		// poles take the u of the middle of the opposite edge
+		if pole >= 0 {
+			us[pole] = (us[(pole+1)%3] + us[(pole+2)%3]) / 2
+		}
+
+		var idx [3]uint32
+		for k, i := range tr {
+			kk := key{i, us[k]}
+			j, ok := cache[kk]
+			if !ok {
+				theta := math.Pi + 2*math.Pi*float64(us[k])
+				tan := [3]float32{float32(math.Cos(theta)), 0, -float32(math.Sin(theta))}
+				j = b.vertex(scale(pos[i], radius), pos[i], us[k], vs[k], tan)
+				cache[kk] = j
+			}
+			idx[k] = j
+		}
+		b.triangle(idx[0], idx[1], idx[2])
+	}
+	return b.mesh()
+}
+
+// frustum builds the side of a cut cone between two radii, with optional
+// caps.
+func frustum(name string, bottom, top, height float32, segments, stacks int, caps bool) *Mesh {
+	segments, stacks = atLeast(segments, 3), atLeast(stacks, 1)
+
+	// outward normal of the slanted side in the (r, y) plane
+	n := normalize([3]float32{height, bottom - top, 0})
+	profile := make([]profilePoint, stacks+1)
+	for i := range profile {
+		f := float32(i) / float32(stacks)
+		profile[i] = profilePoint{bottom + (top-bottom)*f, height * (f - 0.5), n[0], n[1]}
+	}
+
+	b := newBuilder(name)
+	b.lathe(profile, segments)
+	if caps {
+		if bottom > 0 {
+			b.disc(bottom, -height/2, segments, false)
+		}
+		if top > 0 {
+			b.disc(top, height/2, segments, true)
+		}
+	}
+	return b.mesh()
+}
+
+// Cylinder has its axis along Y, segments slices around and stacks rings
+// along the height.
+func Cylinder(radius, height float32, segments, stacks int, caps bool) *Mesh {
+	return frustum("cylinder", radius, radius, height, segments, stacks, caps)
+}
+
+// Cone points up along Y with its base centred at -height/2.
+func Cone(radius, height float32, segments, stacks int, cap bool) *Mesh {
+	return frustum("cone", radius, 0, height, segments, stacks, cap)
+}
+
+// Torus lies in the XZ plane; major is the distance from the centre to the
+// middle of the tube, minor the tube radius.
+func Torus(major, minor float32, segments, sides int) *Mesh {
+	segments, sides = atLeast(segments, 3), atLeast(sides, 3)
+
+	// start on the inside so the texture seam is hidden in the hole
+	profile := make([]profilePoint, sides+1)
+	for i := range profile {
+		phi := math.Pi + 2*math.Pi*float64(i%sides)/float64(sides)
+		c, s := float32(math.Cos(phi)), float32(math.Sin(phi))
+		profile[i] = profilePoint{major + minor*c, minor * s, c, s}
+	}
+
+	b := newBuilder("torus")
+	b.lathe(profile, segments)
+	return b.mesh()
+}
+
+// Capsule is a cylinder of the given height closed by two hemispheres, so
+// the total height is height + 2*radius. rings is the number of stacks in
+// each hemisphere.
+func Capsule(radius, height float32, segments, rings int) *Mesh {
+	segments, rings = atLeast(segments, 3), atLeast(rings, 1)
+
+	var profile []profilePoint
+	for i := 0; i <= rings; i++ {
+		phi := math.Pi / 2 * (float64(i)/float64(rings) - 1)
+		c, s := float32(math.Cos(phi)), float32(math.Sin(phi))
+		if i == 0 {
+			c = 0
+		}
+		profile = append(profile, profilePoint{radius * c, radius*s - height/2, c, s})
+	}
+	for i := 0; i <= rings; i++ {
+		phi := math.Pi / 2 * float64(i) / float64(rings)
+		c, s := float32(math.Cos(phi)), float32(math.Sin(phi))
+		if i == rings {
+			c = 0
+		}
+		profile = append(profile, profilePoint{radius * c, radius*s + height/2, c, s})
+	}
+
+	b := newBuilder("capsule")
+	b.lathe(profile, segments)
+	return b.mesh()
+}
@@ -0,0 +1,145 @@
+package mesh
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPrimitives(t *testing.T) {
+	tests := []struct {
+		mesh      *Mesh
+		vertices  int
+		triangles int
+		volume    float64 // of the closed shape, 0 for open ones
+	}{
+		{Plane(2, 3, 4, 5), 5 * 6, 4 * 5 * 2, 0},
+		{Cube(2, 3), 6 * 4 * 4, 6 * 3 * 3 * 2, 8},
+		{UVSphere(1, 32, 16), 17 * 33, 16*32*2 - 2*32, 4.0 / 3 * math.Pi},
+		{Icosphere(1, 2), -1, 20 * 16, 4.0 / 3 * math.Pi},
+		{Cylinder(1, 2, 32, 2, true), 3*33 + 2*34, 2*32*2 + 2*32, 2 * math.Pi},
+		{Cone(1, 2, 32, 3, true), 4*33 + 34, 3*32*2 - 32 + 32, 2.0 / 3 * math.Pi},
+		{Torus(2, 0.5, 32, 16), 17 * 33, 16 * 32 * 2, 2 * math.Pi * math.Pi * 2 * 0.25},
+		{Capsule(0.5, 1, 32, 8), 18 * 33, 17*32*2 - 2*32, math.Pi*0.25 + 4.0/3*math.Pi*0.125},
+	}
+
+	for _, tt := range tests {
+		m := tt.mesh
+		n := m.VertexCount()
+		if tt.vertices >= 0 && n != tt.vertices {
+			t.Errorf("%s: %d vertices, want %d", m.Name, n, tt.vertices)
+		}
+		if m.TriangleCount() != tt.triangles {
+			t.Errorf("%s: %d triangles, want %d", m.Name, m.TriangleCount(), tt.triangles)
+		}
+		if len(m.Normals) != n*3 || len(m.TexCoords) != n*2 || len(m.Tangents) != n*4 {
+			t.Errorf("%s: attribute lengths do not match %d vertices", m.Name, n)
+			continue
+		}
+
+		for i := 0; i < n; i++ {
+			if l := length(vec3(m.Normals, uint32(i))); math.Abs(float64(l)-1) > 1e-4 {
+				t.Errorf("%s: normal %d has length %g", m.Name, i, l)
+				break
+			}
+		}
+
+		// counter-clockwise triangles have a face normal on the side of
+		// their vertex normals
+		var volume float64
+		for i := 0; i+2 < len(m.Indices); i += 3 {
+			a, b, c := m.Indices[i], m.Indices[i+1], m.Indices[i+2]
+			if int(a) >= n || int(b) >= n || int(c) >= n {
+				t.Fatalf("%s: triangle %d indexes past %d vertices", m.Name, i/3, n)
+			}
+			fn := faceNormal(m.Positions, a, b, c)
+			if length(fn) == 0 {
+				t.Errorf("%s: triangle %d is degenerate", m.Name, i/3)
+				break
+			}
+			bad := false
+			for _, v := range []uint32{a, b, c} {
+				if dot(fn, vec3(m.Normals, v)) <= 0 {
+					t.Errorf("%s: triangle %d is wound against the normal of vertex %d", m.Name, i/3, v)
+					bad = true
+				}
+			}
+			if bad {
+				break
+			}
+			p0, p1, p2 := vec3(m.Positions, a), vec3(m.Positions, b), vec3(m.Positions, c)
+			volume += float64(dot(p0, cross(p1, p2))) / 6
+		}
+
+		// the signed volume is positive only when the faces point outward
+		if tt.volume > 0 && math.Abs(volume-tt.volume) > 0.05*tt.volume {
+			t.Errorf("%s: enclosed volume %.4f, want about %.4f", m.Name, volume, tt.volume)
+		}
+	}
+}
+
+func TestPlaneFacesUp(t *testing.T) {
+	m := Plane(1, 1, 2, 2)
+	for i := 0; i < len(m.Indices); i += 3 {
+		fn := faceNormal(m.Positions, m.Indices[i], m.Indices[i+1], m.Indices[i+2])
+		if fn[1] <= 0 {
+			t.Fatalf("triangle %d faces %v", i/3, fn)
+		}
+	}
+}
+
+func TestIcosphereVertices(t *testing.T) {
+	for s := 0; s <= 3; s++ {
+		m := Icosphere(1, s)
+		// seam and pole vertices are duplicated, positions are not
+		positions := make(map[[3]float32]bool)
+		for i := 0; i < m.VertexCount(); i++ {
+			p := vec3(m.Positions, uint32(i))
+			if l := length(p); math.Abs(float64(l)-1) > 1e-5 {
+				t.Errorf("subdivisions %d: vertex %d off the sphere at %g", s, i, l)
+			}
+			positions[p] = true
+		}
+		if want := 10*int(math.Pow(4, float64(s))) + 2; len(positions) != want {
+			t.Errorf("subdivisions %d: %d positions, want %d", s, len(positions), want)
+		}
+	}
+}
+
+func TestLatheSeamsClosed(t *testing.T) {
+	for _, m := range []*Mesh{UVSphere(1, 16, 8), Cylinder(1, 2, 16, 2, false), Torus(2, 0.5, 16, 8), Capsule(0.5, 1, 16, 4)} {
+		// every edge of a closed surface, welded by position, has two
+		// triangles
+		first := make(map[[3]float32]uint32)
+		weld := func(i uint32) uint32 {
+			p := vec3(m.Positions, i)
+			if w, ok := first[p]; ok {
+				return w
+			}
+			first[p] = i
+			return i
+		}
+		edges := make(map[[2]uint32]int)
+		for i := 0; i+2 < len(m.Indices); i += 3 {
+			for k := 0; k < 3; k++ {
+				a, b := weld(m.Indices[i+k]), weld(m.Indices[i+(k+1)%3])
+				if b < a {
+					a, b = b, a
+				}
+				edges[[2]uint32{a, b}]++
+			}
+		}
+		open := 0
+		for _, c := range edges {
+			if c != 2 {
+				open++
+			}
+		}
+		// the cylinder has no caps, so its two rims stay open
+		if m.Name == "cylinder" {
+			open -= 2 * 16
+		}
+		if open != 0 {
+			t.Errorf("%s: %d edges not shared by two triangles", m.Name, open)
+		}
+	}
+}
@@ -0,0 +1,462 @@
+package mesh
+
+import (
+	"container/heap"
+	"math"
+)
+
+/*
+Simplification by quadric error edge collapse (Garland and Heckbert). Every
+vertex accumulates the planes of its triangles, a collapse of u into v costs
+the summed squared distance of v to the planes of both. Edges collapse onto
+one of their end points, so no attribute has to be interpolated.
+
+Collapses work on the mesh welded by position, so hard edges and flat
+shading do not cut it apart. Edges where texcoords or colours jump are
+seams: like open borders they are held in place by extra planes and may
+only shrink along themselves, which keeps them closed, and vertices where
+seams meet or end are never removed. When writing the result every corner
+picks the vertex at its new position whose attributes are closest to the
+ones it had.
+*/
+
+// quadric is a symmetric 4x4 matrix, a2 ab ac ad b2 bc bd c2 cd d2, followed
+// by the total weight of its planes.
+type quadric [11]float64
+
+func planeQuadric(n [3]float64, d, w float64) quadric {
+	a, b, c := n[0], n[1], n[2]
+	return quadric{
+		w * a * a, w * a * b, w * a * c, w * a * d,
+		w * b * b, w * b * c, w * b * d,
+		w * c * c, w * c * d,
+		w * d * d,
+		w,
+	}
+}
+
+func (q *quadric) add(o quadric) {
+	for i := range q {
+		q[i] += o[i]
+	}
+}
+
+// eval returns the weighted mean squared distance of p to the planes of q.
+func (q *quadric) eval(p [3]float32) float64 {
+	if q[10] == 0 {
+		return 0
+	}
+	x, y, z := float64(p[0]), float64(p[1]), float64(p[2])
+	d := q[0]*x*x + 2*q[1]*x*y + 2*q[2]*x*z + 2*q[3]*x +
+		q[4]*y*y + 2*q[5]*y*z + 2*q[6]*y +
+		q[7]*z*z + 2*q[8]*z + q[9]
+	return math.Max(d, 0) / q[10]
+}
+
+type collapse struct {
+	cost     float64
+	from, to uint32
+	stamp    [2]int
+}
+
+type collapseQueue []collapse
+
+func (q collapseQueue) Len() int            { return len(q) }
+func (q collapseQueue) Less(i, j int) bool  { return q[i].cost < q[j].cost }
+func (q collapseQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *collapseQueue) Push(x interface{}) { *q = append(*q, x.(collapse)) }
+func (q *collapseQueue) Pop() interface{} {
+	old := *q
+	c := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return c
+}
+
+type simplifier struct {
+	m       *Mesh
+	tris    [][3]uint32 // in welded vertices
+	corners [][3]uint32 // the original vertex of every corner
+	alive   []bool
+	live    int
+	weld    []uint32   // first vertex at the same position
+	members [][]uint32 // vertices at the position of a welded vertex
+	around  [][]int    // triangles per vertex, may hold dead ones
+	quadric []quadric
+	locked  []bool
+	border  []bool
+	seam    []bool
+	seams   map[[2]uint32]bool
+	stamp   []int
+	queue   collapseQueue
+}
+
+func f64(a [3]float32) [3]float64 {
+	return [3]float64{float64(a[0]), float64(a[1]), float64(a[2])}
+}
+
+func newSimplifier(m *Mesh) *simplifier {
+	n := m.VertexCount()
+	s := &simplifier{
+		m:       m,
+		tris:    make([][3]uint32, m.TriangleCount()),
+		corners: make([][3]uint32, m.TriangleCount()),
+		alive:   make([]bool, m.TriangleCount()),
+		weld:    make([]uint32, n),
+		members: make([][]uint32, n),
+		around:  make([][]int, n),
+		quadric: make([]quadric, n),
+		locked:  make([]bool, n),
+		border:  make([]bool, n),
+		seam:    make([]bool, n),
+		seams:   make(map[[2]uint32]bool),
+		stamp:   make([]int, n),
+	}
+
+	// vertices differing only in their normals are one side of a seam
+	type side struct {
+		p      [3]float32
+		uv     [2]float32
+		colour [3]float32
+	}
+	first := make(map[[3]float32]uint32)
+	sides := make(map[side]uint32)
+	group := make([]uint32, n)
+	for v := uint32(0); v < uint32(n); v++ {
+		p := vec3(m.Positions, v)
+		w, ok := first[p]
+		if !ok {
+			w = v
+			first[p] = v
+		}
+		s.weld[v] = w
+		s.members[w] = append(s.members[w], v)
+
+		k := side{p: p}
+		if len(m.TexCoords) >= int(v+1)*2 {
+			k.uv = [2]float32{m.TexCoords[v*2], m.TexCoords[v*2+1]}
+		}
+		if len(m.Colours) >= int(v+1)*3 {
+			k.colour = vec3(m.Colours, v)
+		}
+		g, ok := sides[k]
+		if !ok {
+			g = v
+			sides[k] = v
+		}
+		group[v] = g
+	}
+
+	edges := make(map[[2]uint32]int)
+	edgeSides := make(map[[2]uint32][2]uint32)
+	for t := range s.tris {
+		copy(s.corners[t][:], m.Indices[t*3:t*3+3])
+		for k, v := range s.corners[t] {
+			s.tris[t][k] = s.weld[v]
+		}
+		tri := s.tris[t]
+		if tri[0] == tri[1] || tri[1] == tri[2] || tri[2] == tri[0] {
+			continue
+		}
+		s.alive[t] = true
+		s.live++
+		for k := 0; k < 3; k++ {
+			s.around[tri[k]] = append(s.around[tri[k]], t)
+			a, b := tri[k], tri[(k+1)%3]
+			key := edgeKey(a, b)
+			edges[key]++
+			ga, gb := group[s.corners[t][k]], group[s.corners[t][(k+1)%3]]
+			if a != key[0] {
+				ga, gb = gb, ga
+			}
+			if sd, ok := edgeSides[key]; !ok {
+				edgeSides[key] = [2]uint32{ga, gb}
+			} else if sd != [2]uint32{ga, gb} {
+				s.seams[key] = true
+			}
+		}
+
+		fn := f64(faceNormal(m.Positions, tri[0], tri[1], tri[2]))
+		area := math.Sqrt(fn[0]*fn[0]+fn[1]*fn[1]+fn[2]*fn[2]) / 2
+		if area == 0 {
+			continue
+		}
+		nrm := [3]float64{fn[0] / area / 2, fn[1] / area / 2, fn[2] / area / 2}
+		p := f64(vec3(m.Positions, tri[0]))
+		q := planeQuadric(nrm, -(nrm[0]*p[0] + nrm[1]*p[1] + nrm[2]*p[2]), area)
+		for _, v := range tri {
+			s.quadric[v].add(q)
+		}
+	}
+
+	// seams may only end or meet where nothing moves, neither may
+	// non-manifold edges
+	degree := make(map[uint32]int)
+	for e := range s.seams {
+		degree[e[0]]++
+		degree[e[1]]++
+	}
+	for v, d := range degree {
+		s.seam[v] = true
+		s.locked[v] = d != 2
+	}
+	for e, c := range edges {
+		if c > 2 {
+			s.locked[e[0]], s.locked[e[1]] = true, true
+		}
+	}
+
+	// a plane through every border and seam edge, perpendicular to its
+	// triangles
+	for t, tri := range s.tris {
+		if !s.alive[t] {
+			continue
+		}
+		for k := 0; k < 3; k++ {
+			a, b := tri[k], tri[(k+1)%3]
+			key := edgeKey(a, b)
+			if edges[key] == 1 {
+				s.border[a], s.border[b] = true, true
+			} else if !s.seams[key] {
+				continue
+			}
+			pa, pb := f64(vec3(m.Positions, a)), f64(vec3(m.Positions, b))
+			fn := f64(faceNormal(m.Positions, tri[0], tri[1], tri[2]))
+			e := [3]float64{pb[0] - pa[0], pb[1] - pa[1], pb[2] - pa[2]}
+			n := [3]float64{e[1]*fn[2] - e[2]*fn[1], e[2]*fn[0] - e[0]*fn[2], e[0]*fn[1] - e[1]*fn[0]}
+			l := math.Sqrt(n[0]*n[0] + n[1]*n[1] + n[2]*n[2])
+			if l == 0 {
+				continue
+			}
+			n = [3]float64{n[0] / l, n[1] / l, n[2] / l}
+			el := e[0]*e[0] + e[1]*e[1] + e[2]*e[2]
+			q := planeQuadric(n, -(n[0]*pa[0] + n[1]*pa[1] + n[2]*pa[2]), 10*el)
+			s.quadric[a].add(q)
+			s.quadric[b].add(q)
+		}
+	}
+	for v := range s.border {
+		if s.border[v] && s.seam[v] {
+			s.locked[v] = true
+		}
+	}
+
+	for t := range s.tris {
+		if !s.alive[t] {
+			continue
+		}
+		for k := 0; k < 3; k++ {
+			s.push(s.tris[t][k], s.tris[t][(k+1)%3])
+			s.push(s.tris[t][(k+1)%3], s.tris[t][k])
+		}
+	}
+	return s
+}
+
+func edgeKey(a, b uint32) [2]uint32 {
+	if b < a {
+		return [2]uint32{b, a}
+	}
+	return [2]uint32{a, b}
+}
+
+// shared returns the live triangles containing both u and v.
+func (s *simplifier) shared(u, v uint32) int {
+	n := 0
+	for _, t := range s.around[u] {
+		if !s.alive[t] {
+			continue
+		}
+		tri := s.tris[t]
+		if tri[0] == v || tri[1] == v || tri[2] == v {
+			n++
+		}
+	}
+	return n
+}
+
+// push queues the collapse of u into v if it is allowed at all.
+func (s *simplifier) push(u, v uint32) {
+	if u == v || s.locked[u] || s.border[u] && !s.border[v] ||
+		s.seam[u] && !s.seams[edgeKey(u, v)] {
+		return
+	}
+	q := s.quadric[u]
+	q.add(s.quadric[v])
+	heap.Push(&s.queue, collapse{
+		cost:  q.eval(vec3(s.m.Positions, v)),
+		from:  u,
+		to:    v,
+		stamp: [2]int{s.stamp[u], s.stamp[v]},
+	})
+}
+
+// valid checks that collapsing u into v neither flips nor degenerates a
+// triangle and keeps borders and seams along themselves.
+func (s *simplifier) valid(u, v uint32) bool {
+	shared := s.shared(u, v)
+	if shared == 0 || s.border[u] && shared != 1 || s.seam[u] && !s.seams[edgeKey(u, v)] {
+		return false
+	}
+	pos := s.m.Positions
+	for _, t := range s.around[u] {
+		if !s.alive[t] {
+			continue
+		}
+		tri := s.tris[t]
+		if tri[0] == v || tri[1] == v || tri[2] == v {
+			continue
+		}
+		before := normalize(faceNormal(pos, tri[0], tri[1], tri[2]))
+		for k := range tri {
+			if tri[k] == u {
+				tri[k] = v
+			}
+		}
+		after := faceNormal(pos, tri[0], tri[1], tri[2])
+		if length(after) == 0 || dot(before, normalize(after)) < 0.2 {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *simplifier) collapse(u, v uint32) {
+	s.quadric[v].add(s.quadric[u])
+	if s.seam[u] {
+		// the seam now runs through v instead
+		delete(s.seams, edgeKey(u, v))
+		for _, t := range s.around[u] {
+			for _, x := range s.tris[t] {
+				if x != u && x != v && s.seams[edgeKey(u, x)] {
+					delete(s.seams, edgeKey(u, x))
+					s.seams[edgeKey(v, x)] = true
+				}
+			}
+		}
+	}
+	var neighbours []uint32
+	for _, t := range s.around[u] {
+		if !s.alive[t] {
+			continue
+		}
+		tri := &s.tris[t]
+		if tri[0] == v || tri[1] == v || tri[2] == v {
+			s.alive[t] = false
+			s.live--
+			continue
+		}
+		for k := range tri {
+			if tri[k] == u {
+				tri[k] = v
+			}
+		}
+		s.around[v] = append(s.around[v], t)
+	}
+	s.around[u] = nil
+	s.stamp[u]++
+	s.stamp[v]++
+
+	live := s.around[v][:0]
+	for _, t := range s.around[v] {
+		if s.alive[t] {
+			live = append(live, t)
+			neighbours = append(neighbours, s.tris[t][:]...)
+		}
+	}
+	s.around[v] = live
+	for _, n := range neighbours {
+		s.push(n, v)
+		s.push(v, n)
+	}
+}
+
+// vertex returns the vertex written for corner k of triangle t: the
+// original one while it stays in place, else the one at the new position
+// closest in texcoords and colour, with the normal nearest the face.
+func (s *simplifier) vertex(t, k int) uint32 {
+	w, o := s.tris[t][k], s.corners[t][k]
+	if s.weld[o] == w {
+		return o
+	}
+	m := s.m
+	tri := s.tris[t]
+	fn := normalize(faceNormal(m.Positions, tri[0], tri[1], tri[2]))
+	best, bestCost := w, math.Inf(1)
+	for _, v := range s.members[w] {
+		cost := 0.0
+		if len(m.TexCoords) == len(m.Positions)/3*2 {
+			du := float64(m.TexCoords[v*2] - m.TexCoords[o*2])
+			dv := float64(m.TexCoords[v*2+1] - m.TexCoords[o*2+1])
+			cost += 100 * (du*du + dv*dv)
+		}
+		if len(m.Colours) == len(m.Positions) {
+			d := sub(vec3(m.Colours, v), vec3(m.Colours, o))
+			cost += 100 * float64(dot(d, d))
+		}
+		if len(m.Normals) == len(m.Positions) {
+			cost += 1 - float64(dot(vec3(m.Normals, v), fn))
+		}
+		if cost < bestCost {
+			best, bestCost = v, cost
+		}
+	}
+	return best
+}
+
+func (s *simplifier) run(target int, maxError float64) {
+	for s.live > target && s.queue.Len() > 0 {
+		c := heap.Pop(&s.queue).(collapse)
+		if c.stamp != [2]int{s.stamp[c.from], s.stamp[c.to]} {
+			continue
+		}
+		if c.cost > maxError*maxError {
+			break
+		}
+		if s.valid(c.from, c.to) {
+			s.collapse(c.from, c.to)
+		}
+	}
+}
+
+// Simplify returns a copy of the mesh reduced to about target triangles,
+// stopping early once the next collapse would move the surface by more
+// than maxError. Groups keep their surviving triangles.
+func (m *Mesh) Simplify(target int, maxError float32) *Mesh {
+	s := newSimplifier(m)
+	s.run(target, float64(maxError))
+
+	groups := m.Groups
+	if len(groups) == 0 {
+		groups = []Group{{Material: -1, Count: len(m.Indices)}}
+	}
+	out := m.clone()
+	out.Indices = out.Indices[:0]
+	out.Groups = out.Groups[:0]
+	for _, g := range groups {
+		start := len(out.Indices)
+		for t := g.Start / 3; t < (g.Start+g.Count)/3; t++ {
+			if s.alive[t] {
+				out.Indices = append(out.Indices, s.vertex(t, 0), s.vertex(t, 1), s.vertex(t, 2))
+			}
+		}
+		g.Start, g.Count = start, len(out.Indices)-start
+		out.Groups = append(out.Groups, g)
+	}
+	out.OptimizeVertexFetch()
+	return out
+}
+
+// LODs builds one cache optimised level of detail per ratio of the
+// triangle count, e.g. LODs(0.5, 0.25, 0.125). Each level is simplified
+// from the one before.
+func (m *Mesh) LODs(ratios ...float32) []*Mesh {
+	lods := make([]*Mesh, len(ratios))
+	prev := m
+	for i, r := range ratios {
+		lods[i] = prev.Simplify(int(float32(m.TriangleCount())*r), math.MaxFloat32)
+		lods[i].OptimizeVertexCache()
+		lods[i].OptimizeVertexFetch()
+		prev = lods[i]
+	}
+	return lods
+}
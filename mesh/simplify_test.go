@@ -0,0 +1,138 @@
+package mesh
+
+import (
+	"math"
+	"testing"
+)
+
+// openEdges counts the edges of m not shared by exactly two triangles once
+// vertices at the same position are joined, zero for a closed mesh.
+func openEdges(m *Mesh) int {
+	first := make(map[[3]float32]uint32)
+	weld := func(i uint32) uint32 {
+		p := vec3(m.Positions, i)
+		if v, ok := first[p]; ok {
+			return v
+		}
+		first[p] = i
+		return i
+	}
+	edges := make(map[[2]uint32]int)
+	for i := 0; i+2 < len(m.Indices); i += 3 {
+		for k := 0; k < 3; k++ {
+			edges[edgeKey(weld(m.Indices[i+k]), weld(m.Indices[i+(k+1)%3]))]++
+		}
+	}
+	n := 0
+	for _, c := range edges {
+		if c != 2 {
+			n++
+		}
+	}
+	return n
+}
+
+// volume of a closed mesh with counter-clockwise triangles.
+func volume(m *Mesh) float64 {
+	var v float64
+	for i := 0; i+2 < len(m.Indices); i += 3 {
+		p0, p1, p2 := vec3(m.Positions, m.Indices[i]), vec3(m.Positions, m.Indices[i+1]), vec3(m.Positions, m.Indices[i+2])
+		v += float64(dot(p0, cross(p1, p2))) / 6
+	}
+	return v
+}
+
+func TestSimplify(t *testing.T) {
+	flat := func(m *Mesh) *Mesh {
+		m.GenerateFlatNormals()
+		return m
+	}
+	sphere := 4.0 / 3 * math.Pi
+	tests := []struct {
+		name     string
+		mesh     *Mesh
+		target   int
+		maxError float32
+		min, max int     // triangles left
+		volume   float64 // of the closed shape, 0 for open ones
+	}{
+		{"icosphere", positionsOnly(Icosphere(1, 4)), 1280, 1, 1000, 1280, sphere},
+		{"uv sphere", UVSphere(1, 64, 32), 1000, 1, 800, 1000, sphere},
+		{"flat uv sphere", flat(UVSphere(1, 64, 32)), 1000, 1, 800, 1000, sphere},
+		{"flat torus", flat(Torus(2, 0.5, 64, 32)), 1024, 1, 800, 1024, 2 * math.Pi * math.Pi * 2 * 0.25},
+		{"cube", Cube(2, 8), 96, 1, 12, 96, 8},
+		// collapses inside a flat grid cost nothing
+		{"plane", positionsOnly(Plane(2, 2, 20, 20)), 10, 1, 2, 10, 0},
+		// a tight error bound stops well short of the target
+		{"max error", positionsOnly(Icosphere(1, 4)), 10, 0.001, 2000, 5120, sphere},
+	}
+	for _, tt := range tests {
+		m := tt.mesh
+		groups := len(m.Groups)
+		s := m.Simplify(tt.target, tt.maxError)
+		n := s.TriangleCount()
+		if n < tt.min || n > tt.max {
+			t.Errorf("%s: %d triangles left, want %d to %d", tt.name, n, tt.min, tt.max)
+		}
+		if len(s.Groups) != groups && !(groups == 0 && len(s.Groups) == 1) {
+			t.Errorf("%s: %d groups from %d", tt.name, len(s.Groups), groups)
+		}
+		if tt.volume == 0 {
+			continue
+		}
+		if e := openEdges(s); e != 0 {
+			t.Errorf("%s: %d open edges after simplifying", tt.name, e)
+		}
+		if v := volume(s); math.Abs(v-tt.volume) > tt.volume*0.1 {
+			t.Errorf("%s: volume %g, want %g", tt.name, v, tt.volume)
+		}
+	}
+}
+
+func TestSimplifyKeepsSeams(t *testing.T) {
+	for _, m := range []*Mesh{UVSphere(1, 64, 32), Icosphere(1, 4), Torus(2, 0.5, 64, 32)} {
+		m.GenerateFlatNormals()
+		s := m.Simplify(m.TriangleCount()/8, 1)
+
+		// no triangle stretches its texture across the u seam
+		for i := 0; i+2 < len(s.Indices); i += 3 {
+			lo, hi := float32(math.MaxFloat32), float32(-math.MaxFloat32)
+			for _, v := range s.Indices[i : i+3] {
+				u := s.TexCoords[v*2]
+				lo, hi = float32(math.Min(float64(lo), float64(u))), float32(math.Max(float64(hi), float64(u)))
+			}
+			if hi-lo > 0.3 {
+				t.Errorf("%s: triangle %d spans u %g to %g", m.Name, i/3, lo, hi)
+				break
+			}
+		}
+		for i := 0; i+2 < len(s.Indices); i += 3 {
+			fn := faceNormal(s.Positions, s.Indices[i], s.Indices[i+1], s.Indices[i+2])
+			if dot(fn, vec3(s.Normals, s.Indices[i])) <= 0 {
+				t.Errorf("%s: triangle %d is flipped", m.Name, i/3)
+				break
+			}
+		}
+	}
+}
+
+func TestLODs(t *testing.T) {
+	m := UVSphere(1, 64, 32)
+	ratios := []float32{0.5, 0.25, 0.1}
+	lods := m.LODs(ratios...)
+	if len(lods) != len(ratios) {
+		t.Fatalf("%d levels for %d ratios", len(lods), len(ratios))
+	}
+	for i, l := range lods {
+		want := int(float32(m.TriangleCount()) * ratios[i])
+		if n := l.TriangleCount(); n > want || n < want*3/4 {
+			t.Errorf("level %d: %d triangles, want about %d", i, n, want)
+		}
+		if e := openEdges(l); e != 0 {
+			t.Errorf("level %d: %d open edges", i, e)
+		}
+		if acmr := ACMR(l.Indices, VertexCacheSize); acmr > 1 {
+			t.Errorf("level %d: ACMR %g", i, acmr)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package mesh
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// stlBuilder merges facet corners that share both position and normal, so
+// the mesh stays flat shaded like the STL facets while being indexed.
+type stlBuilder struct {
+	mesh  *Mesh
+	cache map[[6]float32]uint32
+}
+
+func (b *stlBuilder) facet(n [3]float32, v [3][3]float32) {
+	if n == ([3]float32{}) {
+		n = normalize(cross(sub(v[1], v[0]), sub(v[2], v[0])))
+	}
+	m := b.mesh
+	for _, p := range v {
+		key := [6]float32{p[0], p[1], p[2], n[0], n[1], n[2]}
+		i, ok := b.cache[key]
+		if !ok {
+			i = uint32(m.VertexCount())
+			b.cache[key] = i
+			m.Positions = append(m.Positions, p[:]...)
+			m.Normals = append(m.Normals, n[:]...)
+		}
+		m.Indices = append(m.Indices, i)
+	}
+}
+
+// LoadSTL reads an ASCII or binary STL file.
+func LoadSTL(filename string) (*Mesh, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	m, err := ReadSTL(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	if m.Name == "" {
+		m.Name = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	}
+	return m, nil
+}
+
+// ReadSTL parses STL data, telling binary from ASCII by the file size
+// since binary files may start with "solid" too. Zero facet normals are
+// replaced with the geometric normal.
+func ReadSTL(r io.Reader) (*Mesh, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &stlBuilder{mesh: &Mesh{}, cache: make(map[[6]float32]uint32)}
+	if len(data) >= 84 && 84+50*int(binary.LittleEndian.Uint32(data[80:])) == len(data) {
+		readBinarySTL(data, b)
+	} else if err := readASCIISTL(data, b); err != nil {
+		return nil, err
+	}
+
+	b.mesh.Groups = []Group{{Material: -1, Count: len(b.mesh.Indices)}}
+	return b.mesh, nil
+}
+
+func readBinarySTL(data []byte, b *stlBuilder) {
+	f := func(off int) float32 {
+		return math.Float32frombits(binary.LittleEndian.Uint32(data[off:]))
+	}
+
+	count := int(binary.LittleEndian.Uint32(data[80:]))
+	for t := 0; t < count; t++ {
+		off := 84 + t*50
+		n := [3]float32{f(off), f(off + 4), f(off + 8)}
+		var v [3][3]float32
+		for i := range v {
+			o := off + 12 + i*12
+			v[i] = [3]float32{f(o), f(o + 4), f(o + 8)}
+		}
+		b.facet(n, v)
+	}
+}
+
+func readASCIISTL(data []byte, b *stlBuilder) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	line := 0
+	errorf := func(format string, a ...interface{}) error {
+		return fmt.Errorf("line %d: %s", line, fmt.Sprintf(format, a...))
+	}
+	floats := func(f []string) (v [3]float32, err error) {
+		if len(f) != 3 {
+			return v, errorf("expected 3 values")
+		}
+		for i, s := range f {
+			x, err := strconv.ParseFloat(s, 32)
+			if err != nil {
+				return v, errorf("bad number %q", s)
+			}
+			v[i] = float32(x)
+		}
+		return v, nil
+	}
+
+	var normal [3]float32
+	var verts [][3]float32
+	inFacet := false
+	solid := false
+	for scanner.Scan() {
+		line++
+		f := strings.Fields(scanner.Text())
+		if len(f) == 0 {
+			continue
+		}
+
+		var err error
+		switch f[0] {
+		case "solid":
+			solid = true
+			if len(f) > 1 && b.mesh.Name == "" {
+				b.mesh.Name = strings.Join(f[1:], " ")
+			}
+		case "facet":
+			if len(f) < 2 || f[1] != "normal" {
+				return errorf("expected facet normal")
+			}
+			if normal, err = floats(f[2:]); err != nil {
+				return err
+			}
+			verts = verts[:0]
+			inFacet = true
+		case "vertex":
+			if !inFacet {
+				return errorf("vertex outside facet")
+			}
+			v, err := floats(f[1:])
+			if err != nil {
+				return err
+			}
+			verts = append(verts, v)
+		case "endfacet":
+			if len(verts) != 3 {
+				return errorf("facet with %d vertices", len(verts))
+			}
+			b.facet(normal, [3][3]float32{verts[0], verts[1], verts[2]})
+			inFacet = false
+		case "outer", "endloop", "endsolid":
+		default:
+			return errorf("unexpected %q", f[0])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if !solid {
+		return errors.New("not an STL file")
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package mesh
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// stlBinary builds a binary STL from facets of a normal and three corners.
+func stlBinary(header string, facets ...[12]float32) string {
+	var b bytes.Buffer
+	b.WriteString(header)
+	b.Write(make([]byte, 80-len(header)))
+	binary.Write(&b, binary.LittleEndian, uint32(len(facets)))
+	for _, f := range facets {
+		binary.Write(&b, binary.LittleEndian, f)
+		b.Write([]byte{0, 0})
+	}
+	return b.String()
+}
+
+func TestReadSTL(t *testing.T) {
+	facet := func(normal, a, b, c string) string {
+		return "facet normal " + normal + "\n outer loop\n  vertex " + a + "\n  vertex " + b + "\n  vertex " + c + "\n endloop\nendfacet\n"
+	}
+	square := facet("0 0 1", "0 0 0", "1 0 0", "1 1 0") + facet("0 0 1", "0 0 0", "1 1 0", "0 1 0")
+
+	tests := []struct {
+		name      string
+		src       string
+		mesh      string
+		vertices  int
+		triangles int
+		err       string
+	}{
+		{"ascii", "solid square\n" + square + "endsolid square\n", "square", 4, 2, ""},
+		{"zero normals", "solid\n" + facet("0 0 0", "0 0 0", "1 0 0", "1 1 0") + "endsolid\n", "", 3, 1, ""},
+		{"corners split by normal", "solid\n" + facet("0 0 1", "0 0 0", "1 0 0", "0 1 0") + facet("0 1 0", "0 0 0", "0 0 1", "1 0 0") + "endsolid\n", "", 6, 2, ""},
+		{"binary", stlBinary("binary", [12]float32{0, 0, 1, 0, 0, 0, 1, 0, 0, 0, 1, 0}), "", 3, 1, ""},
+		{"binary starting with solid", stlBinary("solid but binary",
+			[12]float32{0, 0, 1, 0, 0, 0, 1, 0, 0, 1, 1, 0}, [12]float32{0, 0, 1, 0, 0, 0, 1, 1, 0, 0, 1, 0}), "", 4, 2, ""},
+		{"empty binary", stlBinary("nothing"), "", 0, 0, ""},
+
+		{"not stl", "hello\n", "", 0, 0, "line 1: unexpected \"hello\""},
+		{"no solid", square, "", 0, 0, "not an STL file"},
+		{"vertex outside facet", "solid\nvertex 0 0 0\n", "", 0, 0, "line 2: vertex outside facet"},
+		{"two corners", "solid\nfacet normal 0 0 1\nouter loop\nvertex 0 0 0\nvertex 1 0 0\nendloop\nendfacet\n", "", 0, 0, "line 7: facet with 2 vertices"},
+		{"bad number", "solid\nfacet normal 0 0 one\n", "", 0, 0, "line 2: bad number"},
+		{"short normal", "solid\nfacet normal 0 1\n", "", 0, 0, "line 2: expected 3 values"},
+	}
+	for _, tt := range tests {
+		m, err := ReadSTL(strings.NewReader(tt.src))
+		if tt.err != "" {
+			if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+				t.Errorf("%s: error %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if m.Name != tt.mesh || m.VertexCount() != tt.vertices || m.TriangleCount() != tt.triangles {
+			t.Errorf("%s: %q with %d vertices and %d triangles, want %q with %d and %d",
+				tt.name, m.Name, m.VertexCount(), m.TriangleCount(), tt.mesh, tt.vertices, tt.triangles)
+		}
+		for i := 0; i+2 < len(m.Indices); i += 3 {
+			fn := faceNormal(m.Positions, m.Indices[i], m.Indices[i+1], m.Indices[i+2])
+			if dot(fn, vec3(m.Normals, m.Indices[i])) <= 0 {
+				t.Errorf("%s: triangle %d is wound against its normal", tt.name, i/3)
+			}
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package mesh
+
+import (
+	"errors"
+	"math"
+)
+
+/*
+Tangents follow the MikkTSpace conventions, which is what Blender, Substance
+and most bakers use, so normal maps baked elsewhere shade without seams:
+
+  - per triangle, the texture space directions are normalized and carry the
+    sign of the uv area (orientation),
+  - at every vertex they are projected onto the plane of the vertex normal and
+    averaged, weighted by the corner angle,
+  - triangles of opposite orientation never share a tangent, such vertices are
+    split,
+  - the bitangent is not stored, it is sign * cross(normal, tangent) with the
+    sign in the w component.
+
+This is the common case of MikkTSpace without its handling of degenerate
+triangles beyond falling back to an arbitrary perpendicular direction.
+*/
+
+// GenerateTangents computes tangents and bitangent signs from normals and
+// texcoords, which must both be present.
+func (m *Mesh) GenerateTangents() error {
+	n := m.VertexCount()
+	if len(m.Normals) != n*3 || len(m.TexCoords) != n*2 {
+		return errors.New("tangents need normals and texcoords")
+	}
+
+	tris := m.TriangleCount()
+	dirs := make([][3]float32, tris)
+	orient := make([]bool, tris)
+	for t := 0; t < tris; t++ {
+		i := m.Indices[t*3 : t*3+3]
+		p0 := vec3(m.Positions, i[0])
+		d1 := sub(vec3(m.Positions, i[1]), p0)
+		d2 := sub(vec3(m.Positions, i[2]), p0)
+		s1 := m.TexCoords[i[1]*2] - m.TexCoords[i[0]*2]
+		t1 := m.TexCoords[i[1]*2+1] - m.TexCoords[i[0]*2+1]
+		s2 := m.TexCoords[i[2]*2] - m.TexCoords[i[0]*2]
+		t2 := m.TexCoords[i[2]*2+1] - m.TexCoords[i[0]*2+1]
+
+		area := s1*t2 - s2*t1
+		orient[t] = area > 0
+		vos := sub(scale(d1, t2), scale(d2, t1))
+		if area < 0 {
+			vos = scale(vos, -1)
+		}
+		dirs[t] = normalize(vos)
+	}
+
+	// one output vertex per (vertex, orientation)
+	type key struct {
+		vertex uint32
+		orient bool
+	}
+	vertices := make(map[key]uint32)
+	var src []uint32
+	var sums [][3]float32
+	var signs []float32
+	indices := make([]uint32, len(m.Indices))
+
+	for t := 0; t < tris; t++ {
+		for k := 0; k < 3; k++ {
+			v := m.Indices[t*3+k]
+			kk := key{v, orient[t]}
+			i, ok := vertices[kk]
+			if !ok {
+				i = uint32(len(src))
+				vertices[kk] = i
+				src = append(src, v)
+				sums = append(sums, [3]float32{})
+				sign := float32(-1)
+				if orient[t] {
+					sign = 1
+				}
+				signs = append(signs, sign)
+			}
+			indices[t*3+k] = i
+
+			// project onto the normal plane and weight by the angle between
+			// the projected edges
+			nv := vec3(m.Normals, v)
+			proj := func(a [3]float32) [3]float32 {
+				return normalize(sub(a, scale(nv, dot(nv, a))))
+			}
+			p := vec3(m.Positions, v)
+			e1 := proj(sub(vec3(m.Positions, m.Indices[t*3+(k+1)%3]), p))
+			e2 := proj(sub(vec3(m.Positions, m.Indices[t*3+(k+2)%3]), p))
+			c := float64(dot(e1, e2))
+			w := float32(math.Acos(math.Max(-1, math.Min(1, c))))
+			sums[i] = add(sums[i], scale(proj(dirs[t]), w))
+		}
+	}
+
+	m.Tangents = nil
+	m.remap(src, indices)
+	m.Tangents = make([]float32, 0, len(src)*4)
+	for i, s := range sums {
+		nv := vec3(m.Normals, uint32(i))
+		t := normalize(s)
+		if t == ([3]float32{}) {
+			t = perpendicular(nv)
+		}
+		m.Tangents = append(m.Tangents, t[0], t[1], t[2], signs[i])
+	}
+	return nil
+}
+
+// perpendicular returns some unit vector perpendicular to n.
+func perpendicular(n [3]float32) [3]float32 {
+	a := [3]float32{1, 0, 0}
+	if abs(n[0]) > 0.9 {
+		a = [3]float32{0, 1, 0}
+	}
+	return normalize(cross(a, n))
+}
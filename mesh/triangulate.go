@@ -0,0 +1,137 @@
+package mesh
+
+// triangulate splits a polygon given by vertex indices into triangles with
+// the winding of the polygon. Convex polygons become a fan, concave ones are
+// ear clipped in the plane of the polygon.
+func triangulate(positions []float32, poly []uint32) [][3]uint32 {
+	n := len(poly)
+	if n < 3 {
+		return nil
+	}
+	if n == 3 {
+		return [][3]uint32{{poly[0], poly[1], poly[2]}}
+	}
+
+	// Newell's method gives a robust normal for non-planar polygons
+	var normal [3]float32
+	for i := range poly {
+		a := vec3(positions, poly[i])
+		b := vec3(positions, poly[(i+1)%n])
+		normal[0] += (a[1] - b[1]) * (a[2] + b[2])
+		normal[1] += (a[2] - b[2]) * (a[0] + b[0])
+		normal[2] += (a[0] - b[0]) * (a[1] + b[1])
+	}
+
+	// project onto the plane most perpendicular to the normal
+	u, v := 0, 1
+	switch {
+	case abs(normal[0]) >= abs(normal[1]) && abs(normal[0]) >= abs(normal[2]):
+		u, v = 1, 2
+	case abs(normal[1]) >= abs(normal[2]):
+		u, v = 2, 0
+	}
+	pts := make([][2]float32, n)
+	for i, idx := range poly {
+		p := vec3(positions, idx)
+		pts[i] = [2]float32{p[u], p[v]}
+	}
+
+	// area sign tells us which turn direction is convex
+	var area float32
+	for i := range pts {
+		j := (i + 1) % n
+		area += pts[i][0]*pts[j][1] - pts[j][0]*pts[i][1]
+	}
+	if area == 0 {
+		return fan(poly)
+	}
+	sign := float32(1)
+	if area < 0 {
+		sign = -1
+	}
+
+	convex := true
+	for i := range pts {
+		if sign*cross2(pts[i], pts[(i+1)%n], pts[(i+2)%n]) < 0 {
+			convex = false
+			break
+		}
+	}
+	if convex {
+		return fan(poly)
+	}
+
+	remain := make([]int, n)
+	for i := range remain {
+		remain[i] = i
+	}
+
+	tris := make([][3]uint32, 0, n-2)
+	for len(remain) > 3 {
+		found := false
+		for i := range remain {
+			prev := remain[(i+len(remain)-1)%len(remain)]
+			cur := remain[i]
+			next := remain[(i+1)%len(remain)]
+
+			if sign*cross2(pts[prev], pts[cur], pts[next]) <= 0 {
+				continue
+			}
+
+			ear := true
+			for _, k := range remain {
+				if k == prev || k == cur || k == next {
+					continue
+				}
+				if inTriangle(pts[k], pts[prev], pts[cur], pts[next], sign) {
+					ear = false
+					break
+				}
+			}
+			if !ear {
+				continue
+			}
+
+			tris = append(tris, [3]uint32{poly[prev], poly[cur], poly[next]})
+			remain = append(remain[:i], remain[i+1:]...)
+			found = true
+			break
+		}
+
+		if !found {
+			// self-intersecting or degenerate, finish with a fan
+			rest := make([]uint32, len(remain))
+			for i, k := range remain {
+				rest[i] = poly[k]
+			}
+			return append(tris, fan(rest)...)
+		}
+	}
+
+	return append(tris, [3]uint32{poly[remain[0]], poly[remain[1]], poly[remain[2]]})
+}
+
+func fan(poly []uint32) [][3]uint32 {
+	tris := make([][3]uint32, 0, len(poly)-2)
+	for i := 1; i+1 < len(poly); i++ {
+		tris = append(tris, [3]uint32{poly[0], poly[i], poly[i+1]})
+	}
+	return tris
+}
+
+func cross2(a, b, c [2]float32) float32 {
+	return (b[0]-a[0])*(c[1]-a[1]) - (b[1]-a[1])*(c[0]-a[0])
+}
+
+func inTriangle(p, a, b, c [2]float32, sign float32) bool {
+	return sign*cross2(a, b, p) >= 0 &&
+		sign*cross2(b, c, p) >= 0 &&
+		sign*cross2(c, a, p) >= 0
+}
+
+func abs(f float32) float32 {
+	if f < 0 {
+		return -f
+	}
+	return f
+}
@@ -0,0 +1,61 @@
+package scene
+
+import "github.com/ginuerzh/anton-gocode/gfx"
+
+// Camera is a projection. It looks down the -z axis of its node, with +y
+// up, so the view matrix is the inverse of the node world matrix.
+type Camera struct {
+	Fovy      float32 // vertical field of view in degrees, 0 for orthographic
+	Height    float32 // of the orthographic view volume
+	Near, Far float32
+}
+
+// NewCamera creates a perspective camera.
+func NewCamera(fovy, near, far float32) *Camera {
+	return &Camera{Fovy: fovy, Near: near, Far: far}
+}
+
+// Projection returns the projection matrix for a viewport of the given
+// width / height.
+func (c *Camera) Projection(aspect float32) [16]float32 {
+	if c.Fovy <= 0 {
+		h := c.Height / 2
+		return gfx.Ortho(-h*aspect, h*aspect, -h, h, c.Near, c.Far)
+	}
+	return gfx.Perspective(c.Fovy, aspect, c.Near, c.Far)
+}
+
+// View returns the view matrix of a camera at node n.
+func View(n *Node) [16]float32 {
+	return gfx.InvertMat4(n.World())
+}
+
+// LookAt turns n, keeping its position, so that its -z axis points at
+// target in world space. Parents are assumed to be unrotated and
+// unscaled, as for a camera hanging off the root.
+func (n *Node) LookAt(target, up [3]float32) {
+	p := n.WorldPosition()
+	m := gfx.InvertMat4(gfx.LookAt(p, target, up))
+	n.SetRotation(matrixRotation(m))
+}
+
+// matrixRotation returns the rotation of an orthonormal matrix.
+func matrixRotation(m [16]float32) [4]float32 {
+	var q [4]float32
+	trace := m[0] + m[5] + m[10]
+	switch {
+	case trace > 0:
+		s := sqrt(trace+1) * 2
+		q = [4]float32{(m[6] - m[9]) / s, (m[8] - m[2]) / s, (m[1] - m[4]) / s, s / 4}
+	case m[0] > m[5] && m[0] > m[10]:
+		s := sqrt(1+m[0]-m[5]-m[10]) * 2
+		q = [4]float32{s / 4, (m[4] + m[1]) / s, (m[8] + m[2]) / s, (m[6] - m[9]) / s}
+	case m[5] > m[10]:
+		s := sqrt(1+m[5]-m[0]-m[10]) * 2
+		q = [4]float32{(m[4] + m[1]) / s, s / 4, (m[9] + m[6]) / s, (m[8] - m[2]) / s}
+	default:
+		s := sqrt(1+m[10]-m[0]-m[5]) * 2
+		q = [4]float32{(m[8] + m[2]) / s, (m[9] + m[6]) / s, s / 4, (m[1] - m[4]) / s}
+	}
+	return q
+}
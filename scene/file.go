@@ -0,0 +1,591 @@
+package scene
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ginuerzh/anton-gocode/gfx"
+	"github.com/ginuerzh/anton-gocode/mesh"
+	"github.com/ginuerzh/anton-gocode/texture"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+)
+
+/* JSON schema of a scene file. Resources are listed by name, nodes refer
+to them by name. Paths are relative to the file.
+
+	{
+	  "ambient": [0.1, 0.1, 0.1],
+	  "camera": "eye",
+	  "programs": [{"name": "phong", "vertex": "vs.glsl", "fragment": "fs.glsl"}],
+	  "meshes": [{"name": "ball", "primitive": "sphere", "radius": 0.5},
+	             {"name": "bunny", "file": "bunny.obj"}],
+	  "materials": [{"name": "red", "program": "phong", "phong": {"diffuse": [0.8, 0.1, 0.1]}}],
+	  "nodes": [{"name": "eye", "translation": [0, 1, 5], "camera": {"fovy": 60}},
+	            {"name": "ball", "mesh": "ball", "material": "red", "children": [...]}]
+	}
+*/
+
+type fileDef struct {
+	Ambient   *[3]float32   `json:"ambient,omitempty"`
+	Camera    string        `json:"camera,omitempty"` // node name, the first camera if empty
+	Programs  []programDef  `json:"programs,omitempty"`
+	Meshes    []meshDef     `json:"meshes,omitempty"`
+	Materials []materialDef `json:"materials,omitempty"`
+	Nodes     []nodeDef     `json:"nodes,omitempty"`
+}
+
+type programDef struct {
+	Name     string `json:"name"`
+	Vertex   string `json:"vertex"`
+	Fragment string `json:"fragment"`
+}
+
+// meshDef is a mesh file, anything mesh.Load reads, or a primitive of the
+// mesh package with its parameters. Zero parameters take defaults.
+type meshDef struct {
+	Name      string  `json:"name"`
+	File      string  `json:"file,omitempty"`
+	Primitive string  `json:"primitive,omitempty"` // plane, cube, sphere, icosphere, cylinder, cone, torus, capsule
+	Size      float32 `json:"size,omitempty"`      // cube edge, plane width
+	Depth     float32 `json:"depth,omitempty"`     // plane
+	Radius    float32 `json:"radius,omitempty"`    // of the round ones, the major radius of a torus
+	Minor     float32 `json:"minor,omitempty"`     // torus tube radius
+	Height    float32 `json:"height,omitempty"`
+	Segments  int     `json:"segments,omitempty"`
+	Rings     int     `json:"rings,omitempty"` // or stacks, sides, subdivisions
+}
+
+type materialDef struct {
+	Name        string   `json:"name"`
+	Program     string   `json:"program,omitempty"`
+	DoubleSided bool     `json:"doubleSided,omitempty"`
+	Blend       bool     `json:"blend,omitempty"`
+	Phong       phongDef `json:"phong"`
+	PBR         pbrDef   `json:"pbr"`
+}
+
+type phongDef struct {
+	Ambient   [3]float32 `json:"ambient"`
+	Diffuse   [3]float32 `json:"diffuse"`
+	Specular  [3]float32 `json:"specular"`
+	Emissive  [3]float32 `json:"emissive"`
+	Shininess float32    `json:"shininess"`
+}
+
+type pbrDef struct {
+	BaseColour           [4]float32 `json:"baseColour"`
+	Metallic             float32    `json:"metallic"`
+	Roughness            float32    `json:"roughness"`
+	Emissive             [3]float32 `json:"emissive"`
+	NormalScale          float32    `json:"normalScale"`
+	OcclusionStrength    float32    `json:"occlusionStrength"`
+	AlphaCutoff          float32    `json:"alphaCutoff"`
+	BaseColourMap        string     `json:"baseColourMap,omitempty"`
+	MetallicRoughnessMap string     `json:"metallicRoughnessMap,omitempty"`
+	NormalMap            string     `json:"normalMap,omitempty"`
+	OcclusionMap         string     `json:"occlusionMap,omitempty"`
+	EmissiveMap          string     `json:"emissiveMap,omitempty"`
+}
+
+type nodeDef struct {
+	Name        string      `json:"name,omitempty"`
+	Translation *[3]float32 `json:"translation,omitempty"`
+	Rotation    *[4]float32 `json:"rotation,omitempty"` // quaternion x, y, z, w
+	Euler       *[3]float32 `json:"euler,omitempty"`    // degrees, instead of rotation
+	Scale       *[3]float32 `json:"scale,omitempty"`
+	Mesh        string      `json:"mesh,omitempty"`
+	Material    string      `json:"material,omitempty"`
+	Light       *lightDef   `json:"light,omitempty"`
+	Camera      *cameraDef  `json:"camera,omitempty"`
+	Children    []nodeDef   `json:"children,omitempty"`
+}
+
+// lightDef is a light in node space, shining down -z unless a direction
+// is given. Attenuation is derived from the range unless given.
+type lightDef struct {
+	Type        string      `json:"type"` // directional, point or spot
+	Colour      [3]float32  `json:"colour"`
+	Intensity   *float32    `json:"intensity,omitempty"`
+	Position    *[3]float32 `json:"position,omitempty"`
+	Direction   *[3]float32 `json:"direction,omitempty"`
+	Range       *float32    `json:"range,omitempty"`       // 0 never fades out`
+	Attenuation *[3]float32 `json:"attenuation,omitempty"` // constant, linear, quadratic
+	Cone        *float32    `json:"cone,omitempty"`        // outer half angle in degrees
+	InnerCone   *float32    `json:"innerCone,omitempty"`
+}
+
+type cameraDef struct {
+	Fovy   float32 `json:"fovy,omitempty"`   // degrees, 60 when neither this nor height is set
+	Height float32 `json:"height,omitempty"` // orthographic
+	Near   float32 `json:"near,omitempty"`
+	Far    float32 `json:"far,omitempty"`
+}
+
+// Scene is a node tree read from a scene file, together with the
+// programs, materials, meshes and textures it uses.
+type Scene struct {
+	Root      *Node // holds the nodes of the file
+	Camera    *Node // the node looked through, nil without a camera
+	Ambient   [3]float32
+	Programs  []*Program
+	Materials []*Material
+
+	meshes   []meshAsset
+	textures map[string]*texture.Texture // by path, sRGB ones with a * in front
+}
+
+// meshAsset is a loaded mesh with the definition it came from, for Save.
+type meshAsset struct {
+	def  meshDef // with an absolute File
+	mesh *gfx.Mesh
+}
+
+// NewScene creates an empty scene.
+func NewScene() *Scene {
+	return &Scene{
+		Root:     NewNode(""),
+		Ambient:  [3]float32{0.1, 0.1, 0.1},
+		textures: make(map[string]*texture.Texture),
+	}
+}
+
+// Program returns the program called name, or nil.
+func (s *Scene) Program(name string) *Program {
+	for _, p := range s.Programs {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// Material returns the material called name, or nil.
+func (s *Scene) Material(name string) *Material {
+	for _, m := range s.Materials {
+		if m.Name == name {
+			return m
+		}
+	}
+	return nil
+}
+
+// Mesh returns the mesh called name, or nil.
+func (s *Scene) Mesh(name string) *gfx.Mesh {
+	for _, m := range s.meshes {
+		if m.def.Name == name {
+			return m.mesh
+		}
+	}
+	return nil
+}
+
+// Render draws the scene through its camera with r, using its ambient
+// light.
+func (s *Scene) Render(r *Renderer, aspect float32) {
+	if s.Camera == nil {
+		return
+	}
+	r.Lighting.Ambient = s.Ambient
+	r.Render(s.Root, s.Camera, aspect)
+}
+
+// Delete frees the programs, meshes and textures of the scene.
+func (s *Scene) Delete() {
+	for _, p := range s.Programs {
+		p.Delete()
+	}
+	for _, m := range s.meshes {
+		m.mesh.Delete()
+	}
+	for _, t := range s.textures {
+		t.Delete()
+	}
+	s.Programs, s.meshes = nil, nil
+	s.textures = make(map[string]*texture.Texture)
+}
+
+// sceneLoader reads one file into a scene.
+type sceneLoader struct {
+	jsonFile
+	dir   string
+	scene *Scene
+}
+
+// Load reads a scene file and creates everything in it. Errors point at
+// the place in the file they are about.
+func Load(filename string) (*Scene, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := filepath.Abs(filepath.Dir(filename))
+	if err != nil {
+		return nil, err
+	}
+	l := &sceneLoader{jsonFile: jsonFile{name: filename, data: data}, dir: dir, scene: NewScene()}
+	if err := l.load(); err != nil {
+		l.scene.Delete()
+		return nil, err
+	}
+	return l.scene, nil
+}
+
+// path resolves a path of the file.
+func (l *sceneLoader) path(p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(l.dir, filepath.FromSlash(p))
+}
+
+func (l *sceneLoader) load() error {
+	root, err := l.root()
+	if err != nil {
+		return err
+	}
+	members, err := l.members(root)
+	if err != nil {
+		return err
+	}
+	var top struct {
+		fileDef
+		// the lists are gone through one by one, for their positions
+		Programs  json.RawMessage `json:"programs"`
+		Meshes    json.RawMessage `json:"meshes"`
+		Materials json.RawMessage `json:"materials"`
+		Nodes     json.RawMessage `json:"nodes"`
+	}
+	if err := l.decode(root, &top); err != nil {
+		return err
+	}
+	if top.Ambient != nil {
+		l.scene.Ambient = *top.Ambient
+	}
+
+	for _, step := range []struct {
+		key  string
+		load func(value) error
+	}{
+		{"programs", l.program},
+		{"meshes", l.mesh},
+		{"materials", l.material},
+		{"nodes", func(v value) error { return l.node(v, l.scene.Root) }},
+	} {
+		list, err := l.elements(members[step.key])
+		if err != nil {
+			return err
+		}
+		for _, v := range list {
+			if err := step.load(v); err != nil {
+				return err
+			}
+		}
+	}
+
+	if top.Camera != "" {
+		l.scene.Camera = l.scene.Root.Find(top.Camera)
+		if l.scene.Camera == nil || l.scene.Camera.Camera == nil {
+			return l.errorf(members["camera"].pos, "no camera node %q", top.Camera)
+		}
+	} else {
+		l.scene.Root.Walk(func(n *Node) bool {
+			if l.scene.Camera == nil && n.Camera != nil {
+				l.scene.Camera = n
+			}
+			return l.scene.Camera == nil
+		})
+	}
+	return nil
+}
+
+// named checks the name of a resource.
+func (l *sceneLoader) named(v value, kind, name string, taken bool) error {
+	if name == "" {
+		return l.errorf(v.pos, "%s without a name", kind)
+	}
+	if taken {
+		return l.errorf(v.pos, "%s %q defined twice", kind, name)
+	}
+	return nil
+}
+
+func (l *sceneLoader) program(v value) error {
+	var d programDef
+	if err := l.decode(v, &d); err != nil {
+		return err
+	}
+	if err := l.named(v, "program", d.Name, l.scene.Program(d.Name) != nil); err != nil {
+		return err
+	}
+	p, err := LoadProgram(d.Name, l.path(d.Vertex), l.path(d.Fragment))
+	if err != nil {
+		return l.errorf(v.pos, "program %q: %v", d.Name, err)
+	}
+	l.scene.Programs = append(l.scene.Programs, p)
+	return nil
+}
+
+func (l *sceneLoader) mesh(v value) error {
+	var d meshDef
+	if err := l.decode(v, &d); err != nil {
+		return err
+	}
+	if err := l.named(v, "mesh", d.Name, l.scene.Mesh(d.Name) != nil); err != nil {
+		return err
+	}
+	var m *mesh.Mesh
+	switch {
+	case d.File != "" && d.Primitive != "":
+		return l.errorf(v.pos, "mesh %q has both a file and a primitive", d.Name)
+	case d.File != "":
+		d.File = l.path(d.File)
+		var err error
+		if m, err = mesh.Load(d.File); err != nil {
+			return l.errorf(v.pos, "mesh %q: %v", d.Name, err)
+		}
+	default:
+		if m = primitive(d); m == nil {
+			return l.errorf(v.pos, "mesh %q: unknown primitive %q", d.Name, d.Primitive)
+		}
+	}
+	g, err := gfx.UploadMesh(m)
+	if err != nil {
+		return l.errorf(v.pos, "mesh %q: %v", d.Name, err)
+	}
+	l.scene.meshes = append(l.scene.meshes, meshAsset{d, g})
+	return nil
+}
+
+func or(x, def float32) float32 {
+	if x == 0 {
+		return def
+	}
+	return x
+}
+
+func orInt(x, def int) int {
+	if x == 0 {
+		return def
+	}
+	return x
+}
+
+// primitive generates the mesh of a primitive definition, nil for an
+// unknown one.
+func primitive(d meshDef) *mesh.Mesh {
+	switch d.Primitive {
+	case "plane":
+		w := or(d.Size, 1)
+		s := orInt(d.Segments, 1)
+		return mesh.Plane(w, or(d.Depth, w), s, s)
+	case "cube":
+		return mesh.Cube(or(d.Size, 1), orInt(d.Segments, 1))
+	case "sphere":
+		s := orInt(d.Segments, 32)
+		return mesh.UVSphere(or(d.Radius, 1), s, orInt(d.Rings, s/2))
+	case "icosphere":
+		return mesh.Icosphere(or(d.Radius, 1), orInt(d.Rings, 3))
+	case "cylinder":
+		return mesh.Cylinder(or(d.Radius, 0.5), or(d.Height, 1), orInt(d.Segments, 32), orInt(d.Rings, 1), true)
+	case "cone":
+		return mesh.Cone(or(d.Radius, 0.5), or(d.Height, 1), orInt(d.Segments, 32), orInt(d.Rings, 1), true)
+	case "torus":
+		return mesh.Torus(or(d.Radius, 1), or(d.Minor, 0.25), orInt(d.Segments, 48), orInt(d.Rings, 24))
+	case "capsule":
+		return mesh.Capsule(or(d.Radius, 0.5), or(d.Height, 1), orInt(d.Segments, 32), orInt(d.Rings, 8))
+	}
+	return nil
+}
+
+func (l *sceneLoader) material(v value) error {
+	members, err := l.members(v)
+	if err != nil {
+		return err
+	}
+	d := materialDef{Phong: phongDefOf(gfx.DefaultMaterial()), PBR: pbrDefOf(gfx.DefaultPBRMaterial(), nil)}
+	if err := l.decode(v, &d); err != nil {
+		return err
+	}
+	if err := l.named(v, "material", d.Name, l.scene.Material(d.Name) != nil); err != nil {
+		return err
+	}
+	m := &Material{Name: d.Name, DoubleSided: d.DoubleSided, Blend: d.Blend}
+	if d.Program != "" {
+		if m.Program = l.scene.Program(d.Program); m.Program == nil {
+			return l.errorf(members["program"].pos, "material %q: unknown program %q", d.Name, d.Program)
+		}
+	}
+	p := d.Phong
+	m.Phong = gfx.Material{
+		Ambient: p.Ambient, Diffuse: p.Diffuse, Specular: p.Specular,
+		Emissive: p.Emissive, Shininess: p.Shininess,
+	}
+
+	r := d.PBR
+	m.PBR = gfx.PBRMaterial{
+		BaseColour: r.BaseColour, Metallic: r.Metallic, Roughness: r.Roughness,
+		Emissive: r.Emissive, NormalScale: r.NormalScale,
+		OcclusionStrength: r.OcclusionStrength, AlphaCutoff: r.AlphaCutoff,
+	}
+	var maps map[string]value
+	if pbr, ok := members["pbr"]; ok {
+		if maps, err = l.members(pbr); err != nil {
+			return err
+		}
+	}
+	for _, t := range []struct {
+		key  string
+		file string
+		srgb bool
+		dst  **texture.Texture
+	}{
+		{"baseColourMap", r.BaseColourMap, true, &m.PBR.BaseColourMap},
+		{"metallicRoughnessMap", r.MetallicRoughnessMap, false, &m.PBR.MetallicRoughnessMap},
+		{"normalMap", r.NormalMap, false, &m.PBR.NormalMap},
+		{"occlusionMap", r.OcclusionMap, false, &m.PBR.OcclusionMap},
+		{"emissiveMap", r.EmissiveMap, true, &m.PBR.EmissiveMap},
+	} {
+		if t.file == "" {
+			continue
+		}
+		tex, err := l.texture(l.path(t.file), t.srgb)
+		if err != nil {
+			return l.errorf(maps[t.key].pos, "material %q: %v", d.Name, err)
+		}
+		*t.dst = tex
+	}
+	l.scene.Materials = append(l.scene.Materials, m)
+	return nil
+}
+
+// texture loads a texture once, colour maps as sRGB.
+func (l *sceneLoader) texture(path string, srgb bool) (*texture.Texture, error) {
+	key := path
+	if srgb {
+		key = "*" + path
+	}
+	if t, ok := l.scene.textures[key]; ok {
+		return t, nil
+	}
+	opts := texture.DefaultOptions()
+	opts.SRGB = srgb
+	t, err := texture.Load(path, opts)
+	if err != nil {
+		return nil, err
+	}
+	l.scene.textures[key] = t
+	return t, nil
+}
+
+func (l *sceneLoader) node(v value, parent *Node) error {
+	members, err := l.members(v)
+	if err != nil {
+		return err
+	}
+	var d struct {
+		nodeDef
+		Children json.RawMessage `json:"children"`
+	}
+	if err := l.decode(v, &d); err != nil {
+		return err
+	}
+
+	n := NewNode(d.Name)
+	if d.Translation != nil {
+		n.SetTranslation(*d.Translation)
+	}
+	switch {
+	case d.Rotation != nil && d.Euler != nil:
+		return l.errorf(v.pos, "node %q has both a rotation and euler angles", d.Name)
+	case d.Rotation != nil:
+		n.SetRotation(*d.Rotation)
+	case d.Euler != nil:
+		n.SetEuler(d.Euler[0], d.Euler[1], d.Euler[2])
+	}
+	if d.Scale != nil {
+		n.SetScale(*d.Scale)
+	}
+
+	if d.Mesh != "" {
+		if n.Mesh = l.scene.Mesh(d.Mesh); n.Mesh == nil {
+			return l.errorf(members["mesh"].pos, "unknown mesh %q", d.Mesh)
+		}
+	}
+	if d.Material != "" {
+		if n.Material = l.scene.Material(d.Material); n.Material == nil {
+			return l.errorf(members["material"].pos, "unknown material %q", d.Material)
+		}
+	}
+	if d.Light != nil {
+		light, err := d.Light.light()
+		if err != nil {
+			return l.errorf(members["light"].pos, "%v", err)
+		}
+		n.Light = &light
+	}
+	if d.Camera != nil {
+		c := d.Camera
+		if c.Fovy == 0 && c.Height == 0 {
+			c.Fovy = 60
+		}
+		n.Camera = &Camera{Fovy: c.Fovy, Height: c.Height, Near: or(c.Near, 0.1), Far: or(c.Far, 100)}
+	}
+	parent.Add(n)
+
+	children, err := l.elements(members["children"])
+	if err != nil {
+		return err
+	}
+	for _, c := range children {
+		if err := l.node(c, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func radians(deg float32) float32 {
+	return deg * math.Pi / 180
+}
+
+func (d *lightDef) light() (gfx.Light, error) {
+	dir := [3]float32{0, 0, -1}
+	if d.Direction != nil {
+		dir = *d.Direction
+	}
+	rng, cone := float32(10), float32(30)
+	if d.Range != nil {
+		rng = *d.Range
+	}
+	if d.Cone != nil {
+		cone = *d.Cone
+	}
+	var l gfx.Light
+	switch d.Type {
+	case "directional":
+		l = gfx.NewDirectionalLight(dir, d.Colour)
+	case "point":
+		l = gfx.NewPointLight([3]float32{}, d.Colour, rng)
+	case "spot":
+		l = gfx.NewSpotLight([3]float32{}, dir, d.Colour, rng, radians(cone))
+		if d.InnerCone != nil {
+			l.InnerCone = radians(*d.InnerCone)
+		}
+	default:
+		return l, fmt.Errorf("unknown light type %q", d.Type)
+	}
+	if d.Intensity != nil {
+		l.Intensity = *d.Intensity
+	}
+	if d.Position != nil {
+		l.Position = *d.Position
+	}
+	if rng == 0 {
+		l.Linear, l.Quadratic = 0, 0
+	}
+	if a := d.Attenuation; a != nil {
+		l.Constant, l.Linear, l.Quadratic = a[0], a[1], a[2]
+	}
+	return l, nil
+}
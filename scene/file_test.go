@@ -0,0 +1,44 @@
+package scene
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLoadErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scene")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name, data string
+		pos        string // line:column
+		message    string // start of the message after the position
+	}{
+		// the json package reports syntax and type errors just past the
+		// offending token
+		{"syntax", "{\n  \"nodes\": [\n}", "3:2", "invalid character '}'"},
+		{"unexpected end", "{\n  \"nodes\": [", "2:13", "unexpected end"},
+		{"leading space", "\n\n   {\"nodes\": 1}", "3:14", "array expected"},
+		{"unknown field", "{\n  \"nodes\": [{\"colour\": 1}]\n}", "2:13", "json: unknown field \"colour\""},
+		{"wrong type", "{\n  \"nodes\": [{\"scale\": \"big\"}]\n}", "2:28", "scale: string where [3]float32 was expected"},
+		{"unknown material", "{\n  \"nodes\": [\n    {\"name\": \"a\", \"material\": \"blue\"}\n  ]\n}", "3:31", "unknown material \"blue\""},
+		{"nested light", "{\"nodes\": [{\"children\": [{}, {\"light\": {\"type\": \"laser\"}}]}]}", "1:40", "unknown light type \"laser\""},
+		{"duplicate material", "{\"materials\": [{\"name\": \"a\"},\n {\"name\": \"a\"}]}", "2:2", "material \"a\""},
+	}
+	for _, tt := range tests {
+		file := writeFile(t, dir, "bad.json", tt.data)
+		_, err := Load(file)
+		if err == nil {
+			t.Errorf("%s: no error", tt.name)
+			continue
+		}
+		if want := file + ":" + tt.pos + ": " + tt.message; !strings.HasPrefix(err.Error(), want) {
+			t.Errorf("%s: error %q, want %q...", tt.name, err, want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package scene
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// value is a JSON value of a file with the byte offset it starts at, so
+// errors about it can point into the file.
+type value struct {
+	raw json.RawMessage
+	pos int
+}
+
+// jsonFile decodes a file value by value, keeping track of positions.
+type jsonFile struct {
+	name string
+	data []byte
+}
+
+// errorf returns an error at byte offset pos, as file:line:column.
+func (f *jsonFile) errorf(pos int, format string, a ...interface{}) error {
+	if pos > len(f.data) {
+		pos = len(f.data)
+	}
+	line, col := 1, 1
+	for _, c := range f.data[:pos] {
+		if c == '\n' {
+			line, col = line+1, 1
+		} else {
+			col++
+		}
+	}
+	return fmt.Errorf("%s:%d:%d: %s", f.name, line, col, fmt.Sprintf(format, a...))
+}
+
+// jsonError turns an error of the json package decoding v into one with a
+// position, the exact one where the package reports it.
+func (f *jsonFile) jsonError(v value, err error) error {
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		return f.errorf(v.pos+int(e.Offset), "%v", e)
+	case *json.UnmarshalTypeError:
+		return f.errorf(v.pos+int(e.Offset), "%s: %s where %s was expected", e.Field, e.Value, e.Type)
+	}
+	return f.errorf(v.pos, "%v", err)
+}
+
+// root returns the whole file as a value, checking its syntax.
+func (f *jsonFile) root() (value, error) {
+	start := len(f.data) - len(bytes.TrimLeft(f.data, " \t\r\n"))
+	v := value{raw: bytes.TrimSpace(f.data), pos: start}
+	if !json.Valid(v.raw) {
+		var x interface{}
+		return v, f.jsonError(v, json.Unmarshal(v.raw, &x))
+	}
+	return v, nil
+}
+
+// decode decodes v into out, rejecting unknown fields.
+func (f *jsonFile) decode(v value, out interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(v.raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(out); err != nil {
+		return f.jsonError(v, err)
+	}
+	return nil
+}
+
+// members returns the members of the object v by key.
+func (f *jsonFile) members(v value) (map[string]value, error) {
+	dec := json.NewDecoder(bytes.NewReader(v.raw))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return nil, f.errorf(v.pos, "object expected")
+	}
+	m := make(map[string]value)
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, f.jsonError(v, err)
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, f.jsonError(v, err)
+		}
+		m[t.(string)] = value{raw, v.pos + int(dec.InputOffset()) - len(raw)}
+	}
+	return m, nil
+}
+
+// elements returns the elements of the array v, an absent value being an
+// empty array.
+func (f *jsonFile) elements(v value) ([]value, error) {
+	if len(v.raw) == 0 || string(v.raw) == "null" {
+		return nil, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(v.raw))
+	if t, err := dec.Token(); err != nil || t != json.Delim('[') {
+		return nil, f.errorf(v.pos, "array expected")
+	}
+	var list []value
+	for dec.More() {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, f.jsonError(v, err)
+		}
+		list = append(list, value{raw, v.pos + int(dec.InputOffset()) - len(raw)})
+	}
+	return list, nil
+}
@@ -0,0 +1,111 @@
+package scene
+
+import (
+	"github.com/ginuerzh/anton-gocode/gfx"
+	"github.com/go-gl/gl/v3.3-core/gl"
+)
+
+// Material is how a mesh looks. The renderer hands a program the
+// parameters it understands: Phong to programs including lighting.glsl,
+// PBR to those including pbr.glsl.
+type Material struct {
+	Name        string
+	Program     *Program // nil for the default program of the renderer
+	Phong       gfx.Material
+	PBR         gfx.PBRMaterial
+	DoubleSided bool // drawn without back face culling
+	Blend       bool // alpha blended after the opaque meshes, back to front
+}
+
+// NewMaterial creates a material with the default parameters of both
+// shading models.
+func NewMaterial(name string) *Material {
+	return &Material{Name: name, Phong: gfx.DefaultMaterial(), PBR: gfx.DefaultPBRMaterial()}
+}
+
+// Program is a shader program with the locations of the uniforms the
+// renderer sets. The vertex shader may use any of
+//
+//	uniform mat4 model, view, proj, model_view;
+//	uniform mat3 normal_matrix;
+//
+// and the renderer sets those that are there.
+type Program struct {
+	Name                     string
+	ID                       uint32
+	VertexFile, FragmentFile string // set by LoadProgram
+
+	model, view, proj, modelView, normalMatrix int32
+	phong                                      gfx.MaterialUniforms
+	pbr                                        gfx.PBRUniforms
+	ibl                                        gfx.IBLUniforms
+	isPBR                                      bool
+}
+
+// NewProgram looks up the uniforms of a linked program and binds its
+// Lighting block, if it has one.
+func NewProgram(name string, id uint32) *Program {
+	loc := func(name string) int32 {
+		return gl.GetUniformLocation(id, gl.Str(name+"\x00"))
+	}
+	p := &Program{
+		Name:         name,
+		ID:           id,
+		model:        loc("model"),
+		view:         loc("view"),
+		proj:         loc("proj"),
+		modelView:    loc("model_view"),
+		normalMatrix: loc("normal_matrix"),
+		phong:        gfx.NewMaterialUniforms(id),
+		pbr:          gfx.NewPBRUniforms(id),
+		ibl:          gfx.NewIBLUniforms(id),
+		isPBR:        loc("pbr_material.base_colour") >= 0,
+	}
+	/* unlit programs have no block, which is fine */
+	gfx.BindLighting(id)
+	return p
+}
+
+// LoadProgram compiles a program from shader files, see gfx.LoadProgram.
+func LoadProgram(name, vertexFile, fragmentFile string) (*Program, error) {
+	id, err := gfx.LoadProgram(vertexFile, fragmentFile)
+	if err != nil {
+		return nil, err
+	}
+	p := NewProgram(name, id)
+	p.VertexFile, p.FragmentFile = vertexFile, fragmentFile
+	return p, nil
+}
+
+// use makes p current with the camera matrices.
+func (p *Program) use(view, proj [16]float32, ibl *gfx.IBL) {
+	gl.UseProgram(p.ID)
+	gl.UniformMatrix4fv(p.view, 1, false, &view[0])
+	gl.UniformMatrix4fv(p.proj, 1, false, &proj[0])
+	if p.isPBR {
+		p.ibl.Set(ibl, view, gfx.PBRIBLUnit)
+	}
+}
+
+// setModel sets the matrices of one mesh.
+func (p *Program) setModel(model, view [16]float32) {
+	mv := gfx.MulMat4(view, model)
+	nm := gfx.NormalMatrix(mv)
+	gl.UniformMatrix4fv(p.model, 1, false, &model[0])
+	gl.UniformMatrix4fv(p.modelView, 1, false, &mv[0])
+	gl.UniformMatrix3fv(p.normalMatrix, 1, false, &nm[0])
+}
+
+// setMaterial uploads the parameters of m the program understands.
+func (p *Program) setMaterial(m *Material) {
+	if p.isPBR {
+		p.pbr.Set(m.PBR, gfx.PBRMaterialUnit)
+	} else {
+		p.phong.Set(m.Phong)
+	}
+}
+
+// Delete frees the program.
+func (p *Program) Delete() {
+	gl.DeleteProgram(p.ID)
+}
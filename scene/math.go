@@ -0,0 +1,65 @@
+package scene
+
+import "math"
+
+/* quaternions are x, y, z, w, matrices column-major like the rest of gfx */
+
+// AxisAngle returns the rotation by deg degrees about axis.
+func AxisAngle(axis [3]float32, deg float32) [4]float32 {
+	l := math.Sqrt(float64(axis[0]*axis[0] + axis[1]*axis[1] + axis[2]*axis[2]))
+	if l == 0 {
+		return [4]float32{0, 0, 0, 1}
+	}
+	half := float64(deg) * math.Pi / 360
+	s := float32(math.Sin(half) / l)
+	return [4]float32{axis[0] * s, axis[1] * s, axis[2] * s, float32(math.Cos(half))}
+}
+
+// Euler returns the rotation about z, then x, then y, in degrees.
+func Euler(xDeg, yDeg, zDeg float32) [4]float32 {
+	qx := AxisAngle([3]float32{1, 0, 0}, xDeg)
+	qy := AxisAngle([3]float32{0, 1, 0}, yDeg)
+	qz := AxisAngle([3]float32{0, 0, 1}, zDeg)
+	return mulQuat(qy, mulQuat(qx, qz))
+}
+
+// mulQuat returns a * b, the rotation b followed by a.
+func mulQuat(a, b [4]float32) [4]float32 {
+	return [4]float32{
+		a[3]*b[0] + a[0]*b[3] + a[1]*b[2] - a[2]*b[1],
+		a[3]*b[1] - a[0]*b[2] + a[1]*b[3] + a[2]*b[0],
+		a[3]*b[2] + a[0]*b[1] - a[1]*b[0] + a[2]*b[3],
+		a[3]*b[3] - a[0]*b[0] - a[1]*b[1] - a[2]*b[2],
+	}
+}
+
+// trs returns the matrix T * R * S.
+func trs(t [3]float32, q [4]float32, s [3]float32) [16]float32 {
+	x, y, z, w := q[0], q[1], q[2], q[3]
+	return [16]float32{
+		(1 - 2*(y*y+z*z)) * s[0], 2 * (x*y + z*w) * s[0], 2 * (x*z - y*w) * s[0], 0,
+		2 * (x*y - z*w) * s[1], (1 - 2*(x*x+z*z)) * s[1], 2 * (y*z + x*w) * s[1], 0,
+		2 * (x*z + y*w) * s[2], 2 * (y*z - x*w) * s[2], (1 - 2*(x*x+y*y)) * s[2], 0,
+		t[0], t[1], t[2], 1,
+	}
+}
+
+func transformPoint(m [16]float32, p [3]float32) [3]float32 {
+	return [3]float32{
+		m[0]*p[0] + m[4]*p[1] + m[8]*p[2] + m[12],
+		m[1]*p[0] + m[5]*p[1] + m[9]*p[2] + m[13],
+		m[2]*p[0] + m[6]*p[1] + m[10]*p[2] + m[14],
+	}
+}
+
+func transformVector(m [16]float32, v [3]float32) [3]float32 {
+	return [3]float32{
+		m[0]*v[0] + m[4]*v[1] + m[8]*v[2],
+		m[1]*v[0] + m[5]*v[1] + m[9]*v[2],
+		m[2]*v[0] + m[6]*v[1] + m[10]*v[2],
+	}
+}
+
+func sqrt(x float32) float32 {
+	return float32(math.Sqrt(float64(x)))
+}
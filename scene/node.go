@@ -0,0 +1,203 @@
+// Package scene keeps objects in a hierarchy of nodes with local
+// transforms, with meshes, lights and cameras attached, and draws them.
+package scene
+
+import (
+	"github.com/ginuerzh/anton-gocode/gfx"
+	"math"
+)
+
+// Node is an element of the scene hierarchy. Its transform is translation
+// * rotation * scale relative to the parent. The local and world matrices
+// are cached: setting a transform marks the node and everything below it
+// dirty, and they are recomputed when asked for.
+type Node struct {
+	Name     string
+	Parent   *Node
+	Children []*Node
+
+	// Components, all optional. A mesh is drawn with Material, or the
+	// default material of the renderer when nil.
+	Mesh     *gfx.Mesh
+	Material *Material
+	Light    *gfx.Light // position and direction in node space
+	Camera   *Camera
+
+	translation [3]float32
+	rotation    [4]float32 // unit quaternion x, y, z, w
+	scale       [3]float32
+
+	local, world           [16]float32
+	localDirty, worldDirty bool
+}
+
+// NewNode creates a node with an identity transform.
+func NewNode(name string) *Node {
+	return &Node{
+		Name:       name,
+		rotation:   [4]float32{0, 0, 0, 1},
+		scale:      [3]float32{1, 1, 1},
+		localDirty: true,
+		worldDirty: true,
+	}
+}
+
+// Translation returns the position relative to the parent.
+func (n *Node) Translation() [3]float32 { return n.translation }
+
+// Rotation returns the rotation quaternion x, y, z, w.
+func (n *Node) Rotation() [4]float32 { return n.rotation }
+
+// Scale returns the scale along the local axes.
+func (n *Node) Scale() [3]float32 { return n.scale }
+
+// SetTranslation moves the node relative to its parent.
+func (n *Node) SetTranslation(t [3]float32) {
+	n.translation = t
+	n.changed()
+}
+
+// SetRotation sets the rotation quaternion x, y, z, w, normalising it.
+func (n *Node) SetRotation(q [4]float32) {
+	l := float32(math.Sqrt(float64(q[0]*q[0] + q[1]*q[1] + q[2]*q[2] + q[3]*q[3])))
+	if l == 0 {
+		q, l = [4]float32{0, 0, 0, 1}, 1
+	}
+	n.rotation = [4]float32{q[0] / l, q[1] / l, q[2] / l, q[3] / l}
+	n.changed()
+}
+
+// SetEuler sets the rotation from angles in degrees about x, y and z,
+// applied in the order z, x, y like a camera that rolls, pitches and then
+// turns.
+func (n *Node) SetEuler(xDeg, yDeg, zDeg float32) {
+	n.SetRotation(Euler(xDeg, yDeg, zDeg))
+}
+
+// SetScale scales the node along its local axes.
+func (n *Node) SetScale(s [3]float32) {
+	n.scale = s
+	n.changed()
+}
+
+// SetTRS sets all three parts of the transform at once.
+func (n *Node) SetTRS(t [3]float32, r [4]float32, s [3]float32) {
+	n.translation, n.scale = t, s
+	n.SetRotation(r)
+}
+
+// Translate moves the node by d in parent space.
+func (n *Node) Translate(d [3]float32) {
+	n.SetTranslation([3]float32{n.translation[0] + d[0], n.translation[1] + d[1], n.translation[2] + d[2]})
+}
+
+// Rotate turns the node by deg degrees about axis, in node space.
+func (n *Node) Rotate(axis [3]float32, deg float32) {
+	n.SetRotation(mulQuat(n.rotation, AxisAngle(axis, deg)))
+}
+
+// changed marks the local matrix dirty and the world matrices of the node
+// and its subtree.
+func (n *Node) changed() {
+	n.localDirty = true
+	n.invalidate()
+}
+
+// invalidate marks the world matrices of the subtree dirty. A clean node
+// always has a clean parent, so a dirty node has a dirty subtree already.
+func (n *Node) invalidate() {
+	if n.worldDirty {
+		return
+	}
+	n.worldDirty = true
+	for _, c := range n.Children {
+		c.invalidate()
+	}
+}
+
+// Local returns the column-major T * R * S matrix of the node.
+func (n *Node) Local() [16]float32 {
+	if n.localDirty {
+		n.local = trs(n.translation, n.rotation, n.scale)
+		n.localDirty = false
+	}
+	return n.local
+}
+
+// World returns the node matrix including all of its parents. Only the
+// dirty part of the path to the root is recomputed.
+func (n *Node) World() [16]float32 {
+	if n.worldDirty {
+		if n.Parent != nil {
+			n.world = gfx.MulMat4(n.Parent.World(), n.Local())
+		} else {
+			n.world = n.Local()
+		}
+		n.worldDirty = false
+	}
+	return n.world
+}
+
+// WorldPosition is the origin of the node in world space.
+func (n *Node) WorldPosition() [3]float32 {
+	w := n.World()
+	return [3]float32{w[12], w[13], w[14]}
+}
+
+// WorldBounds returns the bounding box and sphere of the mesh of n in
+// world space. ok is false without a mesh or when the mesh has no bounds.
+func (n *Node) WorldBounds() (box gfx.AABB, sphere gfx.Sphere, ok bool) {
+	if n.Mesh == nil {
+		return
+	}
+	if box, sphere, ok = n.Mesh.Bounds(); !ok {
+		return
+	}
+	world := n.World()
+	return box.Transform(world), sphere.Transform(world), true
+}
+
+// Add makes c a child of n, taking it from its old parent.
+func (n *Node) Add(c *Node) {
+	if c.Parent != nil {
+		c.Parent.Remove(c)
+	}
+	c.Parent = n
+	n.Children = append(n.Children, c)
+	c.invalidate()
+}
+
+// Remove detaches c from n, c keeps its subtree.
+func (n *Node) Remove(c *Node) {
+	for i, k := range n.Children {
+		if k == c {
+			n.Children = append(n.Children[:i], n.Children[i+1:]...)
+			c.Parent = nil
+			c.invalidate()
+			return
+		}
+	}
+}
+
+// Walk visits the subtree of n depth first, parents before children.
+// Returning false from fn skips the children of a node.
+func (n *Node) Walk(fn func(n *Node) bool) {
+	if !fn(n) {
+		return
+	}
+	for _, c := range n.Children {
+		c.Walk(fn)
+	}
+}
+
+// Find returns the first node in the subtree called name, or nil.
+func (n *Node) Find(name string) *Node {
+	var found *Node
+	n.Walk(func(k *Node) bool {
+		if found == nil && k.Name == name {
+			found = k
+		}
+		return found == nil
+	})
+	return found
+}
@@ -0,0 +1,171 @@
+package scene
+
+import (
+	"fmt"
+	"github.com/ginuerzh/anton-gocode/gfx"
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"sort"
+)
+
+// Stats counts what the last Render did.
+type Stats struct {
+	Nodes  int // visited
+	Lights int // uploaded, at most gfx.MaxLights
+	Drawn  int // meshes drawn
+	Culled int // meshes outside the view
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf("%d drawn, %d culled, %d lights", s.Drawn, s.Culled, s.Lights)
+}
+
+// drawItem is a mesh queued for drawing.
+type drawItem struct {
+	node     *Node
+	world    [16]float32
+	material *Material
+	program  *Program
+	depth    float32 // view space z of the origin, more negative is further
+}
+
+// Renderer draws the meshes of a scene with the lights found in it.
+type Renderer struct {
+	Lighting *gfx.Lighting
+	Program  *Program  // for materials without a program of their own
+	Material *Material // for meshes without a material
+	IBL      *gfx.IBL  // surroundings of PBR programs, nil for none
+	Cull     bool      // skip meshes outside the view of the camera
+	Stats    Stats
+
+	frustum         gfx.Frustum
+	opaque, blended []drawItem
+}
+
+// NewRenderer creates a renderer drawing with program by default.
+func NewRenderer(program *Program) *Renderer {
+	return &Renderer{
+		Lighting: gfx.NewLighting(),
+		Program:  program,
+		Material: NewMaterial("default"),
+		Cull:     true,
+	}
+}
+
+// Render draws the subtree of root as seen from the camera at node
+// camera, into a viewport of the given width / height. Opaque meshes are
+// drawn grouped by program and material, blended ones after them from
+// back to front. The lights of the scene replace those of r.Lighting.
+// With Cull set, meshes whose bounds are outside the view are skipped.
+// Without a camera nothing is drawn.
+func (r *Renderer) Render(root, camera *Node, aspect float32) {
+	r.Stats = Stats{}
+	if root == nil || camera == nil || camera.Camera == nil {
+		return
+	}
+	view := View(camera)
+	proj := camera.Camera.Projection(aspect)
+	r.frustum = gfx.NewFrustum(gfx.MulMat4(proj, view))
+	r.opaque, r.blended = r.opaque[:0], r.blended[:0]
+	r.Lighting.Lights = r.Lighting.Lights[:0]
+
+	root.Walk(func(n *Node) bool {
+		r.Stats.Nodes++
+		if n.Light != nil {
+			l := *n.Light
+			world := n.World()
+			l.Position = transformPoint(world, l.Position)
+			l.Direction = transformVector(world, l.Direction)
+			r.Lighting.Lights = append(r.Lighting.Lights, l)
+		}
+		if n.Mesh != nil {
+			r.queue(n, view)
+		}
+		return true
+	})
+	r.Stats.Lights = len(r.Lighting.Lights)
+	if r.Stats.Lights > gfx.MaxLights {
+		r.Stats.Lights = gfx.MaxLights
+	}
+	r.Lighting.Upload(view)
+
+	sort.SliceStable(r.opaque, func(i, j int) bool {
+		a, b := r.opaque[i], r.opaque[j]
+		if a.program != b.program {
+			return a.program.ID < b.program.ID
+		}
+		return a.material.Name < b.material.Name
+	})
+	sort.SliceStable(r.blended, func(i, j int) bool {
+		return r.blended[i].depth < r.blended[j].depth
+	})
+
+	r.draw(r.opaque, view, proj)
+	if len(r.blended) > 0 {
+		gl.Enable(gl.BLEND)
+		gl.BlendFunc(gl.SRC_ALPHA, gl.ONE_MINUS_SRC_ALPHA)
+		gl.DepthMask(false)
+		r.draw(r.blended, view, proj)
+		gl.DepthMask(true)
+		gl.Disable(gl.BLEND)
+	}
+}
+
+// queue adds the mesh of n to the opaque or the blended list, unless it
+// is culled.
+func (r *Renderer) queue(n *Node, view [16]float32) {
+	if r.Cull {
+		// the sphere is quicker to test, the box often tighter
+		if box, sphere, ok := n.WorldBounds(); ok &&
+			(!r.frustum.IntersectsSphere(sphere) || !r.frustum.IntersectsBox(box)) {
+			r.Stats.Culled++
+			return
+		}
+	}
+	it := drawItem{node: n, world: n.World(), material: n.Material}
+	if it.material == nil {
+		it.material = r.Material
+	}
+	it.program = it.material.Program
+	if it.program == nil {
+		it.program = r.Program
+	}
+	if it.program == nil {
+		return
+	}
+	if it.material.Blend {
+		it.depth = transformPoint(view, [3]float32{it.world[12], it.world[13], it.world[14]})[2]
+		r.blended = append(r.blended, it)
+	} else {
+		r.opaque = append(r.opaque, it)
+	}
+}
+
+func (r *Renderer) draw(items []drawItem, view, proj [16]float32) {
+	var program *Program
+	var material *Material
+	for _, it := range items {
+		if it.program != program {
+			program, material = it.program, nil
+			program.use(view, proj, r.IBL)
+		}
+		if it.material != material {
+			material = it.material
+			program.setMaterial(material)
+		}
+		program.setModel(it.world, view)
+		if material.DoubleSided {
+			gl.Disable(gl.CULL_FACE)
+		}
+		it.node.Mesh.DrawAll()
+		if material.DoubleSided {
+			gl.Enable(gl.CULL_FACE)
+		}
+		r.Stats.Drawn++
+	}
+}
+
+// Delete frees the lighting buffer. Programs, materials and meshes
+// belong to the caller.
+func (r *Renderer) Delete() {
+	r.Lighting.Delete()
+}
@@ -0,0 +1,274 @@
+package scene
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ginuerzh/anton-gocode/gfx"
+	"github.com/ginuerzh/anton-gocode/texture"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+)
+
+// sceneSaver turns a scene back into its file definition.
+type sceneSaver struct {
+	scene     *Scene
+	dir       string
+	def       fileDef
+	programs  map[*Program]bool
+	materials map[*Material]string
+	meshes    map[*gfx.Mesh]string
+	textures  map[*texture.Texture]string
+}
+
+// Save writes the scene to a file, with paths relative to it. Programs
+// and meshes have to come from files or primitives, as loaded by Load;
+// materials and nodes made in code are saved as they are, unnamed
+// materials get a name.
+func (s *Scene) Save(filename string) error {
+	dir, err := filepath.Abs(filepath.Dir(filename))
+	if err != nil {
+		return err
+	}
+	sv := &sceneSaver{
+		scene:     s,
+		dir:       dir,
+		programs:  make(map[*Program]bool),
+		materials: make(map[*Material]string),
+		meshes:    make(map[*gfx.Mesh]string),
+		textures:  make(map[*texture.Texture]string),
+	}
+	for key, t := range s.textures {
+		if key[0] == '*' {
+			key = key[1:]
+		}
+		sv.textures[t] = key
+	}
+	if err := sv.save(); err != nil {
+		return fmt.Errorf("%s: %v", filename, err)
+	}
+
+	data, err := json.MarshalIndent(sv.def, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, append(data, '\n'), 0644)
+}
+
+// path makes a path relative to the file where possible.
+func (sv *sceneSaver) path(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		if rel, err := filepath.Rel(sv.dir, abs); err == nil {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.ToSlash(p)
+}
+
+// camera names the camera node so Load finds it again: by its name when
+// that is unique, else by leaving it out when it is the first camera.
+func (sv *sceneSaver) camera() error {
+	c := sv.scene.Camera
+	if c == nil {
+		return nil
+	}
+	if c.Camera == nil {
+		return fmt.Errorf("camera node %q has no camera", c.Name)
+	}
+	if c.Name != "" && sv.scene.Root.Find(c.Name) == c {
+		sv.def.Camera = c.Name
+		return nil
+	}
+	var first *Node
+	sv.scene.Root.Walk(func(n *Node) bool {
+		if first == nil && n.Camera != nil {
+			first = n
+		}
+		return first == nil
+	})
+	if first != c {
+		return fmt.Errorf("camera node %q cannot be told apart by its name", c.Name)
+	}
+	return nil
+}
+
+func (sv *sceneSaver) save() error {
+	s := sv.scene
+	ambient := s.Ambient
+	sv.def.Ambient = &ambient
+	if err := sv.camera(); err != nil {
+		return err
+	}
+	for _, p := range s.Programs {
+		if err := sv.program(p); err != nil {
+			return err
+		}
+	}
+	for _, m := range s.meshes {
+		d := m.def
+		if d.File != "" {
+			d.File = sv.path(d.File)
+		}
+		sv.def.Meshes = append(sv.def.Meshes, d)
+		sv.meshes[m.mesh] = d.Name
+	}
+	for _, m := range s.Materials {
+		if err := sv.material(m); err != nil {
+			return err
+		}
+	}
+	for _, c := range s.Root.Children {
+		d, err := sv.node(c)
+		if err != nil {
+			return err
+		}
+		sv.def.Nodes = append(sv.def.Nodes, d)
+	}
+	return nil
+}
+
+func (sv *sceneSaver) program(p *Program) error {
+	if sv.programs[p] {
+		return nil
+	}
+	if p.VertexFile == "" || p.FragmentFile == "" {
+		return fmt.Errorf("program %q was not loaded from files", p.Name)
+	}
+	sv.programs[p] = true
+	sv.def.Programs = append(sv.def.Programs, programDef{
+		Name:     p.Name,
+		Vertex:   sv.path(p.VertexFile),
+		Fragment: sv.path(p.FragmentFile),
+	})
+	return nil
+}
+
+func (sv *sceneSaver) material(m *Material) error {
+	if _, ok := sv.materials[m]; ok {
+		return nil
+	}
+	name := m.Name
+	if name == "" {
+		name = fmt.Sprintf("material%d", len(sv.def.Materials))
+	}
+	d := materialDef{
+		Name:        name,
+		DoubleSided: m.DoubleSided,
+		Blend:       m.Blend,
+		Phong:       phongDefOf(m.Phong),
+		PBR:         pbrDefOf(m.PBR, sv.textures),
+	}
+	if m.Program != nil {
+		if err := sv.program(m.Program); err != nil {
+			return fmt.Errorf("material %q: %v", name, err)
+		}
+		d.Program = m.Program.Name
+	}
+	for _, t := range []*texture.Texture{
+		m.PBR.BaseColourMap, m.PBR.MetallicRoughnessMap, m.PBR.NormalMap, m.PBR.OcclusionMap, m.PBR.EmissiveMap,
+	} {
+		if t != nil && sv.textures[t] == "" {
+			return fmt.Errorf("material %q: a map was not loaded from a file", name)
+		}
+	}
+	for _, p := range []*string{
+		&d.PBR.BaseColourMap, &d.PBR.MetallicRoughnessMap, &d.PBR.NormalMap, &d.PBR.OcclusionMap, &d.PBR.EmissiveMap,
+	} {
+		if *p != "" {
+			*p = sv.path(*p)
+		}
+	}
+	sv.materials[m] = name
+	sv.def.Materials = append(sv.def.Materials, d)
+	return nil
+}
+
+func (sv *sceneSaver) node(n *Node) (nodeDef, error) {
+	d := nodeDef{Name: n.Name}
+	if t := n.Translation(); t != ([3]float32{}) {
+		d.Translation = &t
+	}
+	if r := n.Rotation(); r != ([4]float32{0, 0, 0, 1}) {
+		d.Rotation = &r
+	}
+	if s := n.Scale(); s != ([3]float32{1, 1, 1}) {
+		d.Scale = &s
+	}
+	if n.Mesh != nil {
+		if d.Mesh = sv.meshes[n.Mesh]; d.Mesh == "" {
+			return d, fmt.Errorf("node %q: the mesh was not loaded from the scene", n.Name)
+		}
+	}
+	if n.Material != nil {
+		if err := sv.material(n.Material); err != nil {
+			return d, err
+		}
+		d.Material = sv.materials[n.Material]
+	}
+	if n.Light != nil {
+		d.Light = lightDefOf(*n.Light)
+	}
+	if c := n.Camera; c != nil {
+		d.Camera = &cameraDef{Fovy: c.Fovy, Height: c.Height, Near: c.Near, Far: c.Far}
+	}
+	for _, c := range n.Children {
+		cd, err := sv.node(c)
+		if err != nil {
+			return d, err
+		}
+		d.Children = append(d.Children, cd)
+	}
+	return d, nil
+}
+
+func phongDefOf(m gfx.Material) phongDef {
+	return phongDef{
+		Ambient: m.Ambient, Diffuse: m.Diffuse, Specular: m.Specular,
+		Emissive: m.Emissive, Shininess: m.Shininess,
+	}
+}
+
+// pbrDefOf converts a material, naming its maps by their files.
+func pbrDefOf(m gfx.PBRMaterial, files map[*texture.Texture]string) pbrDef {
+	return pbrDef{
+		BaseColour: m.BaseColour, Metallic: m.Metallic, Roughness: m.Roughness,
+		Emissive: m.Emissive, NormalScale: m.NormalScale,
+		OcclusionStrength: m.OcclusionStrength, AlphaCutoff: m.AlphaCutoff,
+		BaseColourMap:        files[m.BaseColourMap],
+		MetallicRoughnessMap: files[m.MetallicRoughnessMap],
+		NormalMap:            files[m.NormalMap],
+		OcclusionMap:         files[m.OcclusionMap],
+		EmissiveMap:          files[m.EmissiveMap],
+	}
+}
+
+func degrees(rad float32) float32 {
+	return rad * 180 / math.Pi
+}
+
+// lightDefOf writes out everything of a light, so it loads back the same.
+func lightDefOf(l gfx.Light) *lightDef {
+	d := &lightDef{Colour: l.Colour}
+	intensity, position, direction := l.Intensity, l.Position, l.Direction
+	d.Intensity = &intensity
+	if position != ([3]float32{}) {
+		d.Position = &position
+	}
+	switch l.Type {
+	case gfx.DirectionalLight:
+		d.Type = "directional"
+		d.Direction = &direction
+		return d
+	case gfx.PointLight:
+		d.Type = "point"
+	case gfx.SpotLight:
+		d.Type = "spot"
+		d.Direction = &direction
+		cone, inner := degrees(l.OuterCone), degrees(l.InnerCone)
+		d.Cone, d.InnerCone = &cone, &inner
+	}
+	rng := l.Range
+	d.Range = &rng
+	d.Attenuation = &[3]float32{l.Constant, l.Linear, l.Quadratic}
+	return d
+}
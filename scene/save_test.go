@@ -0,0 +1,196 @@
+package scene
+
+import (
+	"github.com/ginuerzh/anton-gocode/gfx"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name, data string) string {
+	p := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(p, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func near(a, b []float32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(float64(a[i]-b[i])) > 1e-4 {
+			return false
+		}
+	}
+	return true
+}
+
+func lightFloats(l *gfx.Light) []float32 {
+	f := []float32{float32(l.Type), l.Intensity, l.Constant, l.Linear, l.Quadratic, l.Range, l.InnerCone, l.OuterCone}
+	f = append(f, l.Position[:]...)
+	f = append(f, l.Direction[:]...)
+	return append(f, l.Colour[:]...)
+}
+
+// sameScene compares the nodes of two scenes in walk order.
+func sameScene(t *testing.T, name string, a, b *Scene) {
+	var na, nb []*Node
+	a.Root.Walk(func(n *Node) bool { na = append(na, n); return true })
+	b.Root.Walk(func(n *Node) bool { nb = append(nb, n); return true })
+	if len(na) != len(nb) {
+		t.Errorf("%s: %d nodes, reloaded %d", name, len(na), len(nb))
+		return
+	}
+	for i, x := range na {
+		y := nb[i]
+		wx, wy := x.World(), y.World()
+		if x.Name != y.Name || !near(wx[:], wy[:]) {
+			t.Errorf("%s: node %q at %v reloaded as %q at %v", name, x.Name, wx, y.Name, wy)
+		}
+		if (x.Light == nil) != (y.Light == nil) ||
+			x.Light != nil && !near(lightFloats(x.Light), lightFloats(y.Light)) {
+			t.Errorf("%s: light of %q is %+v, reloaded %+v", name, x.Name, x.Light, y.Light)
+		}
+		if (x.Camera == nil) != (y.Camera == nil) || x.Camera != nil && *x.Camera != *y.Camera {
+			t.Errorf("%s: camera of %q is %+v, reloaded %+v", name, x.Name, x.Camera, y.Camera)
+		}
+		if (x.Material == nil) != (y.Material == nil) ||
+			x.Material != nil && (x.Material.Name != y.Material.Name || x.Material.Phong != y.Material.Phong ||
+				x.Material.PBR != y.Material.PBR || x.Material.Blend != y.Material.Blend ||
+				x.Material.DoubleSided != y.Material.DoubleSided) {
+			t.Errorf("%s: material of %q is %+v, reloaded %+v", name, x.Name, x.Material, y.Material)
+		}
+		if (x == a.Camera) != (y == b.Camera) {
+			t.Errorf("%s: %q is the camera in one scene only", name, x.Name)
+		}
+	}
+	if a.Ambient != b.Ambient {
+		t.Errorf("%s: ambient %v, reloaded %v", name, a.Ambient, b.Ambient)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scene")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// lights built in code, which a file could not give a range of 0
+	unfaded := func() *Scene {
+		s := NewScene()
+		point := gfx.NewPointLight([3]float32{}, [3]float32{1, 1, 1}, 10)
+		spot := gfx.NewSpotLight([3]float32{}, [3]float32{0, 0, -1}, [3]float32{1, 1, 1}, 10, 0)
+		point.Range, spot.Range = 0, 0
+		for _, l := range []gfx.Light{point, spot} {
+			l := l
+			n := NewNode("light")
+			n.Light = &l
+			s.Root.Add(n)
+		}
+		return s
+	}
+
+	tests := []struct {
+		name, data string
+		build      func() *Scene
+	}{
+		{"empty", `{}`, nil},
+		{"lights", `{"ambient": [0.2, 0.2, 0.2], "nodes": [
+			{"name": "sun", "light": {"type": "directional", "colour": [1, 0.9, 0.8], "intensity": 2, "direction": [0, -1, 0]}},
+			{"name": "bulb", "translation": [1, 2, 3], "light": {"type": "point", "colour": [1, 1, 1]}},
+			{"name": "lamp", "euler": [0, 90, 0], "light": {"type": "spot", "colour": [1, 1, 1], "range": 25, "cone": 40, "innerCone": 20}}
+		]}`, nil},
+		{"lights that never fade", `{"nodes": [
+			{"name": "bulb", "light": {"type": "point", "colour": [1, 1, 1], "range": 0}},
+			{"name": "lamp", "light": {"type": "spot", "colour": [1, 1, 1], "range": 0, "cone": 0}}
+		]}`, nil},
+		{"lights built with a range of 0", "", unfaded},
+		{"hierarchy", `{"nodes": [
+			{"name": "a", "translation": [1, 0, 0], "scale": [2, 2, 2], "children": [
+				{"name": "b", "rotation": [0, 0.7071068, 0, 0.7071068], "children": [{"name": "c", "translation": [0, 0, 1]}]}
+			]}
+		]}`, nil},
+		{"materials", `{"materials": [
+			{"name": "red", "phong": {"diffuse": [0.8, 0.1, 0.1], "shininess": 64}, "blend": true},
+			{"name": "gold", "pbr": {"baseColour": [1, 0.8, 0.3, 1], "metallic": 1, "roughness": 0.3}, "doubleSided": true}
+		], "nodes": [{"name": "x", "material": "red"}, {"name": "y", "material": "gold"}]}`, nil},
+		{"second camera", `{"camera": "close", "nodes": [
+			{"name": "far", "translation": [0, 0, 50], "camera": {"fovy": 30}},
+			{"name": "close", "translation": [0, 1, 5], "camera": {"height": 4, "near": 0.5, "far": 20}}
+		]}`, nil},
+		{"unnamed camera", `{"nodes": [{"camera": {"fovy": 70}}, {"name": "other", "camera": {}}]}`, nil},
+	}
+
+	for i, tt := range tests {
+		var a *Scene
+		if tt.build != nil {
+			a = tt.build()
+		} else if a, err = Load(writeFile(t, dir, "in.json", tt.data)); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		out := filepath.Join(dir, "out", string(rune('a'+i))+".json")
+		os.MkdirAll(filepath.Dir(out), 0755)
+		if err := a.Save(out); err != nil {
+			t.Errorf("%s: save: %v", tt.name, err)
+			continue
+		}
+		b, err := Load(out)
+		if err != nil {
+			data, _ := ioutil.ReadFile(out)
+			t.Errorf("%s: reload: %v\n%s", tt.name, err, data)
+			continue
+		}
+		sameScene(t, tt.name, a, b)
+	}
+}
+
+func TestSaveCamera(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scene")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "out.json")
+
+	first, second := NewNode(""), NewNode("")
+	first.Camera, second.Camera = &Camera{Fovy: 45}, &Camera{Fovy: 30}
+	tests := []struct {
+		name              string
+		camera            *Node
+		first, second     string // node names
+		ok                bool
+		reloadedFovy      float32
+		reloadedCameraKey string
+	}{
+		{"unnamed first camera", first, "", "", true, 45, ""},
+		{"unnamed second camera", second, "", "", false, 0, ""},
+		{"duplicate name", second, "cam", "cam", false, 0, ""},
+		{"unique name", second, "a", "b", true, 30, "b"},
+		{"no camera component", NewNode("x"), "a", "b", false, 0, ""},
+	}
+	for _, tt := range tests {
+		s := NewScene()
+		first.Name, second.Name = tt.first, tt.second
+		s.Root.Add(first)
+		s.Root.Add(second)
+		s.Camera = tt.camera
+		err := s.Save(out)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: save error %v", tt.name, err)
+			continue
+		}
+		if !tt.ok {
+			continue
+		}
+		r, err := Load(out)
+		if err != nil || r.Camera == nil || r.Camera.Camera.Fovy != tt.reloadedFovy || r.Camera.Name != tt.reloadedCameraKey {
+			t.Errorf("%s: reloaded camera %+v, %v", tt.name, r.Camera, err)
+		}
+	}
+}
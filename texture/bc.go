@@ -0,0 +1,113 @@
+package texture
+
+/*
+Software decoders for the S3TC formats, used when the driver cannot sample
+them. Every 4x4 block holds two RGB565 end points and 2 bit indices picking
+one of four colours along the line between them. BC2 adds explicit 4 bit
+alpha, BC3 an interpolated alpha block in front of the colour block.
+*/
+
+func rgb565(c uint16) [3]int {
+	r, g, b := int(c>>11&0x1f), int(c>>5&0x3f), int(c&0x1f)
+	return [3]int{r<<3 | r>>2, g<<2 | g>>4, b<<3 | b>>2}
+}
+
+// colourBlock decodes the 8 byte colour part of a block into 16 RGBA
+// pixels. BC1 allows the 3 colour mode with transparent black.
+func colourBlock(b []byte, out *[16][4]byte, bc1 bool) {
+	c0 := uint16(b[0]) | uint16(b[1])<<8
+	c1 := uint16(b[2]) | uint16(b[3])<<8
+	p0, p1 := rgb565(c0), rgb565(c1)
+
+	var palette [4][4]byte
+	for k := 0; k < 3; k++ {
+		palette[0][k] = byte(p0[k])
+		palette[1][k] = byte(p1[k])
+		if c0 > c1 || !bc1 {
+			palette[2][k] = byte((2*p0[k] + p1[k]) / 3)
+			palette[3][k] = byte((p0[k] + 2*p1[k]) / 3)
+		} else {
+			palette[2][k] = byte((p0[k] + p1[k]) / 2)
+		}
+	}
+	palette[0][3], palette[1][3], palette[2][3], palette[3][3] = 255, 255, 255, 255
+	if c0 <= c1 && bc1 {
+		palette[3][3] = 0
+	}
+
+	bits := uint32(b[4]) | uint32(b[5])<<8 | uint32(b[6])<<16 | uint32(b[7])<<24
+	for i := range out {
+		out[i] = palette[bits>>(uint(i)*2)&3]
+	}
+}
+
+// decodeBlocks walks the blocks of a width x height image, writing the
+// pixels decode produces into a tightly packed RGBA image.
+func decodeBlocks(src []byte, width, height, size int, decode func(b []byte, px *[16][4]byte)) []byte {
+	dst := make([]byte, width*height*4)
+	var px [16][4]byte
+	off := 0
+	for by := 0; by < (height+3)/4; by++ {
+		for bx := 0; bx < (width+3)/4; bx++ {
+			if off+size > len(src) {
+				return dst
+			}
+			decode(src[off:off+size], &px)
+			off += size
+			for i, p := range px {
+				x, y := bx*4+i%4, by*4+i/4
+				if x < width && y < height {
+					copy(dst[(y*width+x)*4:], p[:])
+				}
+			}
+		}
+	}
+	return dst
+}
+
+// DecodeBC1 decodes BC1 (DXT1) data into RGBA pixels.
+func DecodeBC1(src []byte, width, height int) []byte {
+	return decodeBlocks(src, width, height, 8, func(b []byte, px *[16][4]byte) {
+		colourBlock(b, px, true)
+	})
+}
+
+// DecodeBC2 decodes BC2 (DXT3) data into RGBA pixels.
+func DecodeBC2(src []byte, width, height int) []byte {
+	return decodeBlocks(src, width, height, 16, func(b []byte, px *[16][4]byte) {
+		colourBlock(b[8:], px, false)
+		for i := range px {
+			a := b[i/2] >> (uint(i%2) * 4) & 0xf
+			px[i][3] = a<<4 | a
+		}
+	})
+}
+
+// DecodeBC3 decodes BC3 (DXT5) data into RGBA pixels.
+func DecodeBC3(src []byte, width, height int) []byte {
+	return decodeBlocks(src, width, height, 16, func(b []byte, px *[16][4]byte) {
+		colourBlock(b[8:], px, false)
+
+		a0, a1 := int(b[0]), int(b[1])
+		var alpha [8]byte
+		alpha[0], alpha[1] = byte(a0), byte(a1)
+		if a0 > a1 {
+			for k := 1; k < 7; k++ {
+				alpha[k+1] = byte(((7-k)*a0 + k*a1) / 7)
+			}
+		} else {
+			for k := 1; k < 5; k++ {
+				alpha[k+1] = byte(((5-k)*a0 + k*a1) / 5)
+			}
+			alpha[6], alpha[7] = 0, 255
+		}
+
+		var bits uint64
+		for k := 0; k < 6; k++ {
+			bits |= uint64(b[2+k]) << (uint(k) * 8)
+		}
+		for i := range px {
+			px[i][3] = alpha[bits>>(uint(i)*3)&7]
+		}
+	})
+}
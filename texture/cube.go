@@ -0,0 +1,221 @@
+package texture
+
+import (
+	"fmt"
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"image"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FloatImage is a linear RGB image with one float32 per channel, top row
+// first, able to hold values above 1.
+type FloatImage struct {
+	Width, Height int
+	Pix           []float32
+}
+
+// At returns the colour at x, y.
+func (f *FloatImage) At(x, y int) [3]float32 {
+	i := (y*f.Width + x) * 3
+	return [3]float32{f.Pix[i], f.Pix[i+1], f.Pix[i+2]}
+}
+
+func srgbToLinear(c float64) float32 {
+	if c <= 0.04045 {
+		return float32(c / 12.92)
+	}
+	return float32(math.Pow((c+0.055)/1.055, 2.4))
+}
+
+// NewFloatImage converts an 8 or 16 bit sRGB image to linear floats.
+func NewFloatImage(img image.Image) *FloatImage {
+	b := img.Bounds()
+	f := &FloatImage{Width: b.Dx(), Height: b.Dy(), Pix: make([]float32, 0, b.Dx()*b.Dy()*3)}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, _ := img.At(x, y).RGBA()
+			f.Pix = append(f.Pix, srgbToLinear(float64(r)/0xffff),
+				srgbToLinear(float64(g)/0xffff), srgbToLinear(float64(bl)/0xffff))
+		}
+	}
+	return f
+}
+
+// sample reads the image bilinearly at u, v in [0, 1], wrapping u.
+func (f *FloatImage) sample(u, v float64) [3]float32 {
+	x := u*float64(f.Width) - 0.5
+	y := math.Min(math.Max(v*float64(f.Height)-0.5, 0), float64(f.Height-1))
+	x0, y0 := math.Floor(x), math.Floor(y)
+	fx, fy := float32(x-x0), float32(y-y0)
+
+	wrap := func(x int) int {
+		x %= f.Width
+		if x < 0 {
+			x += f.Width
+		}
+		return x
+	}
+	xa, xb := wrap(int(x0)), wrap(int(x0)+1)
+	ya, yb := int(y0), int(y0)+1
+	if yb >= f.Height {
+		yb = f.Height - 1
+	}
+
+	a, b, c, d := f.At(xa, ya), f.At(xb, ya), f.At(xa, yb), f.At(xb, yb)
+	var out [3]float32
+	for k := range out {
+		top := a[k] + (b[k]-a[k])*fx
+		bottom := c[k] + (d[k]-c[k])*fx
+		out[k] = top + (bottom-top)*fy
+	}
+	return out
+}
+
+// CubeFaceDir returns the direction through texel x, y of a size x size
+// cube map face, following the face orientation of the GL specification
+// with row 0 at the top.
+func CubeFaceDir(face, x, y, size int) [3]float64 {
+	s := 2*(float64(x)+0.5)/float64(size) - 1
+	t := 2*(float64(y)+0.5)/float64(size) - 1
+	var d [3]float64
+	switch face {
+	case 0:
+		d = [3]float64{1, -t, -s}
+	case 1:
+		d = [3]float64{-1, -t, s}
+	case 2:
+		d = [3]float64{s, 1, t}
+	case 3:
+		d = [3]float64{s, -1, -t}
+	case 4:
+		d = [3]float64{s, -t, 1}
+	default:
+		d = [3]float64{-s, -t, -1}
+	}
+	l := math.Sqrt(d[0]*d[0] + d[1]*d[1] + d[2]*d[2])
+	return [3]float64{d[0] / l, d[1] / l, d[2] / l}
+}
+
+func newCube(size int32, internalFormat int32) *Texture {
+	gl.Enable(gl.TEXTURE_CUBE_MAP_SEAMLESS)
+	t := &Texture{
+		Target:         gl.TEXTURE_CUBE_MAP,
+		Width:          size,
+		Height:         size,
+		Levels:         1,
+		InternalFormat: internalFormat,
+	}
+	gl.GenTextures(1, &t.ID)
+	gl.BindTexture(gl.TEXTURE_CUBE_MAP, t.ID)
+	return t
+}
+
+// cubeSampler clamps to the edges, the seamless filtering takes care of
+// the borders between faces.
+func cubeSampler(s Sampler) Sampler {
+	if s == (Sampler{}) {
+		s = DefaultSampler()
+	}
+	s.WrapS, s.WrapT, s.WrapR = gl.CLAMP_TO_EDGE, gl.CLAMP_TO_EDGE, gl.CLAMP_TO_EDGE
+	return s
+}
+
+// LoadCube loads a cube map from six square images of the same size, in
+// +X, -X, +Y, -Y, +Z, -Z order. Faces are not flipped: cube maps keep the
+// top row first.
+func LoadCube(files [6]string, opts Options) (*Texture, error) {
+	var faces [6]image.Image
+	for i, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		img, _, err := image.Decode(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		faces[i] = img
+	}
+	return CubeFromImages(faces, opts)
+}
+
+// CubeFromImages uploads six images as the faces of a cube map.
+func CubeFromImages(faces [6]image.Image, opts Options) (*Texture, error) {
+	size := faces[0].Bounds().Dx()
+	for i, img := range faces {
+		if b := img.Bounds(); b.Dx() != size || b.Dy() != size {
+			return nil, fmt.Errorf("cube face %d is %dx%d, want %dx%d", i, b.Dx(), b.Dy(), size, size)
+		}
+	}
+
+	internal := int32(gl.RGBA8)
+	if opts.SRGB {
+		internal = gl.SRGB8_ALPHA8
+	}
+	t := newCube(int32(size), internal)
+	for i, img := range faces {
+		rgba := NRGBA(img, false)
+		gl.TexImage2D(gl.TEXTURE_CUBE_MAP_POSITIVE_X+uint32(i), 0, internal,
+			int32(size), int32(size), 0, gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(rgba.Pix))
+	}
+	if opts.Mipmaps {
+		t.GenerateMipmaps()
+	}
+	t.SetSampler(cubeSampler(opts.Sampler))
+	return t, nil
+}
+
+// LoadEquirect loads an equirectangular (latitude-longitude) panorama and
+// projects it onto a cube map with faces of faceSize texels. Radiance .hdr
+// panoramas keep their full range.
+func LoadEquirect(filename string, faceSize int, opts Options) (*Texture, error) {
+	if strings.ToLower(filepath.Ext(filename)) == ".hdr" {
+		img, err := LoadHDR(filename)
+		if err != nil {
+			return nil, err
+		}
+		return CubeFromEquirect(img, faceSize, opts), nil
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return CubeFromEquirect(NewFloatImage(img), faceSize, opts), nil
+}
+
+// CubeFromEquirect projects a panorama onto a half float cube map. The
+// centre of the panorama ends up at -Z, the direction the camera looks
+// at by default. The sRGB option does not apply, the data is linear.
+func CubeFromEquirect(img *FloatImage, faceSize int, opts Options) *Texture {
+	t := newCube(int32(faceSize), gl.RGB16F)
+	face := make([]float32, faceSize*faceSize*3)
+	for i := 0; i < 6; i++ {
+		for y := 0; y < faceSize; y++ {
+			for x := 0; x < faceSize; x++ {
+				d := CubeFaceDir(i, x, y, faceSize)
+				u := 0.5 + math.Atan2(d[0], -d[2])/(2*math.Pi)
+				v := math.Acos(math.Max(-1, math.Min(1, d[1]))) / math.Pi
+				c := img.sample(u, v)
+				copy(face[(y*faceSize+x)*3:], c[:])
+			}
+		}
+		gl.TexImage2D(gl.TEXTURE_CUBE_MAP_POSITIVE_X+uint32(i), 0, gl.RGB16F,
+			int32(faceSize), int32(faceSize), 0, gl.RGB, gl.FLOAT, gl.Ptr(face))
+	}
+	if opts.Mipmaps {
+		t.GenerateMipmaps()
+	}
+	t.SetSampler(cubeSampler(opts.Sampler))
+	return t
+}
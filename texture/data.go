@@ -0,0 +1,257 @@
+package texture
+
+import (
+	"fmt"
+	"github.com/ginuerzh/anton-gocode/common"
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"path/filepath"
+	"strings"
+)
+
+// Data is texture data as stored in a KTX or DDS container: a chain of mip
+// levels, each with one image per face.
+type Data struct {
+	Width, Height  int
+	Compressed     bool
+	InternalFormat uint32 // compressed format, or the sized internal format
+	Format, Type   uint32 // pixel format and type of uncompressed data
+	Cube           bool   // six faces in +X, -X, +Y, -Y, +Z, -Z order
+	Levels         []Level
+}
+
+// Level is one mip level.
+type Level struct {
+	Width, Height int
+	Faces         [][]byte
+}
+
+func (d *Data) faces() int {
+	if d.Cube {
+		return 6
+	}
+	return 1
+}
+
+// LoadData reads a .ktx, .ktx2 or .dds file.
+func LoadData(filename string) (*Data, error) {
+	var d *Data
+	var err error
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".ktx":
+		d, err = LoadKTX(filename)
+	case ".ktx2":
+		d, err = LoadKTX2(filename)
+	case ".dds":
+		d, err = LoadDDS(filename)
+	default:
+		return nil, fmt.Errorf("%s: not a texture container", filename)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return d, nil
+}
+
+// Supported reports whether the context can take the data as it is.
+func (d *Data) Supported() bool {
+	return !d.Compressed || common.CompressedFormats()[d.InternalFormat]
+}
+
+// Upload creates a texture from the data. Compressed formats the context
+// does not support are decoded in software where a decoder exists (BC1,
+// BC2 and BC3). Rows are uploaded as stored, FlipY is ignored, and a single
+// level is only extended to a mipmap chain for uncompressed data.
+func (d *Data) Upload(opts Options) (*Texture, error) {
+	if opts.Sampler == (Sampler{}) {
+		opts.Sampler = DefaultSampler()
+	}
+	if !d.Supported() {
+		decoded, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		common.GLog("texture: %s not supported, decoded in software\n",
+			common.CompressedFormatName(d.InternalFormat))
+		d = decoded
+	}
+
+	t := &Texture{
+		Target:         gl.TEXTURE_2D,
+		Width:          int32(d.Width),
+		Height:         int32(d.Height),
+		Levels:         int32(len(d.Levels)),
+		InternalFormat: int32(d.InternalFormat),
+	}
+	face := uint32(gl.TEXTURE_2D)
+	if d.Cube {
+		t.Target = gl.TEXTURE_CUBE_MAP
+		face = gl.TEXTURE_CUBE_MAP_POSITIVE_X
+	}
+
+	gl.GenTextures(1, &t.ID)
+	gl.BindTexture(t.Target, t.ID)
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 1)
+	for l, level := range d.Levels {
+		for f, data := range level.Faces {
+			w, h := int32(level.Width), int32(level.Height)
+			if d.Compressed {
+				gl.CompressedTexImage2D(face+uint32(f), int32(l), d.InternalFormat, w, h, 0,
+					int32(len(data)), gl.Ptr(data))
+			} else {
+				gl.TexImage2D(face+uint32(f), int32(l), int32(d.InternalFormat), w, h, 0,
+					d.Format, d.Type, gl.Ptr(data))
+			}
+		}
+	}
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 4)
+	gl.TexParameteri(t.Target, gl.TEXTURE_MAX_LEVEL, t.Levels-1)
+
+	if len(d.Levels) == 1 && opts.Mipmaps && !d.Compressed {
+		t.GenerateMipmaps()
+		gl.TexParameteri(t.Target, gl.TEXTURE_MAX_LEVEL, t.Levels-1)
+	}
+	if d.Cube {
+		gl.Enable(gl.TEXTURE_CUBE_MAP_SEAMLESS)
+		opts.Sampler = cubeSampler(opts.Sampler)
+	}
+	t.SetSampler(opts.Sampler)
+	return t, nil
+}
+
+// ReadTexture reads every level and face of an uncompressed 2D texture or
+// cube map back from GL, converted to format and type, for example to
+// save textures rendered at load time with SaveKTX.
+func ReadTexture(t *Texture, format, typ uint32) *Data {
+	d := &Data{
+		Width:          int(t.Width),
+		Height:         int(t.Height),
+		InternalFormat: uint32(t.InternalFormat),
+		Format:         format,
+		Type:           typ,
+		Cube:           t.Target == gl.TEXTURE_CUBE_MAP,
+	}
+	face := uint32(gl.TEXTURE_2D)
+	if d.Cube {
+		face = gl.TEXTURE_CUBE_MAP_POSITIVE_X
+	}
+
+	gl.BindTexture(t.Target, t.ID)
+	gl.PixelStorei(gl.PACK_ALIGNMENT, 1)
+	for l := 0; l < int(t.Levels); l++ {
+		w, h := mipSize(d.Width, d.Height, l)
+		level := Level{Width: w, Height: h}
+		for f := 0; f < d.faces(); f++ {
+			data := make([]byte, w*h*pixelBytes(format, typ))
+			gl.GetTexImage(face+uint32(f), int32(l), format, typ, gl.Ptr(data))
+			level.Faces = append(level.Faces, data)
+		}
+		d.Levels = append(d.Levels, level)
+	}
+	gl.PixelStorei(gl.PACK_ALIGNMENT, 4)
+	return d
+}
+
+// decode converts BC1 to BC3 data to uncompressed RGBA.
+func (d *Data) decode() (*Data, error) {
+	var decode func(src []byte, w, h int) []byte
+	switch d.InternalFormat {
+	case compressedRGBDXT1, compressedRGBADXT1, compressedSRGBDXT1, compressedSRGBAlphaDXT1:
+		decode = DecodeBC1
+	case compressedRGBADXT3, compressedSRGBAlphaDXT3:
+		decode = DecodeBC2
+	case compressedRGBADXT5, compressedSRGBAlphaDXT5:
+		decode = DecodeBC3
+	default:
+		return nil, fmt.Errorf("texture format %s (0x%x) is not supported",
+			common.CompressedFormatName(d.InternalFormat), d.InternalFormat)
+	}
+
+	out := &Data{
+		Width:          d.Width,
+		Height:         d.Height,
+		InternalFormat: gl.RGBA8,
+		Format:         gl.RGBA,
+		Type:           gl.UNSIGNED_BYTE,
+		Cube:           d.Cube,
+	}
+	if isSRGB(d.InternalFormat) {
+		out.InternalFormat = gl.SRGB8_ALPHA8
+	}
+	for _, level := range d.Levels {
+		l := Level{Width: level.Width, Height: level.Height}
+		for _, data := range level.Faces {
+			l.Faces = append(l.Faces, decode(data, level.Width, level.Height))
+		}
+		out.Levels = append(out.Levels, l)
+	}
+	return out, nil
+}
+
+// toSRGB switches linear colour formats to their sRGB twins.
+func (d *Data) toSRGB() {
+	switch {
+	case d.Compressed:
+		d.InternalFormat = srgbFormat(d.InternalFormat)
+	case d.InternalFormat == gl.RGBA8:
+		d.InternalFormat = gl.SRGB8_ALPHA8
+	case d.InternalFormat == gl.RGB8:
+		d.InternalFormat = gl.SRGB8
+	}
+}
+
+// checkLevels makes sure every face of every level holds enough data.
+func (d *Data) checkLevels() error {
+	if d.Width <= 0 || d.Height <= 0 {
+		return fmt.Errorf("bad size %dx%d", d.Width, d.Height)
+	}
+	if d.Compressed && blockBytes(d.InternalFormat) == 0 {
+		return fmt.Errorf("unknown compressed format 0x%x", d.InternalFormat)
+	}
+	for i, l := range d.Levels {
+		if len(l.Faces) != d.faces() {
+			return fmt.Errorf("level %d has %d faces", i, len(l.Faces))
+		}
+		size := l.Width * l.Height * pixelBytes(d.Format, d.Type)
+		if d.Compressed {
+			size = compressedSize(d.InternalFormat, l.Width, l.Height)
+		}
+		for _, f := range l.Faces {
+			if len(f) < size {
+				return fmt.Errorf("level %d is truncated", i)
+			}
+		}
+	}
+	return nil
+}
+
+// mipSize is the size of level l of a width x height image.
+func mipSize(width, height, l int) (int, int) {
+	w, h := width>>uint(l), height>>uint(l)
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	return w, h
+}
+
+// pixelBytes is the size of an uncompressed pixel.
+func pixelBytes(format, typ uint32) int {
+	n := 4
+	switch format {
+	case gl.RED:
+		n = 1
+	case gl.RG:
+		n = 2
+	case gl.RGB, gl.BGR:
+		n = 3
+	}
+	switch typ {
+	case gl.UNSIGNED_SHORT, gl.HALF_FLOAT:
+		n *= 2
+	case gl.FLOAT:
+		n *= 4
+	}
+	return n
+}
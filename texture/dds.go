@@ -0,0 +1,178 @@
+package texture
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"io/ioutil"
+	"math/bits"
+)
+
+const (
+	ddsHeaderSize     = 4 + 124
+	ddsDX10HeaderSize = 20
+	ddsFourCC         = 0x4    // DDPF_FOURCC
+	ddsRGB            = 0x40   // DDPF_RGB
+	ddsCubemap        = 0x200  // DDSCAPS2_CUBEMAP
+	ddsAllFaces       = 0xFC00 // DDSCAPS2_CUBEMAP_POSITIVEX | ... | NEGATIVEZ
+	dx10TextureCube   = 0x4    // D3D10_RESOURCE_MISC_TEXTURECUBE
+	dx10Texture2D     = 3      // D3D10_RESOURCE_DIMENSION_TEXTURE2D
+	ddsMagic          = "DDS "
+)
+
+// LoadDDS reads a DirectDraw Surface file.
+func LoadDDS(filename string) (*Data, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDDS(data)
+}
+
+// dxgiFormats maps DXGI formats of DX10 headers to GL: compressed format, or
+// internal format, format and type.
+var dxgiFormats = map[uint32][3]uint32{
+	71: {compressedRGBADXT1},
+	72: {compressedSRGBAlphaDXT1},
+	74: {compressedRGBADXT3},
+	75: {compressedSRGBAlphaDXT3},
+	77: {compressedRGBADXT5},
+	78: {compressedSRGBAlphaDXT5},
+	80: {compressedRedRGTC1},
+	81: {compressedSRedRGTC1},
+	83: {compressedRGRGTC2},
+	84: {compressedSRGRGTC2},
+	95: {compressedRGBBPTCUF},
+	96: {compressedRGBBPTCSF},
+	98: {compressedRGBABPTC},
+	99: {compressedSRGBABPTC},
+
+	2:  {gl.RGBA32F, gl.RGBA, gl.FLOAT},
+	10: {gl.RGBA16F, gl.RGBA, gl.HALF_FLOAT},
+	28: {gl.RGBA8, gl.RGBA, gl.UNSIGNED_BYTE},
+	29: {gl.SRGB8_ALPHA8, gl.RGBA, gl.UNSIGNED_BYTE},
+	87: {gl.RGBA8, gl.BGRA, gl.UNSIGNED_BYTE},
+	91: {gl.SRGB8_ALPHA8, gl.BGRA, gl.UNSIGNED_BYTE},
+}
+
+// fourCCFormats maps the FourCC codes of legacy headers to GL formats.
+var fourCCFormats = map[string]uint32{
+	"DXT1": compressedRGBADXT1,
+	"DXT2": compressedRGBADXT3,
+	"DXT3": compressedRGBADXT3,
+	"DXT4": compressedRGBADXT5,
+	"DXT5": compressedRGBADXT5,
+	"ATI1": compressedRedRGTC1,
+	"BC4U": compressedRedRGTC1,
+	"BC4S": compressedSRedRGTC1,
+	"ATI2": compressedRGRGTC2,
+	"BC5U": compressedRGRGTC2,
+	"BC5S": compressedSRGRGTC2,
+}
+
+// ParseDDS parses a DDS file holding a 2D texture or a full cube map, with
+// legacy FourCC, 32 bit RGBA/BGRA or DX10 headers. DDS stores rows top
+// down, so the texture comes out upside down for GL texcoords unless the
+// file was written flipped.
+func ParseDDS(data []byte) (*Data, error) {
+	if len(data) < ddsHeaderSize || string(data[:4]) != ddsMagic {
+		return nil, errors.New("not a DDS file")
+	}
+	le := binary.LittleEndian
+	field := func(off int) uint32 { return le.Uint32(data[4+off:]) }
+	height, width := int(field(8)), int(field(12))
+	levels := int(field(24))
+	pfFlags, fourCC := field(76), string(data[4+80:4+84])
+	bitCount, rmask, gmask, bmask, amask := field(84), field(88), field(92), field(96), field(100)
+	caps2 := field(108)
+
+	// no more levels than halvings down to 1x1, whatever the header says
+	if n := bits.Len(uint(width | height)); levels > n {
+		levels = n
+	}
+	if levels == 0 {
+		levels = 1
+	}
+	d := &Data{Width: width, Height: height}
+	faces := 1
+	if caps2&ddsCubemap != 0 {
+		if caps2&ddsAllFaces != ddsAllFaces {
+			return nil, errors.New("DDS cube maps need all six faces")
+		}
+		faces = 6
+	}
+
+	off := ddsHeaderSize
+	switch {
+	case pfFlags&ddsFourCC != 0 && fourCC == "DX10":
+		if len(data) < off+ddsDX10HeaderSize {
+			return nil, errors.New("DDS is truncated")
+		}
+		dxgi, dimension := le.Uint32(data[off:]), le.Uint32(data[off+4:])
+		misc, arraySize := le.Uint32(data[off+8:]), le.Uint32(data[off+12:])
+		off += ddsDX10HeaderSize
+
+		if dimension != dx10Texture2D || arraySize > 1 {
+			return nil, errors.New("only single 2D DDS textures are supported")
+		}
+		format, ok := dxgiFormats[dxgi]
+		if !ok {
+			return nil, fmt.Errorf("DXGI format %d is not supported", dxgi)
+		}
+		d.Compressed = format[1] == 0
+		d.InternalFormat, d.Format, d.Type = format[0], format[1], format[2]
+		if misc&dx10TextureCube != 0 {
+			faces = 6
+		}
+
+	case pfFlags&ddsFourCC != 0:
+		format, ok := fourCCFormats[fourCC]
+		if !ok {
+			return nil, fmt.Errorf("DDS FourCC %q is not supported", fourCC)
+		}
+		d.Compressed = true
+		d.InternalFormat = format
+
+	case pfFlags&ddsRGB != 0 && bitCount == 32 && rmask == 0xff && gmask == 0xff00 && bmask == 0xff0000:
+		d.InternalFormat, d.Format, d.Type = gl.RGBA8, gl.RGBA, gl.UNSIGNED_BYTE
+		if amask == 0 {
+			d.InternalFormat = gl.RGB8
+		}
+
+	case pfFlags&ddsRGB != 0 && bitCount == 32 && rmask == 0xff0000 && gmask == 0xff00 && bmask == 0xff:
+		d.InternalFormat, d.Format, d.Type = gl.RGBA8, gl.BGRA, gl.UNSIGNED_BYTE
+		if amask == 0 {
+			d.InternalFormat = gl.RGB8
+		}
+
+	default:
+		return nil, errors.New("DDS pixel format is not supported")
+	}
+	d.Cube = faces == 6
+
+	// DDS stores each face with all of its levels, GL wants them by level
+	d.Levels = make([]Level, levels)
+	for l := range d.Levels {
+		w, h := mipSize(width, height, l)
+		d.Levels[l] = Level{Width: w, Height: h}
+	}
+	for f := 0; f < faces; f++ {
+		for l := range d.Levels {
+			level := &d.Levels[l]
+			size := level.Width * level.Height * pixelBytes(d.Format, d.Type)
+			if d.Compressed {
+				size = compressedSize(d.InternalFormat, level.Width, level.Height)
+			}
+			if size < 0 || size > len(data)-off {
+				return nil, errors.New("DDS is truncated")
+			}
+			level.Faces = append(level.Faces, data[off:off+size])
+			off += size
+		}
+	}
+	if err := d.checkLevels(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
@@ -0,0 +1,90 @@
+package texture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// dds builds a DDS header with a FourCC pixel format followed by data.
+func dds(fourCC string, width, height, levels, caps2 uint32, data []byte) []byte {
+	h := make([]byte, ddsHeaderSize)
+	copy(h, ddsMagic)
+	le := binary.LittleEndian
+	le.PutUint32(h[4:], 124)
+	le.PutUint32(h[4+8:], height)
+	le.PutUint32(h[4+12:], width)
+	le.PutUint32(h[4+24:], levels)
+	le.PutUint32(h[4+76:], ddsFourCC)
+	copy(h[4+80:], fourCC)
+	le.PutUint32(h[4+108:], caps2)
+	return append(h, data...)
+}
+
+func TestParseDDS(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   []byte
+		ok     bool
+		faces  int
+		levels int
+	}{
+		{"one level", dds("DXT1", 8, 8, 0, 0, make([]byte, 32)), true, 1, 1},
+		{"mip chain", dds("DXT5", 8, 8, 4, 0, make([]byte, 64+16+16+16)), true, 1, 4},
+		{"more levels than halvings", dds("DXT1", 8, 8, 10, 0, make([]byte, 32+8+8+8)), true, 1, 4},
+		{"huge level count", dds("DXT1", 8, 8, 0xffffffff, 0, make([]byte, 32)), false, 0, 0},
+		{"cube", dds("DXT1", 4, 4, 1, ddsCubemap|ddsAllFaces, make([]byte, 6*8)), true, 6, 1},
+		{"partial cube", dds("DXT1", 4, 4, 1, ddsCubemap|0x400, make([]byte, 6*8)), false, 0, 0},
+		{"truncated", dds("DXT1", 8, 8, 2, 0, make([]byte, 32+4)), false, 0, 0},
+		{"huge size", dds("DXT1", 0x7fffffff, 0x7fffffff, 1, 0, make([]byte, 32)), false, 0, 0},
+		{"unknown FourCC", dds("ABCD", 4, 4, 1, 0, make([]byte, 8)), false, 0, 0},
+		{"not dds", append([]byte("DDX "), make([]byte, 200)...), false, 0, 0},
+	}
+	for _, tt := range tests {
+		d, err := ParseDDS(tt.data)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: error %v", tt.name, err)
+			continue
+		}
+		if tt.ok && (len(d.Levels) != tt.levels || len(d.Levels[0].Faces) != tt.faces || d.Cube != (tt.faces == 6)) {
+			t.Errorf("%s: %d levels of %d faces, want %d of %d", tt.name, len(d.Levels), len(d.Levels[0].Faces), tt.levels, tt.faces)
+		}
+	}
+}
+
+func TestDecodeBC(t *testing.T) {
+	// red and blue end points, the top row of indices 0, the rest 1
+	red, blue := []byte{255, 0, 0, 255}, []byte{0, 0, 255, 255}
+	colour := []byte{0x00, 0xf8, 0x1f, 0x00, 0x00, 0x55, 0x55, 0x55}
+	// the 3 colour mode, c0 <= c1, with index 3 transparent
+	transparent := []byte{0x1f, 0x00, 0x00, 0xf8, 0xff, 0xff, 0xff, 0xff}
+	// BC2 alpha of 0x8 everywhere, BC3 alpha end points 200 and 100 all at 0
+	bc2 := append(bytes.Repeat([]byte{0x88}, 8), colour...)
+	bc3 := append([]byte{200, 100, 0, 0, 0, 0, 0, 0}, colour...)
+
+	tests := []struct {
+		name          string
+		decode        func(src []byte, width, height int) []byte
+		src           []byte
+		width, height int
+		first, last   []byte // the first and last pixel
+	}{
+		{"bc1", DecodeBC1, colour, 4, 4, red, blue},
+		{"bc1 transparent", DecodeBC1, transparent, 4, 4, []byte{0, 0, 0, 0}, []byte{0, 0, 0, 0}},
+		{"bc1 partial block", DecodeBC1, colour, 2, 2, red, blue},
+		{"bc1 two blocks", DecodeBC1, append(append([]byte(nil), transparent...), colour...), 8, 4, []byte{0, 0, 0, 0}, blue},
+		{"bc1 short data", DecodeBC1, colour[:4], 4, 4, []byte{0, 0, 0, 0}, []byte{0, 0, 0, 0}},
+		{"bc2", DecodeBC2, bc2, 4, 4, []byte{255, 0, 0, 0x88}, []byte{0, 0, 255, 0x88}},
+		{"bc3", DecodeBC3, bc3, 4, 4, []byte{255, 0, 0, 200}, []byte{0, 0, 255, 200}},
+	}
+	for _, tt := range tests {
+		px := tt.decode(tt.src, tt.width, tt.height)
+		if len(px) != tt.width*tt.height*4 {
+			t.Errorf("%s: %d bytes for %dx%d", tt.name, len(px), tt.width, tt.height)
+			continue
+		}
+		if first, last := px[:4], px[len(px)-4:]; !bytes.Equal(first, tt.first) || !bytes.Equal(last, tt.last) {
+			t.Errorf("%s: pixels %v ... %v, want %v ... %v", tt.name, first, last, tt.first, tt.last)
+		}
+	}
+}
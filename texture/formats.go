@@ -0,0 +1,95 @@
+package texture
+
+/* block compressed formats, most of them from extensions */
+const (
+	compressedRGBDXT1       = 0x83F0
+	compressedRGBADXT1      = 0x83F1
+	compressedRGBADXT3      = 0x83F2
+	compressedRGBADXT5      = 0x83F3
+	compressedSRGBDXT1      = 0x8C4C
+	compressedSRGBAlphaDXT1 = 0x8C4D
+	compressedSRGBAlphaDXT3 = 0x8C4E
+	compressedSRGBAlphaDXT5 = 0x8C4F
+	compressedRedRGTC1      = 0x8DBB
+	compressedSRedRGTC1     = 0x8DBC
+	compressedRGRGTC2       = 0x8DBD
+	compressedSRGRGTC2      = 0x8DBE
+	compressedRGBABPTC      = 0x8E8C
+	compressedSRGBABPTC     = 0x8E8D
+	compressedRGBBPTCSF     = 0x8E8E
+	compressedRGBBPTCUF     = 0x8E8F
+	compressedR11EAC        = 0x9270
+	compressedSR11EAC       = 0x9271
+	compressedRG11EAC       = 0x9272
+	compressedSRG11EAC      = 0x9273
+	compressedRGB8ETC2      = 0x9274
+	compressedSRGB8ETC2     = 0x9275
+	compressedRGB8A1ETC2    = 0x9276
+	compressedSRGB8A1ETC2   = 0x9277
+	compressedRGBA8ETC2     = 0x9278
+	compressedSRGBA8ETC2    = 0x9279
+)
+
+// blockBytes returns the size of a 4x4 block of a compressed format, 0
+// for formats that are not block compressed.
+func blockBytes(format uint32) int {
+	switch format {
+	case compressedRGBDXT1, compressedRGBADXT1, compressedSRGBDXT1, compressedSRGBAlphaDXT1,
+		compressedRedRGTC1, compressedSRedRGTC1,
+		compressedR11EAC, compressedSR11EAC,
+		compressedRGB8ETC2, compressedSRGB8ETC2, compressedRGB8A1ETC2, compressedSRGB8A1ETC2:
+		return 8
+	case compressedRGBADXT3, compressedRGBADXT5, compressedSRGBAlphaDXT3, compressedSRGBAlphaDXT5,
+		compressedRGRGTC2, compressedSRGRGTC2,
+		compressedRGBABPTC, compressedSRGBABPTC, compressedRGBBPTCSF, compressedRGBBPTCUF,
+		compressedRG11EAC, compressedSRG11EAC,
+		compressedRGBA8ETC2, compressedSRGBA8ETC2:
+		return 16
+	}
+	return 0
+}
+
+// compressedSize is the byte size of a width x height image.
+func compressedSize(format uint32, width, height int) int {
+	bw, bh := (width+3)/4, (height+3)/4
+	if bw < 1 {
+		bw = 1
+	}
+	if bh < 1 {
+		bh = 1
+	}
+	return bw * bh * blockBytes(format)
+}
+
+// srgbFormat returns the sRGB twin of a compressed format, or the format
+// itself when it has none.
+func srgbFormat(format uint32) uint32 {
+	switch format {
+	case compressedRGBDXT1:
+		return compressedSRGBDXT1
+	case compressedRGBADXT1:
+		return compressedSRGBAlphaDXT1
+	case compressedRGBADXT3:
+		return compressedSRGBAlphaDXT3
+	case compressedRGBADXT5:
+		return compressedSRGBAlphaDXT5
+	case compressedRGBABPTC:
+		return compressedSRGBABPTC
+	case compressedRGB8ETC2:
+		return compressedSRGB8ETC2
+	case compressedRGB8A1ETC2:
+		return compressedSRGB8A1ETC2
+	case compressedRGBA8ETC2:
+		return compressedSRGBA8ETC2
+	}
+	return format
+}
+
+func isSRGB(format uint32) bool {
+	switch format {
+	case compressedSRGBDXT1, compressedSRGBAlphaDXT1, compressedSRGBAlphaDXT3, compressedSRGBAlphaDXT5,
+		compressedSRGBABPTC, compressedSRGB8ETC2, compressedSRGB8A1ETC2, compressedSRGBA8ETC2:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,217 @@
+package texture
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"io/ioutil"
+	"math"
+	"strings"
+)
+
+// LoadHDR reads a Radiance .hdr (RGBE) file.
+func LoadHDR(filename string) (*FloatImage, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	img, err := ParseHDR(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return img, nil
+}
+
+// ParseHDR parses a Radiance .hdr file with flat, old style run length or
+// adaptive run length encoded scanlines. Only the usual -Y h +X w layout
+// and its bottom-up twin +Y h +X w are supported. Pixel values are kept
+// as stored, the EXPOSURE header is ignored like most tools do.
+func ParseHDR(data []byte) (*FloatImage, error) {
+	if !bytes.HasPrefix(data, []byte("#?")) {
+		return nil, errors.New("not a Radiance HDR file")
+	}
+
+	// the header is a list of lines ended by an empty one, followed by
+	// the resolution line
+	off := 0
+	line := func() (string, bool) {
+		i := bytes.IndexByte(data[off:], '\n')
+		if i < 0 {
+			return "", false
+		}
+		s := string(data[off : off+i])
+		off += i + 1
+		return s, true
+	}
+	for {
+		s, ok := line()
+		if !ok {
+			return nil, errors.New("HDR header is truncated")
+		}
+		if s == "" {
+			break
+		}
+		if strings.HasPrefix(s, "FORMAT=") && s != "FORMAT=32-bit_rle_rgbe" {
+			return nil, fmt.Errorf("HDR %s is not supported", s)
+		}
+	}
+	res, ok := line()
+	if !ok {
+		return nil, errors.New("HDR resolution is missing")
+	}
+	var ysign, xsign string
+	var width, height int
+	if _, err := fmt.Sscanf(res, "%2s %d %2s %d", &ysign, &height, &xsign, &width); err != nil {
+		return nil, fmt.Errorf("HDR resolution %q: %v", res, err)
+	}
+	if (ysign != "-Y" && ysign != "+Y") || xsign != "+X" {
+		return nil, fmt.Errorf("HDR orientation %q is not supported", res)
+	}
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("bad size %dx%d", width, height)
+	}
+
+	img := &FloatImage{Width: width, Height: height, Pix: make([]float32, width*height*3)}
+	scanline := make([]byte, width*4)
+	for y := 0; y < height; y++ {
+		n, err := readScanline(data[off:], scanline)
+		if err != nil {
+			return nil, fmt.Errorf("HDR scanline %d: %v", y, err)
+		}
+		off += n
+
+		row := y
+		if ysign == "+Y" {
+			row = height - 1 - y
+		}
+		pix := img.Pix[row*width*3:]
+		for x := 0; x < width; x++ {
+			r, g, b := rgbe(scanline[x*4 : x*4+4])
+			pix[x*3], pix[x*3+1], pix[x*3+2] = r, g, b
+		}
+	}
+	return img, nil
+}
+
+// rgbe decodes a pixel with a shared exponent.
+func rgbe(p []byte) (float32, float32, float32) {
+	if p[3] == 0 {
+		return 0, 0, 0
+	}
+	f := float32(math.Ldexp(1, int(p[3])-(128+8)))
+	return float32(p[0]) * f, float32(p[1]) * f, float32(p[2]) * f
+}
+
+// readScanline decodes one scanline into dst, 4 bytes per pixel, and
+// returns the number of bytes used.
+func readScanline(data []byte, dst []byte) (int, error) {
+	width := len(dst) / 4
+	if width < 8 || width > 0x7fff || len(data) < 4 ||
+		data[0] != 2 || data[1] != 2 || data[2]&0x80 != 0 {
+		return readFlatScanline(data, dst)
+	}
+	if int(data[2])<<8|int(data[3]) != width {
+		return 0, errors.New("scanline width mismatch")
+	}
+
+	// adaptive RLE stores each channel on its own, as runs and literals
+	off := 4
+	for c := 0; c < 4; c++ {
+		for x := 0; x < width; {
+			if off >= len(data) {
+				return 0, errors.New("truncated")
+			}
+			n := int(data[off])
+			off++
+			if n > 128 {
+				n -= 128
+				if x+n > width || off >= len(data) {
+					return 0, errors.New("bad run")
+				}
+				for ; n > 0; n-- {
+					dst[x*4+c] = data[off]
+					x++
+				}
+				off++
+			} else {
+				if n == 0 || x+n > width || off+n > len(data) {
+					return 0, errors.New("bad literal")
+				}
+				for ; n > 0; n-- {
+					dst[x*4+c] = data[off]
+					x++
+					off++
+				}
+			}
+		}
+	}
+	return off, nil
+}
+
+// readFlatScanline reads plain RGBE pixels, where 1, 1, 1, n repeats the
+// previous pixel in the old run length encoding.
+func readFlatScanline(data []byte, dst []byte) (int, error) {
+	width := len(dst) / 4
+	off, shift := 0, uint(0)
+	for x := 0; x < width; {
+		if off+4 > len(data) {
+			return 0, errors.New("truncated")
+		}
+		p := data[off : off+4]
+		off += 4
+		if p[0] == 1 && p[1] == 1 && p[2] == 1 {
+			if x == 0 {
+				return 0, errors.New("run without a pixel")
+			}
+			n := int(p[3]) << shift
+			if x+n > width {
+				return 0, errors.New("bad run")
+			}
+			for ; n > 0; n-- {
+				copy(dst[x*4:x*4+4], dst[x*4-4:x*4])
+				x++
+			}
+			shift += 8
+			continue
+		}
+		copy(dst[x*4:x*4+4], p)
+		x++
+		shift = 0
+	}
+	return off, nil
+}
+
+// FromFloatImage uploads a float image into a new half float 2D texture.
+// The SRGB option does not apply, the data is linear.
+func FromFloatImage(img *FloatImage, opts Options) *Texture {
+	if opts.Sampler == (Sampler{}) {
+		opts.Sampler = DefaultSampler()
+	}
+	pix := img.Pix
+	if opts.FlipY {
+		pix = make([]float32, len(img.Pix))
+		stride := img.Width * 3
+		for y := 0; y < img.Height; y++ {
+			copy(pix[y*stride:(y+1)*stride], img.Pix[(img.Height-1-y)*stride:])
+		}
+	}
+
+	t := &Texture{
+		Target:         gl.TEXTURE_2D,
+		Width:          int32(img.Width),
+		Height:         int32(img.Height),
+		Levels:         1,
+		InternalFormat: gl.RGB16F,
+	}
+	gl.GenTextures(1, &t.ID)
+	gl.BindTexture(gl.TEXTURE_2D, t.ID)
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 4)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, t.InternalFormat, t.Width, t.Height, 0,
+		gl.RGB, gl.FLOAT, gl.Ptr(pix))
+	if opts.Mipmaps {
+		t.GenerateMipmaps()
+	}
+	t.SetSampler(opts.Sampler)
+	return t
+}
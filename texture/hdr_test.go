@@ -0,0 +1,60 @@
+package texture
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func hdr(ysign string, width, height int, scanlines ...[]byte) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "#?RADIANCE\nFORMAT=32-bit_rle_rgbe\nEXPOSURE=1.0\n\n%s %d +X %d\n", ysign, height, width)
+	for _, s := range scanlines {
+		b.Write(s)
+	}
+	return b.Bytes()
+}
+
+func TestParseHDR(t *testing.T) {
+	// red as a run of ten 128s, green as literals 0, 10, ..., 90, blue as
+	// a run of zeros and the exponent as a run of 129
+	adaptive := []byte{2, 2, 0, 10, 128 + 10, 128, 10, 0, 10, 20, 30, 40, 50, 60, 70, 80, 90, 128 + 10, 0, 128 + 10, 129}
+	// one pixel then the old run length encoding repeating it nine times
+	flat := []byte{128, 0, 0, 130, 1, 1, 1, 9}
+	// one pixel and 1, 1, 1, 1 twice: one repeat, then 256 more
+	long := []byte{0, 0, 128, 129, 1, 1, 1, 1, 1, 1, 1, 1}
+
+	tests := []struct {
+		name string
+		data []byte
+		ok   bool
+		x, y int
+		want [3]float32
+	}{
+		{"adaptive rle", hdr("-Y", 10, 2, adaptive, flat), true, 3, 0, [3]float32{1, 30.0 / 128, 0}},
+		{"old rle", hdr("-Y", 10, 2, adaptive, flat), true, 9, 1, [3]float32{2, 0, 0}},
+		{"bottom up", hdr("+Y", 10, 2, adaptive, flat), true, 9, 0, [3]float32{2, 0, 0}},
+		{"shifted run", hdr("-Y", 258, 1, long), true, 257, 0, [3]float32{0, 0, 1}},
+		{"zero exponent", hdr("-Y", 1, 1, []byte{200, 200, 200, 0}), true, 0, 0, [3]float32{}},
+		{"truncated", hdr("-Y", 10, 2, adaptive, flat[:6]), false, 0, 0, [3]float32{}},
+		{"run past the end", hdr("-Y", 10, 1, []byte{2, 2, 0, 10, 128 + 11, 1}), false, 0, 0, [3]float32{}},
+		{"width mismatch", hdr("-Y", 10, 1, []byte{2, 2, 0, 9}), false, 0, 0, [3]float32{}},
+		{"run without a pixel", hdr("-Y", 4, 1, []byte{1, 1, 1, 4}), false, 0, 0, [3]float32{}},
+		{"sideways", hdr("+X", 1, 1, []byte{0, 0, 0, 0}), false, 0, 0, [3]float32{}},
+		{"xyz", []byte("#?RADIANCE\nFORMAT=32-bit_rle_xyze\n\n-Y 1 +X 1\n\x00\x00\x00\x00"), false, 0, 0, [3]float32{}},
+		{"no header end", []byte("#?RADIANCE\nFORMAT=32-bit_rle_rgbe\n"), false, 0, 0, [3]float32{}},
+		{"not hdr", []byte("P6\n1 1\n255\n\x00\x00\x00"), false, 0, 0, [3]float32{}},
+	}
+	for _, tt := range tests {
+		img, err := ParseHDR(tt.data)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: error %v", tt.name, err)
+			continue
+		}
+		if tt.ok {
+			if c := img.At(tt.x, tt.y); c != tt.want {
+				t.Errorf("%s: pixel %d, %d is %v, want %v", tt.name, tt.x, tt.y, c, tt.want)
+			}
+		}
+	}
+}
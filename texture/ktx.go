@@ -0,0 +1,288 @@
+package texture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+var (
+	ktxIdentifier  = []byte("\xABKTX 11\xBB\r\n\x1A\n")
+	ktx2Identifier = []byte("\xABKTX 20\xBB\r\n\x1A\n")
+)
+
+// LoadKTX reads a KTX 1 file.
+func LoadKTX(filename string) (*Data, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return ParseKTX(data)
+}
+
+// ParseKTX parses a KTX 1 file holding a 2D texture or a cube map. A file
+// without mip levels gets a single level, Upload can generate the rest.
+// The rows of uncompressed levels lose their 4 byte padding.
+func ParseKTX(data []byte) (*Data, error) {
+	if len(data) < 64 || !bytes.Equal(data[:12], ktxIdentifier) {
+		return nil, errors.New("not a KTX file")
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if order.Uint32(data[12:]) != 0x04030201 {
+		order = binary.BigEndian
+	}
+	field := func(i int) int { return int(order.Uint32(data[12+i*4:])) }
+	glType, glTypeSize, glFormat, glInternalFormat := field(1), field(2), field(3), field(4)
+	width, height, depth := field(6), field(7), field(8)
+	arrayElements, faces, levels, keyValueBytes := field(9), field(10), field(11), field(12)
+
+	if depth > 0 || arrayElements > 0 {
+		return nil, errors.New("KTX 3D and array textures are not supported")
+	}
+	if faces != 1 && faces != 6 {
+		return nil, fmt.Errorf("KTX with %d faces", faces)
+	}
+	if height == 0 {
+		height = 1
+	}
+	if levels == 0 {
+		levels = 1
+	}
+
+	d := &Data{
+		Width:          width,
+		Height:         height,
+		Compressed:     glType == 0,
+		InternalFormat: uint32(glInternalFormat),
+		Format:         uint32(glFormat),
+		Type:           uint32(glType),
+		Cube:           faces == 6,
+	}
+
+	off := 64 + keyValueBytes
+	for l := 0; l < levels; l++ {
+		if off+4 > len(data) {
+			return nil, errors.New("KTX is truncated")
+		}
+		size := int(order.Uint32(data[off:]))
+		off += 4
+
+		w, h := mipSize(width, height, l)
+		level := Level{Width: w, Height: h}
+		for f := 0; f < faces; f++ {
+			if size < 0 || off+size > len(data) {
+				return nil, errors.New("KTX is truncated")
+			}
+			face := data[off : off+size]
+			if order == binary.BigEndian && glTypeSize > 1 {
+				face = swapBytes(face, glTypeSize)
+			}
+			if !d.Compressed {
+				face = unpadRows(face, w*pixelBytes(d.Format, d.Type), h)
+			}
+			level.Faces = append(level.Faces, face)
+			off += (size + 3) &^ 3
+		}
+		d.Levels = append(d.Levels, level)
+	}
+	if err := d.checkLevels(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// unpadRows drops the padding KTX puts after every row to align it to 4
+// bytes, as Data rows are tightly packed. Data that is not padded is
+// returned as it is.
+func unpadRows(data []byte, rowBytes, rows int) []byte {
+	padded := (rowBytes + 3) &^ 3
+	if padded == rowBytes || len(data) < padded*rows {
+		return data
+	}
+	out := make([]byte, 0, rowBytes*rows)
+	for y := 0; y < rows; y++ {
+		out = append(out, data[y*padded:y*padded+rowBytes]...)
+	}
+	return out
+}
+
+// swapBytes returns a copy of data with every size byte word reversed.
+func swapBytes(data []byte, size int) []byte {
+	out := make([]byte, len(data))
+	for i := 0; i+size <= len(data); i += size {
+		for k := 0; k < size; k++ {
+			out[i+k] = data[i+size-1-k]
+		}
+	}
+	return out
+}
+
+// WriteKTX writes uncompressed data as a little endian KTX 1 file, with
+// all its levels and faces and rows padded to 4 bytes.
+func WriteKTX(out io.Writer, d *Data) error {
+	if d.Compressed {
+		return errors.New("writing compressed KTX is not supported")
+	}
+	if err := d.checkLevels(); err != nil {
+		return err
+	}
+	typeSize := pixelBytes(gl.RED, d.Type)
+	header := []uint32{
+		0x04030201, d.Type, uint32(typeSize), d.Format, d.InternalFormat, d.Format,
+		uint32(d.Width), uint32(d.Height), 0, 0, uint32(d.faces()), uint32(len(d.Levels)), 0,
+	}
+	buf := bytes.NewBuffer(append([]byte(nil), ktxIdentifier...))
+	binary.Write(buf, binary.LittleEndian, header)
+
+	var pad [3]byte
+	for _, l := range d.Levels {
+		// rows are padded to 4 bytes, the image size is of one face even
+		// for cube maps
+		row := l.Width * pixelBytes(d.Format, d.Type)
+		padding := pad[:(4-row%4)%4]
+		binary.Write(buf, binary.LittleEndian, uint32((row+len(padding))*l.Height))
+		for _, f := range l.Faces {
+			for y := 0; y < l.Height; y++ {
+				buf.Write(f[y*row : (y+1)*row])
+				buf.Write(padding)
+			}
+		}
+	}
+	_, err := buf.WriteTo(out)
+	return err
+}
+
+// SaveKTX writes uncompressed data to a KTX 1 file.
+func SaveKTX(filename string, d *Data) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := WriteKTX(f, d); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// LoadKTX2 reads a KTX 2 file.
+func LoadKTX2(filename string) (*Data, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return ParseKTX2(data)
+}
+
+// vkFormats maps the Vulkan formats KTX 2 files use to GL: compressed
+// format, or internal format, format and type.
+var vkFormats = map[uint32][3]uint32{
+	131: {compressedRGBDXT1},
+	132: {compressedSRGBDXT1},
+	133: {compressedRGBADXT1},
+	134: {compressedSRGBAlphaDXT1},
+	135: {compressedRGBADXT3},
+	136: {compressedSRGBAlphaDXT3},
+	137: {compressedRGBADXT5},
+	138: {compressedSRGBAlphaDXT5},
+	139: {compressedRedRGTC1},
+	140: {compressedSRedRGTC1},
+	141: {compressedRGRGTC2},
+	142: {compressedSRGRGTC2},
+	143: {compressedRGBBPTCUF},
+	144: {compressedRGBBPTCSF},
+	145: {compressedRGBABPTC},
+	146: {compressedSRGBABPTC},
+	147: {compressedRGB8ETC2},
+	148: {compressedSRGB8ETC2},
+	149: {compressedRGB8A1ETC2},
+	150: {compressedSRGB8A1ETC2},
+	151: {compressedRGBA8ETC2},
+	152: {compressedSRGBA8ETC2},
+	153: {compressedR11EAC},
+	154: {compressedSR11EAC},
+	155: {compressedRG11EAC},
+	156: {compressedSRG11EAC},
+
+	9:   {gl.R8, gl.RED, gl.UNSIGNED_BYTE},
+	16:  {gl.RG8, gl.RG, gl.UNSIGNED_BYTE},
+	37:  {gl.RGBA8, gl.RGBA, gl.UNSIGNED_BYTE},
+	43:  {gl.SRGB8_ALPHA8, gl.RGBA, gl.UNSIGNED_BYTE},
+	44:  {gl.RGBA8, gl.BGRA, gl.UNSIGNED_BYTE},
+	50:  {gl.SRGB8_ALPHA8, gl.BGRA, gl.UNSIGNED_BYTE},
+	97:  {gl.RGBA16F, gl.RGBA, gl.HALF_FLOAT},
+	109: {gl.RGBA32F, gl.RGBA, gl.FLOAT},
+}
+
+// ParseKTX2 parses a KTX 2 file holding a 2D texture or a cube map.
+// Supercompressed files (Basis, zstd) are not supported yet.
+func ParseKTX2(data []byte) (*Data, error) {
+	if len(data) < 80 || !bytes.Equal(data[:12], ktx2Identifier) {
+		return nil, errors.New("not a KTX 2 file")
+	}
+	le := binary.LittleEndian
+	field := func(i int) int { return int(le.Uint32(data[12+i*4:])) }
+	vkFormat := uint32(field(0))
+	width, height, depth := field(2), field(3), field(4)
+	layers, faces, levels, scheme := field(5), field(6), field(7), field(8)
+
+	if scheme != 0 {
+		return nil, fmt.Errorf("KTX 2 supercompression scheme %d is not supported", scheme)
+	}
+	if depth > 0 || layers > 0 {
+		return nil, errors.New("KTX 2 3D and array textures are not supported")
+	}
+	if faces != 1 && faces != 6 {
+		return nil, fmt.Errorf("KTX 2 with %d faces", faces)
+	}
+	format, ok := vkFormats[vkFormat]
+	if !ok {
+		return nil, fmt.Errorf("KTX 2 format %d is not supported", vkFormat)
+	}
+	if height == 0 {
+		height = 1
+	}
+	if levels == 0 {
+		levels = 1
+	}
+
+	d := &Data{
+		Width:          width,
+		Height:         height,
+		Compressed:     format[1] == 0,
+		InternalFormat: format[0],
+		Format:         format[1],
+		Type:           format[2],
+		Cube:           faces == 6,
+	}
+
+	// the level index follows the 80 byte header
+	if 80+levels*24 > len(data) {
+		return nil, errors.New("KTX 2 is truncated")
+	}
+	for l := 0; l < levels; l++ {
+		entry := data[80+l*24:]
+		off, size := le.Uint64(entry), le.Uint64(entry[8:])
+		if off > uint64(len(data)) || size > uint64(len(data))-off || size%uint64(faces) != 0 {
+			return nil, errors.New("KTX 2 is truncated")
+		}
+
+		w, h := mipSize(width, height, l)
+		level := Level{Width: w, Height: h}
+		faceSize := int(size) / faces
+		for f := 0; f < faces; f++ {
+			start := int(off) + f*faceSize
+			level.Faces = append(level.Faces, data[start:start+faceSize])
+		}
+		d.Levels = append(d.Levels, level)
+	}
+	if err := d.checkLevels(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
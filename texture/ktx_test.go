@@ -0,0 +1,157 @@
+package texture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"testing"
+)
+
+// ktx1 builds a little endian KTX 1 file from its header fields and the
+// image size prefixed levels.
+func ktx1(fields [13]uint32, levels ...[]byte) []byte {
+	var b bytes.Buffer
+	b.Write(ktxIdentifier)
+	binary.Write(&b, binary.LittleEndian, fields)
+	for _, l := range levels {
+		binary.Write(&b, binary.LittleEndian, uint32(len(l)))
+		b.Write(l)
+	}
+	return b.Bytes()
+}
+
+// ktx2 builds a KTX 2 file with a level index of offset, size pairs.
+func ktx2(vkFormat, width, height, faces uint32, index [][2]uint64, data []byte) []byte {
+	b := make([]byte, 80+24*len(index))
+	copy(b, ktx2Identifier)
+	le := binary.LittleEndian
+	le.PutUint32(b[12:], vkFormat)
+	le.PutUint32(b[20:], width)
+	le.PutUint32(b[24:], height)
+	le.PutUint32(b[36:], faces)
+	le.PutUint32(b[40:], uint32(len(index)))
+	for l, e := range index {
+		le.PutUint64(b[80+l*24:], e[0])
+		le.PutUint64(b[88+l*24:], e[1])
+	}
+	return append(b, data...)
+}
+
+func TestParseKTX(t *testing.T) {
+	rgb := [13]uint32{0x04030201, gl.UNSIGNED_BYTE, 1, gl.RGB, gl.RGB8, gl.RGB, 3, 2, 0, 0, 1, 1, 0}
+	rgba := [13]uint32{0x04030201, gl.UNSIGNED_BYTE, 1, gl.RGBA, gl.RGBA8, gl.RGBA, 4, 4, 0, 0, 1, 1, 0}
+	dxt1 := [13]uint32{0x04030201, 0, 1, 0, compressedRGBADXT1, gl.RGBA, 8, 8, 0, 0, 1, 2, 0}
+	cube := rgba
+	cube[10] = 6
+	// one image size for all six faces
+	cubeFile := append(ktx1(cube), 64, 0, 0, 0)
+	cubeFile = append(cubeFile, make([]byte, 6*64)...)
+
+	tests := []struct {
+		name   string
+		data   []byte
+		ok     bool
+		levels int
+		first  []byte // first face of the first level, if checked
+	}{
+		{"padded rows", ktx1(rgb, []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 0, 0, 0, 11, 12, 13, 14, 15, 16, 17, 18, 19, 0, 0, 0}),
+			true, 1, []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 11, 12, 13, 14, 15, 16, 17, 18, 19}},
+		{"rgba", ktx1(rgba, make([]byte, 64)), true, 1, make([]byte, 64)},
+		{"compressed levels", ktx1(dxt1, make([]byte, 32), make([]byte, 8)), true, 2, nil},
+		{"cube", cubeFile, true, 1, nil},
+		{"truncated level", ktx1(rgba, make([]byte, 64))[:64+4+32], false, 0, nil},
+		{"missing level", ktx1(dxt1, make([]byte, 32)), false, 0, nil},
+		{"not ktx", append([]byte("not a ktx file"), make([]byte, 64)...), false, 0, nil},
+		{"array", ktx1([13]uint32{0x04030201, gl.UNSIGNED_BYTE, 1, gl.RGBA, gl.RGBA8, gl.RGBA, 4, 4, 0, 2, 1, 1, 0}), false, 0, nil},
+	}
+	for _, tt := range tests {
+		d, err := ParseKTX(tt.data)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: error %v", tt.name, err)
+			continue
+		}
+		if !tt.ok {
+			continue
+		}
+		if len(d.Levels) != tt.levels {
+			t.Errorf("%s: %d levels, want %d", tt.name, len(d.Levels), tt.levels)
+		}
+		if tt.first != nil && !bytes.Equal(d.Levels[0].Faces[0], tt.first) {
+			t.Errorf("%s: first level %v, want %v", tt.name, d.Levels[0].Faces[0], tt.first)
+		}
+	}
+}
+
+func TestParseKTX2(t *testing.T) {
+	const bc7, rgba8 = 145, 37
+	huge := ^uint64(0) - 7
+	tests := []struct {
+		name   string
+		data   []byte
+		ok     bool
+		faces  int
+		levels int
+	}{
+		{"rgba", ktx2(rgba8, 2, 2, 1, [][2]uint64{{104, 16}}, make([]byte, 16)), true, 1, 1},
+		{"levels", ktx2(rgba8, 2, 2, 1, [][2]uint64{{128, 16}, {144, 4}}, make([]byte, 20)), true, 1, 2},
+		{"compressed cube", ktx2(bc7, 4, 4, 6, [][2]uint64{{104, 96}}, make([]byte, 96)), true, 6, 1},
+		{"size past the end", ktx2(rgba8, 2, 2, 1, [][2]uint64{{104, 32}}, make([]byte, 16)), false, 0, 0},
+		{"offset past the end", ktx2(rgba8, 2, 2, 1, [][2]uint64{{1 << 20, 16}}, make([]byte, 16)), false, 0, 0},
+		{"wrapping offset", ktx2(rgba8, 2, 2, 1, [][2]uint64{{huge, 16}}, make([]byte, 16)), false, 0, 0},
+		{"wrapping size", ktx2(rgba8, 2, 2, 1, [][2]uint64{{104, huge}}, make([]byte, 16)), false, 0, 0},
+		{"faces do not split the level", ktx2(bc7, 4, 4, 6, [][2]uint64{{104, 95}}, make([]byte, 96)), false, 0, 0},
+		{"unknown format", ktx2(1000, 2, 2, 1, [][2]uint64{{104, 16}}, make([]byte, 16)), false, 0, 0},
+		{"missing index", ktx2(rgba8, 2, 2, 1, [][2]uint64{{104, 16}}, nil)[:90], false, 0, 0},
+	}
+	for _, tt := range tests {
+		d, err := ParseKTX2(tt.data)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: error %v", tt.name, err)
+			continue
+		}
+		if tt.ok && (len(d.Levels) != tt.levels || len(d.Levels[0].Faces) != tt.faces || d.Cube != (tt.faces == 6)) {
+			t.Errorf("%s: %d levels of %d faces, want %d of %d", tt.name, len(d.Levels), len(d.Levels[0].Faces), tt.levels, tt.faces)
+		}
+	}
+}
+
+func TestWriteKTX(t *testing.T) {
+	rgb := &Data{Width: 3, Height: 2, InternalFormat: gl.RGB8, Format: gl.RGB, Type: gl.UNSIGNED_BYTE,
+		Levels: []Level{{Width: 3, Height: 2, Faces: [][]byte{{1, 2, 3, 4, 5, 6, 7, 8, 9, 11, 12, 13, 14, 15, 16, 17, 18, 19}}}}}
+	cube := &Data{Width: 3, Height: 2, InternalFormat: gl.RGB16F, Format: gl.RGB, Type: gl.HALF_FLOAT, Cube: true}
+	for l := 0; l < 2; l++ {
+		w, h := mipSize(3, 2, l)
+		level := Level{Width: w, Height: h}
+		for f := 0; f < 6; f++ {
+			b := make([]byte, w*h*6)
+			for i := range b {
+				b[i] = byte(i + f + l)
+			}
+			level.Faces = append(level.Faces, b)
+		}
+		cube.Levels = append(cube.Levels, level)
+	}
+
+	for _, d := range []*Data{rgb, cube} {
+		var b bytes.Buffer
+		if err := WriteKTX(&b, d); err != nil {
+			t.Fatal(err)
+		}
+		p, err := ParseKTX(b.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Width != d.Width || p.Height != d.Height || p.Cube != d.Cube || len(p.Levels) != len(d.Levels) ||
+			p.InternalFormat != d.InternalFormat || p.Format != d.Format || p.Type != d.Type {
+			t.Errorf("wrote %+v, read %+v", d, p)
+			continue
+		}
+		for l := range d.Levels {
+			for f := range d.Levels[l].Faces {
+				if !bytes.Equal(p.Levels[l].Faces[f], d.Levels[l].Faces[f]) {
+					t.Errorf("%dx%d: face %d of level %d is %v, want %v", d.Width, d.Height, f, l, p.Levels[l].Faces[f], d.Levels[l].Faces[f])
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package texture
+
+import (
+	"fmt"
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Options controls how an image becomes a texture.
+type Options struct {
+	// SRGB marks colour data, which the sampler then converts to linear.
+	// Leave it off for normal maps, roughness and other plain data. Linear
+	// formats in KTX and DDS files are switched to their sRGB twins.
+	SRGB bool
+	// FlipY puts the first image row at t = 0. Images are stored top row
+	// first while GL texcoords start at the bottom.
+	FlipY   bool
+	Mipmaps bool
+	Sampler Sampler
+}
+
+// DefaultOptions suits colour textures: sRGB, flipped and mipmapped.
+func DefaultOptions() Options {
+	return Options{SRGB: true, FlipY: true, Mipmaps: true, Sampler: DefaultSampler()}
+}
+
+// Load decodes a PNG, JPEG or GIF file into a 2D texture, a Radiance .hdr
+// file into a half float one, or uploads a KTX, KTX 2 or DDS file as it
+// is.
+func Load(filename string, opts Options) (*Texture, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".ktx", ".ktx2", ".dds":
+		d, err := LoadData(filename)
+		if err != nil {
+			return nil, err
+		}
+		if opts.SRGB {
+			d.toSRGB()
+		}
+		t, err := d.Upload(opts)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+		return t, nil
+	case ".hdr":
+		img, err := LoadHDR(filename)
+		if err != nil {
+			return nil, err
+		}
+		return FromFloatImage(img, opts), nil
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	return FromImage(img, opts), nil
+}
+
+// NRGBA converts an image to tightly packed, non-premultiplied 8 bit RGBA
+// as GL expects it, optionally flipping the rows.
+func NRGBA(img image.Image, flipY bool) *image.NRGBA {
+	b := img.Bounds()
+	dst, ok := img.(*image.NRGBA)
+	if !ok || dst.Stride != b.Dx()*4 || flipY {
+		dst = image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+	}
+	if flipY {
+		row := make([]byte, dst.Stride)
+		for y, h := 0, dst.Rect.Dy(); y < h/2; y++ {
+			top := dst.Pix[y*dst.Stride : (y+1)*dst.Stride]
+			bottom := dst.Pix[(h-1-y)*dst.Stride : (h-y)*dst.Stride]
+			copy(row, top)
+			copy(top, bottom)
+			copy(bottom, row)
+		}
+	}
+	return dst
+}
+
+// FromImage uploads an image into a new 2D texture. A zero Sampler in opts
+// means DefaultSampler.
+func FromImage(img image.Image, opts Options) *Texture {
+	if opts.Sampler == (Sampler{}) {
+		opts.Sampler = DefaultSampler()
+	}
+	rgba := NRGBA(img, opts.FlipY)
+
+	t := &Texture{
+		Target:         gl.TEXTURE_2D,
+		Width:          int32(rgba.Rect.Dx()),
+		Height:         int32(rgba.Rect.Dy()),
+		Levels:         1,
+		InternalFormat: gl.RGBA8,
+	}
+	if opts.SRGB {
+		t.InternalFormat = gl.SRGB8_ALPHA8
+	}
+
+	gl.GenTextures(1, &t.ID)
+	gl.BindTexture(gl.TEXTURE_2D, t.ID)
+	gl.PixelStorei(gl.UNPACK_ALIGNMENT, 4)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, t.InternalFormat, t.Width, t.Height, 0,
+		gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(rgba.Pix))
+	if opts.Mipmaps {
+		t.GenerateMipmaps()
+	}
+	t.SetSampler(opts.Sampler)
+	return t
+}
@@ -0,0 +1,101 @@
+package texture
+
+import (
+	"github.com/ginuerzh/anton-gocode/common"
+	"github.com/go-gl/gl/v3.3-core/gl"
+)
+
+/* GL_EXT_texture_filter_anisotropic, not part of the 3.3 core profile */
+const (
+	textureMaxAnisotropy    = 0x84FE
+	maxTextureMaxAnisotropy = 0x84FF
+)
+
+// Sampler holds the filtering and wrapping state of a texture. Fields left
+// zero are not set.
+type Sampler struct {
+	MinFilter  int32 // gl.LINEAR_MIPMAP_LINEAR, gl.NEAREST, ...
+	MagFilter  int32 // gl.LINEAR or gl.NEAREST
+	WrapS      int32 // gl.REPEAT, gl.CLAMP_TO_EDGE, gl.MIRRORED_REPEAT, ...
+	WrapT      int32
+	WrapR      int32
+	Anisotropy float32 // 1 or less disables it, clamped to what the driver allows
+}
+
+// DefaultSampler is trilinear filtering with repeating texcoords.
+func DefaultSampler() Sampler {
+	return Sampler{
+		MinFilter:  gl.LINEAR_MIPMAP_LINEAR,
+		MagFilter:  gl.LINEAR,
+		WrapS:      gl.REPEAT,
+		WrapT:      gl.REPEAT,
+		WrapR:      gl.REPEAT,
+		Anisotropy: 1,
+	}
+}
+
+// MaxAnisotropy returns the largest anisotropy the driver supports, 0
+// without GL_EXT_texture_filter_anisotropic.
+func MaxAnisotropy() float32 {
+	if !common.HasExtension("GL_EXT_texture_filter_anisotropic") &&
+		!common.HasExtension("GL_ARB_texture_filter_anisotropic") {
+		return 0
+	}
+	var max float32
+	gl.GetFloatv(maxTextureMaxAnisotropy, &max)
+	return max
+}
+
+// withoutMipmaps replaces a mipmapped minification filter by its base
+// filter, a texture with a single level is incomplete otherwise.
+func (s Sampler) withoutMipmaps() Sampler {
+	switch s.MinFilter {
+	case gl.NEAREST_MIPMAP_NEAREST, gl.NEAREST_MIPMAP_LINEAR:
+		s.MinFilter = gl.NEAREST
+	case gl.LINEAR_MIPMAP_NEAREST, gl.LINEAR_MIPMAP_LINEAR:
+		s.MinFilter = gl.LINEAR
+	}
+	return s
+}
+
+// apply sets the state through parami and paramf. Zero fields are not
+// valid GL values and are skipped, keeping what the texture or sampler
+// object had.
+func (s Sampler) apply(parami func(pname uint32, v int32), paramf func(pname uint32, v float32)) {
+	for _, p := range []struct {
+		pname uint32
+		v     int32
+	}{
+		{gl.TEXTURE_MIN_FILTER, s.MinFilter},
+		{gl.TEXTURE_MAG_FILTER, s.MagFilter},
+		{gl.TEXTURE_WRAP_S, s.WrapS},
+		{gl.TEXTURE_WRAP_T, s.WrapT},
+		{gl.TEXTURE_WRAP_R, s.WrapR},
+	} {
+		if p.v != 0 {
+			parami(p.pname, p.v)
+		}
+	}
+
+	if s.Anisotropy > 1 {
+		if max := MaxAnisotropy(); max > 0 {
+			if s.Anisotropy > max {
+				s.Anisotropy = max
+			}
+			paramf(textureMaxAnisotropy, s.Anisotropy)
+		}
+	}
+}
+
+// NewSampler creates a GL sampler object, which overrides the sampling
+// state of whatever texture is bound to the same unit.
+func NewSampler(s Sampler) uint32 {
+	var id uint32
+	gl.GenSamplers(1, &id)
+	s.apply(func(pname uint32, v int32) {
+		gl.SamplerParameteri(id, pname, v)
+	}, func(pname uint32, v float32) {
+		gl.SamplerParameterf(id, pname, v)
+	})
+	return id
+}
@@ -0,0 +1,55 @@
+package texture
+
+import (
+	"github.com/go-gl/gl/v3.3-core/gl"
+	"testing"
+)
+
+func TestSamplerApply(t *testing.T) {
+	tests := []struct {
+		name    string
+		sampler Sampler
+		want    map[uint32]int32
+	}{
+		{"default", DefaultSampler(), map[uint32]int32{
+			gl.TEXTURE_MIN_FILTER: gl.LINEAR_MIPMAP_LINEAR, gl.TEXTURE_MAG_FILTER: gl.LINEAR,
+			gl.TEXTURE_WRAP_S: gl.REPEAT, gl.TEXTURE_WRAP_T: gl.REPEAT, gl.TEXTURE_WRAP_R: gl.REPEAT,
+		}},
+		{"partial", Sampler{MinFilter: gl.NEAREST, MagFilter: gl.NEAREST, WrapS: gl.CLAMP_TO_EDGE}, map[uint32]int32{
+			gl.TEXTURE_MIN_FILTER: gl.NEAREST, gl.TEXTURE_MAG_FILTER: gl.NEAREST, gl.TEXTURE_WRAP_S: gl.CLAMP_TO_EDGE,
+		}},
+		{"zero", Sampler{}, map[uint32]int32{}},
+	}
+	for _, tt := range tests {
+		got := make(map[uint32]int32)
+		tt.sampler.apply(func(pname uint32, v int32) {
+			if v == 0 {
+				t.Errorf("%s: parameter 0x%x set to 0", tt.name, pname)
+			}
+			got[pname] = v
+		}, func(uint32, float32) {})
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: set %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for pname, v := range tt.want {
+			if got[pname] != v {
+				t.Errorf("%s: 0x%x is 0x%x, want 0x%x", tt.name, pname, got[pname], v)
+			}
+		}
+	}
+}
+
+func TestSamplerWithoutMipmaps(t *testing.T) {
+	for in, want := range map[int32]int32{
+		gl.LINEAR_MIPMAP_LINEAR:   gl.LINEAR,
+		gl.LINEAR_MIPMAP_NEAREST:  gl.LINEAR,
+		gl.NEAREST_MIPMAP_LINEAR:  gl.NEAREST,
+		gl.NEAREST_MIPMAP_NEAREST: gl.NEAREST,
+		gl.LINEAR:                 gl.LINEAR,
+	} {
+		if got := (Sampler{MinFilter: in}).withoutMipmaps().MinFilter; got != want {
+			t.Errorf("0x%x: got 0x%x, want 0x%x", in, got, want)
+		}
+	}
+}
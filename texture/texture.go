@@ -0,0 +1,62 @@
+// Package texture loads images into GL textures and configures how they
+// are sampled.
+package texture
+
+import (
+	"github.com/go-gl/gl/v3.3-core/gl"
+)
+
+// Texture is a GL texture object.
+type Texture struct {
+	ID             uint32
+	Target         uint32 // gl.TEXTURE_2D, gl.TEXTURE_CUBE_MAP, ...
+	Width, Height  int32
+	Levels         int32
+	InternalFormat int32
+}
+
+// levels is the length of a full mipmap chain.
+func levels(width, height int32) int32 {
+	n := int32(1)
+	for width > 1 || height > 1 {
+		width, height = width/2, height/2
+		n++
+	}
+	return n
+}
+
+// Bind binds the texture to a texture unit, 0 for gl.TEXTURE0.
+func (t *Texture) Bind(unit uint32) {
+	gl.ActiveTexture(gl.TEXTURE0 + unit)
+	gl.BindTexture(t.Target, t.ID)
+}
+
+// GenerateMipmaps rebuilds the mipmap chain from level 0.
+func (t *Texture) GenerateMipmaps() {
+	gl.BindTexture(t.Target, t.ID)
+	gl.GenerateMipmap(t.Target)
+	t.Levels = levels(t.Width, t.Height)
+}
+
+// SetSampler changes the sampling state of the texture. Mipmapped
+// filters fall back to their base filter while the texture has a single
+// level.
+func (t *Texture) SetSampler(s Sampler) {
+	if t.Levels <= 1 {
+		s = s.withoutMipmaps()
+	}
+	gl.BindTexture(t.Target, t.ID)
+	s.apply(func(pname uint32, v int32) {
+		gl.TexParameteri(t.Target, pname, v)
+	}, func(pname uint32, v float32) {
+		gl.TexParameterf(t.Target, pname, v)
+	})
+}
+
+// Delete frees the texture object.
+func (t *Texture) Delete() {
+	if t.ID != 0 {
+		gl.DeleteTextures(1, &t.ID)
+		t.ID = 0
+	}
+}